@@ -51,7 +51,35 @@ const docTemplate = `{
                             "properties": {
                                 "status": {"type": "string", "example": "ok"},
                                 "service": {"type": "string", "example": "WikiRacer API"},
-                                "version": {"type": "string", "example": "1.0.0"}
+                                "version": {"type": "string", "example": "1.0.0"},
+                                "rate_limits": {
+                                    "type": "object",
+                                    "description": "Последние квоты X-RateLimit по хостам API",
+                                    "additionalProperties": {"$ref": "#/definitions/RateQuota"}
+                                }
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/cache": {
+            "get": {
+                "description": "Размер, лимит, попадания, вытеснения и устаревшие записи кеша ссылок по каждому языку",
+                "produces": ["application/json"],
+                "tags": ["admin"],
+                "summary": "Статистика кеша ссылок",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "properties": {
+                                "enabled": {"type": "boolean", "example": true},
+                                "languages": {
+                                    "type": "object",
+                                    "additionalProperties": {"$ref": "#/definitions/LinkCacheStats"}
+                                }
                             }
                         }
                     }
@@ -61,7 +89,7 @@ const docTemplate = `{
         "/search": {
             "get": {
                 "description": "Ищет кратчайший путь между двумя статьями Wikipedia используя bidirectional search",
-                "produces": ["application/json"],
+                "produces": ["application/json", "text/plain", "application/xml", "text/markdown"],
                 "tags": ["search"],
                 "summary": "Найти путь между статьями Wikipedia (GET)",
                 "parameters": [
@@ -88,6 +116,197 @@ const docTemplate = `{
                         "in": "query",
                         "default": "ru",
                         "example": "ru"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Формат ответа: json, text (нумерованный рецепт text/plain), xml (граф пути, при explored=true - и дерева поиска, при capture=true - и снимка), dot (тот же граф для Graphviz) или markdown (путь строкой ссылок text/markdown)",
+                        "name": "format",
+                        "in": "query",
+                        "enum": ["json", "text", "xml", "dot", "markdown"],
+                        "default": "json"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Добавить Wikidata Q-ID к каждому шагу пути (дополнительный запрос pageprops на язык)",
+                        "name": "wikidata",
+                        "in": "query",
+                        "default": false
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Вернуть снимок графа ссылок (title → соседи), увиденного поиском, для офлайн-воспроизведения",
+                        "name": "capture",
+                        "in": "query",
+                        "default": false
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Добавить объяснение, что связывает статьи: узел встречи, его вводное предложение и тема (дополнительный запрос)",
+                        "name": "summary",
+                        "in": "query",
+                        "default": false
+                    },
+                    {
+                        "type": "string",
+                        "description": "Статьи, через которые путь проходить не может, через | (только для этого поиска): Название - в разделе lang, de:Название - в другом разделе. Если путь есть только через них - 404 с кодом FORBIDDEN_PATH_NOT_FOUND",
+                        "name": "forbidden",
+                        "in": "query",
+                        "example": "Млекопитающие|Животные"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Экспериментально: разрешить шаги через общую категорию (transition type category - такие шаги не проходятся одним кликом)",
+                        "name": "categories",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Проверить, что ссылки пути стоят в тексте статей, а не только в навбоксах",
+                        "name": "prose",
+                        "in": "query",
+                        "default": false
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Сколько путей собрать, до 5: встречи фронтов в последнем раунде дают разные пути",
+                        "name": "paths",
+                        "in": "query",
+                        "default": 1
+                    },
+                    {
+                        "type": "string",
+                        "description": "Порядок путей в paths: shortest - короче, interwiki - меньше interwiki-переходов, prominence - больше средний размер статей",
+                        "name": "rank_by",
+                        "in": "query",
+                        "enum": ["shortest", "interwiki", "prominence"],
+                        "default": "shortest"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Вернуть в debug.requests статус, размер и время каждого запроса к API",
+                        "name": "debug",
+                        "in": "query",
+                        "default": false
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Заменить названия статей пути на цели редиректов, чтобы каждая ссылка вела на статью",
+                        "name": "canonical",
+                        "in": "query",
+                        "default": false
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Сколько разных языковых разделов может пройти путь, 0 - без ограничения",
+                        "name": "max_languages",
+                        "in": "query",
+                        "default": 0
+                    },
+                    {
+                        "type": "string",
+                        "description": "Явный язык начальной статьи вместо определения по названию",
+                        "name": "from_lang",
+                        "in": "query",
+                        "example": "de"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Явный язык конечной статьи вместо определения по названию",
+                        "name": "to_lang",
+                        "in": "query",
+                        "example": "en"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Вернуть журнал раскрытий и встречи фронтов для покадровой анимации",
+                        "name": "animate",
+                        "in": "query",
+                        "default": false
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Проверить ребро встречи фронтов запросом к API: ссылка, обратная ссылка или interwiki",
+                        "name": "verify_meet",
+                        "in": "query",
+                        "default": false
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Бюджет поиска в миллисекундах, до 60000; без параметра - WIKI_SEARCH_TIMEOUT_MS или 10 с",
+                        "name": "timeout_ms",
+                        "in": "query",
+                        "example": 20000
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Найти предложение и раздел, где стоит каждая ссылка пути (по запросу action=parse на статью)",
+                        "name": "with_context",
+                        "in": "query",
+                        "default": false
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Предел длины пути в переходах; 0 - WIKI_MAX_DEPTH",
+                        "name": "max_depth",
+                        "in": "query",
+                        "example": 6
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Проверить каждую ссылку пути запросом prop=links: в transitions появляется verified, direction обратных ссылок исправляется",
+                        "name": "verify",
+                        "in": "query",
+                        "default": false
+                    },
+                    {
+                        "type": "string",
+                        "description": "Пространства имён, через которые может идти путь, через |: 0 - статьи, 14 - категории, 100 - порталы (номера зависят от раздела). Такие поиски идут мимо кеша ссылок",
+                        "name": "namespaces",
+                        "in": "query",
+                        "example": "0|14"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Эвристика поиска: default или monolingual - сильный штраф статьям не на языке from, путь по возможности без смены языка",
+                        "name": "mode",
+                        "in": "query",
+                        "enum": ["default", "monolingual"],
+                        "default": "default"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Вернуть дерево поиска обоих фронтов: ребро от родителя к каждой посещённой статье (до WIKI_EXPLORED_NODES)",
+                        "name": "explored",
+                        "in": "query",
+                        "default": false
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Путь только внутри одного раздела: false - interwiki не раскрываются, оба конца ищутся в from_lang/to_lang или lang",
+                        "name": "cross_lang",
+                        "in": "query",
+                        "default": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Искать путь с наименьшим числом переходов: поиск в ширину от обоих концов, эвристика решает только порядок внутри слоя. Медленнее и дороже по запросам, чем жадный поиск",
+                        "name": "shortest",
+                        "in": "query",
+                        "default": false
+                    },
+                    {
+                        "type": "string",
+                        "description": "Регулярки названий через запятую (как в WIKI_BLOCKLIST_FILE, только для этого поиска): совпавшие статьи не попадают в очередь, даже если на них есть ссылка. Регулярку с запятой можно передать только в POST. Ошибка в регулярке или больше 50 штук - 400 INVALID_EXCLUDE",
+                        "name": "exclude",
+                        "in": "query",
+                        "example": "^[0-9]+$,^Список"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Не проводить путь через страницы значений (pageprops disambiguation или пометка в названии)",
+                        "name": "exclude_disambig",
+                        "in": "query",
+                        "default": false
                     }
                 ],
                 "responses": {
@@ -95,12 +314,28 @@ const docTemplate = `{
                         "description": "Успешный поиск",
                         "schema": {"$ref": "#/definitions/SearchResponse"}
                     },
+                    "206": {
+                        "description": "Бюджет или время исчерпаны: частичный путь, partial=true, complete=false, разрыв в gap",
+                        "schema": {"$ref": "#/definitions/SearchResponse"}
+                    },
                     "400": {
                         "description": "Ошибка в параметрах",
                         "schema": {"$ref": "#/definitions/ErrorResponse"}
                     },
                     "404": {
-                        "description": "Путь не найден",
+                        "description": "Путь не найден или статьи нет (START_NOT_FOUND, END_NOT_FOUND, ARTICLE_NOT_FOUND)",
+                        "schema": {"$ref": "#/definitions/ErrorResponse"}
+                    },
+                    "408": {
+                        "description": "Время поиска истекло (SEARCH_TIMEOUT)",
+                        "schema": {"$ref": "#/definitions/ErrorResponse"}
+                    },
+                    "502": {
+                        "description": "Wikipedia API недоступен (UPSTREAM_ERROR)",
+                        "schema": {"$ref": "#/definitions/ErrorResponse"}
+                    },
+                    "503": {
+                        "description": "Поиск отменён или сервер останавливается (SHUTTING_DOWN)",
                         "schema": {"$ref": "#/definitions/ErrorResponse"}
                     }
                 }
@@ -108,7 +343,7 @@ const docTemplate = `{
             "post": {
                 "description": "Ищет кратчайший путь между двумя статьями Wikipedia используя bidirectional search",
                 "consumes": ["application/json"],
-                "produces": ["application/json"],
+                "produces": ["application/json", "text/plain", "application/xml", "text/markdown"],
                 "tags": ["search"],
                 "summary": "Найти путь между статьями Wikipedia (POST)",
                 "parameters": [
@@ -125,149 +360,2058 @@ const docTemplate = `{
                         "description": "Успешный поиск",
                         "schema": {"$ref": "#/definitions/SearchResponse"}
                     },
-                    "400": {
-                        "description": "Ошибка в параметрах",
-                        "schema": {"$ref": "#/definitions/ErrorResponse"}
+                    "206": {
+                        "description": "Бюджет или время исчерпаны: частичный путь, partial=true, complete=false, разрыв в gap",
+                        "schema": {"$ref": "#/definitions/SearchResponse"}
+                    },
+                    "400": {
+                        "description": "Ошибка в параметрах",
+                        "schema": {"$ref": "#/definitions/ErrorResponse"}
+                    },
+                    "404": {
+                        "description": "Путь не найден или статьи нет (START_NOT_FOUND, END_NOT_FOUND, ARTICLE_NOT_FOUND)",
+                        "schema": {"$ref": "#/definitions/ErrorResponse"}
+                    },
+                    "408": {
+                        "description": "Время поиска истекло (SEARCH_TIMEOUT)",
+                        "schema": {"$ref": "#/definitions/ErrorResponse"}
+                    },
+                    "502": {
+                        "description": "Wikipedia API недоступен (UPSTREAM_ERROR)",
+                        "schema": {"$ref": "#/definitions/ErrorResponse"}
+                    },
+                    "503": {
+                        "description": "Поиск отменён или сервер останавливается (SHUTTING_DOWN)",
+                        "schema": {"$ref": "#/definitions/ErrorResponse"}
+                    }
+                }
+            }
+        },
+        "/search/batch": {
+            "post": {
+                "description": "Ищет пути для всех пар из pairs, одновременно - не больше WIKI_BATCH_CONCURRENCY. Ответ - массив в порядке pairs: SearchResponse для найденных путей, ErrorResponse для остальных",
+                "consumes": ["application/json"],
+                "produces": ["application/json"],
+                "tags": ["search"],
+                "summary": "Найти пути для нескольких пар статей",
+                "parameters": [
+                    {
+                        "description": "Пары статей",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {"$ref": "#/definitions/BatchRequest"}
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Результаты в порядке pairs: SearchResponse или ErrorResponse",
+                        "schema": {
+                            "type": "array",
+                            "items": {"$ref": "#/definitions/SearchResponse"}
+                        }
+                    },
+                    "400": {
+                        "description": "Ошибка в параметрах (MISSING_PARAMS, BATCH_TOO_LARGE)",
+                        "schema": {"$ref": "#/definitions/ErrorResponse"}
+                    }
+                }
+            }
+        },
+        "/search/stream": {
+            "get": {
+                "description": "Пока идёт поиск - событие progress после каждого раунда (ProgressEvent: раунд, размеры очередей, запросы, время). Фаза 1: событие path с первым найденным путём (тот же JSON, что у /search). Фаза 2 (optimize=true): поиск shortest между найденными концами (ссылки первой фазы уже в кеше), событие optimized, если путь короче. Поток закрывает событие done с {\"optimized\": bool}; если путь не найден - событие error с ErrorResponse.",
+                "produces": ["text/event-stream"],
+                "tags": ["search"],
+                "summary": "Двухфазный поиск с потоковой выдачей (SSE)",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "Кошка",
+                        "description": "Начальная статья",
+                        "name": "from",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "Теория относительности",
+                        "description": "Конечная статья",
+                        "name": "to",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "ru",
+                        "description": "Язык по умолчанию",
+                        "name": "lang",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "После первого пути искать более короткий",
+                        "name": "optimize",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Бюджет поиска в миллисекундах, до 60000; без параметра - WIKI_SEARCH_TIMEOUT_MS или 10 с",
+                        "name": "timeout_ms",
+                        "in": "query",
+                        "example": 20000
+                    },
+                    {
+                        "type": "string",
+                        "description": "Эвристика поиска: default или monolingual - сильный штраф статьям не на языке from, путь по возможности без смены языка",
+                        "name": "mode",
+                        "in": "query",
+                        "enum": ["default", "monolingual"],
+                        "default": "default"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Путь только внутри одного раздела: false - interwiki не раскрываются, оба конца ищутся в from_lang/to_lang или lang",
+                        "name": "cross_lang",
+                        "in": "query",
+                        "default": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Поток событий path, optimized, done",
+                        "schema": {"$ref": "#/definitions/SearchResponse"}
+                    },
+                    "400": {
+                        "description": "Ошибка в параметрах",
+                        "schema": {"$ref": "#/definitions/ErrorResponse"}
+                    }
+                }
+            }
+        },
+        "/admin/searches": {
+            "get": {
+                "description": "Последние сохранённые в SQLite поиски, новые первыми (WIKI_SQLITE_DSN)",
+                "produces": ["application/json"],
+                "tags": ["admin"],
+                "summary": "Последние поиски",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "example": 50,
+                        "description": "Сколько записей вернуть (1-1000)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "properties": {
+                                "enabled": {
+                                    "type": "boolean",
+                                    "example": true
+                                },
+                                "dropped": {
+                                    "type": "integer",
+                                    "example": 0
+                                },
+                                "searches": {
+                                    "type": "array",
+                                    "items": {"$ref": "#/definitions/SearchRecord"}
+                                }
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка чтения истории",
+                        "schema": {"$ref": "#/definitions/ErrorResponse"}
+                    }
+                }
+            }
+        },
+        "/hint": {
+            "get": {
+                "description": "Раскрывает текущую статью и возвращает лучшие по эвристике ссылки в сторону цели, без поиска полного пути",
+                "produces": ["application/json"],
+                "tags": ["search"],
+                "summary": "Подсказка следующего шага",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "Кошка",
+                        "description": "Текущая статья игрока",
+                        "name": "from",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "Собака",
+                        "description": "Целевая статья",
+                        "name": "to",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "ru",
+                        "description": "Язык",
+                        "name": "lang",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "example": 3,
+                        "description": "Сколько подсказок вернуть (1-20)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {"$ref": "#/definitions/HintResponse"}
+                    },
+                    "400": {
+                        "description": "Ошибка в параметрах",
+                        "schema": {"$ref": "#/definitions/ErrorResponse"}
+                    },
+                    "404": {
+                        "description": "У статьи нет подходящих ссылок",
+                        "schema": {"$ref": "#/definitions/ErrorResponse"}
+                    }
+                }
+            }
+        },
+        "/neighbors": {
+            "get": {
+                "description": "Все исходящие и/или входящие ссылки статьи и её interwiki, с продолжениями (continue) и оценкой эвристики без цели",
+                "produces": ["application/json"],
+                "tags": ["graph"],
+                "summary": "Соседи статьи в графе ссылок",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "Кошка",
+                        "description": "Статья",
+                        "name": "title",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "ru",
+                        "description": "Язык",
+                        "name": "lang",
+                        "in": "query"
+                    },
+                    {
+                        "enum": ["forward", "backward", "both"],
+                        "type": "string",
+                        "default": "both",
+                        "description": "Направление: forward - ссылки статьи, backward - ссылки на статью, both - оба",
+                        "name": "dir",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "0|14",
+                        "description": "Пространства имён соседей через |, 0 - статьи, 14 - категории",
+                        "name": "namespaces",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {"$ref": "#/definitions/NeighborsResponse"}
+                    },
+                    "400": {
+                        "description": "Ошибка в параметрах",
+                        "schema": {"$ref": "#/definitions/ErrorResponse"}
+                    },
+                    "404": {
+                        "description": "Статьи нет (ARTICLE_NOT_FOUND)",
+                        "schema": {"$ref": "#/definitions/ErrorResponse"}
+                    },
+                    "502": {
+                        "description": "Wikipedia API недоступен (UPSTREAM_ERROR)",
+                        "schema": {"$ref": "#/definitions/ErrorResponse"}
+                    }
+                }
+            }
+        },
+        "/compare": {
+            "get": {
+                "description": "Ищет путь между одними понятиями отдельно в каждом разделе, без interwiki, и показывает, какие промежуточные понятия (по Wikidata) общие, а какие есть только в одном разделе",
+                "produces": ["application/json"],
+                "tags": ["search"],
+                "summary": "Сравнить пути в разных языковых разделах",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "Q146",
+                        "description": "Начальное понятие: Wikidata ID или название",
+                        "name": "from",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "Q144",
+                        "description": "Конечное понятие: Wikidata ID или название",
+                        "name": "to",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "en,ru",
+                        "description": "Разделы через запятую",
+                        "name": "langs",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "Cat",
+                        "description": "Название начала в разделе en (from_<lang> для любого раздела)",
+                        "name": "from_en",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "Dog",
+                        "description": "Название конца в разделе en (to_<lang> для любого раздела)",
+                        "name": "to_en",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {"$ref": "#/definitions/CompareResponse"}
+                    },
+                    "400": {
+                        "description": "Ошибка в параметрах",
+                        "schema": {"$ref": "#/definitions/ErrorResponse"}
+                    },
+                    "502": {
+                        "description": "Wikidata API недоступен",
+                        "schema": {"$ref": "#/definitions/ErrorResponse"}
+                    }
+                }
+            }
+        },
+        "/search/category": {
+            "get": {
+                "description": "Статьи категории (до 500, без подкатегорий) становятся целями: поиск останавливается на первой достигнутой",
+                "produces": ["application/json"],
+                "tags": ["search"],
+                "summary": "Найти путь до любой статьи категории",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "Кошка",
+                        "description": "Начальная статья",
+                        "name": "from",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "Физика",
+                        "description": "Целевая категория, с пространством имён или без",
+                        "name": "category",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "ru",
+                        "description": "Язык категории, если не определился язык статьи",
+                        "name": "lang",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Бюджет поиска в миллисекундах, до 60000; без параметра - WIKI_SEARCH_TIMEOUT_MS или 10 с",
+                        "name": "timeout_ms",
+                        "in": "query",
+                        "example": 20000
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {"$ref": "#/definitions/CategorySearchResponse"}
+                    },
+                    "400": {
+                        "description": "Ошибка в параметрах",
+                        "schema": {"$ref": "#/definitions/ErrorResponse"}
+                    },
+                    "404": {
+                        "description": "В категории нет статей или путь не найден",
+                        "schema": {"$ref": "#/definitions/ErrorResponse"}
+                    },
+                    "408": {
+                        "description": "Время поиска истекло",
+                        "schema": {"$ref": "#/definitions/ErrorResponse"}
+                    },
+                    "502": {
+                        "description": "Wikipedia API недоступен",
+                        "schema": {"$ref": "#/definitions/ErrorResponse"}
+                    }
+                }
+            }
+        },
+        "/ws/search": {
+            "get": {
+                "description": "Апгрейд до WebSocket. Сервер шлёт JSON-кадры WSFrame: round после каждого раунда (раскрытые узлы обоих фронтов, размеры посещённых множеств, узел встречи), в конце - result с ответом как у /search или error, затем закрывает соединение. Сообщения клиента не читаются; закрытие соединения клиентом отменяет поиск.",
+                "tags": ["search"],
+                "summary": "Поиск с покадровыми данными фронтов (WebSocket)",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Начальная статья",
+                        "name": "from",
+                        "in": "query",
+                        "required": true,
+                        "example": "Кошка"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Конечная статья",
+                        "name": "to",
+                        "in": "query",
+                        "required": true,
+                        "example": "Теория относительности"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Язык по умолчанию",
+                        "name": "lang",
+                        "in": "query",
+                        "example": "ru"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Бюджет поиска в миллисекундах, до 60000",
+                        "name": "timeout_ms",
+                        "in": "query",
+                        "example": 20000
+                    },
+                    {
+                        "type": "string",
+                        "description": "Эвристика поиска: default или monolingual - сильный штраф статьям не на языке from, путь по возможности без смены языка",
+                        "name": "mode",
+                        "in": "query",
+                        "enum": ["default", "monolingual"],
+                        "default": "default"
+                    }
+                ],
+                "responses": {
+                    "101": {
+                        "description": "Switching Protocols; дальше - кадры WSFrame",
+                        "schema": {"$ref": "#/definitions/WSFrame"}
+                    },
+                    "400": {
+                        "description": "Ошибка в параметрах",
+                        "schema": {"$ref": "#/definitions/ErrorResponse"}
+                    },
+                    "426": {
+                        "description": "Запрос без Upgrade: websocket",
+                        "schema": {"$ref": "#/definitions/ErrorResponse"}
+                    }
+                }
+            }
+        },
+        "/search.dot": {
+            "get": {
+                "description": "Тот же поиск, что GET /search, с format=dot и explored=true по умолчанию: дерево поиска обоих фронтов (до WIKI_EXPLORED_NODES рёбер), путь выделен красным. Рисуется через dot -Tsvg.",
+                "produces": ["text/vnd.graphviz"],
+                "tags": ["search"],
+                "summary": "Исследованный подграф в Graphviz DOT",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "Кошка",
+                        "description": "Начальная статья",
+                        "name": "from",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "Теория относительности",
+                        "description": "Конечная статья",
+                        "name": "to",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "default": "ru",
+                        "description": "Язык",
+                        "name": "lang",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "default": true,
+                        "description": "Добавить дерево поиска",
+                        "name": "explored",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "default": false,
+                        "description": "Добавить все рёбра снимка",
+                        "name": "capture",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "digraph",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {"$ref": "#/definitions/ErrorResponse"}
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {"$ref": "#/definitions/ErrorResponse"}
+                    }
+                }
+            }
+        },
+        "/explain": {
+            "get": {
+                "description": "Приоритет, который эвристика поиска дала бы статье title на пути к target, по слагаемым. Запросов к Wikipedia не делает. Для dir=F target - конечная статья поиска, для dir=B - начальная.",
+                "produces": ["application/json"],
+                "tags": ["graph"],
+                "summary": "Разбор оценки эвристики",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "Домашняя кошка",
+                        "description": "Оцениваемая статья",
+                        "name": "title",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "ru",
+                        "description": "Язык статьи",
+                        "name": "lang",
+                        "in": "query"
+                    },
+                    {
+                        "enum": ["F", "B"],
+                        "type": "string",
+                        "default": "F",
+                        "description": "Фронт: F (forward) или B (backward)",
+                        "name": "dir",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "Кошка",
+                        "description": "Цель фронта",
+                        "name": "target",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "ru",
+                        "description": "Язык цели, по умолчанию lang",
+                        "name": "target_lang",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {"$ref": "#/definitions/ExplainResponse"}
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {"$ref": "#/definitions/ErrorResponse"}
+                    }
+                }
+            }
+        },
+        "/suggest": {
+            "get": {
+                "description": "Статьи, названия которых начинаются с q (MediaWiki opensearch), для подсказок при вводе from и to. Редиректы разрешаются в статьи.",
+                "produces": ["application/json"],
+                "tags": ["graph"],
+                "summary": "Автодополнение названий статей",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "Кош",
+                        "description": "Начало названия",
+                        "name": "q",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "ru",
+                        "description": "Язык",
+                        "name": "lang",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 10,
+                        "description": "Сколько вариантов вернуть (1-50)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {"$ref": "#/definitions/SuggestResponse"}
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {"$ref": "#/definitions/ErrorResponse"}
+                    },
+                    "502": {
+                        "description": "Bad Gateway",
+                        "schema": {"$ref": "#/definitions/ErrorResponse"}
+                    }
+                }
+            }
+        },
+        "/search/compare": {
+            "post": {
+                "description": "Запускает два поиска для одной пары статей - конфигурации a и b (поля POST /search; from, to и lang общие) - и возвращает оба результата и разницу b минус a. Без a - настройки по умолчанию, без b - mode=monolingual. Оба поиска идут мимо кэша отрицательных ответов, format всегда json",
+                "consumes": ["application/json"],
+                "produces": ["application/json"],
+                "tags": ["search"],
+                "summary": "Сравнить две конфигурации поиска",
+                "parameters": [
+                    {
+                        "description": "Пара статей и две конфигурации",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {"$ref": "#/definitions/SearchCompareRequest"}
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Результаты обеих конфигураций: SearchResponse или ErrorResponse; diff - null, если путь не вернула хотя бы одна",
+                        "schema": {"$ref": "#/definitions/SearchCompareResponse"}
+                    },
+                    "400": {
+                        "description": "Ошибка в параметрах (MISSING_PARAMS или код ошибки конфигурации с префиксом a: / b: в сообщении)",
+                        "schema": {"$ref": "#/definitions/ErrorResponse"}
+                    }
+                }
+            }
+        },
+        "/random": {
+            "get": {
+                "description": "Берёт две случайные статьи раздела (list=random) и ищет путь между ними, как GET /search. Для демонстраций и нагрузочных тестов. Ответ - 200 с выбранными концами; result - SearchResponse или ErrorResponse поиска.",
+                "produces": ["application/json"],
+                "tags": ["search"],
+                "summary": "Путь между двумя случайными статьями",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "ru",
+                        "description": "Язык",
+                        "name": "lang",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "example": 20000,
+                        "description": "Бюджет поиска в мс, до 60000",
+                        "name": "timeout_ms",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Искать путь с наименьшим числом переходов (в ширину)",
+                        "name": "shortest",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Выбранные концы и результат поиска",
+                        "schema": {"$ref": "#/definitions/RandomResponse"}
+                    },
+                    "400": {
+                        "description": "Неизвестный язык (UNKNOWN_LANG)",
+                        "schema": {"$ref": "#/definitions/ErrorResponse"}
+                    },
+                    "502": {
+                        "description": "Не удалось получить случайные статьи (UPSTREAM_ERROR)",
+                        "schema": {"$ref": "#/definitions/ErrorResponse"}
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "SearchRequest": {
+            "type": "object",
+            "required": ["from", "to"],
+            "properties": {
+                "from": {
+                    "type": "string",
+                    "description": "Начальная статья",
+                    "example": "Кошка"
+                },
+                "to": {
+                    "type": "string",
+                    "description": "Конечная статья",
+                    "example": "Теория относительности"
+                },
+                "lang": {
+                    "type": "string",
+                    "description": "Язык по умолчанию",
+                    "default": "ru",
+                    "example": "ru"
+                },
+                "format": {
+                    "type": "string",
+                    "description": "Формат ответа: json, text (нумерованный рецепт text/plain), xml (граф пути, при explored=true - и дерева поиска, при capture=true - и снимка), dot (тот же граф для Graphviz) или markdown (путь строкой ссылок text/markdown)",
+                    "enum": ["json", "text", "xml", "dot", "markdown"],
+                    "default": "json"
+                },
+                "wikidata": {
+                    "type": "boolean",
+                    "description": "Добавить Wikidata Q-ID к каждому шагу пути",
+                    "default": false
+                },
+                "capture": {
+                    "type": "boolean",
+                    "description": "Вернуть снимок графа ссылок для офлайн-воспроизведения",
+                    "default": false
+                },
+                "summary": {
+                    "type": "boolean",
+                    "description": "Добавить объяснение, что связывает статьи",
+                    "default": false
+                },
+                "forbidden": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "description": "Статьи, через которые путь проходить не может (только для этого поиска): Название - в разделе lang, de:Название - в другом разделе",
+                    "example": ["Млекопитающие"]
+                },
+                "categories": {
+                    "type": "boolean",
+                    "description": "Экспериментально: разрешить шаги через общую категорию",
+                    "example": false
+                },
+                "prose": {
+                    "type": "boolean",
+                    "description": "Проверить, что ссылки пути стоят в тексте статей, а не только в навбоксах",
+                    "example": false
+                },
+                "paths": {
+                    "type": "integer",
+                    "description": "Сколько путей собрать, до 5",
+                    "example": 3
+                },
+                "rank_by": {
+                    "type": "string",
+                    "description": "Порядок путей: shortest, interwiki или prominence",
+                    "enum": ["shortest", "interwiki", "prominence"],
+                    "example": "shortest"
+                },
+                "debug": {
+                    "type": "boolean",
+                    "description": "Вернуть статус, размер и время каждого запроса к API",
+                    "example": false
+                },
+                "canonical": {
+                    "type": "boolean",
+                    "description": "Заменить названия статей пути на цели редиректов",
+                    "example": false
+                },
+                "max_languages": {
+                    "type": "integer",
+                    "description": "Сколько разных языковых разделов может пройти путь, 0 - без ограничения",
+                    "example": 2
+                },
+                "from_lang": {
+                    "type": "string",
+                    "description": "Явный язык начальной статьи",
+                    "example": "de"
+                },
+                "to_lang": {
+                    "type": "string",
+                    "description": "Явный язык конечной статьи",
+                    "example": "en"
+                },
+                "animate": {
+                    "type": "boolean",
+                    "description": "Вернуть журнал поиска для покадровой анимации",
+                    "example": false
+                },
+                "verify_meet": {
+                    "type": "boolean",
+                    "description": "Проверить ребро встречи фронтов запросом к API",
+                    "example": false
+                },
+                "timeout_ms": {
+                    "type": "integer",
+                    "description": "Бюджет поиска в миллисекундах, до 60000; 0 - из настроек",
+                    "example": 20000
+                },
+                "with_context": {
+                    "type": "boolean",
+                    "description": "Найти предложение и раздел, где стоит каждая ссылка пути",
+                    "example": false
+                },
+                "max_depth": {
+                    "type": "integer",
+                    "description": "Предел длины пути в переходах, 0 - WIKI_MAX_DEPTH",
+                    "example": 6
+                },
+                "verify": {
+                    "type": "boolean",
+                    "description": "Проверить каждую ссылку пути по живому графу ссылок и исправить direction обратных",
+                    "example": false
+                },
+                "namespaces": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    },
+                    "description": "Пространства имён, через которые может идти путь: 0 - статьи, 14 - категории; пусто - WIKI_LANG_NAMESPACES",
+                    "example": [0, 14]
+                },
+                "mode": {
+                    "type": "string",
+                    "description": "Эвристика поиска: default или monolingual (путь по возможности без смены языка)",
+                    "enum": ["default", "monolingual"],
+                    "example": "default"
+                },
+                "explored": {
+                    "type": "boolean",
+                    "description": "Вернуть дерево поиска обоих фронтов",
+                    "default": false
+                },
+                "cross_lang": {
+                    "type": "boolean",
+                    "description": "Путь только внутри одного раздела: false - interwiki не раскрываются, оба конца ищутся в from_lang/to_lang или lang",
+                    "default": true
+                },
+                "shortest": {
+                    "type": "boolean",
+                    "description": "Искать путь с наименьшим числом переходов: поиск в ширину от обоих концов, эвристика решает только порядок внутри слоя. Медленнее и дороже по запросам, чем жадный поиск",
+                    "example": false
+                },
+                "exclude": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "description": "Регулярки названий, через которые путь не идёт (только для этого поиска)",
+                    "example": ["^[0-9]+$", "^Список"]
+                },
+                "exclude_disambig": {
+                    "type": "boolean",
+                    "description": "Не проводить путь через страницы значений",
+                    "default": false
+                }
+            }
+        },
+        "SearchResponse": {
+            "type": "object",
+            "properties": {
+                "success": {
+                    "type": "boolean",
+                    "example": true
+                },
+                "from": {
+                    "type": "string",
+                    "example": "Кошка"
+                },
+                "to": {
+                    "type": "string",
+                    "example": "Теория относительности"
+                },
+                "path_length": {
+                    "type": "integer",
+                    "example": 3
+                },
+                "direct": {
+                    "type": "boolean",
+                    "description": "Статьи связаны напрямую (путь найден за один шаг)",
+                    "example": false
+                },
+                "path": {
+                    "type": "array",
+                    "items": {"$ref": "#/definitions/PathStep"}
+                },
+                "transitions": {
+                    "type": "array",
+                    "items": {"$ref": "#/definitions/Transition"}
+                },
+                "stats": {"$ref": "#/definitions/SearchStats"},
+                "capture": {"$ref": "#/definitions/Capture"},
+                "connection": {"$ref": "#/definitions/ConnectionSummary"},
+                "difficulty": {
+                    "type": "integer",
+                    "description": "Сложность пары от 1 до 10 по длине пути, запросам, раундам и пику очередей (формула стабильна)",
+                    "example": 4
+                },
+                "partial": {
+                    "type": "boolean",
+                    "description": "Бюджет или время исчерпаны: path - лучшая догадка и до цели не доходит (HTTP 206)",
+                    "example": false
+                },
+                "prose_only": {
+                    "type": "boolean",
+                    "description": "Все проверенные ссылки пути стоят в тексте статей (prose=true)",
+                    "example": true
+                },
+                "paths": {
+                    "type": "array",
+                    "description": "Все найденные пути по rank_by, первый - он же path (paths > 1)",
+                    "items": {
+                        "type": "array",
+                        "items": {"$ref": "#/definitions/PathStep"}
+                    }
+                },
+                "debug": {"$ref": "#/definitions/DebugInfo"},
+                "warnings": {
+                    "type": "array",
+                    "description": "Отступления от запроса, которые поиск пережил (например, статьи нет в from_lang и язык определён заново)",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "animation": {"$ref": "#/definitions/Animation"},
+                "meet_transition": {"$ref": "#/definitions/MeetTransition"},
+                "forward_hops": {
+                    "type": "integer",
+                    "description": "Сколько переходов пути нашёл forward-фронт (от from до статьи встречи)",
+                    "example": 2
+                },
+                "backward_hops": {
+                    "type": "integer",
+                    "description": "Сколько переходов пути нашёл backward-фронт (от статьи встречи до to)",
+                    "example": 1
+                },
+                "explored": {"$ref": "#/definitions/Explored"},
+                "complete": {
+                    "type": "boolean",
+                    "description": "path доходит до to; false вместе с partial",
+                    "example": true
+                },
+                "tail": {
+                    "type": "array",
+                    "description": "При partial: цепочка backward-фронта от его лучшей статьи до to",
+                    "items": {"$ref": "#/definitions/PathStep"}
+                },
+                "gap": {"$ref": "#/definitions/PathGap"}
+            }
+        },
+        "Capture": {
+            "type": "object",
+            "description": "Снимок графа ссылок, увиденного поиском (не более 5000 статей)",
+            "properties": {
+                "version": {
+                    "type": "integer",
+                    "description": "Версия схемы снимка",
+                    "example": 1
+                },
+                "truncated": {
+                    "type": "boolean",
+                    "description": "Лимит статей был достигнут, снимок неполный"
+                },
+                "entries": {
+                    "type": "array",
+                    "items": {
+                        "type": "object",
+                        "properties": {
+                            "lang": {"type": "string", "example": "ru"},
+                            "dir": {"type": "string", "enum": ["F", "B"], "description": "F - исходящие ссылки, B - входящие"},
+                            "title": {"type": "string", "example": "Кошка"},
+                            "links": {"type": "array", "items": {"type": "string"}},
+                            "langlinks": {
+                                "type": "array",
+                                "items": {
+                                    "type": "object",
+                                    "properties": {
+                                        "lang": {"type": "string", "example": "en"},
+                                        "title": {"type": "string", "example": "Cat"}
+                                    }
+                                }
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "PathStep": {
+            "type": "object",
+            "properties": {
+                "step": {
+                    "type": "integer",
+                    "description": "Номер шага (1-based)",
+                    "example": 1
+                },
+                "title": {
+                    "type": "string",
+                    "description": "Название статьи",
+                    "example": "Кошка"
+                },
+                "lang": {
+                    "type": "string",
+                    "description": "Язык статьи",
+                    "example": "ru"
+                },
+                "url": {
+                    "type": "string",
+                    "description": "URL статьи в Wikipedia",
+                    "example": "https://ru.wikipedia.org/wiki/Кошка"
+                },
+                "full_name": {
+                    "type": "string",
+                    "description": "Полное имя (lang:title)",
+                    "example": "ru:Кошка"
+                },
+                "wikidata_id": {
+                    "type": "string",
+                    "description": "Wikidata Q-ID статьи (null, если не запрошен wikidata=true или у статьи нет элемента)",
+                    "x-nullable": true,
+                    "example": "Q146"
+                },
+                "direction": {
+                    "type": "string",
+                    "description": "Какой фронт дошёл до статьи: forward - от from, backward - от to, meet - статья встречи фронтов. В частичном пути (partial) все шаги forward",
+                    "enum": ["forward", "backward", "meet"],
+                    "example": "forward"
+                }
+            }
+        },
+        "Transition": {
+            "type": "object",
+            "properties": {
+                "from": {
+                    "type": "string",
+                    "description": "Исходная статья",
+                    "example": "Кошка"
+                },
+                "to": {
+                    "type": "string",
+                    "description": "Целевая статья",
+                    "example": "Квантовая механика"
+                },
+                "type": {
+                    "type": "string",
+                    "description": "Тип перехода (link или interwiki)",
+                    "enum": ["link", "interwiki", "category"],
+                    "example": "link"
+                },
+                "description": {
+                    "type": "string",
+                    "description": "Описание как найти ссылку",
+                    "example": "Найти 'Квантовая механика' в статье 'Кошка'"
+                },
+                "check_url": {
+                    "type": "string",
+                    "description": "URL для проверки перехода",
+                    "example": "https://ru.wikipedia.org/wiki/Кошка"
+                },
+                "direction": {
+                    "type": "string",
+                    "description": "Откуда ребро: forward - ссылка в статье from (links), backward - ссылка в статье to на from (linkshere)",
+                    "enum": ["forward", "backward"],
+                    "example": "forward"
+                },
+                "prose": {
+                    "type": "boolean",
+                    "description": "Ссылка стоит в тексте статьи (prose=true); нет поля - не проверялась",
+                    "example": true
+                },
+                "context": {"$ref": "#/definitions/LinkContext"},
+                "verified": {
+                    "type": "boolean",
+                    "description": "В статье from есть ссылка на to (verify=true); false - ссылка обратная, direction исправлен; нет поля - не проверялась",
+                    "example": true
+                }
+            }
+        },
+        "SearchStats": {
+            "type": "object",
+            "properties": {
+                "duration": {
+                    "type": "string",
+                    "description": "Время поиска (human readable)",
+                    "example": "823.45ms"
+                },
+                "duration_ms": {
+                    "type": "number",
+                    "description": "Время поиска в миллисекундах",
+                    "example": 823.45
+                },
+                "request_count": {
+                    "type": "integer",
+                    "description": "Количество запросов к Wikipedia API",
+                    "example": 12
+                },
+                "blocked_nodes": {
+                    "type": "integer",
+                    "description": "Сколько кандидатов не пущено в путь: WIKI_BLOCKLIST_FILE и exclude, forbidden, WIKI_SKIP_LISTS, страницы значений при WIKI_DISAMBIG_STRATEGY=skip",
+                    "example": 0
+                },
+                "blocked_by": {
+                    "type": "object",
+                    "description": "blocked_nodes по причинам: blocklist, forbidden, list, disambig; нулевые не выводятся",
+                    "additionalProperties": {"type": "integer"}
+                },
+                "failed_fetches": {
+                    "type": "integer",
+                    "description": "Сколько батчей потеряно из-за ошибок запроса",
+                    "example": 0
+                },
+                "degraded": {
+                    "type": "boolean",
+                    "description": "failed_fetches больше WIKI_DEGRADED_THRESHOLD: путь найден по неполному графу",
+                    "example": false
+                },
+                "rounds": {
+                    "type": "integer",
+                    "description": "Раундов основного цикла поиска",
+                    "example": 1
+                },
+                "peak_frontier": {
+                    "type": "integer",
+                    "description": "Максимум узлов в обеих очередях на начало раунда",
+                    "example": 480
+                },
+                "largest_response_bytes": {
+                    "type": "integer",
+                    "description": "Размер самого большого ответа MediaWiki API за поиск, байт",
+                    "example": 48213
+                },
+                "interwiki_rejected": {
+                    "type": "integer",
+                    "description": "Interwiki без обратной ссылки, отсеянные WIKI_STRICT_INTERWIKI",
+                    "example": 0
+                },
+                "interwiki_dropped": {
+                    "type": "integer",
+                    "description": "Interwiki в разделы не из WIKI_LANGS - по ним поиск не идёт",
+                    "example": 3
+                },
+                "interwiki_fallback": {
+                    "type": "integer",
+                    "description": "Interwiki-кандидаты, найденные через версии статей в неподключённых разделах (WIKI_INTERWIKI_FALLBACK)",
+                    "example": 0
+                },
+                "cache_hits": {
+                    "type": "integer",
+                    "description": "Статьи, взятые из кеша ссылок",
+                    "example": 12
+                },
+                "cache_misses": {
+                    "type": "integer",
+                    "description": "Статьи, которых не было в кеше (или запись устарела) и которые запрошены у API",
+                    "example": 140
+                },
+                "depth_pruned": {
+                    "type": "integer",
+                    "description": "Узлы, не раскрытые из-за предела глубины, и отброшенные встречи",
+                    "example": 0
+                },
+                "reprioritized": {
+                    "type": "integer",
+                    "description": "Узлы очереди, найденные снова через другого родителя с лучшим приоритетом (decrease-key)",
+                    "example": 37
+                },
+                "limiter_wait_ms": {
+                    "type": "number",
+                    "description": "Сколько запросы поиска в сумме ждали общего лимита WIKI_MAX_INFLIGHT/WIKI_MAX_RPS; параллельные запросы ждут одновременно, так что сумма может быть больше duration_ms",
+                    "example": 0
+                },
+                "nodes_visited_forward": {
+                    "type": "integer",
+                    "description": "Сколько статей посетил forward-фронт, вместе с start",
+                    "example": 1840
+                },
+                "nodes_visited_backward": {
+                    "type": "integer",
+                    "description": "Сколько статей посетил backward-фронт, вместе с to",
+                    "example": 920
+                }
+            }
+        },
+        "LinkCacheStats": {
+            "type": "object",
+            "properties": {
+                "size": {
+                    "type": "integer",
+                    "description": "Текущее число записей",
+                    "example": 1520
+                },
+                "capacity": {
+                    "type": "integer",
+                    "description": "Лимит записей для языка",
+                    "example": 10000
+                },
+                "hits": {
+                    "type": "integer",
+                    "example": 340
+                },
+                "misses": {
+                    "type": "integer",
+                    "example": 1600
+                },
+                "evictions": {
+                    "type": "integer",
+                    "description": "Сколько записей вытеснено по LRU",
+                    "example": 0
+                },
+                "expired": {
+                    "type": "integer",
+                    "description": "Сколько записей удалено по сроку WIKI_CACHE_TTL_MS",
+                    "example": 12
+                },
+                "hit_rate": {
+                    "type": "number",
+                    "example": 0.175
+                }
+            }
+        },
+        "ConnectionSummary": {
+            "type": "object",
+            "description": "Что связывает две статьи (summary=true)",
+            "properties": {
+                "via": {
+                    "type": "string",
+                    "description": "Узел встречи (lang:title)",
+                    "example": "ru:Млекопитающие"
+                },
+                "topic": {
+                    "type": "string",
+                    "description": "Первая видимая категория узла встречи",
+                    "example": "Классы позвоночных"
+                },
+                "extract": {
+                    "type": "string",
+                    "description": "Вводное предложение статьи узла встречи",
+                    "example": "Млекопитающие — класс позвоночных животных."
+                },
+                "summary": {
+                    "type": "string",
+                    "example": "Связаны через «Млекопитающие» (Классы позвоночных)"
+                }
+            }
+        },
+        "SearchRecord": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "from": {
+                    "type": "string",
+                    "example": "Кошка"
+                },
+                "to": {
+                    "type": "string",
+                    "example": "Собака"
+                },
+                "lang": {
+                    "type": "string",
+                    "example": "ru"
+                },
+                "from_lang": {
+                    "type": "string",
+                    "example": "ru"
+                },
+                "to_lang": {
+                    "type": "string",
+                    "example": "ru"
+                },
+                "path": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": ["ru:Кошка", "ru:Собака"]
+                },
+                "stats": {"$ref": "#/definitions/SearchStats"},
+                "outcome": {
+                    "type": "string",
+                    "enum": ["found", "not_found", "cancelled", "partial", "timeout", "upstream_error"],
+                    "example": "found"
+                },
+                "created_at": {
+                    "type": "string",
+                    "example": "2024-05-01T12:00:00Z"
+                }
+            }
+        },
+        "HintSuggestion": {
+            "type": "object",
+            "properties": {
+                "title": {
+                    "type": "string",
+                    "example": "Млекопитающие"
+                },
+                "lang": {
+                    "type": "string",
+                    "example": "ru"
+                },
+                "url": {
+                    "type": "string",
+                    "example": "https://ru.wikipedia.org/wiki/Млекопитающие"
+                },
+                "score": {
+                    "type": "integer",
+                    "description": "Приоритет эвристики, меньше - лучше",
+                    "example": 35
+                }
+            }
+        },
+        "HintResponse": {
+            "type": "object",
+            "properties": {
+                "success": {
+                    "type": "boolean",
+                    "example": true
+                },
+                "from": {
+                    "type": "string",
+                    "example": "Кошка"
+                },
+                "to": {
+                    "type": "string",
+                    "example": "Собака"
+                },
+                "direct": {
+                    "type": "boolean",
+                    "description": "Цель есть среди ссылок текущей статьи",
+                    "example": false
+                },
+                "suggestions": {
+                    "type": "array",
+                    "items": {"$ref": "#/definitions/HintSuggestion"}
+                }
+            }
+        },
+        "DebugInfo": {
+            "type": "object",
+            "properties": {
+                "requests": {
+                    "type": "array",
+                    "items": {"$ref": "#/definitions/RequestTrace"}
+                },
+                "dropped": {
+                    "type": "integer",
+                    "description": "Сколько запросов не попало в requests из-за WIKI_DEBUG_REQUESTS",
+                    "example": 0
+                }
+            }
+        },
+        "RequestTrace": {
+            "type": "object",
+            "properties": {
+                "url": {
+                    "type": "string",
+                    "example": "https://ru.wikipedia.org/w/api.php?action=query&titles=Кошка"
+                },
+                "status": {
+                    "type": "integer",
+                    "description": "HTTP-статус; 0 - ответа нет (сеть, отмена)",
+                    "example": 200
+                },
+                "bytes": {
+                    "type": "integer",
+                    "description": "Размер тела ответа",
+                    "example": 48213
+                },
+                "latency_ms": {
+                    "type": "number",
+                    "description": "Время до конца чтения тела",
+                    "example": 182.4
+                },
+                "error": {
+                    "type": "string",
+                    "description": "Ошибка запроса, если ответа нет"
+                }
+            }
+        },
+        "CompareResponse": {
+            "type": "object",
+            "properties": {
+                "success": {
+                    "type": "boolean",
+                    "example": true
+                },
+                "from": {
+                    "type": "string",
+                    "example": "Q146"
+                },
+                "to": {
+                    "type": "string",
+                    "example": "Q144"
+                },
+                "editions": {
+                    "type": "array",
+                    "items": {"$ref": "#/definitions/CompareEdition"}
+                },
+                "shared": {
+                    "type": "array",
+                    "description": "Промежуточные понятия, общие для путей всех разделов, где путь нашёлся",
+                    "items": {"$ref": "#/definitions/SharedConcept"}
+                }
+            }
+        },
+        "CompareEdition": {
+            "type": "object",
+            "properties": {
+                "lang": {
+                    "type": "string",
+                    "example": "en"
+                },
+                "from": {
+                    "type": "string",
+                    "example": "Cat"
+                },
+                "to": {
+                    "type": "string",
+                    "example": "Dog"
+                },
+                "success": {
+                    "type": "boolean",
+                    "example": true
+                },
+                "path": {
+                    "type": "array",
+                    "items": {"$ref": "#/definitions/PathStep"}
+                },
+                "specific": {
+                    "type": "array",
+                    "description": "Промежуточные статьи, понятий которых нет в путях других разделов",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": ["Felidae"]
+                },
+                "error": {
+                    "type": "string",
+                    "description": "Почему пути нет"
+                }
+            }
+        },
+        "SharedConcept": {
+            "type": "object",
+            "properties": {
+                "wikidata_id": {
+                    "type": "string",
+                    "example": "Q7377"
+                },
+                "titles": {
+                    "type": "object",
+                    "description": "Язык -> название статьи",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "RateQuota": {
+            "type": "object",
+            "properties": {
+                "limit": {
+                    "type": "integer",
+                    "example": 500
+                },
+                "remaining": {
+                    "type": "integer",
+                    "example": 420
+                },
+                "reset": {
+                    "type": "string",
+                    "format": "date-time",
+                    "description": "Когда квота сбросится"
+                },
+                "updated": {
+                    "type": "string",
+                    "format": "date-time",
+                    "description": "Когда квота получена"
+                }
+            }
+        },
+        "Animation": {
+            "type": "object",
+            "properties": {
+                "events": {
+                    "type": "array",
+                    "description": "События в порядке времени, последнее - встреча фронтов",
+                    "items": {"$ref": "#/definitions/AnimationEvent"}
+                },
+                "truncated": {
+                    "type": "boolean",
+                    "description": "Журнал упёрся в WIKI_ANIMATE_EVENTS",
+                    "example": false
+                }
+            }
+        },
+        "AnimationEvent": {
+            "type": "object",
+            "properties": {
+                "t": {
+                    "type": "number",
+                    "description": "Мс от начала поиска",
+                    "example": 182.4
+                },
+                "round": {
+                    "type": "integer",
+                    "description": "Раунд; 0 - раскрытие концов",
+                    "example": 1
+                },
+                "type": {
+                    "type": "string",
+                    "enum": ["expand", "meet"],
+                    "example": "expand"
+                },
+                "dir": {
+                    "type": "string",
+                    "enum": ["F", "B"],
+                    "example": "F"
+                },
+                "node": {
+                    "type": "string",
+                    "example": "ru:Кошка"
+                },
+                "children": {
+                    "type": "array",
+                    "description": "Новые соседи, до 20 лучших по приоритету",
+                    "items": {"$ref": "#/definitions/AnimationChild"}
+                }
+            }
+        },
+        "AnimationChild": {
+            "type": "object",
+            "properties": {
+                "node": {
+                    "type": "string",
+                    "example": "ru:Млекопитающие"
+                },
+                "score": {
+                    "type": "integer",
+                    "description": "Приоритет эвристики, меньше - лучше",
+                    "example": 35
+                }
+            }
+        },
+        "CategorySearchResponse": {
+            "allOf": [
+                {"$ref": "#/definitions/SearchResponse"},
+                {
+                    "type": "object",
+                    "properties": {
+                        "category": {
+                            "type": "string",
+                            "example": "Категория:Физика"
+                        },
+                        "reached": {
+                            "type": "string",
+                            "description": "Статья категории, на которой закончился путь",
+                            "example": "Теория относительности"
+                        },
+                        "members": {
+                            "type": "integer",
+                            "description": "Сколько статей категории было целями (не больше 500)",
+                            "example": 312
+                        }
+                    }
+                }
+            ]
+        },
+        "MeetTransition": {
+            "type": "object",
+            "properties": {
+                "index": {
+                    "type": "integer",
+                    "description": "Индекс в transitions",
+                    "example": 1
+                },
+                "type": {
+                    "type": "string",
+                    "enum": ["link", "interwiki", "category"],
+                    "example": "link"
+                },
+                "direction": {
+                    "type": "string",
+                    "enum": ["forward", "backward"],
+                    "example": "backward"
+                },
+                "verified": {
+                    "type": "string",
+                    "description": "Результат проверки (verify_meet=true)",
+                    "enum": ["forward_link", "backlink", "langlink", "category", "unconfirmed"],
+                    "example": "forward_link"
+                }
+            }
+        },
+        "LinkContext": {
+            "type": "object",
+            "properties": {
+                "anchor": {
+                    "type": "string",
+                    "description": "Видимый текст ссылки",
+                    "example": "кот Шрёдингера"
+                },
+                "sentence": {
+                    "type": "string",
+                    "description": "Предложение со ссылкой, без вики-разметки, до 300 символов",
+                    "example": "Мысленный эксперимент известен как кот Шрёдингера."
+                },
+                "section": {
+                    "type": "string",
+                    "description": "Раздел статьи; нет поля - вводная часть",
+                    "example": "В культуре"
+                }
+            }
+        },
+        "ProgressEvent": {
+            "type": "object",
+            "description": "Состояние поиска после раунда (событие progress у /search/stream)",
+            "properties": {
+                "round": {
+                    "type": "integer",
+                    "example": 3
+                },
+                "frontier_f": {
+                    "type": "integer",
+                    "description": "Узлов в очереди forward",
+                    "example": 512
+                },
+                "frontier_b": {
+                    "type": "integer",
+                    "description": "Узлов в очереди backward",
+                    "example": 430
+                },
+                "requests": {
+                    "type": "integer",
+                    "description": "Запросов к API с начала поиска",
+                    "example": 18
+                },
+                "elapsed_ms": {
+                    "type": "number",
+                    "example": 640.5
+                }
+            }
+        },
+        "RoundInfo": {
+            "type": "object",
+            "description": "Что произошло за раунд (кадр round у /ws/search)",
+            "properties": {
+                "round": {
+                    "type": "integer",
+                    "example": 2
+                },
+                "popped_f": {
+                    "type": "array",
+                    "description": "Узлы, раскрытые forward-фронтом, \"lang:title\"",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": ["ru:Кошка"]
+                },
+                "popped_b": {
+                    "type": "array",
+                    "description": "Узлы, раскрытые backward-фронтом",
+                    "items": {
+                        "type": "string"
                     },
-                    "404": {
-                        "description": "Путь не найден",
-                        "schema": {"$ref": "#/definitions/ErrorResponse"}
-                    }
+                    "example": ["ru:Собака"]
+                },
+                "visited_f": {
+                    "type": "integer",
+                    "description": "Посещено forward-поиском",
+                    "example": 1840
+                },
+                "visited_b": {
+                    "type": "integer",
+                    "description": "Посещено backward-поиском",
+                    "example": 920
+                },
+                "meet": {
+                    "type": "string",
+                    "description": "Узел встречи, если фронты сошлись в этом раунде",
+                    "example": "ru:Млекопитающие"
                 }
             }
-        }
-    },
-    "definitions": {
-        "SearchRequest": {
+        },
+        "WSFrame": {
             "type": "object",
-            "required": ["from", "to"],
+            "description": "Кадр /ws/search",
             "properties": {
-                "from": {
+                "type": {
                     "type": "string",
-                    "description": "Начальная статья",
-                    "example": "Кошка"
+                    "enum": ["round", "result", "error"],
+                    "example": "round"
                 },
-                "to": {
+                "round": {"$ref": "#/definitions/RoundInfo"},
+                "result": {"$ref": "#/definitions/SearchResponse"},
+                "error": {"$ref": "#/definitions/ErrorResponse"}
+            }
+        },
+        "BatchRequest": {
+            "type": "object",
+            "properties": {
+                "pairs": {
+                    "type": "array",
+                    "description": "Поиски; у каждого те же поля, что у POST /search, format всегда json",
+                    "items": {"$ref": "#/definitions/SearchRequest"}
+                }
+            }
+        },
+        "Neighbor": {
+            "type": "object",
+            "properties": {
+                "title": {
                     "type": "string",
-                    "description": "Конечная статья",
-                    "example": "Теория относительности"
+                    "example": "Собака"
                 },
                 "lang": {
                     "type": "string",
-                    "description": "Язык по умолчанию",
-                    "default": "ru",
                     "example": "ru"
+                },
+                "namespace": {
+                    "type": "integer",
+                    "example": 0
+                },
+                "direction": {
+                    "type": "string",
+                    "description": "forward - статья ссылается на соседа, backward - сосед ссылается на статью, interwiki - языковая версия",
+                    "enum": ["forward", "backward", "interwiki"],
+                    "example": "forward"
+                },
+                "score": {
+                    "type": "integer",
+                    "description": "Приоритет эвристики без цели, меньше - лучше",
+                    "example": 85
                 }
             }
         },
-        "SearchResponse": {
+        "NeighborsResponse": {
             "type": "object",
             "properties": {
                 "success": {
                     "type": "boolean",
                     "example": true
                 },
-                "from": {
+                "title": {
                     "type": "string",
+                    "description": "Название статьи после редиректов",
                     "example": "Кошка"
                 },
-                "to": {
+                "lang": {
                     "type": "string",
-                    "example": "Теория относительности"
+                    "example": "ru"
                 },
-                "path_length": {
+                "count": {
                     "type": "integer",
-                    "example": 3
+                    "example": 412
                 },
-                "path": {
+                "neighbors": {
                     "type": "array",
-                    "items": {"$ref": "#/definitions/PathStep"}
-                },
-                "transitions": {
+                    "items": {"$ref": "#/definitions/Neighbor"}
+                }
+            }
+        },
+        "Explored": {
+            "type": "object",
+            "properties": {
+                "edges": {
                     "type": "array",
-                    "items": {"$ref": "#/definitions/Transition"}
+                    "items": {"$ref": "#/definitions/ExploredEdge"}
                 },
-                "stats": {"$ref": "#/definitions/SearchStats"}
+                "truncated": {
+                    "type": "boolean",
+                    "description": "Дерево упёрлось в WIKI_EXPLORED_NODES"
+                }
             }
         },
-        "PathStep": {
+        "ExploredEdge": {
             "type": "object",
             "properties": {
-                "step": {
+                "from": {
+                    "type": "string",
+                    "example": "ru:Кошка"
+                },
+                "to": {
+                    "type": "string",
+                    "example": "ru:Млекопитающие"
+                },
+                "type": {
+                    "type": "string",
+                    "enum": ["link", "interwiki", "category"],
+                    "example": "link"
+                },
+                "dir": {
+                    "type": "string",
+                    "description": "F - forward, B - backward (to ссылается на from)",
+                    "enum": ["F", "B"],
+                    "example": "F"
+                }
+            }
+        },
+        "HeuristicBreakdown": {
+            "type": "object",
+            "properties": {
+                "total": {
                     "type": "integer",
-                    "description": "Номер шага (1-based)",
-                    "example": 1
+                    "description": "Приоритет: base + остальные поля, меньше - лучше",
+                    "example": -10
+                },
+                "base": {
+                    "type": "integer",
+                    "example": 100
+                },
+                "lang_bonus": {
+                    "type": "integer",
+                    "description": "Язык цели (-25) или языка-моста, плюс -10 разделам en и ru",
+                    "example": -35
+                },
+                "word_bonus": {
+                    "type": "integer",
+                    "description": "-40 за каждое слово названия, которое есть в цели",
+                    "example": -40
+                },
+                "substring_bonus": {
+                    "type": "integer",
+                    "description": "-20 за каждое слово цели, входящее в название подстрокой",
+                    "example": -20
+                },
+                "length_adj": {
+                    "type": "integer",
+                    "description": "-5 коротким названиям (до 20 символов), +15 длинным (от 61)",
+                    "example": -5
+                },
+                "list_penalty": {
+                    "type": "integer",
+                    "description": "Штраф спискам и страницам значений (WIKI_LIST_PENALTY)",
+                    "example": 0
+                },
+                "similarity": {
+                    "type": "integer",
+                    "description": "Бонус за похожесть на цель (WIKI_SIMILARITY_WEIGHT)",
+                    "example": -10
+                }
+            }
+        },
+        "ExplainResponse": {
+            "type": "object",
+            "properties": {
+                "success": {
+                    "type": "boolean",
+                    "example": true
                 },
                 "title": {
                     "type": "string",
-                    "description": "Название статьи",
-                    "example": "Кошка"
+                    "example": "Домашняя кошка"
                 },
                 "lang": {
                     "type": "string",
-                    "description": "Язык статьи",
                     "example": "ru"
                 },
+                "dir": {
+                    "type": "string",
+                    "enum": ["F", "B"],
+                    "example": "F"
+                },
+                "target": {
+                    "type": "string",
+                    "example": "Кошка"
+                },
+                "target_lang": {
+                    "type": "string",
+                    "example": "ru"
+                },
+                "breakdown": {"$ref": "#/definitions/HeuristicBreakdown"}
+            }
+        },
+        "PathGap": {
+            "type": "object",
+            "description": "Непройденный участок частичного пути: от последней статьи path до первой статьи tail (или to)",
+            "properties": {
+                "from": {
+                    "type": "string",
+                    "example": "Млекопитающие"
+                },
+                "from_lang": {
+                    "type": "string",
+                    "example": "ru"
+                },
+                "to": {
+                    "type": "string",
+                    "example": "Физика"
+                },
+                "to_lang": {
+                    "type": "string",
+                    "example": "ru"
+                }
+            }
+        },
+        "TitleSuggestion": {
+            "type": "object",
+            "properties": {
+                "title": {
+                    "type": "string",
+                    "example": "Кошка"
+                },
                 "url": {
                     "type": "string",
-                    "description": "URL статьи в Wikipedia",
                     "example": "https://ru.wikipedia.org/wiki/Кошка"
+                }
+            }
+        },
+        "SuggestResponse": {
+            "type": "object",
+            "properties": {
+                "success": {
+                    "type": "boolean",
+                    "example": true
                 },
-                "full_name": {
+                "query": {
                     "type": "string",
-                    "description": "Полное имя (lang:title)",
-                    "example": "ru:Кошка"
+                    "example": "Кош"
+                },
+                "lang": {
+                    "type": "string",
+                    "example": "ru"
+                },
+                "suggestions": {
+                    "type": "array",
+                    "items": {"$ref": "#/definitions/TitleSuggestion"}
                 }
             }
         },
-        "Transition": {
+        "SearchCompareRequest": {
             "type": "object",
             "properties": {
                 "from": {
                     "type": "string",
-                    "description": "Исходная статья",
                     "example": "Кошка"
                 },
                 "to": {
                     "type": "string",
-                    "description": "Целевая статья",
-                    "example": "Квантовая механика"
+                    "example": "Теория относительности"
                 },
-                "type": {
+                "lang": {
                     "type": "string",
-                    "description": "Тип перехода (link или interwiki)",
-                    "enum": ["link", "interwiki"],
-                    "example": "link"
+                    "example": "ru"
                 },
-                "description": {
-                    "type": "string",
-                    "description": "Описание как найти ссылку",
-                    "example": "Найти 'Квантовая механика' в статье 'Кошка'"
+                "a": {
+                    "description": "Конфигурация a - поля POST /search, без from, to и lang; по умолчанию настройки по умолчанию",
+                    "$ref": "#/definitions/SearchRequest"
                 },
-                "check_url": {
-                    "type": "string",
-                    "description": "URL для проверки перехода",
-                    "example": "https://ru.wikipedia.org/wiki/Кошка"
+                "b": {
+                    "description": "Конфигурация b; по умолчанию mode=monolingual",
+                    "$ref": "#/definitions/SearchRequest"
                 }
             }
         },
-        "SearchStats": {
+        "SearchCompareResponse": {
             "type": "object",
             "properties": {
-                "duration": {
+                "success": {
+                    "type": "boolean",
+                    "example": true
+                },
+                "from": {
                     "type": "string",
-                    "description": "Время поиска (human readable)",
-                    "example": "823.45ms"
+                    "example": "Кошка"
                 },
-                "duration_ms": {
-                    "type": "number",
-                    "description": "Время поиска в миллисекундах",
-                    "example": 823.45
+                "to": {
+                    "type": "string",
+                    "example": "Теория относительности"
+                },
+                "a": {
+                    "description": "SearchResponse, если путь найден (в том числе частичный), иначе ErrorResponse",
+                    "$ref": "#/definitions/SearchResponse"
+                },
+                "b": {
+                    "description": "То же для конфигурации b",
+                    "$ref": "#/definitions/SearchResponse"
+                },
+                "diff": {
+                    "description": "b минус a; null, если хотя бы одна конфигурация не вернула путь",
+                    "$ref": "#/definitions/SearchDiff"
+                }
+            }
+        },
+        "SearchDiff": {
+            "type": "object",
+            "properties": {
+                "path_length": {
+                    "type": "integer",
+                    "example": -1,
+                    "description": "Разница длин путей; отрицательная - путь b короче"
                 },
                 "request_count": {
                     "type": "integer",
-                    "description": "Количество запросов к Wikipedia API",
                     "example": 12
+                },
+                "duration_ms": {
+                    "type": "number",
+                    "example": -140.5
+                },
+                "same_path": {
+                    "type": "boolean",
+                    "example": false,
+                    "description": "Пути совпадают статья в статью"
+                }
+            }
+        },
+        "RandomResponse": {
+            "type": "object",
+            "properties": {
+                "success": {
+                    "type": "boolean",
+                    "example": true
+                },
+                "lang": {
+                    "type": "string",
+                    "example": "ru"
+                },
+                "from": {
+                    "type": "string",
+                    "example": "Кошка",
+                    "description": "Случайная начальная статья"
+                },
+                "to": {
+                    "type": "string",
+                    "example": "Теория относительности",
+                    "description": "Случайная конечная статья"
+                },
+                "result": {
+                    "description": "SearchResponse, если путь найден (в том числе частичный), иначе ErrorResponse",
+                    "$ref": "#/definitions/SearchResponse"
                 }
             }
         },
@@ -286,8 +2430,14 @@ const docTemplate = `{
                 "code": {
                     "type": "string",
                     "description": "Код ошибки",
-                    "enum": ["INVALID_REQUEST", "MISSING_PARAMS", "PATH_NOT_FOUND"],
+                    "enum": ["INVALID_REQUEST", "MISSING_PARAMS", "PATH_NOT_FOUND", "SEARCH_CANCELLED", "STORE_ERROR", "FORBIDDEN_PATH_NOT_FOUND", "SEARCH_TIMEOUT", "UPSTREAM_ERROR", "INTERNAL_ERROR", "INVALID_RANK_BY", "UNKNOWN_LANG", "LANGUAGE_LIMIT_PATH_NOT_FOUND", "ARTICLE_NOT_FOUND", "CATEGORY_EMPTY", "WEBSOCKET_REQUIRED", "DEPTH_EXCEEDED", "INVALID_NAMESPACES", "INVALID_MODE", "START_NOT_FOUND", "END_NOT_FOUND", "BATCH_TOO_LARGE", "INVALID_DIR", "CROSS_LANG_CONFLICT", "SHUTTING_DOWN", "INVALID_EXCLUDE"],
                     "example": "PATH_NOT_FOUND"
+                },
+                "debug": {"$ref": "#/definitions/DebugInfo"},
+                "cached": {
+                    "type": "boolean",
+                    "description": "Тот же поиск недавно не удался, ответ из кеша неудач (WIKI_NEGATIVE_CACHE_TTL_MS), запросов к Wikipedia не было",
+                    "example": false
                 }
             }
         }
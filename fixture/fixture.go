@@ -0,0 +1,121 @@
+// Package fixture описывает снимок графа ссылок, увиденного поиском:
+// для каждой раскрытой статьи - её соседи в нужном направлении.
+//
+// Снимок пишется в режиме capture (API и CLI) и позволяет воспроизвести
+// тот же поиск офлайн, не обращаясь к Wikipedia.
+package fixture
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Version - версия схемы снимка; Load отклоняет другие версии
+const Version = 1
+
+// LangLink - interwiki ссылка статьи
+type LangLink struct {
+	Lang  string `json:"lang"`
+	Title string `json:"title"`
+}
+
+// Entry - соседи одной статьи в одном направлении
+type Entry struct {
+	Lang      string     `json:"lang"`
+	Dir       string     `json:"dir"` // "F" - links, "B" - linkshere
+	Title     string     `json:"title"`
+	Links     []string   `json:"links"`
+	LangLinks []LangLink `json:"langlinks,omitempty"`
+}
+
+// Key - ключ записи, совпадает для одинаковых (lang, dir, title)
+func (e Entry) Key() string {
+	return strings.ToLower(e.Lang + ":" + e.Dir + ":" + e.Title)
+}
+
+// Capture - снимок целиком
+type Capture struct {
+	Version   int     `json:"version"`
+	Truncated bool    `json:"truncated,omitempty"` // лимит записей был достигнут
+	Entries   []Entry `json:"entries"`
+}
+
+// Recorder собирает записи из параллельных fetch. Нулевой *Recorder
+// ничего не записывает, так что вызывающему коду не нужны проверки.
+type Recorder struct {
+	mu        sync.Mutex
+	limit     int
+	entries   map[string]Entry
+	truncated bool
+}
+
+// NewRecorder создаёт Recorder; limit <= 0 - без ограничения
+func NewRecorder(limit int) *Recorder {
+	return &Recorder{limit: limit, entries: make(map[string]Entry)}
+}
+
+// Record сохраняет соседей статьи; повторная запись той же статьи заменяет прежнюю
+func (r *Recorder) Record(e Entry) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := e.Key()
+	if _, ok := r.entries[key]; !ok && r.limit > 0 && len(r.entries) >= r.limit {
+		r.truncated = true
+		return
+	}
+	r.entries[key] = e
+}
+
+// Capture возвращает снимок с записями, отсортированными по ключу
+func (r *Recorder) Capture() Capture {
+	c := Capture{Version: Version}
+	if r == nil {
+		return c
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c.Truncated = r.truncated
+	c.Entries = make([]Entry, 0, len(r.entries))
+	for _, e := range r.entries {
+		c.Entries = append(c.Entries, e)
+	}
+	sort.Slice(c.Entries, func(i, j int) bool { return c.Entries[i].Key() < c.Entries[j].Key() })
+	return c
+}
+
+// Save пишет снимок в файл
+func Save(path string, c Capture) error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// Load читает снимок из файла и проверяет версию схемы и записи
+func Load(path string) (Capture, error) {
+	var c Capture
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return c, err
+	}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return c, fmt.Errorf("%s: %w", path, err)
+	}
+	if c.Version != Version {
+		return c, fmt.Errorf("%s: версия снимка %d, поддерживается %d", path, c.Version, Version)
+	}
+	for i, e := range c.Entries {
+		if e.Lang == "" || e.Title == "" || (e.Dir != "F" && e.Dir != "B") {
+			return c, fmt.Errorf("%s: запись %d: нужны lang, title и dir F или B", path, i)
+		}
+	}
+	return c, nil
+}
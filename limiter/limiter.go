@@ -0,0 +1,148 @@
+// Package limiter - предел запросов к Wikipedia, общий для всех поисков
+// сервера, и самоограничение по квотам X-RateLimit, которые сообщают хосты.
+//
+// Каждый поиск по отдельности укладывается в свои бюджеты, но вместе
+// они легко превышают лимиты Wikimedia: Limiter ограничивает их суммарно,
+// а Delay растягивает запросы, когда квота хоста на исходе.
+package limiter
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Limiter - не больше заданного числа запросов одновременно и в секунду
+type Limiter struct {
+	slots    chan struct{} // занятые слоты; nil - без предела одновременных
+	interval time.Duration // минимальный промежуток между запросами, 0 - без предела частоты
+
+	mu   sync.Mutex
+	next time.Time // когда можно отправить следующий запрос
+}
+
+// New - не больше inflight запросов одновременно и не больше perSecond
+// в секунду; 0 - без соответствующего предела
+func New(inflight, perSecond int) *Limiter {
+	l := &Limiter{}
+	if inflight > 0 {
+		l.slots = make(chan struct{}, inflight)
+	}
+	if perSecond > 0 {
+		l.interval = time.Second / time.Duration(perSecond)
+	}
+	return l
+}
+
+// Acquire ждёт очереди на запрос и свободного слота. release освобождает
+// слот - вызывать, когда ответ прочитан. Ошибка - контекст закончился
+// раньше; тогда слот не занят.
+func (l *Limiter) Acquire(ctx context.Context) (release func(), err error) {
+	if l.interval > 0 {
+		// Запросы идут равномерно: каждый бронирует свою отметку времени
+		l.mu.Lock()
+		now := time.Now()
+		at := l.next
+		if at.Before(now) {
+			at = now
+		}
+		l.next = at.Add(l.interval)
+		l.mu.Unlock()
+
+		if wait := at.Sub(now); wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, ctx.Err()
+			case <-timer.C:
+			}
+		}
+	}
+
+	if l.slots == nil {
+		return func() {}, nil
+	}
+	select {
+	case l.slots <- struct{}{}:
+		return func() { <-l.slots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Quota - квота, которую хост сообщил в заголовках X-RateLimit-*
+type Quota struct {
+	Limit     int       `json:"limit" example:"500"`
+	Remaining int       `json:"remaining" example:"420"`
+	Reset     time.Time `json:"reset"`
+	Updated   time.Time `json:"updated"`
+}
+
+var (
+	quotasMu sync.Mutex
+	quotas   = make(map[string]Quota) // хост API -> последняя увиденная квота
+)
+
+// Observe запоминает квоту хоста из заголовков ответа. Reset бывает
+// и числом секунд до сброса, и Unix-временем - различаем по величине.
+func Observe(host string, h http.Header) {
+	limit, errL := strconv.Atoi(h.Get("X-RateLimit-Limit"))
+	remaining, errR := strconv.Atoi(h.Get("X-RateLimit-Remaining"))
+	if errL != nil || errR != nil || limit <= 0 {
+		return
+	}
+	now := time.Now()
+	q := Quota{Limit: limit, Remaining: remaining, Updated: now}
+	if reset, err := strconv.ParseInt(h.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+		if reset > 1e9 {
+			q.Reset = time.Unix(reset, 0)
+		} else {
+			q.Reset = now.Add(time.Duration(reset) * time.Second)
+		}
+	}
+
+	quotasMu.Lock()
+	quotas[host] = q
+	quotasMu.Unlock()
+}
+
+// maxDelay - дольше одного запроса не ждём: у поиска свой таймаут
+const maxDelay = 2 * time.Second
+
+// Delay - пауза перед запросом к хосту. Пока остаток квоты выше
+// percent% лимита, не ждём; ниже - растягиваем оставшиеся запросы до сброса,
+// не дожидаясь 429. percent <= 0 - без самоограничения.
+func Delay(host string, percent int, now time.Time) time.Duration {
+	if percent <= 0 {
+		return 0
+	}
+	quotasMu.Lock()
+	q, ok := quotas[host]
+	quotasMu.Unlock()
+	if !ok || q.Reset.IsZero() || !now.Before(q.Reset) || q.Remaining*100 > q.Limit*percent {
+		return 0
+	}
+
+	delay := q.Reset.Sub(now)
+	if q.Remaining > 0 {
+		delay /= time.Duration(q.Remaining + 1)
+	}
+	if delay > maxDelay {
+		delay = maxDelay
+	}
+	return delay
+}
+
+// Quotas - копия квот всех хостов, например для /health
+func Quotas() map[string]Quota {
+	quotasMu.Lock()
+	defer quotasMu.Unlock()
+	out := make(map[string]Quota, len(quotas))
+	for host, q := range quotas {
+		out[host] = q
+	}
+	return out
+}
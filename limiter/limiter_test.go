@@ -0,0 +1,78 @@
+package limiter
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestAcquireInflight(t *testing.T) {
+	l := New(1, 0)
+	release, err := l.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Слот занят: второй запрос ждёт, пока не кончится контекст
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := l.Acquire(ctx); err == nil {
+		t.Fatal("второй слот выдан при inflight=1")
+	}
+
+	release()
+	release2, err := l.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("слот не освободился: %v", err)
+	}
+	release2()
+}
+
+func TestAcquireRate(t *testing.T) {
+	l := New(0, 50)
+	t0 := time.Now()
+	for i := 0; i < 3; i++ {
+		release, err := l.Acquire(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		release()
+	}
+	// Три запроса при 50 в секунду - не раньше двух интервалов по 20мс
+	if elapsed := time.Since(t0); elapsed < 40*time.Millisecond {
+		t.Errorf("три запроса за %v, want >= 40ms", elapsed)
+	}
+}
+
+func TestDelay(t *testing.T) {
+	h := http.Header{}
+	h.Set("X-RateLimit-Limit", "100")
+	h.Set("X-RateLimit-Remaining", "9")
+	h.Set("X-RateLimit-Reset", "10")
+	Observe("low.example", h)
+	h.Set("X-RateLimit-Remaining", "90")
+	Observe("high.example", h)
+	now := time.Now()
+
+	tests := []struct {
+		host    string
+		percent int
+		min     time.Duration
+		max     time.Duration
+	}{
+		// 9 запросов на 10 секунд - по секунде на запрос
+		{"low.example", 10, 900 * time.Millisecond, time.Second},
+		{"low.example", 0, 0, 0},
+		{"high.example", 10, 0, 0},
+		{"unknown.example", 10, 0, 0},
+	}
+	for _, tt := range tests {
+		if got := Delay(tt.host, tt.percent, now); got < tt.min || got > tt.max {
+			t.Errorf("Delay(%s, %d) = %v, want %v..%v", tt.host, tt.percent, got, tt.min, tt.max)
+		}
+	}
+	if q := Quotas()["low.example"]; q.Limit != 100 || q.Remaining != 9 {
+		t.Errorf("квота %+v", q)
+	}
+}
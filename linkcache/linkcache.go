@@ -0,0 +1,185 @@
+// Package linkcache - кеш ссылок статей, общий для всех поисков API.
+//
+// Кеш разбит по языкам: у каждого языка свой LRU со своим лимитом, чтобы
+// en и ru при мультиязычном обходе не вытесняли остальные языки. Ключ
+// внутри языка строит вызывающий: кеш не знает, как нормализуются названия.
+package linkcache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+type entry[V any] struct {
+	key     string
+	value   V
+	expires time.Time // нулевое - не устаревает
+}
+
+// shard - LRU кеш одного языка
+type shard struct {
+	mu        sync.Mutex
+	capacity  int
+	order     *list.List // от недавно использованных к давно
+	items     map[string]*list.Element
+	hits      int64
+	misses    int64
+	evictions int64
+	expired   int64
+}
+
+// Cache - LRU по языкам с общим сроком жизни записей. Методы безопасны
+// для nil кеша: nil - кеш отключён.
+type Cache[V any] struct {
+	mu          sync.Mutex
+	shards      map[string]*shard
+	defaultSize int
+	langSizes   map[string]int
+	ttl         time.Duration // срок жизни записи, 0 - без срока
+}
+
+// Stats - статистика кеша для одного языка
+type Stats struct {
+	Size      int     `json:"size" example:"1520"`
+	Capacity  int     `json:"capacity" example:"10000"`
+	Hits      int64   `json:"hits" example:"340"`
+	Misses    int64   `json:"misses" example:"1600"`
+	Evictions int64   `json:"evictions" example:"0"`
+	Expired   int64   `json:"expired" example:"12"`
+	HitRate   float64 `json:"hit_rate" example:"0.175"`
+}
+
+// New - кеш на defaultSize записей на язык; langSizes задаёт лимиты
+// отдельных языков (0 - язык не кешируется), ttl - срок жизни записи
+func New[V any](defaultSize int, langSizes map[string]int, ttl time.Duration) *Cache[V] {
+	return &Cache[V]{
+		shards:      make(map[string]*shard),
+		defaultSize: defaultSize,
+		langSizes:   langSizes,
+		ttl:         ttl,
+	}
+}
+
+func (c *Cache[V]) shard(lang string) *shard {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	sh, ok := c.shards[lang]
+	if !ok {
+		capacity, ok := c.langSizes[lang]
+		if !ok {
+			capacity = c.defaultSize
+		}
+		sh = &shard{
+			capacity: capacity,
+			order:    list.New(),
+			items:    make(map[string]*list.Element),
+		}
+		c.shards[lang] = sh
+	}
+	return sh
+}
+
+// Get возвращает запись key языка lang, если она есть и не устарела
+func (c *Cache[V]) Get(lang, key string) (V, bool) {
+	var zero V
+	if c == nil {
+		return zero, false
+	}
+	sh := c.shard(lang)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	el, ok := sh.items[key]
+	if !ok {
+		sh.misses++
+		return zero, false
+	}
+	e := el.Value.(*entry[V])
+	if !e.expires.IsZero() && time.Now().After(e.expires) {
+		sh.order.Remove(el)
+		delete(sh.items, e.key)
+		sh.expired++
+		sh.misses++
+		return zero, false
+	}
+	sh.hits++
+	sh.order.MoveToFront(el)
+	return e.value, true
+}
+
+// Set сохраняет запись на срок ttl, вытесняя давно неиспользуемые
+// записи того же языка
+func (c *Cache[V]) Set(lang, key string, value V) {
+	if c == nil {
+		return
+	}
+	var expires time.Time
+	if c.ttl > 0 {
+		expires = time.Now().Add(c.ttl)
+	}
+	c.set(lang, key, value, expires)
+}
+
+// SetPermanent - Set без срока жизни: запись уходит только вытеснением
+func (c *Cache[V]) SetPermanent(lang, key string, value V) {
+	if c == nil {
+		return
+	}
+	c.set(lang, key, value, time.Time{})
+}
+
+func (c *Cache[V]) set(lang, key string, value V, expires time.Time) {
+	sh := c.shard(lang)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	if sh.capacity == 0 {
+		return
+	}
+	if el, ok := sh.items[key]; ok {
+		e := el.Value.(*entry[V])
+		e.value, e.expires = value, expires
+		sh.order.MoveToFront(el)
+		return
+	}
+	sh.items[key] = sh.order.PushFront(&entry[V]{key: key, value: value, expires: expires})
+	for sh.order.Len() > sh.capacity {
+		oldest := sh.order.Back()
+		sh.order.Remove(oldest)
+		delete(sh.items, oldest.Value.(*entry[V]).key)
+		sh.evictions++
+	}
+}
+
+// Stats возвращает статистику по каждому языку
+func (c *Cache[V]) Stats() map[string]Stats {
+	stats := make(map[string]Stats)
+	if c == nil {
+		return stats
+	}
+	c.mu.Lock()
+	shards := make(map[string]*shard, len(c.shards))
+	for lang, sh := range c.shards {
+		shards[lang] = sh
+	}
+	c.mu.Unlock()
+
+	for lang, sh := range shards {
+		sh.mu.Lock()
+		st := Stats{
+			Size:      sh.order.Len(),
+			Capacity:  sh.capacity,
+			Hits:      sh.hits,
+			Misses:    sh.misses,
+			Evictions: sh.evictions,
+			Expired:   sh.expired,
+		}
+		sh.mu.Unlock()
+		if total := st.Hits + st.Misses; total > 0 {
+			st.HitRate = float64(st.Hits) / float64(total)
+		}
+		stats[lang] = st
+	}
+	return stats
+}
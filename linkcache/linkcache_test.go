@@ -0,0 +1,65 @@
+package linkcache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCacheLRU(t *testing.T) {
+	c := New[string](2, map[string]int{"uk": 0}, 0)
+	c.Set("en", "a", "A")
+	c.Set("en", "b", "B")
+	c.Get("en", "a") // b теперь давнее a
+	c.Set("en", "c", "C")
+	c.Set("ru", "x", "X") // у ru свой лимит: en не вытесняется
+	c.Set("uk", "y", "Y") // лимит 0 - язык не кешируется
+
+	tests := []struct {
+		lang, key string
+		want      bool
+	}{
+		{"en", "a", true},
+		{"en", "b", false},
+		{"en", "c", true},
+		{"ru", "x", true},
+		{"uk", "y", false},
+	}
+	for _, tt := range tests {
+		if _, ok := c.Get(tt.lang, tt.key); ok != tt.want {
+			t.Errorf("Get(%s, %s) = %v, want %v", tt.lang, tt.key, ok, tt.want)
+		}
+	}
+
+	en := c.Stats()["en"]
+	if en.Size != 2 || en.Capacity != 2 || en.Evictions != 1 || en.Hits != 3 || en.Misses != 1 {
+		t.Errorf("статистика en %+v", en)
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+	c := New[int](10, nil, 20*time.Millisecond)
+	c.Set("en", "short", 1)
+	c.SetPermanent("en", "bootstrap", 2)
+	time.Sleep(30 * time.Millisecond)
+
+	if _, ok := c.Get("en", "short"); ok {
+		t.Error("запись пережила ttl")
+	}
+	if v, ok := c.Get("en", "bootstrap"); !ok || v != 2 {
+		t.Errorf("постоянная запись: %d, %v", v, ok)
+	}
+	if st := c.Stats()["en"]; st.Expired != 1 {
+		t.Errorf("expired = %d, want 1", st.Expired)
+	}
+}
+
+func TestCacheNil(t *testing.T) {
+	var c *Cache[string]
+	c.Set("en", "a", "A")
+	if _, ok := c.Get("en", "a"); ok {
+		t.Error("nil кеш вернул запись")
+	}
+	if len(c.Stats()) != 0 {
+		t.Error("статистика nil кеша не пуста")
+	}
+}
@@ -4,43 +4,78 @@ import (
 	"container/heap"
 	"context"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
+	"math/rand"
 	"net/http"
 	"net/url"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"golang.org/x/net/http2"
+
+	"wikiracer/fixture"
+	"wikiracer/render"
+	"wikiracer/tokenize"
+	"wikiracer/wikis"
 )
 
-var wikiAPIs = map[string]string{
-	"en": "https://en.wikipedia.org/w/api.php",
-	"ru": "https://ru.wikipedia.org/w/api.php",
-	"de": "https://de.wikipedia.org/w/api.php",
-	"fr": "https://fr.wikipedia.org/w/api.php",
-	"es": "https://es.wikipedia.org/w/api.php",
-	"it": "https://it.wikipedia.org/w/api.php",
-	"pt": "https://pt.wikipedia.org/w/api.php",
-	"uk": "https://uk.wikipedia.org/w/api.php",
+// wikiAPIs - URL API включённых разделов, заполняет setLangs
+var wikiAPIs = map[string]string{}
+
+// detectLangs - включённые разделы в порядке detectPriority
+var detectLangs []string
+
+// setLangs включает разделы langs: по ним идёт поиск, в них ищутся
+// interwiki и определяется язык статей
+func setLangs(langs []string) {
+	wikiAPIs = make(map[string]string, len(langs))
+	for _, l := range langs {
+		wikiAPIs[l] = wikis.APIURL(l)
+	}
+	detectLangs = wikis.Sort(langs, detectPriority)
 }
 
+// defaultUserAgent - описательный User-Agent с контактом, как просит
+// политика Wikimedia API: без него запросы могут резать первыми
+const defaultUserAgent = "WikiRacer/5.0 (https://github.com/Prost0Name/wiki-search)"
+
+// defaultMaxLag - maxlag запросов в секундах: при отставании реплик сильнее
+// API отвечает ошибкой maxlag, и запрос повторяется позже
+const defaultMaxLag = 5
+
 type WikiNode struct {
 	Title    string
 	Lang     string
 	Priority int
 	Index    int
+	key      string // Key(), запомненный при Push: Less не нормализует название на каждом сравнении
 }
 
 func (n WikiNode) String() string { return n.Lang + ":" + n.Title }
-func (n WikiNode) Key() string    { return strings.ToLower(n.Lang + ":" + n.Title) }
+func (n WikiNode) Key() string    { return n.Lang + ":" + normalizeTitle(n.Title) }
 
 type PriorityQueue []*WikiNode
 
-func (pq PriorityQueue) Len() int           { return len(pq) }
-func (pq PriorityQueue) Less(i, j int) bool { return pq[i].Priority < pq[j].Priority }
+func (pq PriorityQueue) Len() int { return len(pq) }
+
+// Less при равном приоритете сравнивает ключи: порядок раскрытия не
+// зависит от порядка вставки, и одинаковый ввод даёт одинаковый путь
+func (pq PriorityQueue) Less(i, j int) bool {
+	if pq[i].Priority != pq[j].Priority {
+		return pq[i].Priority < pq[j].Priority
+	}
+	return pq[i].key < pq[j].key
+}
 func (pq PriorityQueue) Swap(i, j int) {
 	pq[i], pq[j] = pq[j], pq[i]
 	pq[i].Index = i
@@ -50,6 +85,7 @@ func (pq *PriorityQueue) Push(x interface{}) {
 	n := len(*pq)
 	item := x.(*WikiNode)
 	item.Index = n
+	item.key = item.Key()
 	*pq = append(*pq, item)
 }
 func (pq *PriorityQueue) Pop() interface{} {
@@ -81,15 +117,63 @@ func (l *LangLink) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+type WikiPage struct {
+	Title     string                   `json:"title"`
+	Links     []struct{ Title string } `json:"links"`
+	LinksHere []struct{ Title string } `json:"linkshere"`
+	LangLinks []LangLink               `json:"langlinks"`
+	Aliases   []string                 `json:"-"` // названия, под которыми статью запросили (редиректы)
+}
+
+// TitleMapping - элемент query.normalized / query.redirects
+type TitleMapping struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
 type WikiResponse struct {
 	Query struct {
-		Pages map[string]struct {
-			Title     string                   `json:"title"`
-			Links     []struct{ Title string } `json:"links"`
-			LinksHere []struct{ Title string } `json:"linkshere"`
-			LangLinks []LangLink               `json:"langlinks"`
-		} `json:"pages"`
+		Pages      map[string]WikiPage `json:"pages"`
+		Normalized []TitleMapping      `json:"normalized"`
+		Redirects  []TitleMapping      `json:"redirects"`
 	} `json:"query"`
+	Continue map[string]string `json:"continue"` // plcontinue/lhcontinue/llcontinue, если ответ обрезан
+}
+
+// attachAliases записывает в страницы ответа названия, под которыми их
+// запросили: нормализация и редиректы, в том числе цепочкой
+func (r *WikiResponse) attachAliases() {
+	resolve := make(map[string]string)
+	for _, m := range r.Query.Normalized {
+		resolve[m.From] = m.To
+	}
+	for _, m := range r.Query.Redirects {
+		resolve[m.From] = m.To
+	}
+	if len(resolve) == 0 {
+		return
+	}
+	byTitle := make(map[string]string, len(r.Query.Pages))
+	for id, page := range r.Query.Pages {
+		if !strings.HasPrefix(id, "-") {
+			byTitle[page.Title] = id
+		}
+	}
+	for from := range resolve {
+		to := from
+		for i := 0; i < len(resolve); i++ {
+			next, ok := resolve[to]
+			if !ok {
+				break
+			}
+			to = next
+		}
+		if id, ok := byTitle[to]; ok {
+			page := r.Query.Pages[id]
+			page.Aliases = append(page.Aliases, from)
+			r.Query.Pages[id] = page
+		}
+	}
 }
 
 type Searcher struct {
@@ -106,53 +190,287 @@ type Searcher struct {
 	startLang   string
 	startWords  map[string]bool // слова из Start (для backward)
 	targetWords map[string]bool // слова из End (для forward)
+
+	detectTimeout time.Duration     // окно на запросы detectLang
+	continuePages int               // максимум страниц продолжения на один fetch
+	retries       int               // попыток на запрос (doWithRetry)
+	reqTimeout    time.Duration     // предел одной попытки запроса, 0 - только бюджет поиска
+	userAgent     string            // User-Agent и Api-User-Agent запросов
+	maxLag        int               // maxlag запросов в секундах, 0 - не передавать
+	namespaces    string            // plnamespace/lhnamespace, несколько - через "|"
+	capture       *fixture.Recorder // снимок графа ссылок (-capture)
+	progress      chan<- Progress   // события хода поиска, nil - не отправлять
+	missing       []error           // концы, которых нет ни в одном разделе
+
+	// HeuristicFunc - приоритет узла в очереди (меньше - раньше), по
+	// умолчанию s.heuristic; вызывается из параллельных fetch
+	HeuristicFunc func(title, lang, dir string) int
 }
 
-func NewSearcher(startLang, startTitle, targetLang, targetTitle string) *Searcher {
-	tr := &http.Transport{
-		MaxIdleConns:        1000,
-		MaxIdleConnsPerHost: 200,
-		MaxConnsPerHost:     0,
-		IdleConnTimeout:     30 * time.Second,
-		DisableCompression:  false,
-		ForceAttemptHTTP2:   true,
+// Progress - состояние поиска в начале очередного раунда
+type Progress struct {
+	Round     int
+	FrontierF int // узлов в очереди forward
+	FrontierB int // узлов в очереди backward
+	Requests  int64
+	Elapsed   time.Duration
+}
+
+// emitProgress отправляет событие, не блокируя поиск: если читатель
+// не успевает, событие пропускается
+func (s *Searcher) emitProgress(p Progress) {
+	if s.progress == nil {
+		return
+	}
+	select {
+	case s.progress <- p:
+	default:
 	}
-	http2.ConfigureTransport(tr)
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+var (
+	clientOnce sync.Once
+	httpClient *http.Client
+)
 
-	// Слова из Start (для backward эвристики)
-	startWords := make(map[string]bool)
-	for _, word := range strings.Fields(strings.ToLower(startTitle)) {
-		if len(word) > 2 {
-			startWords[word] = true
+// sharedClient возвращает общий HTTP/2 клиент, чтобы прогрев и поиск
+// использовали одни и те же соединения
+func sharedClient() *http.Client {
+	clientOnce.Do(func() {
+		tr := &http.Transport{
+			MaxIdleConns:        1000,
+			MaxIdleConnsPerHost: 200,
+			MaxConnsPerHost:     0,
+			IdleConnTimeout:     30 * time.Second,
+			DisableCompression:  false,
+			ForceAttemptHTTP2:   true,
 		}
-	}
+		http2.ConfigureTransport(tr)
+		// Без Client.Timeout: предел задаёт контекст каждой попытки
+		// (reqTimeout), и повтор получает новый
+		httpClient = &http.Client{Transport: tr}
+	})
+	return httpClient
+}
 
-	// Слова из End (для forward эвристики)
-	targetWords := make(map[string]bool)
-	for _, word := range strings.Fields(strings.ToLower(targetTitle)) {
-		if len(word) > 2 {
-			targetWords[word] = true
+// warmupConnections делает лёгкий meta=siteinfo запрос к каждой вики,
+// чтобы TCP + TLS + HTTP/2 handshake не попадал во время поиска. Каждый
+// запрос ограничен timeout, 0 - без предела
+func warmupConnections(langs []string, userAgent string, maxLag int, timeout time.Duration) {
+	var wg sync.WaitGroup
+	for _, lang := range langs {
+		apiURL, ok := wikiAPIs[lang]
+		if !ok {
+			continue
 		}
+		wg.Add(1)
+		go func(u string) {
+			defer wg.Done()
+			params := url.Values{
+				"action": {"query"},
+				"format": {"json"},
+				"meta":   {"siteinfo"},
+			}
+			setMaxLag(params, maxLag)
+			ctx, cancel := context.Background(), context.CancelFunc(func() {})
+			if timeout > 0 {
+				ctx, cancel = context.WithTimeout(ctx, timeout)
+			}
+			defer cancel()
+			req, _ := http.NewRequestWithContext(ctx, "GET", u+"?"+params.Encode(), nil)
+			setUserAgent(req, userAgent)
+			if resp, err := sharedClient().Do(req); err == nil {
+				resp.Body.Close()
+			}
+		}(apiURL)
+	}
+	wg.Wait()
+}
+
+// defaultTimeout - бюджет поиска, если timeout не задан
+const defaultTimeout = 10 * time.Second
+
+// defaultRequestTimeout - предел одной попытки запроса к API. Отдельно от
+// бюджета поиска: медленный ответ хаба повторяется, а не съедает весь поиск
+const defaultRequestTimeout = 800 * time.Millisecond
+
+// NewSearcher создаёт поиск с бюджетом timeout; 0 - defaultTimeout
+func NewSearcher(startLang, startTitle, targetLang, targetTitle string, timeout time.Duration) *Searcher {
+	if timeout <= 0 {
+		timeout = defaultTimeout
 	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 
-	return &Searcher{
-		client:      &http.Client{Transport: tr, Timeout: 800 * time.Millisecond},
+	// Слова из Start (для backward эвристики) и из End (для forward)
+	startWords := tokenize.Set(stripNamespace(startTitle))
+	targetWords := tokenize.Set(stripNamespace(targetTitle))
+
+	s := &Searcher{
+		client:      sharedClient(),
 		ctx:         ctx,
 		cancel:      cancel,
 		startLang:   startLang,
 		startWords:  startWords,
 		targetLang:  targetLang,
 		targetWords: targetWords,
+
+		detectTimeout: 500 * time.Millisecond,
+		continuePages: 5,
+		retries:       3,
+		reqTimeout:    defaultRequestTimeout,
+		userAgent:     defaultUserAgent,
+		maxLag:        defaultMaxLag,
+		namespaces:    "0",
+	}
+	s.HeuristicFunc = s.heuristic
+	return s
+}
+
+// monolingualPenalty - штраф узлу не на языке start в режиме -mode
+// monolingual: больше любого бонуса heuristic
+const monolingualPenalty = 200
+
+// monolingualHeuristic - heuristic, но узлы не на языке start сильно
+// штрафуются: путь уходит в interwiki, только если без этого никак
+func (s *Searcher) monolingualHeuristic(title, lang, dir string) int {
+	score := s.heuristic(title, lang, dir)
+	if lang != s.startLang {
+		score += monolingualPenalty
+	}
+	return score
+}
+
+// parseNamespaces проверяет пространства имён через "|", как
+// plnamespace: "0|14" - статьи и категории
+func parseNamespaces(v string) error {
+	for _, ns := range strings.Split(v, "|") {
+		if n, err := strconv.Atoi(ns); err != nil || n < 0 {
+			return fmt.Errorf("неверное пространство имён %q", ns)
+		}
 	}
+	return nil
+}
+
+// stripNamespace убирает префикс пространства имён ("Категория:Кошки" -
+// "Кошки"), чтобы эвристика сравнивала слова самого названия. Префикс -
+// до двоеточия без пробела после: "Звёздные войны: Эпизод IV" не трогается.
+func stripNamespace(title string) string {
+	if i := strings.IndexByte(title, ':'); i > 0 && i+1 < len(title) && title[i+1] != ' ' {
+		return title[i+1:]
+	}
+	return title
+}
+
+// setUserAgent ставит User-Agent и дублирует его в Api-User-Agent
+func setUserAgent(req *http.Request, ua string) {
+	req.Header.Set("User-Agent", ua)
+	req.Header.Set("Api-User-Agent", ua)
+}
+
+// setMaxLag добавляет maxlag к параметрам запроса; 0 - не добавлять
+func setMaxLag(params url.Values, maxLag int) {
+	if maxLag > 0 {
+		params.Set("maxlag", strconv.Itoa(maxLag))
+	}
+}
+
+// retryBackoff - пауза перед вторым запросом, дальше удваивается
+const retryBackoff = 200 * time.Millisecond
+
+// doWithRetry выполняет запрос, повторяя его при сетевой ошибке, ответах
+// 429/503 и ошибке maxlag - всего не больше attempts попыток. Пауза берётся из Retry-After,
+// иначе растёт экспоненциально со случайной добавкой, чтобы параллельные
+// fetch не повторяли хором. Повторы прекращаются с отменой контекста запроса.
+// Каждая попытка ограничена reqTimeout, пока её тело не закрыто.
+func (s *Searcher) doWithRetry(req *http.Request, attempts int) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		resp, err := s.doAttempt(req)
+		if attempt >= attempts || (err == nil && !retryable(resp)) {
+			return resp, err
+		}
+
+		delay := backoffDelay(retryBackoff, attempt)
+		if err == nil {
+			if d, ok := retryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+				delay = d
+			}
+			resp.Body.Close()
+		}
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// doAttempt выполняет одну попытку запроса с пределом reqTimeout
+func (s *Searcher) doAttempt(req *http.Request) (*http.Response, error) {
+	if s.reqTimeout <= 0 {
+		return s.client.Do(req)
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), s.reqTimeout)
+	resp, err := s.client.Do(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// cancelBody отменяет контекст попытки, когда тело ответа закрыто
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// retryable - ответы, после которых есть смысл повторить запрос: лимит
+// частоты, перегрузка (в том числе 503 с X-Database-Lag) и maxlag, который
+// MediaWiki отдаёт с кодом 200 и заголовком MediaWiki-API-Error
+func retryable(resp *http.Response) bool {
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return true
+	}
+	return resp.Header.Get("MediaWiki-API-Error") == "maxlag"
+}
+
+// backoffDelay - пауза перед повтором attempt: base * 2^(attempt-1)
+// плюс случайная добавка до половины этой величины
+func backoffDelay(base time.Duration, attempt int) time.Duration {
+	d := base << (attempt - 1)
+	return d + time.Duration(rand.Int63n(int64(d)/2+1))
+}
+
+// retryAfter разбирает Retry-After: секунды или HTTP-дата
+func retryAfter(v string, now time.Time) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := t.Sub(now); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
 }
 
 // Быстрая эвристика (меньше = лучше)
 // dir="F" -> ищем слова из End, dir="B" -> ищем слова из Start
 func (s *Searcher) heuristic(title, lang, dir string) int {
 	score := 100
-	titleLower := strings.ToLower(title)
+	titleLower := strings.ToLower(stripNamespace(title))
 
 	// Выбираем целевые слова в зависимости от направления
 	var words map[string]bool
@@ -171,8 +489,8 @@ func (s *Searcher) heuristic(title, lang, dir string) int {
 	}
 
 	// Бонус за общие слова с целью (усилен)
-	for _, word := range strings.Fields(titleLower) {
-		if len(word) > 2 && words[word] {
+	for _, word := range tokenize.Words(titleLower) {
+		if words[word] {
 			score -= 40
 		}
 	}
@@ -189,48 +507,105 @@ func (s *Searcher) heuristic(title, lang, dir string) int {
 		score -= 10
 	}
 
-	// Бонус за короткие названия (часто хабы)
-	if len(title) < 20 {
+	// Бонус за короткие названия (часто хабы); длина в символах, а не
+	// байтах - иначе кириллица "длиннее" латиницы вдвое
+	titleLen := utf8.RuneCountInString(title)
+	if titleLen < 20 {
 		score -= 5
 	}
 
 	// Штраф за очень длинные названия
-	if len(title) > 60 {
+	if titleLen > 60 {
 		score += 15
 	}
 
 	return score
 }
 
-// Быстрое определение языка по символам
+// normalizeTitle приводит название к виду MediaWiki: "_" - пробел,
+// пробелы по краям убираются, внутренние схлопываются, первая буква
+// заглавная. Регистр остальных букв значим: "new_york" - это "New york",
+// а не "New York", "eBay" - "EBay"
+func normalizeTitle(title string) string {
+	title = strings.Join(strings.Fields(strings.ReplaceAll(title, "_", " ")), " ")
+	r, size := utf8.DecodeRuneInString(title)
+	if up := unicode.ToUpper(r); up != r {
+		title = string(up) + title[size:]
+	}
+	return title
+}
+
+// langLetters - буквы, по которым узнаётся язык названия. Проверяются
+// по порядку: ї - украинский, хотя рядом может быть и общая кириллица;
+// ã в "São Paulo" - португальский, хотя ç бывает и во французском
+var langLetters = []struct{ lang, letters string }{
+	{"uk", "іїєґ"},
+	{"ru", "ыэъё"},
+	{"de", "ßäöü"},
+	{"pt", "ãõ"},
+	{"es", "ñ¿¡"},
+	{"fr", "çœæéèêëîïûùÿâô"},
+	{"it", "ìòà"},
+	{"es", "áíóú"},
+}
+
+// guessLang - быстрое определение языка по символам: характерные буквы
+// языков, затем письменность (любая кириллица - ru), "en" - если
+// ничего не подошло
 func guessLang(title string) string {
-	for _, r := range title {
-		if r >= 'А' && r <= 'я' || r == 'ё' || r == 'Ё' {
+	lower := strings.ToLower(title)
+	for _, l := range langLetters {
+		if strings.ContainsAny(lower, l.letters) {
+			return l.lang
+		}
+	}
+	for _, r := range lower {
+		if unicode.Is(unicode.Cyrillic, r) {
 			return "ru"
 		}
 	}
 	return "en"
 }
 
-// detectLang проверяет на каких языках существует статья
-func (s *Searcher) detectLang(title string) (string, string) {
-	// Быстрая проверка по символам - только ru и en
-	guessed := guessLang(title)
-	langs := []string{guessed}
-	if guessed == "ru" {
-		langs = append(langs, "en")
-	} else {
-		langs = append(langs, "ru")
+// detectPriority - порядок языков при определении: статья, найденная
+// в нескольких разделах, достаётся первому
+var detectPriority = []string{"ru", "en", "uk", "de", "fr", "es", "it", "pt"}
+
+// cyrillicLangs - разделы на кириллице
+var cyrillicLangs = map[string]bool{"ru": true, "uk": true}
+
+// detectOrder - языки, в которых detectLang ищет статью: угаданный
+// по символам, затем разделы той же письменности, затем остальные.
+// Только включённые разделы.
+func detectOrder(guessed string) []string {
+	var langs []string
+	if _, ok := wikiAPIs[guessed]; ok {
+		langs = append(langs, guessed)
 	}
+	for _, sameScript := range []bool{true, false} {
+		for _, l := range detectLangs {
+			if l != guessed && (cyrillicLangs[l] == cyrillicLangs[guessed]) == sameScript {
+				langs = append(langs, l)
+			}
+		}
+	}
+	return langs
+}
+
+// detectLang проверяет на каких языках существует статья. missing - все
+// языки успели ответить, и статьи нет ни в одном
+func (s *Searcher) detectLang(title string) (lang, realTitle string, missing bool) {
+	langs := detectOrder(guessLang(title))
 
 	type result struct {
-		lang       string
-		realTitle  string
-		found      bool
+		lang      string
+		realTitle string
+		found     bool
+		answered  bool // Wikipedia ответила (а не ошибка запроса)
 	}
 
 	results := make(chan result, len(langs))
-	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), s.detectTimeout)
 	defer cancel()
 
 	for _, lang := range langs {
@@ -242,17 +617,18 @@ func (s *Searcher) detectLang(title string) (string, string) {
 				"titles":    {title},
 				"redirects": {"1"},
 			}
+			setMaxLag(params, s.maxLag)
 
 			req, err := http.NewRequestWithContext(ctx, "GET", apiURL+"?"+params.Encode(), nil)
 			if err != nil {
-				results <- result{l, "", false}
+				results <- result{l, "", false, false}
 				return
 			}
-			req.Header.Set("User-Agent", "WikiRacer/5.0")
+			setUserAgent(req, s.userAgent)
 
-			resp, err := s.client.Do(req)
+			resp, err := s.doWithRetry(req, s.retries)
 			if err != nil {
-				results <- result{l, "", false}
+				results <- result{l, "", false, false}
 				return
 			}
 			defer resp.Body.Close()
@@ -266,40 +642,55 @@ func (s *Searcher) detectLang(title string) (string, string) {
 				} `json:"query"`
 			}
 			if json.NewDecoder(resp.Body).Decode(&data) != nil {
-				results <- result{l, "", false}
+				results <- result{l, "", false, false}
 				return
 			}
 
 			for id, page := range data.Query.Pages {
 				if id != "-1" && !page.Missing {
-					results <- result{l, page.Title, true}
+					results <- result{l, page.Title, true, true}
 					return
 				}
 			}
-			results <- result{l, "", false}
+			results <- result{l, "", false, true}
 		}(lang)
 	}
 
-	// Собираем результаты
+	// Собираем результаты; по истечении окна работаем с тем, что успело прийти
 	foundLangs := make(map[string]string)
+	done := make(map[string]bool)
+	absent := 0
+collect:
 	for i := 0; i < len(langs); i++ {
 		select {
 		case r := <-results:
+			done[r.lang] = true
 			if r.found {
 				foundLangs[r.lang] = r.realTitle
+			} else if r.answered {
+				absent++
 			}
 		case <-ctx.Done():
-			break
+			break collect
+		}
+		// Все языки приоритетнее найденного ответили - остальных не ждём
+		for _, lang := range langs {
+			if !done[lang] {
+				break
+			}
+			if realTitle, ok := foundLangs[lang]; ok {
+				return lang, realTitle, false
+			}
 		}
 	}
 
 	// Возвращаем первый найденный по приоритету
 	for _, lang := range langs {
 		if realTitle, ok := foundLangs[lang]; ok {
-			return lang, realTitle
+			return lang, realTitle, false
 		}
 	}
-	return "", ""
+	return "", "", len(langs) > 0 && absent == len(langs)
 }
 
 func (s *Searcher) fetch(titles []string, lang, dir string) []*WikiNode {
@@ -319,7 +710,7 @@ func (s *Searcher) fetch(titles []string, lang, dir string) []*WikiNode {
 			"titles":      {strings.Join(titles, "|")},
 			"pllimit":     {"max"},
 			"lllimit":     {"max"},
-			"plnamespace": {"0"},
+			"plnamespace": {s.namespaces},
 			"redirects":   {"1"},
 		}
 	} else {
@@ -331,23 +722,13 @@ func (s *Searcher) fetch(titles []string, lang, dir string) []*WikiNode {
 			"titles":      {strings.Join(titles, "|")},
 			"lhlimit":     {"max"},
 			"lllimit":     {"max"},
-			"lhnamespace": {"0"},
+			"lhnamespace": {s.namespaces},
 			"redirects":   {"1"},
 		}
 	}
 
-	req, _ := http.NewRequestWithContext(s.ctx, "GET", apiURL+"?"+params.Encode(), nil)
-	req.Header.Set("User-Agent", "WikiRacer/5.0")
-
-	resp, err := s.client.Do(req)
-	if err != nil {
-		return nil
-	}
-	defer resp.Body.Close()
-	s.reqCount.Add(1)
-
-	var data WikiResponse
-	if json.NewDecoder(resp.Body).Decode(&data) != nil {
+	pages, ok := s.queryAll(apiURL, params)
+	if !ok {
 		return nil
 	}
 
@@ -360,7 +741,7 @@ func (s *Searcher) fetch(titles []string, lang, dir string) []*WikiNode {
 
 	var newNodes []*WikiNode
 
-	for _, page := range data.Query.Pages {
+	for _, page := range pages {
 		if s.found.Load() {
 			return nil
 		}
@@ -374,17 +755,50 @@ func (s *Searcher) fetch(titles []string, lang, dir string) []*WikiNode {
 			links = page.LinksHere
 		}
 
+		if s.capture != nil {
+			e := fixture.Entry{Lang: lang, Dir: dir, Title: page.Title, Links: make([]string, len(links))}
+			for i, link := range links {
+				e.Links[i] = link.Title
+			}
+			for _, ll := range page.LangLinks {
+				e.LangLinks = append(e.LangLinks, fixture.LangLink{Lang: ll.Lang, Title: ll.Title})
+			}
+			s.capture.Record(e)
+		}
+
+		// Статья запрошена под другим названием (редирект USA → United
+		// States): канонический ключ получает того же родителя, иначе
+		// цепочка рвётся, а встреча на этой статье не распознаётся
+		for _, alias := range page.Aliases {
+			aliasKey := WikiNode{Title: alias, Lang: lang}.Key()
+			p, ok := own.Load(aliasKey)
+			if !ok || aliasKey == parent.Key() {
+				continue
+			}
+			if _, loaded := own.LoadOrStore(parent.Key(), p); loaded {
+				break
+			}
+			if _, exists := other.Load(parent.Key()); exists && s.found.CompareAndSwap(false, true) {
+				s.resultMu.Lock()
+				s.result = s.buildPath(parent)
+				s.resultMu.Unlock()
+				s.cancel()
+				return nil
+			}
+			break
+		}
+
 		for _, link := range links {
 			child := &WikiNode{
 				Title:    link.Title,
 				Lang:     lang,
-				Priority: s.heuristic(link.Title, lang, dir),
+				Priority: s.HeuristicFunc(link.Title, lang, dir),
 			}
 			key := child.Key()
 
 			if _, exists := other.Load(key); exists {
 				if s.found.CompareAndSwap(false, true) {
-					own.Store(key, &parent)
+					own.LoadOrStore(key, &parent)
 					s.resultMu.Lock()
 					s.result = s.buildPath(*child)
 					s.resultMu.Unlock()
@@ -405,13 +819,13 @@ func (s *Searcher) fetch(titles []string, lang, dir string) []*WikiNode {
 			child := &WikiNode{
 				Title:    ll.Title,
 				Lang:     ll.Lang,
-				Priority: s.heuristic(ll.Title, ll.Lang, dir),
+				Priority: s.HeuristicFunc(ll.Title, ll.Lang, dir),
 			}
 			key := child.Key()
 
 			if _, exists := other.Load(key); exists {
 				if s.found.CompareAndSwap(false, true) {
-					own.Store(key, &parent)
+					own.LoadOrStore(key, &parent)
 					s.resultMu.Lock()
 					s.result = s.buildPath(*child)
 					s.resultMu.Unlock()
@@ -429,6 +843,77 @@ func (s *Searcher) fetch(titles []string, lang, dir string) []*WikiNode {
 	return newNodes
 }
 
+// query выполняет один запрос к API
+func (s *Searcher) query(apiURL string, params url.Values) (*WikiResponse, error) {
+	setMaxLag(params, s.maxLag)
+	req, _ := http.NewRequestWithContext(s.ctx, "GET", apiURL+"?"+params.Encode(), nil)
+	setUserAgent(req, s.userAgent)
+
+	resp, err := s.doWithRetry(req, s.retries)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	s.reqCount.Add(1)
+
+	var data WikiResponse
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// queryAll выполняет запрос и идёт по continue-токенам, пока ответ обрезан,
+// но не больше continuePages дополнительных страниц. Без этого у хабов
+// вроде "United States" видны только первые 500 ссылок. Ссылки со всех
+// страниц сливаются по pageid. false - если не удался даже первый запрос.
+func (s *Searcher) queryAll(apiURL string, params url.Values) (map[string]WikiPage, bool) {
+	data, err := s.query(apiURL, params)
+	if err != nil {
+		return nil, false
+	}
+	// Редиректы приходят в первом ответе, продолжения их только повторяют
+	data.attachAliases()
+	pages := data.Query.Pages
+	if pages == nil {
+		pages = make(map[string]WikiPage)
+	}
+	cont := data.Continue
+
+	for i := 0; i < s.continuePages && cont != nil && !s.found.Load(); i++ {
+		next := url.Values{}
+		for k, v := range params {
+			next[k] = v
+		}
+		for k, v := range cont {
+			next.Set(k, v)
+		}
+
+		data, err := s.query(apiURL, next)
+		if err != nil {
+			// Уже полученные страницы остаются полезными
+			break
+		}
+		for id, page := range data.Query.Pages {
+			merged, ok := pages[id]
+			if !ok {
+				pages[id] = page
+				continue
+			}
+			merged.Links = append(merged.Links, page.Links...)
+			merged.LinksHere = append(merged.LinksHere, page.LinksHere...)
+			merged.LangLinks = append(merged.LangLinks, page.LangLinks...)
+			pages[id] = merged
+		}
+		cont = data.Continue
+	}
+
+	return pages, true
+}
+
+// buildPath собирает путь от start через meet к end: meet - последний
+// узел forward-половины, backward-половина начинается с его родителя
+// в visitedB.
 func (s *Searcher) buildPath(meet WikiNode) []WikiNode {
 	var fwd []WikiNode
 	curr := meet
@@ -445,8 +930,10 @@ func (s *Searcher) buildPath(meet WikiNode) []WikiNode {
 		curr = *p
 	}
 
+	// У end в visitedB лежит (*WikiNode)(nil) - interface не nil,
+	// проверять надо сам указатель
 	var bwd []WikiNode
-	if val, ok := s.visitedB.Load(meet.Key()); ok && val != nil {
+	if val, ok := s.visitedB.Load(meet.Key()); ok && val.(*WikiNode) != nil {
 		curr = *val.(*WikiNode)
 		for {
 			bwd = append(bwd, curr)
@@ -462,47 +949,138 @@ func (s *Searcher) buildPath(meet WikiNode) []WikiNode {
 		}
 	}
 
-	return append(fwd, bwd...)
+	return joinPath(fwd, bwd)
+}
+
+// joinPath склеивает половины пути так, чтобы каждая статья была в нём
+// один раз. Параллельные fetch могут успеть записать узел в обе половины,
+// тогда путь проходит через него дважды - петля между вхождениями
+// вырезается, путь только короче.
+func joinPath(fwd, bwd []WikiNode) []WikiNode {
+	path := make([]WikiNode, 0, len(fwd)+len(bwd))
+	pos := make(map[string]int, len(fwd)+len(bwd))
+	for _, half := range [][]WikiNode{fwd, bwd} {
+		for _, n := range half {
+			if i, ok := pos[n.Key()]; ok {
+				for _, cut := range path[i+1:] {
+					delete(pos, cut.Key())
+				}
+				path = path[:i+1]
+				continue
+			}
+			pos[n.Key()] = len(path)
+			path = append(path, n)
+		}
+	}
+	return path
+}
+
+// sortedLangs - языки раунда по алфавиту: обход map случаен, а от порядка
+// запуска батчей зависит, чья встреча найдётся первой
+func sortedLangs(byLang map[string][]string) []string {
+	langs := make([]string, 0, len(byLang))
+	for lang := range byLang {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// roundBudgets делит раунд между фронтами: каждому по perSide узлов, а
+// то, что фронт потратить не может (в очереди меньше), достаётся другому.
+// Опустевший фронт отдаёт весь бюджет - поиск идёт однонаправленным.
+func roundBudgets(lenF, lenB, perSide int) (budgetF, budgetB int) {
+	budgetF, budgetB = perSide, perSide
+	if lenB < perSide {
+		budgetF += perSide - lenB
+	}
+	if lenF < perSide {
+		budgetB += perSide - lenF
+	}
+	return budgetF, budgetB
+}
+
+// Ошибки Search: пустой путь всегда объясняется одной из них
+var (
+	ErrStartMissing = errors.New("начальной статьи нет")
+	ErrEndMissing   = errors.New("конечной статьи нет")
+	ErrTimeout      = errors.New("время поиска истекло")
+	ErrNoPath       = errors.New("путь не найден")
+)
+
+// Search ищет путь от start к end; пустой путь объясняет ошибка
+func (s *Searcher) Search(start, end, lang string) ([]WikiNode, error) {
+	path := s.search(start, end, lang)
+	if len(path) > 0 {
+		return path, nil
+	}
+	if len(s.missing) > 0 {
+		return nil, errors.Join(s.missing...)
+	}
+	if errors.Is(s.ctx.Err(), context.DeadlineExceeded) {
+		return nil, ErrTimeout
+	}
+	return nil, ErrNoPath
 }
 
-func (s *Searcher) Search(start, end, lang string) []WikiNode {
+func (s *Searcher) search(start, end, lang string) []WikiNode {
+	start, end = normalizeTitle(start), normalizeTitle(end)
+
 	// Автоопределение языка для start и end
 	startLang, startTitle := lang, start
 	endLang, endTitle := lang, end
 
 	var wgDetect sync.WaitGroup
+	var startOK, endOK, startMissing, endMissing bool
 	wgDetect.Add(2)
 
 	go func() {
 		defer wgDetect.Done()
-		if l, t := s.detectLang(start); l != "" {
+		var l, t string
+		if l, t, startMissing = s.detectLang(start); l != "" {
 			startLang, startTitle = l, t
+			startOK = true
 		}
 	}()
 	go func() {
 		defer wgDetect.Done()
-		if l, t := s.detectLang(end); l != "" {
+		var l, t string
+		if l, t, endMissing = s.detectLang(end); l != "" {
 			endLang, endTitle = l, t
+			endOK = true
 		}
 	}()
 	wgDetect.Wait()
 
-	// Обновляем целевые слова после определения языка
-	s.startLang = startLang
-	s.targetLang = endLang
-	s.startWords = make(map[string]bool)
-	for _, word := range strings.Fields(strings.ToLower(startTitle)) {
-		if len(word) > 2 {
-			s.startWords[word] = true
-		}
+	// Статьи нет ни в одном разделе - искать нечего, не тратим таймаут
+	if startMissing {
+		s.missing = append(s.missing, fmt.Errorf("%w: '%s' (проверены %s)",
+			ErrStartMissing, start, strings.Join(detectOrder(guessLang(start)), ", ")))
+	}
+	if endMissing {
+		s.missing = append(s.missing, fmt.Errorf("%w: '%s' (проверены %s)",
+			ErrEndMissing, end, strings.Join(detectOrder(guessLang(end)), ", ")))
+	}
+	if len(s.missing) > 0 {
+		return nil
 	}
-	s.targetWords = make(map[string]bool)
-	for _, word := range strings.Fields(strings.ToLower(endTitle)) {
-		if len(word) > 2 {
-			s.targetWords[word] = true
+
+	// Если определился только один конец, второй берём по символам,
+	// а не языком по умолчанию
+	if startOK != endOK {
+		if !startOK {
+			startLang = guessLang(start)
+		} else {
+			endLang = guessLang(end)
 		}
 	}
 
+	// Обновляем целевые слова после определения языка
+	s.startLang = startLang
+	s.targetLang = endLang
+	s.startWords = tokenize.Set(stripNamespace(startTitle))
+	s.targetWords = tokenize.Set(stripNamespace(endTitle))
+
 	startNode := &WikiNode{Title: startTitle, Lang: startLang, Priority: 0}
 	endNode := &WikiNode{Title: endTitle, Lang: endLang, Priority: 0}
 
@@ -556,6 +1134,8 @@ func (s *Searcher) Search(start, end, lang string) []WikiNode {
 	const batchSize = 50
 	const maxPerRound = 250
 
+	t0 := time.Now()
+	round := 0
 	for !s.found.Load() && (pqF.Len() > 0 || pqB.Len() > 0) {
 		select {
 		case <-s.ctx.Done():
@@ -563,20 +1143,31 @@ func (s *Searcher) Search(start, end, lang string) []WikiNode {
 		default:
 		}
 
+		round++
+		s.emitProgress(Progress{
+			Round:     round,
+			FrontierF: pqF.Len(),
+			FrontierB: pqB.Len(),
+			Requests:  s.reqCount.Load(),
+			Elapsed:   time.Since(t0),
+		})
+
 		var wg sync.WaitGroup
 		var muF, muB sync.Mutex
 		var nextF, nextB []*WikiNode
+		budgetF, budgetB := roundBudgets(pqF.Len(), pqB.Len(), maxPerRound)
 
 		// Forward
 		byLangF := make(map[string][]string)
 		count := 0
-		for pqF.Len() > 0 && count < maxPerRound {
+		for pqF.Len() > 0 && count < budgetF {
 			node := heap.Pop(pqF).(*WikiNode)
 			byLangF[node.Lang] = append(byLangF[node.Lang], node.Title)
 			count++
 		}
 
-		for lang, titles := range byLangF {
+		for _, lang := range sortedLangs(byLangF) {
+			titles := byLangF[lang]
 			for i := 0; i < len(titles); i += batchSize {
 				end := i + batchSize
 				if end > len(titles) {
@@ -599,13 +1190,14 @@ func (s *Searcher) Search(start, end, lang string) []WikiNode {
 		// Backward
 		byLangB := make(map[string][]string)
 		count = 0
-		for pqB.Len() > 0 && count < maxPerRound {
+		for pqB.Len() > 0 && count < budgetB {
 			node := heap.Pop(pqB).(*WikiNode)
 			byLangB[node.Lang] = append(byLangB[node.Lang], node.Title)
 			count++
 		}
 
-		for lang, titles := range byLangB {
+		for _, lang := range sortedLangs(byLangB) {
+			titles := byLangB[lang]
 			for i := 0; i < len(titles); i += batchSize {
 				end := i + batchSize
 				if end > len(titles) {
@@ -645,58 +1237,129 @@ func (s *Searcher) Search(start, end, lang string) []WikiNode {
 }
 
 func main() {
+	warmup := flag.Bool("warmup", false, "прогреть соединения к Wikipedia до запуска таймера")
+	detectTimeout := flag.Duration("detect-timeout", 500*time.Millisecond, "окно на определение языка статей")
+	capturePath := flag.String("capture", "", "записать снимок графа ссылок в файл для офлайн-воспроизведения")
+	continuePages := flag.Int("continue-pages", 5, "максимум страниц продолжения (continue) на один запрос ссылок")
+	retries := flag.Int("retries", 3, "попыток на запрос при сетевой ошибке и ответах 429/503")
+	requestTimeout := flag.Duration("request-timeout", defaultRequestTimeout, "предел одной попытки запроса к API, отдельно от -timeout; 0 - без предела")
+	timeout := flag.Duration("timeout", defaultTimeout, "бюджет всего поиска")
+	userAgent := flag.String("user-agent", defaultUserAgent, "User-Agent запросов; Wikimedia просит контакт (URL или e-mail)")
+	maxLag := flag.Int("maxlag", defaultMaxLag, "maxlag запросов в секундах, 0 - не передавать")
+	showProgress := flag.Bool("progress", true, "печатать ход поиска в stderr")
+	mode := flag.String("mode", "default", "эвристика: default или monolingual - путь по возможности без смены языка")
+	namespaces := flag.String("namespaces", "0", "пространства имён пути через |, например 0|14 - ещё и категории")
+	langList := flag.String("langs", os.Getenv("WIKI_LANGS"), "разделы Wikipedia через запятую, по умолчанию "+strings.Join(wikis.Default, ","))
+	flag.Parse()
+	args := flag.Args()
+
+	langs, err := wikis.Parse(*langList)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "❌ -langs:", err)
+		os.Exit(2)
+	}
+	setLangs(langs)
+	if *mode != "default" && *mode != "monolingual" {
+		fmt.Fprintf(os.Stderr, "❌ -mode: неизвестный режим %q, допустимо: default, monolingual\n", *mode)
+		os.Exit(2)
+	}
+	if err := parseNamespaces(*namespaces); err != nil {
+		fmt.Fprintln(os.Stderr, "❌ -namespaces:", err)
+		os.Exit(2)
+	}
+
 	start, end, lang := "Ибраево", "Arch Linux", "ru"
-	if len(os.Args) >= 3 {
-		start, end = os.Args[1], os.Args[2]
+	if len(args) >= 2 {
+		start, end = normalizeTitle(args[0]), normalizeTitle(args[1])
+	}
+	if len(args) >= 3 {
+		lang = args[2]
+	} else if _, ok := wikiAPIs[lang]; !ok {
+		lang = langs[0]
 	}
-	if len(os.Args) >= 4 {
-		lang = os.Args[3]
+	if _, ok := wikiAPIs[lang]; !ok {
+		fmt.Fprintf(os.Stderr, "❌ Язык %s не включён (-langs %s)\n", lang, strings.Join(langs, ","))
+		os.Exit(2)
+	}
+
+	// Прогреваем языки, которые поиск затронет первыми: явный язык,
+	// угаданные по символам и пару ru/en, которую проверяет detectLang
+	var warmupTime time.Duration
+	if *warmup {
+		langs := []string{lang, guessLang(start), guessLang(end), "ru", "en"}
+		seen := make(map[string]bool)
+		unique := langs[:0]
+		for _, l := range langs {
+			if !seen[l] {
+				seen[l] = true
+				unique = append(unique, l)
+			}
+		}
+		tw := time.Now()
+		warmupConnections(unique, *userAgent, *maxLag, *requestTimeout)
+		warmupTime = time.Since(tw)
 	}
 
 	t0 := time.Now()
-	s := NewSearcher(lang, start, lang, end)
-	path := s.Search(start, end, lang)
+	s := NewSearcher(lang, start, lang, end, *timeout)
+	s.detectTimeout = *detectTimeout
+	s.continuePages = *continuePages
+	s.retries = *retries
+	s.reqTimeout = *requestTimeout
+	s.userAgent = *userAgent
+	s.maxLag = *maxLag
+	s.namespaces = *namespaces
+	if *mode == "monolingual" {
+		s.HeuristicFunc = s.monolingualHeuristic
+	}
+	if *capturePath != "" {
+		s.capture = fixture.NewRecorder(0)
+	}
+
+	// Ход поиска и статистика идут в stderr, в stdout - только результат,
+	// чтобы вывод можно было передать дальше по конвейеру
+	var progressDone chan struct{}
+	if *showProgress {
+		events := make(chan Progress, 16)
+		progressDone = make(chan struct{})
+		s.progress = events
+		go func() {
+			defer close(progressDone)
+			for p := range events {
+				fmt.Fprintf(os.Stderr, "⏳ раунд %d | F %d | B %d | 📊 %d req | %v\n",
+					p.Round, p.FrontierF, p.FrontierB, p.Requests, p.Elapsed.Round(time.Millisecond))
+			}
+		}()
+	}
 
-	fmt.Printf("\n⏱️ %v | 📊 %d req\n", time.Since(t0), s.reqCount.Load())
+	path, err := s.Search(start, end, lang)
 
-	if len(path) > 0 {
-		fmt.Printf("🎯 Путь (%d):\n", len(path))
-		for i, n := range path {
-			fmt.Printf("  %d. %s\n", i+1, n)
-		}
+	if s.progress != nil {
+		close(s.progress)
+		<-progressDone
+	}
+
+	if *warmup {
+		fmt.Fprintf(os.Stderr, "\n🔥 прогрев %v | ⏱️ %v | 📊 %d req\n", warmupTime, time.Since(t0), s.reqCount.Load())
+	} else {
+		fmt.Fprintf(os.Stderr, "\n⏱️ %v | 📊 %d req\n", time.Since(t0), s.reqCount.Load())
+	}
 
-		// Выводим ссылки для проверки
-		fmt.Println("\n🔗 Проверка (ссылки на статьи):")
+	if err == nil {
+		steps := make([]render.Step, len(path))
 		for i, n := range path {
-			wikiURL := fmt.Sprintf("https://%s.wikipedia.org/wiki/%s",
-				n.Lang, strings.ReplaceAll(url.PathEscape(n.Title), "%2F", "/"))
-			fmt.Printf("  %d. %s\n", i+1, wikiURL)
-		}
-
-		// Показываем переходы
-		fmt.Println("\n📍 Переходы (где искать ссылку):")
-		fmt.Println("   ⚠️  Путь найден bidirectional поиском - некоторые связи могут быть backlinks")
-		fmt.Println("   (т.е. B ссылается на A, а не A на B)")
-		fmt.Println()
-		for i := 0; i < len(path)-1; i++ {
-			from := path[i]
-			to := path[i+1]
-			if from.Lang == to.Lang {
-				// Внутри одного языка - ссылка в статье
-				fmt.Printf("  %s → %s\n", from.Title, to.Title)
-				// Проверить в обе стороны
-				fmt.Printf("     Проверить: https://%s.wikipedia.org/wiki/%s\n",
-					from.Lang, strings.ReplaceAll(url.PathEscape(from.Title), "%2F", "/"))
-				fmt.Printf("     Или обратно: https://%s.wikipedia.org/wiki/%s\n",
-					to.Lang, strings.ReplaceAll(url.PathEscape(to.Title), "%2F", "/"))
-			} else {
-				// Interwiki переход
-				fmt.Printf("  %s → %s (interwiki)\n", from, to)
-				fmt.Printf("     Слева 'Languages': https://%s.wikipedia.org/wiki/%s\n",
-					from.Lang, strings.ReplaceAll(url.PathEscape(from.Title), "%2F", "/"))
-			}
+			steps[i] = render.Step{Title: n.Title, Lang: n.Lang}
 		}
+		fmt.Print(render.Recipe(steps, "ru"))
 	} else {
-		fmt.Println("❌ Не найден")
+		fmt.Println("❌", err)
+	}
+
+	if *capturePath != "" {
+		if err := fixture.Save(*capturePath, s.capture.Capture()); err != nil {
+			fmt.Fprintln(os.Stderr, "❌ Не удалось записать снимок:", err)
+		} else {
+			fmt.Fprintf(os.Stderr, "\n💾 Снимок графа: %s\n", *capturePath)
+		}
 	}
 }
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// slowWiki отвечает пустым query после паузы delay и считает запросы
+func slowWiki(delay time.Duration, requests *atomic.Int64) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"query":{"pages":{"1":{"pageid":1,"title":"Кошка"}}}}`))
+	}))
+}
+
+func TestQueryRequestTimeout(t *testing.T) {
+	tests := []struct {
+		name     string
+		delay    time.Duration
+		timeout  time.Duration
+		wantErr  bool
+		requests int64
+	}{
+		{"медленный ответ в пределах попытки", 300 * time.Millisecond, time.Second, false, 1},
+		{"без предела попытки", 300 * time.Millisecond, 0, false, 1},
+		// Каждая попытка упирается в предел и повторяется
+		{"ответ дольше предела", 300 * time.Millisecond, 50 * time.Millisecond, true, 2},
+	}
+	for _, tt := range tests {
+		var requests atomic.Int64
+		srv := slowWiki(tt.delay, &requests)
+
+		s := NewSearcher("ru", "Кошка", "ru", "Космос", 5*time.Second)
+		s.client = srv.Client()
+		s.reqTimeout = tt.timeout
+		s.retries = 2
+		data, err := s.query(srv.URL, url.Values{"action": {"query"}})
+		s.cancel()
+		srv.Close()
+
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: ошибка %v, want ошибка %v", tt.name, err, tt.wantErr)
+		}
+		if err == nil && len(data.Query.Pages) != 1 {
+			t.Errorf("%s: статей в ответе %d, want 1", tt.name, len(data.Query.Pages))
+		}
+		if got := requests.Load(); got != tt.requests {
+			t.Errorf("%s: запросов %d, want %d", tt.name, got, tt.requests)
+		}
+	}
+}
+
+func TestNormalizeTitle(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"Кошка", "Кошка"},
+		{" Кошка", "Кошка"},
+		{"Кошка \t", "Кошка"},
+		{"Сырная  корка", "Сырная корка"},
+		{" сырная_ корка ", "Сырная корка"},
+	}
+	for _, tt := range tests {
+		if got := normalizeTitle(tt.in); got != tt.want {
+			t.Errorf("normalizeTitle(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+// continueWiki отдаёт ссылки Hub двумя страницами: вторая - по plcontinue
+func continueWiki() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page := map[string]interface{}{"pageid": 7, "ns": 0, "title": "Hub"}
+		resp := map[string]interface{}{}
+		if r.URL.Query().Get("plcontinue") == "" {
+			page["links"] = []map[string]interface{}{{"ns": 0, "title": "First"}}
+			resp["continue"] = map[string]string{"plcontinue": "7|0|Second", "continue": "||"}
+		} else {
+			page["links"] = []map[string]interface{}{{"ns": 0, "title": "Second"}}
+		}
+		resp["query"] = map[string]interface{}{"pages": map[string]interface{}{"7": page}}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}))
+}
+
+func TestFetchContinue(t *testing.T) {
+	srv := continueWiki()
+	defer srv.Close()
+	oldAPIs := wikiAPIs
+	wikiAPIs = map[string]string{"en": srv.URL}
+	defer func() { wikiAPIs = oldAPIs }()
+
+	tests := []struct {
+		continuePages int
+		want          []string
+	}{
+		{5, []string{"First", "Second"}},
+		{0, []string{"First"}},
+	}
+	for _, tt := range tests {
+		s := NewSearcher("en", "Hub", "en", "Target", 5*time.Second)
+		s.client = srv.Client()
+		s.continuePages = tt.continuePages
+		nodes := s.fetch([]string{"Hub"}, "en", "F")
+		s.cancel()
+
+		var got []string
+		for _, n := range nodes {
+			got = append(got, n.Title)
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("continuePages=%d: узлы %v, want %v", tt.continuePages, got, tt.want)
+		}
+	}
+}
+
+// endlessWiki - граф без конца: у каждой статьи три новые ссылки и три
+// новые обратные; ответ после паузы delay
+func endlessWiki(delay time.Duration) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+		pages := map[string]interface{}{}
+		for i, title := range strings.Split(r.URL.Query().Get("titles"), "|") {
+			var links []map[string]interface{}
+			for j := 1; j <= 3; j++ {
+				links = append(links, map[string]interface{}{"ns": 0, "title": fmt.Sprintf("%s/%d", title, j)})
+			}
+			pages[fmt.Sprint(i+1)] = map[string]interface{}{"pageid": i + 1, "ns": 0, "title": title, "links": links, "linkshere": links}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"query": map[string]interface{}{"pages": pages}})
+	}))
+}
+
+func TestSearchSmallTimeout(t *testing.T) {
+	srv := endlessWiki(20 * time.Millisecond)
+	defer srv.Close()
+	oldAPIs, oldDetect := wikiAPIs, detectLangs
+	wikiAPIs, detectLangs = map[string]string{"en": srv.URL}, []string{"en"}
+	defer func() { wikiAPIs, detectLangs = oldAPIs, oldDetect }()
+
+	for _, timeout := range []time.Duration{100 * time.Millisecond, 300 * time.Millisecond} {
+		s := NewSearcher("en", "Start", "en", "Target", timeout)
+		s.client = srv.Client()
+		started := time.Now()
+		path, err := s.Search("Start", "Target", "en")
+		elapsed := time.Since(started)
+		s.cancel()
+
+		if len(path) != 0 || !errors.Is(err, ErrTimeout) {
+			t.Errorf("timeout=%v: путь %v, ошибка %v, want ErrTimeout", timeout, path, err)
+		}
+		// Бюджет, а не defaultTimeout: ответ сразу после него
+		if elapsed > timeout+time.Second {
+			t.Errorf("timeout=%v: поиск шёл %v", timeout, elapsed)
+		}
+	}
+}
+
+// titlesWiki - раздел, где есть только статьи titles: отвечает на проверку
+// существования, как detectLang её спрашивает
+func titlesWiki(titles ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		title := r.URL.Query().Get("titles")
+		page := map[string]interface{}{"title": title, "missing": true}
+		id := "-1"
+		for _, t := range titles {
+			if t == title {
+				page, id = map[string]interface{}{"pageid": 1, "title": title}, "1"
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"query": map[string]interface{}{"pages": map[string]interface{}{id: page}}})
+	}
+}
+
+func TestDetectLangAllWikis(t *testing.T) {
+	wikis := map[string]http.HandlerFunc{
+		"ru": titlesWiki("Кошка"),
+		"en": titlesWiki("Cat"),
+		"uk": titlesWiki("Київ", "Одеса"),
+		"de": titlesWiki("Straße", "Berlin Hauptbahnhof"),
+		"fr": titlesWiki("Château de Versailles"),
+		"es": titlesWiki(), "it": titlesWiki(), "pt": titlesWiki(),
+	}
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	oldAPIs, oldDetect := wikiAPIs, detectLangs
+	defer func() { wikiAPIs, detectLangs = oldAPIs, oldDetect }()
+	wikiAPIs = map[string]string{}
+	for lang, h := range wikis {
+		mux.Handle("/"+lang+"/", h)
+		wikiAPIs[lang] = srv.URL + "/" + lang + "/api.php"
+	}
+	detectLangs = detectPriority
+
+	tests := []struct{ title, want string }{
+		{"Straße", "de"},
+		// Без умлаутов угадывается en, но статья есть только в de
+		{"Berlin Hauptbahnhof", "de"},
+		{"Київ", "uk"},
+		// Без ї/і/є/ґ угадывается ru, статья - в uk
+		{"Одеса", "uk"},
+		{"Château de Versailles", "fr"},
+	}
+	for _, tt := range tests {
+		s := NewSearcher("en", "Start", "en", "Target", 5*time.Second)
+		s.client = srv.Client()
+		lang, realTitle, missing := s.detectLang(tt.title)
+		s.cancel()
+		if lang != tt.want || realTitle != tt.title || missing {
+			t.Errorf("detectLang(%q) = %s, %q, missing=%v, want %s", tt.title, lang, realTitle, missing, tt.want)
+		}
+	}
+}
+
+func TestHeuristicFuncInjected(t *testing.T) {
+	srv := endlessWiki(0)
+	defer srv.Close()
+	oldAPIs := wikiAPIs
+	wikiAPIs = map[string]string{"en": srv.URL}
+	defer func() { wikiAPIs = oldAPIs }()
+
+	s := NewSearcher("en", "Start", "en", "Target", 5*time.Second)
+	s.client = srv.Client()
+	defer s.cancel()
+	var calls atomic.Int64
+	s.HeuristicFunc = func(title, lang, dir string) int {
+		calls.Add(1)
+		return 7 * len(title)
+	}
+	nodes := s.fetch([]string{"Start"}, "en", "F")
+
+	if len(nodes) != 3 || calls.Load() != 3 {
+		t.Fatalf("узлов %d, вызовов HeuristicFunc %d, want 3 и 3", len(nodes), calls.Load())
+	}
+	for _, n := range nodes {
+		if n.Priority != 7*len(n.Title) {
+			t.Errorf("%s: приоритет %d, want %d от HeuristicFunc", n.Title, n.Priority, 7*len(n.Title))
+		}
+	}
+}
+
+func TestSearchErrors(t *testing.T) {
+	graph := httptest.NewServer(titlesWiki("Island", "Rock"))
+	defer graph.Close()
+	endless := endlessWiki(20 * time.Millisecond)
+	defer endless.Close()
+	oldAPIs, oldDetect := wikiAPIs, detectLangs
+	defer func() { wikiAPIs, detectLangs = oldAPIs, oldDetect }()
+	detectLangs = []string{"en"}
+
+	tests := []struct {
+		name     string
+		srv      *httptest.Server
+		from, to string
+		want     error
+	}{
+		{"нет начала", graph, "Ghost town", "Rock", ErrStartMissing},
+		{"нет конца", graph, "Island", "Ghost town", ErrEndMissing},
+		{"нет пути", graph, "Island", "Rock", ErrNoPath},
+		{"бюджет истёк", endless, "Start", "Target", ErrTimeout},
+	}
+	for _, tt := range tests {
+		wikiAPIs = map[string]string{"en": tt.srv.URL}
+		s := NewSearcher("en", tt.from, "en", tt.to, 300*time.Millisecond)
+		s.client = tt.srv.Client()
+		path, err := s.Search(tt.from, tt.to, "en")
+		s.cancel()
+		if len(path) != 0 || !errors.Is(err, tt.want) {
+			t.Errorf("%s: путь %v, ошибка %v, want %v", tt.name, path, err, tt.want)
+		}
+	}
+}
+
+func TestHeuristicRuneLength(t *testing.T) {
+	// Цель в третьем языке и без общих слов: оценки отличает только длина
+	s := NewSearcher("de", "Anfang", "de", "Ziel", 5*time.Second)
+	defer s.cancel()
+	tests := []struct{ en, ru string }{
+		{"Saint-Peterburg", "Санкт-Петербург"},
+		{strings.Repeat("Cat ", 11) + "x", strings.Repeat("Кот ", 11) + "ы"},
+		{strings.Repeat("Cat ", 16) + "x", strings.Repeat("Кот ", 16) + "ы"},
+	}
+	for _, tt := range tests {
+		en, ru := s.heuristic(tt.en, "en", "F"), s.heuristic(tt.ru, "ru", "F")
+		if en != ru {
+			t.Errorf("%d символов: en %d, ru %d, want равные", len([]rune(tt.en)), en, ru)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+// Package metrics - метрики Prometheus для API (GET /metrics): длительность
+// и исходы поисков, запросы к Wikipedia, попадания в кеш ссылок.
+//
+// Метрики живут в собственном Registry, а не в глобальном prometheus:
+// в выдачу попадает только то, что объявлено здесь, плюс метрики Go и процесса.
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	// SearchDuration - длительность завершённых поисков по исходу
+	// (found, partial, not_found, timeout, upstream_error, cancelled)
+	SearchDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "wikiracer_search_duration_seconds",
+		Help:    "Длительность поиска пути, по исходу.",
+		Buckets: []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 20, 30, 60},
+	}, []string{"outcome"})
+
+	// Searches - завершённые поиски по исходу
+	Searches = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "wikiracer_searches_total",
+		Help: "Завершённые поиски пути, по исходу.",
+	}, []string{"outcome"})
+
+	// InFlight - поиски, идущие прямо сейчас
+	InFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "wikiracer_searches_in_flight",
+		Help: "Поиски пути, идущие сейчас.",
+	})
+
+	// WikiRequests - запросы к MediaWiki API по языку и направлению:
+	// forward (links), backward (linkshere), other - остальные
+	WikiRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "wikiracer_wiki_requests_total",
+		Help: "Запросы к MediaWiki API, по языку и направлению.",
+	}, []string{"lang", "direction"})
+
+	// CacheLookups - обращения к кешу ссылок по языку и результату (hit, miss);
+	// доля попаданий - hit / (hit + miss)
+	CacheLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "wikiracer_link_cache_lookups_total",
+		Help: "Обращения к кешу ссылок, по языку и результату.",
+	}, []string{"lang", "result"})
+)
+
+// Registry - реестр всех метрик пакета
+var Registry = prometheus.NewRegistry()
+
+func init() {
+	Registry.MustRegister(
+		SearchDuration, Searches, InFlight, WikiRequests, CacheLookups,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// ObserveSearch учитывает завершённый поиск
+func ObserveSearch(outcome string, d time.Duration) {
+	SearchDuration.WithLabelValues(outcome).Observe(d.Seconds())
+	Searches.WithLabelValues(outcome).Inc()
+}
+
+// Handler отдаёт метрики в текстовом формате Prometheus
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package peer
+
+import "net"
+
+// Closed на платформах без MSG_PEEK разрыв не определяет: поиск
+// доработает бюджет, как и без проверки
+func Closed(conn net.Conn) bool {
+	return false
+}
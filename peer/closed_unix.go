@@ -0,0 +1,32 @@
+//go:build unix
+
+package peer
+
+import (
+	"errors"
+	"net"
+	"syscall"
+)
+
+// Closed сообщает, что клиент закрыл conn (EOF или RST). Байт читается
+// с MSG_PEEK без ожидания: данные следующего запроса остаются в сокете.
+// Соединения без дескриптора (TLS, net.Pipe) считаются открытыми.
+func Closed(conn net.Conn) bool {
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return false
+	}
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return false
+	}
+	var buf [1]byte
+	closed := false
+	err = raw.Read(func(fd uintptr) bool {
+		n, _, err := syscall.Recvfrom(int(fd), buf[:], syscall.MSG_PEEK|syscall.MSG_DONTWAIT)
+		closed = (n == 0 && err == nil) || errors.Is(err, syscall.ECONNRESET)
+		// true - не ждать данных: нужен только текущий статус сокета
+		return true
+	})
+	return err == nil && closed
+}
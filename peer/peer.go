@@ -0,0 +1,38 @@
+// Package peer узнаёт, что клиент закрыл соединение, пока сервер ещё
+// готовит ответ.
+//
+// fasthttp не читает сокет, пока работает обработчик, поэтому сам
+// разрыв не замечает: долгий поиск дорабатывал бы бюджет впустую.
+package peer
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// Watch возвращает контекст, производный от parent, который отменяется,
+// когда клиент на conn закрыл соединение; conn проверяется раз в every.
+// cancel обязателен - он же останавливает проверку.
+func Watch(parent context.Context, conn net.Conn, every time.Duration) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+	if conn == nil {
+		return ctx, cancel
+	}
+	go func() {
+		t := time.NewTicker(every)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+				if Closed(conn) {
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+	return ctx, cancel
+}
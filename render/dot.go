@@ -0,0 +1,49 @@
+package render
+
+import (
+	"fmt"
+	"strings"
+)
+
+// dotQuote экранирует строку для DOT: в кавычках особые только " и \,
+// переводы строк заменяются пробелом
+func dotQuote(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", " ", "\r", " ").Replace(s)
+	return `"` + s + `"`
+}
+
+// DOT сериализует граф для Graphviz (dot -Tsvg): статьи и рёбра пути
+// выделены красным, backward-рёбра пунктиром, interwiki и категории -
+// точками.
+func DOT(g Graph) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "digraph %s {\n", dotQuote(g.From+" → "+g.To))
+	b.WriteString("  node [shape=box, style=rounded, fontname=\"sans-serif\"];\n")
+	b.WriteString("  edge [color=gray60];\n")
+	for _, n := range g.Nodes {
+		attrs := "label=" + dotQuote(n.Title) + ", tooltip=" + dotQuote(n.ID)
+		if n.Path {
+			attrs += ", color=red, fontcolor=red, penwidth=2"
+		}
+		fmt.Fprintf(&b, "  %s [%s];\n", dotQuote(n.ID), attrs)
+	}
+	for _, e := range g.Edges {
+		var attrs []string
+		switch {
+		case e.Type == "interwiki" || e.Type == "category":
+			attrs = append(attrs, "style=dotted", "label="+dotQuote(e.Type))
+		case e.Direction == "backward":
+			attrs = append(attrs, "style=dashed")
+		}
+		if e.Path {
+			attrs = append(attrs, "color=red", "penwidth=2")
+		}
+		fmt.Fprintf(&b, "  %s -> %s", dotQuote(e.From), dotQuote(e.To))
+		if len(attrs) > 0 {
+			fmt.Fprintf(&b, " [%s]", strings.Join(attrs, ", "))
+		}
+		b.WriteString(";\n")
+	}
+	b.WriteString("}\n")
+	return []byte(b.String())
+}
@@ -0,0 +1,220 @@
+package render
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"unicode"
+)
+
+// dotGraph - то, что тест читает из DOT: подписи узлов и рёбра с атрибутами
+type dotGraph struct {
+	name   string
+	labels map[string]string
+	edges  map[[2]string]map[string]string
+}
+
+// dotTokens разбивает DOT на лексемы. Строка в кавычках возвращается
+// раскавыченной с префиксом '"', чтобы отличать её от слов и знаков.
+func dotTokens(src string) ([]string, error) {
+	var tokens []string
+	rs := []rune(src)
+	for i := 0; i < len(rs); i++ {
+		r := rs[i]
+		switch {
+		case unicode.IsSpace(r):
+		case r == '"':
+			var b strings.Builder
+			b.WriteRune('"')
+			for i++; ; i++ {
+				if i >= len(rs) {
+					return nil, fmt.Errorf("незакрытая строка: %s", b.String())
+				}
+				if rs[i] == '\n' {
+					return nil, fmt.Errorf("перевод строки в строке: %s", b.String())
+				}
+				if rs[i] == '\\' && i+1 < len(rs) {
+					i++
+					if rs[i] != '"' && rs[i] != '\\' {
+						b.WriteRune('\\')
+					}
+				} else if rs[i] == '"' {
+					break
+				}
+				b.WriteRune(rs[i])
+			}
+			tokens = append(tokens, b.String())
+		case r == '-' && i+1 < len(rs) && rs[i+1] == '>':
+			tokens = append(tokens, "->")
+			i++
+		case strings.ContainsRune("{}[];,=", r):
+			tokens = append(tokens, string(r))
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			j := i
+			for j < len(rs) && (unicode.IsLetter(rs[j]) || unicode.IsDigit(rs[j]) || rs[j] == '_' || rs[j] == '-') {
+				j++
+			}
+			tokens = append(tokens, string(rs[i:j]))
+			i = j - 1
+		default:
+			return nil, fmt.Errorf("неожиданный символ %q", r)
+		}
+	}
+	return tokens, nil
+}
+
+// parseDOT разбирает подмножество DOT, которое выдаёт DOT(): digraph,
+// операторы node/edge, узлы и рёбра со списками атрибутов
+func parseDOT(src string) (*dotGraph, error) {
+	tokens, err := dotTokens(src)
+	if err != nil {
+		return nil, err
+	}
+	pos := 0
+	next := func() string {
+		if pos >= len(tokens) {
+			return ""
+		}
+		pos++
+		return tokens[pos-1]
+	}
+	expect := func(want string) error {
+		if got := next(); got != want {
+			return fmt.Errorf("лексема %d: %q, ожидалось %q", pos, got, want)
+		}
+		return nil
+	}
+	id := func() (string, error) {
+		tok := next()
+		if !strings.HasPrefix(tok, `"`) {
+			return "", fmt.Errorf("лексема %d: %q, ожидалась строка", pos, tok)
+		}
+		return tok[1:], nil
+	}
+	attrs := func() (map[string]string, error) {
+		a := map[string]string{}
+		if pos >= len(tokens) || tokens[pos] != "[" {
+			return a, nil
+		}
+		pos++
+		for {
+			key := next()
+			if err := expect("="); err != nil {
+				return nil, err
+			}
+			a[key] = strings.TrimPrefix(next(), `"`)
+			switch tok := next(); tok {
+			case ",":
+			case "]":
+				return a, nil
+			default:
+				return nil, fmt.Errorf("лексема %d: %q в списке атрибутов", pos, tok)
+			}
+		}
+	}
+
+	g := &dotGraph{labels: map[string]string{}, edges: map[[2]string]map[string]string{}}
+	if err := expect("digraph"); err != nil {
+		return nil, err
+	}
+	if g.name, err = id(); err != nil {
+		return nil, err
+	}
+	if err := expect("{"); err != nil {
+		return nil, err
+	}
+	for pos < len(tokens) && tokens[pos] != "}" {
+		if tokens[pos] == "node" || tokens[pos] == "edge" {
+			pos++
+			if _, err := attrs(); err != nil {
+				return nil, err
+			}
+		} else {
+			from, err := id()
+			if err != nil {
+				return nil, err
+			}
+			edge := pos < len(tokens) && tokens[pos] == "->"
+			var to string
+			if edge {
+				pos++
+				if to, err = id(); err != nil {
+					return nil, err
+				}
+			}
+			a, err := attrs()
+			if err != nil {
+				return nil, err
+			}
+			if edge {
+				g.edges[[2]string{from, to}] = a
+			} else {
+				g.labels[from] = a["label"]
+			}
+		}
+		if err := expect(";"); err != nil {
+			return nil, err
+		}
+	}
+	if err := expect("}"); err != nil {
+		return nil, err
+	}
+	if pos != len(tokens) {
+		return nil, fmt.Errorf("лишние лексемы после графа: %q", tokens[pos:])
+	}
+	return g, nil
+}
+
+func TestDOT(t *testing.T) {
+	g := Graph{
+		From: `Say "Hi"`,
+		To:   `C:\Windows`,
+		Nodes: []GraphNode{
+			{ID: `en:Say "Hi"`, Title: `Say "Hi"`, Lang: "en", Path: true},
+			{ID: "en:Back\\slash\nline", Title: "Back\\slash\nline", Lang: "en", Path: true},
+			{ID: "de:Schrägstrich", Title: "Schrägstrich", Lang: "de"},
+			{ID: `en:C:\Windows`, Title: `C:\Windows`, Lang: "en", Path: true},
+		},
+		Edges: []GraphEdge{
+			{From: `en:Say "Hi"`, To: "en:Back\\slash\nline", Type: "link", Direction: "forward", Path: true},
+			{From: "en:Back\\slash\nline", To: `en:C:\Windows`, Type: "link", Direction: "backward", Path: true},
+			{From: "en:Back\\slash\nline", To: "de:Schrägstrich", Type: "interwiki"},
+		},
+	}
+
+	out := string(DOT(g))
+	parsed, err := parseDOT(out)
+	if err != nil {
+		t.Fatalf("DOT не разбирается: %v\n%s", err, out)
+	}
+
+	// Переводы строк DOT заменяет пробелом, остальное - как было
+	flat := strings.NewReplacer("\n", " ").Replace
+	if want := flat(g.From + " → " + g.To); parsed.name != want {
+		t.Errorf("имя графа = %q, want %q", parsed.name, want)
+	}
+	for _, n := range g.Nodes {
+		if label, ok := parsed.labels[flat(n.ID)]; !ok || label != flat(n.Title) {
+			t.Errorf("узел %q: label = %q (есть: %v), want %q", n.ID, label, ok, flat(n.Title))
+		}
+	}
+	if len(parsed.edges) != len(g.Edges) {
+		t.Errorf("рёбер %d, want %d", len(parsed.edges), len(g.Edges))
+	}
+	for _, e := range g.Edges {
+		attrs, ok := parsed.edges[[2]string{flat(e.From), flat(e.To)}]
+		if !ok {
+			t.Errorf("нет ребра %q -> %q", e.From, e.To)
+			continue
+		}
+		if got := attrs["color"] == "red"; got != e.Path {
+			t.Errorf("ребро %q -> %q: выделено = %v, want %v", e.From, e.To, got, e.Path)
+		}
+	}
+	if style := parsed.edges[[2]string{flat(g.Edges[1].From), flat(g.Edges[1].To)}]["style"]; style != "dashed" {
+		t.Errorf("backward-ребро: style = %q, want dashed", style)
+	}
+	if style := parsed.edges[[2]string{flat(g.Edges[2].From), flat(g.Edges[2].To)}]["style"]; style != "dotted" {
+		t.Errorf("interwiki-ребро: style = %q, want dotted", style)
+	}
+}
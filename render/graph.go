@@ -0,0 +1,40 @@
+package render
+
+import "encoding/xml"
+
+// Graph - найденный путь (и, если есть, исследованный подграф)
+// как узлы и типизированные рёбра
+type Graph struct {
+	XMLName xml.Name    `xml:"graph"`
+	From    string      `xml:"from,attr"`
+	To      string      `xml:"to,attr"`
+	Nodes   []GraphNode `xml:"nodes>node"`
+	Edges   []GraphEdge `xml:"edges>edge"`
+}
+
+// GraphNode - статья; ID - "lang:title"
+type GraphNode struct {
+	ID    string `xml:"id,attr"`
+	Title string `xml:"title,attr"`
+	Lang  string `xml:"lang,attr"`
+	Path  bool   `xml:"path,attr,omitempty"` // статья входит в путь
+}
+
+// GraphEdge - переход между статьями
+type GraphEdge struct {
+	From      string `xml:"from,attr"`
+	To        string `xml:"to,attr"`
+	Type      string `xml:"type,attr"`                // link, interwiki, category
+	Direction string `xml:"direction,attr,omitempty"` // forward или backward
+	Path      bool   `xml:"path,attr,omitempty"`      // ребро входит в путь
+}
+
+// XML сериализует граф с XML-заголовком. Названия экранируются
+// encoding/xml, так что &, <, > и кавычки в них безопасны.
+func XML(g Graph) ([]byte, error) {
+	out, err := xml.MarshalIndent(g, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(out, '\n')...), nil
+}
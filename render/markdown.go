@@ -0,0 +1,43 @@
+package render
+
+import (
+	"fmt"
+	"strings"
+)
+
+// mdEscape экранирует текст ссылки markdown: скобки и обратный слеш
+var mdEscape = strings.NewReplacer(`\`, `\\`, `[`, `\[`, `]`, `\]`)
+
+// mdNotes - пометки переходов, которые не клик по ссылке в статье
+var mdNotes = map[string]map[string]string{
+	"ru": {"interwiki": "*(interwiki, %s)*", "category": "*(через категорию)*"},
+	"en": {"interwiki": "*(interwiki, %s)*", "category": "*(via category)*"},
+}
+
+// Markdown возвращает путь одной строкой "link trail" для вставки в чат:
+// [Кошка](url) → [Млекопитающие](url) → ... types[i] - тип перехода от
+// path[i] к path[i+1] (link, interwiki, category); interwiki и категории
+// помечаются перед статьёй. locale - "ru" или "en", неизвестные дают "ru".
+func Markdown(path []Step, types []string, locale string) string {
+	notes, ok := mdNotes[locale]
+	if !ok {
+		notes = mdNotes["ru"]
+	}
+
+	var b strings.Builder
+	for i, n := range path {
+		if i > 0 {
+			b.WriteString(" → ")
+			if i-1 < len(types) {
+				switch types[i-1] {
+				case "interwiki":
+					fmt.Fprintf(&b, notes["interwiki"]+" ", n.Lang)
+				case "category":
+					b.WriteString(notes["category"] + " ")
+				}
+			}
+		}
+		fmt.Fprintf(&b, "[%s](%s)", mdEscape.Replace(n.Title), WikiURL(n.Lang, n.Title))
+	}
+	return b.String()
+}
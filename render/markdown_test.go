@@ -0,0 +1,39 @@
+package render
+
+import "testing"
+
+func TestMarkdown(t *testing.T) {
+	path := []Step{
+		{Title: "Кошка", Lang: "ru"},
+		{Title: "Cat", Lang: "en"},
+		{Title: "Felidae [family]", Lang: "en"},
+		{Title: "AC/DC", Lang: "en"},
+	}
+	types := []string{"interwiki", "category", "link"}
+
+	tests := []struct {
+		locale string
+		want   string
+	}{
+		{"ru", "[Кошка](https://ru.wikipedia.org/wiki/%D0%9A%D0%BE%D1%88%D0%BA%D0%B0)" +
+			" → *(interwiki, en)* [Cat](https://en.wikipedia.org/wiki/Cat)" +
+			" → *(через категорию)* [Felidae \\[family\\]](https://en.wikipedia.org/wiki/Felidae%20%5Bfamily%5D)" +
+			" → [AC/DC](https://en.wikipedia.org/wiki/AC/DC)"},
+		{"en", "[Кошка](https://ru.wikipedia.org/wiki/%D0%9A%D0%BE%D1%88%D0%BA%D0%B0)" +
+			" → *(interwiki, en)* [Cat](https://en.wikipedia.org/wiki/Cat)" +
+			" → *(via category)* [Felidae \\[family\\]](https://en.wikipedia.org/wiki/Felidae%20%5Bfamily%5D)" +
+			" → [AC/DC](https://en.wikipedia.org/wiki/AC/DC)"},
+	}
+	for _, tt := range tests {
+		if got := Markdown(path, types, tt.locale); got != tt.want {
+			t.Errorf("Markdown(%s) =\n%s\nwant\n%s", tt.locale, got, tt.want)
+		}
+	}
+
+	// Неизвестная локаль - ru; types короче пути - без пометок
+	got := Markdown(path[:2], nil, "xx")
+	want := "[Кошка](https://ru.wikipedia.org/wiki/%D0%9A%D0%BE%D1%88%D0%BA%D0%B0) → [Cat](https://en.wikipedia.org/wiki/Cat)"
+	if got != want {
+		t.Errorf("Markdown без types =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,105 @@
+// Package render форматирует найденный путь: текстовый "рецепт", строку
+// markdown и граф в XML или DOT.
+//
+// Используется и CLI (main.go), и API (format=text), чтобы вывод
+// в обоих местах был одинаковым.
+package render
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Step - одна статья пути
+type Step struct {
+	Title string
+	Lang  string
+}
+
+func (s Step) String() string { return s.Lang + ":" + s.Title }
+
+// WikiURL строит ссылку на статью Wikipedia
+func WikiURL(lang, title string) string {
+	return fmt.Sprintf("https://%s.wikipedia.org/wiki/%s",
+		lang, strings.ReplaceAll(url.PathEscape(title), "%2F", "/"))
+}
+
+// messages - тексты рецепта для поддерживаемых локалей
+type messages struct {
+	path        string
+	check       string
+	transitions string
+	warning     string
+	warningNote string
+	checkFrom   string
+	checkBack   string
+	interwiki   string
+	languages   string
+}
+
+var locales = map[string]messages{
+	"ru": {
+		path:        "🎯 Путь (%d):\n",
+		check:       "\n🔗 Проверка (ссылки на статьи):\n",
+		transitions: "\n📍 Переходы (где искать ссылку):\n",
+		warning:     "   ⚠️  Путь найден bidirectional поиском - некоторые связи могут быть backlinks\n",
+		warningNote: "   (т.е. B ссылается на A, а не A на B)\n",
+		checkFrom:   "     Проверить: %s\n",
+		checkBack:   "     Или обратно: %s\n",
+		interwiki:   "  %s → %s (interwiki)\n",
+		languages:   "     Слева 'Languages': %s\n",
+	},
+	"en": {
+		path:        "🎯 Path (%d):\n",
+		check:       "\n🔗 Check (article links):\n",
+		transitions: "\n📍 Transitions (where to find the link):\n",
+		warning:     "   ⚠️  Path found by bidirectional search - some hops may be backlinks\n",
+		warningNote: "   (i.e. B links to A, not A to B)\n",
+		checkFrom:   "     Check: %s\n",
+		checkBack:   "     Or backwards: %s\n",
+		interwiki:   "  %s → %s (interwiki)\n",
+		languages:   "     'Languages' menu: %s\n",
+	},
+}
+
+// Recipe возвращает путь в виде нумерованного текстового рецепта:
+// список статей, ссылки для проверки и инструкции для каждого перехода.
+// locale - "ru" или "en", неизвестные значения дают "ru".
+func Recipe(path []Step, locale string) string {
+	m, ok := locales[locale]
+	if !ok {
+		m = locales["ru"]
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, m.path, len(path))
+	for i, n := range path {
+		fmt.Fprintf(&b, "  %d. %s\n", i+1, n)
+	}
+
+	b.WriteString(m.check)
+	for i, n := range path {
+		fmt.Fprintf(&b, "  %d. %s\n", i+1, WikiURL(n.Lang, n.Title))
+	}
+
+	b.WriteString(m.transitions)
+	b.WriteString(m.warning)
+	b.WriteString(m.warningNote)
+	b.WriteString("\n")
+	for i := 0; i < len(path)-1; i++ {
+		from := path[i]
+		to := path[i+1]
+		if from.Lang == to.Lang {
+			// Внутри одного языка - ссылка в статье, проверить в обе стороны
+			fmt.Fprintf(&b, "  %s → %s\n", from.Title, to.Title)
+			fmt.Fprintf(&b, m.checkFrom, WikiURL(from.Lang, from.Title))
+			fmt.Fprintf(&b, m.checkBack, WikiURL(to.Lang, to.Title))
+		} else {
+			fmt.Fprintf(&b, m.interwiki, from, to)
+			fmt.Fprintf(&b, m.languages, WikiURL(from.Lang, from.Title))
+		}
+	}
+
+	return b.String()
+}
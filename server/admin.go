@@ -0,0 +1,71 @@
+package server
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"wikiracer/limiter"
+	"wikiracer/store"
+)
+
+// HealthCheck godoc
+// @Summary Проверка состояния API
+// @Description Возвращает статус API
+// @Tags health
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /health [get]
+func HealthCheck(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{
+		"status":  "ok",
+		"service": "WikiRacer API",
+		"version": "1.0.0",
+		// Последние квоты X-RateLimit по хостам API; пусто, если их не присылали
+		"rate_limits": limiter.Quotas(),
+	})
+}
+
+// CacheStats godoc
+// @Summary Статистика кеша ссылок
+// @Description Размер, лимит, попадания, вытеснения и устаревшие записи кеша ссылок по каждому языку
+// @Tags admin
+// @Produce json
+// @Success 200 {object} map[string]linkcache.Stats
+// @Router /admin/cache [get]
+func CacheStats(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{
+		"enabled":   globalLinkCache != nil,
+		"languages": globalLinkCache.Stats(),
+	})
+}
+
+// RecentSearches godoc
+// @Summary Последние поиски
+// @Description Последние сохранённые в SQLite поиски, новые первыми (WIKI_SQLITE_DSN)
+// @Tags admin
+// @Produce json
+// @Param limit query int false "Сколько записей вернуть (1-1000)" example(50)
+// @Success 200 {object} map[string]interface{}
+// @Failure 500 {object} ErrorResponse
+// @Router /admin/searches [get]
+func RecentSearches(c *fiber.Ctx) error {
+	limit := c.QueryInt("limit", 50)
+	if limit < 1 || limit > 1000 {
+		limit = 50
+	}
+	records, err := globalStore.Recent(limit)
+	if err != nil {
+		return c.Status(500).JSON(ErrorResponse{
+			Success: false,
+			Error:   "Не удалось прочитать историю поисков",
+			Code:    "STORE_ERROR",
+		})
+	}
+	if records == nil {
+		records = []store.Record{}
+	}
+	return c.JSON(fiber.Map{
+		"enabled":  globalStore != nil,
+		"dropped":  globalStore.Dropped(),
+		"searches": records,
+	})
+}
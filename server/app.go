@@ -0,0 +1,195 @@
+// Package server - REST API WikiRacer на Fiber: поиск пути, сравнение,
+// подсказки, потоковая выдача и служебные эндпоинты.
+//
+// Настройки читаются из окружения WIKI_* один раз при старте (Configure);
+// поиски, кеш ссылок, лимит запросов и хранилище общие на весь процесс.
+// Сам api.go только собирает сервер и запускает его.
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/gofiber/fiber/v2/middleware/cors"
+	"github.com/gofiber/fiber/v2/middleware/logger"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
+	"github.com/gofiber/swagger"
+
+	_ "wikiracer/docs" // swagger docs
+	"wikiracer/limiter"
+	"wikiracer/metrics"
+)
+
+// Configure читает настройки из окружения и готовит общий HTTP клиент.
+// Ошибка - неверная переменная окружения; сервер с ней не запускается.
+func Configure() error {
+	for _, load := range []func() error{
+		loadLangs,
+		loadAPIOptions,
+		loadLinkCache,
+		loadWikiConfigs,
+		loadStore,
+		loadLimiter,
+	} {
+		if err := load(); err != nil {
+			return err
+		}
+	}
+	initGlobalClient()
+	return nil
+}
+
+// Close закрывает хранилища поисков (WIKI_SQLITE_DSN, WIKI_RESULTS_JSONL)
+func Close() error {
+	return globalSink.Close()
+}
+
+// warmupRetryDelay - пауза перед повторным прогревом вики, не ответившей
+// с первого раза
+const warmupRetryDelay = 500 * time.Millisecond
+
+// WarmupConnections прогревает HTTP/2 соединения ко всем Wikipedia API
+// Это убирает 200-300мс на первый запрос (TCP + TLS + HTTP/2 handshake).
+// Неудачный прогрев повторяется один раз; результат - ошибка по каждому
+// языку, nil - соединение готово.
+func WarmupConnections() map[string]error {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	results := make(map[string]error, len(apiWikis))
+	for lang, wiki := range apiWikis {
+		wg.Add(1)
+		go func(l, u string) {
+			defer wg.Done()
+			err := warmupWiki(u)
+			if err != nil {
+				time.Sleep(warmupRetryDelay)
+				err = warmupWiki(u)
+			}
+			mu.Lock()
+			results[l] = err
+			mu.Unlock()
+		}(lang, wiki.APIURL)
+	}
+	wg.Wait()
+	return results
+}
+
+// warmupWiki - один лёгкий meta=siteinfo запрос к API; ответ не 200 -
+// тоже ошибка: соединение есть, но вики работать не будет
+func warmupWiki(apiURL string) error {
+	params := url.Values{
+		"action": {"query"},
+		"format": {"json"},
+		"meta":   {"siteinfo"},
+	}
+	setMaxLagAPI(params, defaultAPIOptions.MaxLag)
+	ctx := context.Background()
+	if timeout := defaultAPIOptions.RequestTimeout; timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", apiURL+"?"+params.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	setUserAgent(req, defaultAPIOptions.UserAgent)
+	resp, err := globalHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	limiter.Observe(req.URL.Host, resp.Header)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// WarmupSummary - итог прогрева одной строкой: "7/8 вики прогреты, не удалось: de"
+func WarmupSummary(results map[string]error) string {
+	var failed []string
+	for lang, err := range results {
+		if err != nil {
+			failed = append(failed, lang)
+		}
+	}
+	summary := fmt.Sprintf("%d/%d вики прогреты", len(results)-len(failed), len(results))
+	if len(failed) > 0 {
+		sort.Strings(failed)
+		summary += ", не удалось: " + strings.Join(failed, ", ")
+	}
+	return summary
+}
+
+// NewApp собирает приложение: middleware и маршруты
+func NewApp() *fiber.App {
+	app := fiber.New(fiber.Config{
+		AppName: "WikiRacer API v1.0.0",
+	})
+
+	// Middleware
+	// requestid - первым: ID нужен журналу доступа и логам поиска
+	app.Use(requestid.New())
+	app.Use(logger.New(logger.Config{
+		Format: "${time} | ${status} | ${latency} | ${ip} | ${method} | ${path} | ${locals:requestid} | ${error}\n",
+		Output: os.Stderr,
+	}))
+	app.Use(cors.New())
+
+	// Swagger
+	app.Get("/swagger/*", swagger.HandlerDefault)
+
+	// Prometheus
+	app.Get("/metrics", adaptor.HTTPHandler(metrics.Handler()))
+
+	// API routes
+	api := app.Group("/api/v1")
+	api.Get("/health", HealthCheck)
+	api.Get("/search", SearchPathGet)
+	api.Get("/search.dot", SearchPathDOT)
+	api.Get("/search/stream", SearchStream)
+	api.Get("/search/category", SearchCategoryPath)
+	api.Get("/ws/search", SearchWebSocket)
+	api.Get("/hint", SearchHint)
+	api.Get("/neighbors", SearchNeighbors)
+	api.Get("/explain", ExplainHeuristic)
+	api.Get("/suggest", SuggestTitles)
+	api.Get("/compare", ComparePaths)
+	api.Get("/random", RandomSearch)
+	api.Post("/search", SearchPath)
+	api.Post("/search/batch", SearchBatch)
+	api.Post("/search/compare", CompareSearches)
+	api.Get("/admin/cache", CacheStats)
+	api.Get("/admin/searches", RecentSearches)
+
+	// Root redirect
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.Redirect("/swagger/index.html")
+	})
+	return app
+}
+
+// Shutdown останавливает сервер: новые соединения не принимаются, текущие
+// поиски доигрывают до shutdownTimeout, потом отменяются и отвечают 503
+// SHUTTING_DOWN. Соединения, не закрывшиеся и после shutdownGrace,
+// разрываются.
+func Shutdown(app *fiber.App) {
+	fmt.Printf("🛑 Остановка: ждём текущие поиски до %v\n", shutdownTimeout)
+	timer := time.AfterFunc(shutdownTimeout, stopSearches)
+	defer timer.Stop()
+	if err := app.ShutdownWithTimeout(shutdownTimeout + shutdownGrace); err != nil {
+		fmt.Println("⚠️ Остановка:", err)
+	}
+	stopSearches()
+	fmt.Println("✅ Сервер остановлен")
+}
@@ -0,0 +1,125 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestShutdownSlowBackend(t *testing.T) {
+	// Бэкенд не отвечает, пока запрос не отменят
+	arrived := make(chan struct{}, 1)
+	withFakeWiki(t, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case arrived <- struct{}{}:
+		default:
+		}
+		select {
+		case <-r.Context().Done():
+		case <-time.After(10 * time.Second):
+		}
+	}, "en")
+	oldCtx, oldStop, oldTimeout := serverCtx, stopSearches, shutdownTimeout
+	serverCtx, stopSearches = context.WithCancel(context.Background())
+	shutdownTimeout = 200 * time.Millisecond
+	t.Cleanup(func() { serverCtx, stopSearches, shutdownTimeout = oldCtx, oldStop, oldTimeout })
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := NewApp()
+	go app.Listener(ln)
+
+	type result struct {
+		status int
+		code   string
+	}
+	results := make(chan result, 1)
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String() + "/api/v1/search?from=Slowpoke&to=Turtle&lang=en")
+		if err != nil {
+			results <- result{code: err.Error()}
+			return
+		}
+		defer resp.Body.Close()
+		var data ErrorResponse
+		json.NewDecoder(resp.Body).Decode(&data)
+		results <- result{resp.StatusCode, data.Code}
+	}()
+
+	select {
+	case <-arrived:
+	case <-time.After(5 * time.Second):
+		t.Fatal("поиск не дошёл до бэкенда")
+	}
+	t0 := time.Now()
+	Shutdown(app)
+	if elapsed := time.Since(t0); elapsed > shutdownTimeout+shutdownGrace {
+		t.Errorf("остановка заняла %v, want не больше %v", elapsed, shutdownTimeout+shutdownGrace)
+	}
+
+	select {
+	case r := <-results:
+		if r.status != http.StatusServiceUnavailable || r.code != "SHUTTING_DOWN" {
+			t.Errorf("ответ поиска: %d %s, want 503 SHUTTING_DOWN", r.status, r.code)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("поиск не ответил после остановки")
+	}
+}
+
+func TestWarmupConnections(t *testing.T) {
+	// de отвечает 503 всегда, fr - только на первый запрос
+	var deRequests, frRequests atomic.Int64
+	srv := withFakeWiki(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/de":
+			deRequests.Add(1)
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case "/fr":
+			if frRequests.Add(1) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			writeJSON(w, map[string]interface{}{"query": map[string]interface{}{}})
+		default:
+			writeJSON(w, map[string]interface{}{"query": map[string]interface{}{}})
+		}
+	}, "en", "de", "fr")
+	apiWikis["de"] = &WikiConfig{APIURL: srv.URL + "/de", Limit: "max", Namespace: "0"}
+	apiWikis["fr"] = &WikiConfig{APIURL: srv.URL + "/fr", Limit: "max", Namespace: "0"}
+
+	results := WarmupConnections()
+	if len(results) != 3 || results["en"] != nil || results["fr"] != nil || results["de"] == nil {
+		t.Fatalf("результаты прогрева: %v", results)
+	}
+	if de, fr := deRequests.Load(), frRequests.Load(); de != 2 || fr != 2 {
+		t.Errorf("запросов de %d, fr %d: want по одному повтору", de, fr)
+	}
+	if got, want := WarmupSummary(results), "2/3 вики прогреты, не удалось: de"; got != want {
+		t.Errorf("WarmupSummary = %q, want %q", got, want)
+	}
+}
+
+func TestWarmupSummary(t *testing.T) {
+	failed := errors.New("HTTP 503")
+	tests := []struct {
+		results map[string]error
+		want    string
+	}{
+		{map[string]error{}, "0/0 вики прогреты"},
+		{map[string]error{"en": nil, "ru": nil}, "2/2 вики прогреты"},
+		{map[string]error{"uk": failed, "en": nil, "de": failed}, "1/3 вики прогреты, не удалось: de, uk"},
+	}
+	for _, tt := range tests {
+		if got := WarmupSummary(tt.results); got != tt.want {
+			t.Errorf("WarmupSummary(%v) = %q, want %q", tt.results, got, tt.want)
+		}
+	}
+}
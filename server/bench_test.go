@@ -0,0 +1,195 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// benchSearch - пара статей для бенчмарков поиска
+type benchSearch struct{ from, to, lang string }
+
+// reportSearches прогоняет поиски pairs с настройками opts b.N раз и
+// сообщает на поиск: запросы к API, пик очередей, длину пути и долю
+// найденных путей. Кеш ссылок сбрасывается перед каждым поиском, иначе
+// повторы были бы бесплатными.
+func reportSearches(b *testing.B, opts APISearchOptions, pairs []benchSearch) {
+	var requests, peak, hops, found int64
+	for i := 0; i < b.N; i++ {
+		for _, p := range pairs {
+			globalLinkCache = newLinkCache(100000, nil, 0)
+			s := NewAPISearcher(context.Background(), p.lang, p.from, p.lang, p.to, opts)
+			path, _ := s.Search(p.from, p.to, p.lang)
+			s.cancel()
+			requests += s.reqCount.Load()
+			peak += int64(s.peakFrontier)
+			if len(path) > 0 {
+				found++
+				hops += int64(len(path) - 1)
+			}
+		}
+	}
+	runs := float64(b.N * len(pairs))
+	b.ReportMetric(float64(requests)/runs, "requests/search")
+	b.ReportMetric(float64(peak)/runs, "frontier/search")
+	b.ReportMetric(100*float64(found)/runs, "found%")
+	if found > 0 {
+		b.ReportMetric(float64(hops)/float64(found), "hops/path")
+	}
+}
+
+// benchWords - словарь названий randomGraph
+var benchWords = strings.Fields(`river stone empire music theory battle city
+	island language science king war bridge garden ocean mountain history
+	engine festival planet novel church castle railway painter forest`)
+
+// randomGraph - детерминированный по seed граф из n статей в духе
+// Wikipedia: немного хабов с сотнями ссылок, у остальных от 3 до 12.
+// Названия - 1-3 слова из benchWords и номер, так что эвристика видит
+// и совпадения слов с целью, и разную длину.
+func randomGraph(seed int64, n int) (*graphWiki, []string) {
+	rnd := rand.New(rand.NewSource(seed))
+	titles := make([]string, n)
+	for i := range titles {
+		words := make([]string, 1+rnd.Intn(3))
+		for j := range words {
+			words[j] = benchWords[rnd.Intn(len(benchWords))]
+		}
+		titles[i] = normalizeTitleAPI(fmt.Sprintf("%s %d", strings.Join(words, " "), i))
+	}
+	g := &graphWiki{links: make(map[string][]string, n)}
+	for i, title := range titles {
+		degree := 3 + rnd.Intn(10)
+		if i%50 == 0 {
+			degree = 300
+		}
+		for j := 0; j < degree; j++ {
+			g.links[title] = append(g.links[title], titles[rnd.Intn(n)])
+		}
+	}
+	return g, titles
+}
+
+// randomPairs - count пар статей графа titles в разделе en
+func randomPairs(seed int64, titles []string, count int) []benchSearch {
+	rnd := rand.New(rand.NewSource(seed))
+	pairs := make([]benchSearch, count)
+	for i := range pairs {
+		pairs[i] = benchSearch{titles[rnd.Intn(len(titles))], titles[rnd.Intn(len(titles))], "en"}
+	}
+	return pairs
+}
+
+// BenchmarkEnqueueSlack - цена и потери отсечения детей хуже лучшего узла
+// фронта (WIKI_ENQUEUE_SLACK) на графе с хабами. Бюджет запросов ограничен,
+// как на нагруженном сервере: слишком жёсткое отсечение теряет мосты и
+// снижает found%, слишком мягкое тратит бюджет на очередь хаба.
+//
+//	go test -run '^$' -bench EnqueueSlack api.go api_test.go
+func BenchmarkEnqueueSlack(b *testing.B) {
+	graph, titles := randomGraph(1, 2000)
+	withFakeWiki(b, graph.ServeHTTP, "en")
+	pairs := randomPairs(2, titles, 20)
+	for _, slack := range []int{0, 10, 30, 1000} {
+		b.Run("slack="+strconv.Itoa(slack), func(b *testing.B) {
+			opts := defaultAPIOptions
+			opts.EnqueueSlack = slack
+			opts.MaxRequests = 40
+			reportSearches(b, opts, pairs)
+		})
+	}
+}
+
+// BenchmarkLangLinkCap - пик очередей и запросы поиска от статьи с
+// interwiki во все разделы (как "Earth") при разных ограничениях
+// WIKI_LANGLINK_LIMIT и WIKI_LANGLINK_LANGS. Путь - внутри en.
+//
+//	go test -run '^$' -bench LangLinkCap api.go api_test.go
+func BenchmarkLangLinkCap(b *testing.B) {
+	langs := []string{"en", "ru", "uk", "de", "fr", "es", "it", "pt"}
+	// Ещё сотня interwiki в неподключённые разделы, как у настоящей Earth
+	var foreign []string
+	for i := 0; i < 100; i++ {
+		foreign = append(foreign, fmt.Sprintf("x%d:Earth", i))
+	}
+	wikis := map[string]http.Handler{}
+	for _, lang := range langs {
+		g := &graphWiki{links: map[string][]string{}, langlinks: map[string][]string{}}
+		titles := []string{"Earth"}
+		for i := 0; i < 60; i++ {
+			topic := fmt.Sprintf("Earth topic %d", i)
+			g.links["Earth"] = append(g.links["Earth"], topic)
+			g.links[topic] = []string{"Earth"}
+			titles = append(titles, topic)
+		}
+		// У каждой статьи interwiki на ту же статью во всех остальных разделах
+		for _, title := range titles {
+			for _, other := range langs {
+				if other != lang {
+					g.langlinks[title] = append(g.langlinks[title], other+":"+title)
+				}
+			}
+			g.langlinks[title] = append(g.langlinks[title], foreign...)
+		}
+		if lang == "en" {
+			g.links["Earth topic 59"] = []string{"Earth", "Lunar orbit"}
+			g.links["Lunar orbit"] = []string{"Moon"}
+		}
+		wikis[lang] = g
+	}
+	withFakeWikis(b, wikis)
+	pairs := []benchSearch{{"Earth", "Moon", "en"}, {"Earth topic 7", "Moon", "en"}}
+
+	tests := []struct {
+		name  string
+		limit int
+		langs []string
+	}{
+		{"all", 0, nil},
+		{"limit=2", 2, nil},
+		{"langs=de,fr/limit=1", 1, []string{"de", "fr"}},
+	}
+	for _, tt := range tests {
+		b.Run(tt.name, func(b *testing.B) {
+			opts := defaultAPIOptions
+			opts.LangLinkLimit = tt.limit
+			opts.LangLinkLangs = tt.langs
+			reportSearches(b, opts, pairs)
+		})
+	}
+}
+
+// BenchmarkBridgeBonus - находит ли поиск ru → ru путь через английский
+// хаб раньше с бонусом языкам-мостам (WIKI_BRIDGE_BONUS). У обоих концов
+// по 600 ссылок-тупиков в ru, которые эвристика без бонуса ставит выше
+// interwiki в en: язык цели важнее.
+//
+//	go test -run '^$' -bench BridgeBonus api.go api_test.go
+func BenchmarkBridgeBonus(b *testing.B) {
+	ru := &graphWiki{
+		links:     map[string][]string{},
+		langlinks: map[string][]string{"Старт": {"en:Start"}, "Финиш": {"en:Finish"}},
+	}
+	for i := 0; i < 600; i++ {
+		ru.links["Старт"] = append(ru.links["Старт"], fmt.Sprintf("Шум %d", i))
+		ru.links[fmt.Sprintf("Фон %d", i)] = []string{"Финиш"}
+	}
+	en := &graphWiki{
+		links:     map[string][]string{"Start": {"Hub"}, "Hub": {"Finish"}},
+		langlinks: map[string][]string{"Start": {"ru:Старт"}, "Finish": {"ru:Финиш"}},
+	}
+	withFakeWikis(b, map[string]http.Handler{"ru": ru, "en": en})
+	pairs := []benchSearch{{"Старт", "Финиш", "ru"}}
+
+	for _, bonus := range []int{0, 15, 30} {
+		b.Run("bonus="+strconv.Itoa(bonus), func(b *testing.B) {
+			opts := defaultAPIOptions
+			opts.BridgeBonus = bonus
+			reportSearches(b, opts, pairs)
+		})
+	}
+}
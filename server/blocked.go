@@ -0,0 +1,113 @@
+package server
+
+import "net/url"
+
+// blockReason - почему кандидат не может стать узлом пути
+type blockReason int
+
+const (
+	notBlocked       blockReason = iota
+	blockedBlocklist             // Blocklist: WIKI_BLOCKLIST_FILE и exclude запроса
+	blockedForbidden             // forbidden запроса
+	blockedList                  // список или страница значений при SkipLists
+	blockedDisambig              // страница значений со стратегией skip
+	numBlockReasons
+)
+
+// blockReasonNames - причины в stats.blocked_by
+var blockReasonNames = [numBlockReasons]string{"", "blocklist", "forbidden", "list", "disambig"}
+
+// blocked - единый фильтр запретов для кандидата: Blocklist (вместе с exclude),
+// forbidden, SkipLists и DisambigSkip. Страницу значений с пометкой в названии
+// незачем и запрашивать: раскрыта она всё равно не будет; без пометки её
+// поймает pageprops при раскрытии или встрече
+func (s *APISearcher) blocked(child APIWikiNode) blockReason {
+	for _, re := range s.opts.Blocklist {
+		if re.MatchString(child.Title) {
+			return blockedBlocklist
+		}
+	}
+	switch {
+	case s.forbidden[child.Key()]:
+		return blockedForbidden
+	case s.opts.SkipLists && isListTitle(child.Title):
+		return blockedList
+	case s.opts.DisambigStrategy == DisambigSkip && isDisambigTitle(child.Title):
+		return blockedDisambig
+	}
+	return notBlocked
+}
+
+// blockedStats - всего отсеянных кандидатов и разбивка по причинам
+func (s *APISearcher) blockedStats() (int64, map[string]int64) {
+	var total int64
+	var by map[string]int64
+	for r := notBlocked + 1; r < numBlockReasons; r++ {
+		n := s.blockedBy[r].Load()
+		if n == 0 {
+			continue
+		}
+		if by == nil {
+			by = make(map[string]int64)
+		}
+		by[blockReasonNames[r]] = n
+		total += n
+	}
+	return total, by
+}
+
+// skipsDisambig сообщает, что страницы значений не должны попадать в путь
+func (s *APISearcher) skipsDisambig() bool {
+	return s.opts.SkipLists || s.opts.DisambigStrategy == DisambigSkip
+}
+
+// disambigMeet проверяет, не страница ли значений узел встречи. Флаг берётся
+// из кеша ссылок, иначе - отдельным запросом pageprops; ответ запоминается
+// на весь поиск. При ошибке запроса встреча разрешается.
+func (s *APISearcher) disambigMeet(node APIWikiNode) bool {
+	key := node.Key()
+	if v, ok := s.disambigMeets.Load(key); ok {
+		return v.(bool)
+	}
+	for _, dir := range []string{"F", "B"} {
+		if page, ok := s.cached(node.Lang, node.Title, dir); ok {
+			_, disambig := page.PageProps["disambiguation"]
+			s.disambigMeets.Store(key, disambig)
+			return disambig
+		}
+	}
+	wiki, ok := apiWikis[node.Lang]
+	if !ok {
+		return false
+	}
+	data, err := s.query(s.ctx, wiki.APIURL, url.Values{
+		"action":    {"query"},
+		"format":    {"json"},
+		"prop":      {"pageprops"},
+		"ppprop":    {"disambiguation"},
+		"titles":    {node.Title},
+		"redirects": {"1"},
+	})
+	if err != nil {
+		return false
+	}
+	page, _ := data.pageByTitle(node.Title)
+	_, disambig := page.PageProps["disambiguation"]
+	s.disambigMeets.Store(key, disambig)
+	return disambig
+}
+
+// prunedBySlack сообщает, что кандидат хуже лучшего узла фронта больше чем
+// на EnqueueSlack. До первого раунда лучший узел неизвестен - не отсекаем.
+func (s *APISearcher) prunedBySlack(priority int, dir string) bool {
+	var best int64
+	var ok bool
+	if dir == "F" {
+		best, ok = s.bestF.Load(), s.bestFSet.Load()
+	} else {
+		best, ok = s.bestB.Load(), s.bestBSet.Load()
+	}
+	// В Shortest приоритет - прежде всего глубина, отсечение по нему
+	// выбросило бы следующий слой целиком
+	return ok && !s.opts.Shortest && int64(priority) > best+int64(s.opts.EnqueueSlack)
+}
@@ -0,0 +1,144 @@
+package server
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"wikiracer/fixture"
+	"wikiracer/linkcache"
+	"wikiracer/store"
+)
+
+// Глобальный кеш ссылок статей, общий для всех поисков
+var globalLinkCache *linkCache
+
+// linkCache - кеш ссылок статей по языкам, ключ записи - linkCacheKey
+type linkCache = linkcache.Cache[APIWikiPage]
+
+func newLinkCache(defaultSize int, langSizes map[string]int, ttl time.Duration) *linkCache {
+	return linkcache.New[APIWikiPage](defaultSize, langSizes, ttl)
+}
+
+// loadLinkCache создаёт globalLinkCache по переменным окружения.
+// WIKI_CACHE_SIZE - лимит записей на язык (0 отключает кеш),
+// WIKI_CACHE_LANG_SIZES - лимиты отдельных языков, например "en=20000,uk=2000".
+// WIKI_CACHE_TTL_MS - срок жизни записи (по умолчанию час).
+func loadLinkCache() error {
+	size := 10000
+	if err := envInt("WIKI_CACHE_SIZE", &size); err != nil {
+		return err
+	}
+	ttl := time.Hour
+	if err := envMillis("WIKI_CACHE_TTL_MS", &ttl); err != nil {
+		return err
+	}
+
+	langSizes := make(map[string]int)
+	if v := os.Getenv("WIKI_CACHE_LANG_SIZES"); v != "" {
+		for _, pair := range strings.Split(v, ",") {
+			lang, num, ok := strings.Cut(strings.TrimSpace(pair), "=")
+			n, err := strconv.Atoi(num)
+			if !ok || err != nil || n < 0 {
+				return fmt.Errorf("WIKI_CACHE_LANG_SIZES: неверная пара %q", pair)
+			}
+			langSizes[lang] = n
+		}
+	}
+
+	bootstrap := os.Getenv("WIKI_CACHE_BOOTSTRAP")
+	if size == 0 && len(langSizes) == 0 {
+		if bootstrap != "" {
+			return fmt.Errorf("WIKI_CACHE_BOOTSTRAP: кеш отключён (WIKI_CACHE_SIZE=0)")
+		}
+		globalLinkCache = nil
+		return nil
+	}
+	globalLinkCache = newLinkCache(size, langSizes, ttl)
+
+	if bootstrap != "" {
+		n, err := bootstrapLinkCache(globalLinkCache, bootstrap)
+		if err != nil {
+			return fmt.Errorf("WIKI_CACHE_BOOTSTRAP: %w", err)
+		}
+		fmt.Printf("📦 Кеш ссылок прогрет из %s: %d статей\n", bootstrap, n)
+	}
+	return nil
+}
+
+// bootstrapLinkCache загружает в кеш снимок capture из прошлого запуска,
+// чтобы поиск по этим статьям сразу попадал в кеш. Версия и записи
+// снимка проверяются fixture.Load. Записи снимка не устаревают по TTL:
+// офлайн-демо не должно через час уйти в сеть. Возвращает число
+// загруженных статей.
+func bootstrapLinkCache(c *linkCache, path string) (int, error) {
+	capture, err := fixture.Load(path)
+	if err != nil {
+		return 0, err
+	}
+	for _, e := range capture.Entries {
+		page := APIWikiPage{Title: e.Title}
+		links := make([]APILink, len(e.Links))
+		for i, title := range e.Links {
+			links[i].Title = title
+		}
+		if e.Dir == "F" {
+			page.Links = links
+		} else {
+			page.LinksHere = links
+		}
+		for _, ll := range e.LangLinks {
+			page.LangLinks = append(page.LangLinks, APILangLink{Lang: ll.Lang, Title: ll.Title})
+		}
+		c.SetPermanent(e.Lang, linkCacheKey(e.Title, e.Dir, ""), page)
+	}
+	return len(capture.Entries), nil
+}
+
+// globalStore - история поисков в SQLite; nil, если WIKI_SQLITE_DSN не задан
+var globalStore *store.Store
+
+// globalSink получает каждый завершённый поиск. По умолчанию ничего
+// не делает; loadStore подключает SQLite и/или JSONL. Встраивающий
+// сервер может подменить его до запуска
+var globalSink store.Sink = store.Nop{}
+
+// loadStore открывает хранилища завершённых поисков:
+// WIKI_SQLITE_DSN - SQLite (она же /history), WIKI_SQLITE_BUFFER - размер
+// очереди записи (по умолчанию 1000), WIKI_RESULTS_JSONL - файл, куда
+// поиски дописываются по одному JSON на строку.
+func loadStore() error {
+	var sinks []store.Sink
+	if dsn := os.Getenv("WIKI_SQLITE_DSN"); dsn != "" {
+		buffer := 1000
+		if err := envInt("WIKI_SQLITE_BUFFER", &buffer); err != nil {
+			return err
+		}
+		st, err := store.Open(dsn, buffer)
+		if err != nil {
+			return fmt.Errorf("WIKI_SQLITE_DSN: %w", err)
+		}
+		globalStore = st
+		sinks = append(sinks, st)
+	}
+	if path := os.Getenv("WIKI_RESULTS_JSONL"); path != "" {
+		j, err := store.OpenJSONL(path)
+		if err != nil {
+			store.Multi(sinks...).Close()
+			return fmt.Errorf("WIKI_RESULTS_JSONL: %w", err)
+		}
+		sinks = append(sinks, j)
+	}
+	globalSink = store.Multi(sinks...)
+	return nil
+}
+
+// linkCacheKey - ключ записи кеша: направление, дополнительные prop
+// запроса (cacheProps) и название, приведённое как в Key(). Статья,
+// загруженная без pageprops, не годится поиску, которому нужен флаг
+// страницы значений; "NICE" и "Nice" - разные статьи
+func linkCacheKey(title, dir, props string) string {
+	return dir + props + ":" + normalizeTitleAPI(title)
+}
@@ -0,0 +1,151 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLinkCacheTitleCase(t *testing.T) {
+	c := newLinkCache(10, nil, 0)
+	c.Set("en", linkCacheKey("NICE", "F", ""), APIWikiPage{Title: "NICE", Links: links("National Institute for Health and Care Excellence")})
+	c.Set("en", linkCacheKey("Nice", "F", ""), APIWikiPage{Title: "Nice", Links: links("France")})
+
+	tests := []struct{ title, want string }{
+		{"NICE", "NICE"},
+		{"Nice", "Nice"},
+		{"nice", "Nice"},
+		{"Nice_", "Nice"},
+		{"NiCE", ""},
+	}
+	for _, tt := range tests {
+		page, ok := c.Get("en", linkCacheKey(tt.title, "F", ""))
+		if got := page.Title; ok != (tt.want != "") || got != tt.want {
+			t.Errorf("Get(%q) = %q, %v, want %q", tt.title, got, ok, tt.want)
+		}
+	}
+}
+
+func TestLinkCacheWarm(t *testing.T) {
+	wiki := &graphWiki{links: map[string][]string{
+		"Hub":   {"Alpha", "Beta"},
+		"Alpha": {"Hub"},
+		"Beta":  {"Hub"},
+	}}
+	withFakeWiki(t, wiki.ServeHTTP, "en")
+	titles := []string{"Hub", "Alpha", "Beta"}
+
+	cold := newTestSearcher(t, defaultAPIOptions)
+	for _, dir := range []string{"F", "B"} {
+		if pages := cold.load(titles, "en", dir); len(pages) != len(titles) {
+			t.Fatalf("%s: загружено %d статей", dir, len(pages))
+		}
+	}
+	if wiki.requests.Load() == 0 || cold.cacheHits.Load() != 0 {
+		t.Fatalf("холодный кеш: запросов %d, попаданий %d", wiki.requests.Load(), cold.cacheHits.Load())
+	}
+
+	// Другой поиск с тем же кешем: ни одного запроса
+	wiki.requests.Store(0)
+	warm := newTestSearcher(t, defaultAPIOptions)
+	for _, dir := range []string{"F", "B"} {
+		pages := warm.load(titles, "en", dir)
+		if len(pages) != len(titles) {
+			t.Fatalf("%s: из кеша %d статей", dir, len(pages))
+		}
+	}
+	if got := wiki.requests.Load(); got != 0 {
+		t.Errorf("тёплый кеш: запросов %d, want 0", got)
+	}
+	if got := warm.cacheHits.Load(); got != int64(2*len(titles)) {
+		t.Errorf("попаданий в кеш %d, want %d", got, 2*len(titles))
+	}
+
+	// Устаревшие записи запрашиваются заново
+	globalLinkCache = newLinkCache(1000, nil, time.Millisecond)
+	newTestSearcher(t, defaultAPIOptions).load(titles, "en", "F")
+	time.Sleep(5 * time.Millisecond)
+	wiki.requests.Store(0)
+	newTestSearcher(t, defaultAPIOptions).load(titles, "en", "F")
+	if wiki.requests.Load() == 0 {
+		t.Error("записи после TTL отданы из кеша")
+	}
+}
+
+func TestLinkCachePageProps(t *testing.T) {
+	withFakeWiki(t, (&graphWiki{
+		links:    map[string][]string{"Mercury": {"Mercury (planet)", "Mercury (element)"}},
+		disambig: map[string]bool{"Mercury": true},
+	}).ServeHTTP, "en")
+
+	// Поиск без флага страниц значений кладёт статью в кеш без pageprops
+	hub := newTestSearcher(t, defaultAPIOptions)
+	if pages := hub.load([]string{"Mercury"}, "en", "F"); len(pages) != 1 {
+		t.Fatalf("загружено %d статей", len(pages))
+	}
+
+	// Поискам с флагом запись без pageprops не годится; запись с ним
+	// общая для всех, кому он нужен
+	tests := []struct {
+		name     string
+		strategy string
+		check    bool
+		hits     int64
+	}{
+		{"skip", DisambigSkip, false, 0},
+		{"best", DisambigBest, false, 1},
+		{"check_disambig", DisambigHub, true, 1},
+	}
+	for _, tt := range tests {
+		opts := defaultAPIOptions
+		opts.DisambigStrategy, opts.CheckDisambig = tt.strategy, tt.check
+		s := newTestSearcher(t, opts)
+		for _, page := range s.load([]string{"Mercury"}, "en", "F") {
+			if _, ok := page.PageProps["disambiguation"]; !ok {
+				t.Errorf("%s: статья без pageprops: %+v", tt.name, page)
+			}
+		}
+		if got := s.cacheHits.Load(); got != tt.hits {
+			t.Errorf("%s: попаданий в кеш %d, want %d", tt.name, got, tt.hits)
+		}
+	}
+}
+
+func TestLinkCacheCategories(t *testing.T) {
+	withFakeWiki(t, (&graphWiki{
+		links:      map[string][]string{"Venus": {"Morning star"}},
+		categories: map[string][]string{"Venus": {"Category:Planets"}},
+	}).ServeHTTP, "en")
+
+	// Поиск без мостов кладёт статью в кеш без категорий
+	plain := newTestSearcher(t, defaultAPIOptions)
+	plain.load([]string{"Venus"}, "en", "F")
+	plain.load([]string{"Venus"}, "en", "B")
+
+	// Forward-фронту с мостами нужна запись с категориями, backward
+	// их не запрашивает и берёт общую
+	opts := defaultAPIOptions
+	opts.CategoryBridges = true
+	tests := []struct {
+		dir        string
+		hits       int64
+		categories int
+	}{
+		{"F", 0, 1},
+		{"B", 1, 0},
+	}
+	for _, tt := range tests {
+		s := newTestSearcher(t, opts)
+		pages := s.load([]string{"Venus"}, "en", tt.dir)
+		if len(pages) != 1 {
+			t.Fatalf("%s: загружено %d статей", tt.dir, len(pages))
+		}
+		for _, page := range pages {
+			if len(page.Categories) != tt.categories {
+				t.Errorf("%s: категорий %d, want %d", tt.dir, len(page.Categories), tt.categories)
+			}
+		}
+		if got := s.cacheHits.Load(); got != tt.hits {
+			t.Errorf("%s: попаданий в кеш %d, want %d", tt.dir, got, tt.hits)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package server
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// maxCategoryTargets - сколько статей целевой категории берётся в цели
+// (один запрос list=categorymembers)
+const maxCategoryTargets = 500
+
+// categoryPrefixes - пространство имён категорий; английское работает в любом разделе
+var categoryPrefixes = []string{"category:", "категория:", "категорія:", "kategorie:", "catégorie:", "categoría:", "categoria:"}
+
+// categoryTitle дописывает к названию категории пространство имён, если его нет
+func categoryTitle(name string) string {
+	lower := strings.ToLower(name)
+	for _, p := range categoryPrefixes {
+		if strings.HasPrefix(lower, p) {
+			return name
+		}
+	}
+	return "Category:" + name
+}
+
+// SearchCategory ищет путь от start до ближайшей статьи категории: её
+// статьи (до maxCategoryTargets) становятся корнями backward-фронта, и
+// путь заканчивается на первой достигнутой. members - сколько статей
+// в категории нашлось.
+func (s *APISearcher) SearchCategory(start, category, lang string) (path []APIWikiNode, members int) {
+	defer s.drain()
+	start = normalizeTitleAPI(start)
+	category = categoryTitle(normalizeTitleAPI(category))
+	if s.ctx.Err() != nil {
+		return nil, 0
+	}
+
+	startLang, startTitle := lang, start
+	if l, t, _ := s.detectLang(start); l != "" {
+		startLang, startTitle = l, t
+	}
+	wiki, ok := apiWikis[startLang]
+	if !ok {
+		return nil, 0
+	}
+
+	params := url.Values{
+		"action":      {"query"},
+		"format":      {"json"},
+		"list":        {"categorymembers"},
+		"cmtitle":     {category},
+		"cmnamespace": {"0"},
+		"cmlimit":     {strconv.Itoa(maxCategoryTargets)},
+	}
+	data, err := s.query(s.ctx, wiki.APIURL, params)
+	if err != nil {
+		s.failedFetches.Add(1)
+		return nil, 0
+	}
+	members = len(data.Query.CategoryMembers)
+	if members == 0 {
+		return nil, 0
+	}
+
+	// Эвристика ведёт к названию категории; сама категория не корень
+	startNode := s.seed(startLang, startTitle, startLang, category)
+	s.visitedB.Delete(s.endKey)
+	s.endKey = ""
+
+	pqB := &APIPriorityQueue{}
+	heap.Init(pqB)
+	for _, m := range data.Query.CategoryMembers {
+		node := &APIWikiNode{Title: m.Title, Lang: startLang}
+		if node.Key() == s.startKey {
+			return []APIWikiNode{*startNode}, members
+		}
+		node.Priority = s.HeuristicFunc(node.Title, node.Lang, "B")
+		s.visitedB.Store(node.Key(), (*APIWikiNode)(nil))
+		s.enqueue(pqB, node, "B")
+	}
+
+	pqF := &APIPriorityQueue{}
+	heap.Init(pqF)
+	for _, n := range s.fetch([]string{startTitle}, startLang, "F") {
+		s.enqueue(pqF, n, "F")
+	}
+	if s.found.Load() {
+		s.resultMu.Lock()
+		defer s.resultMu.Unlock()
+		s.direct = len(s.result) == 2
+		return s.result, members
+	}
+
+	return s.expand(pqF, pqB), members
+}
+
+// CategorySearchResponse - путь до ближайшей статьи категории
+type CategorySearchResponse struct {
+	SearchResponse
+	Category string `json:"category" example:"Категория:Физика"`
+	// Reached - статья категории, на которой закончился путь
+	Reached string `json:"reached" example:"Теория относительности"`
+	// Members - сколько статей категории было целями (не больше 500)
+	Members int `json:"members" example:"312"`
+}
+
+// SearchCategoryPath godoc
+// @Summary Найти путь до любой статьи категории
+// @Description Статьи категории (до 500, без подкатегорий) становятся целями: поиск останавливается на первой достигнутой
+// @Tags search
+// @Produce json
+// @Param from query string true "Начальная статья" example(Кошка)
+// @Param category query string true "Целевая категория, с пространством имён или без" example(Физика)
+// @Param lang query string false "Язык категории, если не определился язык статьи" example(ru)
+// @Param timeout_ms query int false "Бюджет поиска в мс, до 60000" example(20000)
+// @Success 200 {object} CategorySearchResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 408 {object} ErrorResponse
+// @Failure 502 {object} ErrorResponse
+// @Router /search/category [get]
+func SearchCategoryPath(c *fiber.Ctx) error {
+	req := SearchRequest{
+		From:      normalizeTitleAPI(c.Query("from")),
+		To:        categoryTitle(normalizeTitleAPI(c.Query("category"))),
+		Lang:      c.Query("lang", defaultLang),
+		TimeoutMs: c.QueryInt("timeout_ms"),
+	}
+	if req.From == "" || c.Query("category") == "" {
+		return c.Status(400).JSON(ErrorResponse{
+			Success: false,
+			Error:   "Необходимо указать параметры 'from' и 'category'",
+			Code:    "MISSING_PARAMS",
+		})
+	}
+	if resp := validateSearch(&req); resp != nil {
+		return c.Status(400).JSON(resp)
+	}
+
+	ctx, cancel := requestContext(c)
+	defer cancel()
+	t0 := time.Now()
+	s := NewAPISearcher(ctx, req.Lang, req.From, req.Lang, req.To, withTimeout(defaultAPIOptions, req.TimeoutMs))
+	defer s.cancel()
+	path, members := s.SearchCategory(req.From, req.To, req.Lang)
+	duration := time.Since(t0)
+
+	switch {
+	case len(path) > 0:
+	case s.failedFetches.Load() > 0 && s.rounds == 0:
+		return c.Status(502).JSON(ErrorResponse{
+			Success: false,
+			Error:   "Wikipedia API недоступен",
+			Code:    "UPSTREAM_ERROR",
+		})
+	case members == 0:
+		return c.Status(404).JSON(ErrorResponse{
+			Success: false,
+			Error:   "В категории нет статей",
+			Code:    "CATEGORY_EMPTY",
+		})
+	case errors.Is(s.ctx.Err(), context.DeadlineExceeded):
+		return c.Status(408).JSON(ErrorResponse{
+			Success: false,
+			Error:   "Время поиска истекло",
+			Code:    "SEARCH_TIMEOUT",
+		})
+	default:
+		return c.Status(404).JSON(ErrorResponse{
+			Success: false,
+			Error:   "Путь не найден",
+			Code:    "PATH_NOT_FOUND",
+		})
+	}
+
+	return c.JSON(CategorySearchResponse{
+		SearchResponse: s.response(req, path, duration),
+		Category:       req.To,
+		Reached:        path[len(path)-1].Title,
+		Members:        members,
+	})
+}
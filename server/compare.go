@@ -0,0 +1,282 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// CompareEdition - путь между понятиями в одном языковом разделе
+type CompareEdition struct {
+	Lang    string     `json:"lang" example:"en"`
+	From    string     `json:"from" example:"Cat"`
+	To      string     `json:"to" example:"Dog"`
+	Success bool       `json:"success" example:"true"`
+	Path    []PathStep `json:"path"`
+	// Specific - промежуточные статьи, понятий которых нет в путях других разделов
+	Specific []string `json:"specific" example:"Felidae"`
+	Error    string   `json:"error,omitempty"`
+}
+
+// SharedConcept - промежуточное понятие, общее для путей всех разделов
+type SharedConcept struct {
+	WikidataID string            `json:"wikidata_id" example:"Q7377"`
+	Titles     map[string]string `json:"titles"` // язык -> название статьи
+}
+
+// CompareResponse - пути между одними понятиями в разных разделах
+type CompareResponse struct {
+	Success  bool             `json:"success" example:"true"`
+	From     string           `json:"from" example:"Q146"`
+	To       string           `json:"to" example:"Q144"`
+	Editions []CompareEdition `json:"editions"`
+	Shared   []SharedConcept  `json:"shared"`
+}
+
+const wikidataAPIURL = "https://www.wikidata.org/w/api.php"
+
+var wikidataIDRe = regexp.MustCompile(`^Q[1-9][0-9]*$`)
+
+// wikidataTitles возвращает названия статей элемента Wikidata в разделах langs
+// (sitelinks). Разделы без статьи в результат не попадают.
+func (s *APISearcher) wikidataTitles(qid string, langs []string) (map[string]string, error) {
+	ctx, cancel := s.postSearchContext()
+	defer cancel()
+
+	sites := make([]string, len(langs))
+	for i, lang := range langs {
+		sites[i] = lang + "wiki"
+	}
+	params := url.Values{
+		"action":     {"wbgetentities"},
+		"format":     {"json"},
+		"ids":        {qid},
+		"props":      {"sitelinks"},
+		"sitefilter": {strings.Join(sites, "|")},
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", wikidataAPIURL+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var data struct {
+		Entities map[string]struct {
+			Sitelinks map[string]struct {
+				Title string `json:"title"`
+			} `json:"sitelinks"`
+		} `json:"entities"`
+		Error *APIError `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return nil, err
+	}
+	if data.Error != nil {
+		return nil, data.Error
+	}
+
+	titles := make(map[string]string)
+	for _, lang := range langs {
+		if link, ok := data.Entities[qid].Sitelinks[lang+"wiki"]; ok {
+			titles[lang] = link.Title
+		}
+	}
+	return titles, nil
+}
+
+// compareEnd - названия одного конца по разделам: из Wikidata, если задан
+// Q-ID, иначе одно название для всех; from_<lang> / to_<lang> перекрывают
+func compareEnd(c *fiber.Ctx, name string, langs []string, s *APISearcher) (map[string]string, error) {
+	value := normalizeTitleAPI(c.Query(name))
+	titles := make(map[string]string)
+	if wikidataIDRe.MatchString(value) {
+		resolved, err := s.wikidataTitles(value, langs)
+		if err != nil {
+			return nil, err
+		}
+		titles = resolved
+	} else if value != "" {
+		for _, lang := range langs {
+			titles[lang] = value
+		}
+	}
+	for _, lang := range langs {
+		if v := normalizeTitleAPI(c.Query(name + "_" + lang)); v != "" {
+			titles[lang] = v
+		}
+	}
+	return titles, nil
+}
+
+// compareEditions находит общие промежуточные понятия (по Wikidata ID) путей
+// всех успешных разделов и помечает остальные статьи как особенности раздела.
+// Общих нет, если путь нашёлся меньше чем в двух разделах.
+func compareEditions(editions []CompareEdition) []SharedConcept {
+	found := 0
+	count := make(map[string]int)
+	titles := make(map[string]map[string]string)
+	for _, e := range editions {
+		if !e.Success {
+			continue
+		}
+		found++
+		seen := make(map[string]bool)
+		for _, step := range intermediate(e.Path) {
+			if step.WikidataID == nil || seen[*step.WikidataID] {
+				continue
+			}
+			id := *step.WikidataID
+			seen[id] = true
+			count[id]++
+			if titles[id] == nil {
+				titles[id] = make(map[string]string)
+			}
+			titles[id][e.Lang] = step.Title
+		}
+	}
+
+	shared := []SharedConcept{}
+	isShared := make(map[string]bool)
+	if found >= 2 {
+		for id, n := range count {
+			if n == found {
+				isShared[id] = true
+				shared = append(shared, SharedConcept{WikidataID: id, Titles: titles[id]})
+			}
+		}
+		sort.Slice(shared, func(i, j int) bool { return shared[i].WikidataID < shared[j].WikidataID })
+	}
+
+	for i := range editions {
+		editions[i].Specific = []string{}
+		for _, step := range intermediate(editions[i].Path) {
+			if step.WikidataID == nil || !isShared[*step.WikidataID] {
+				editions[i].Specific = append(editions[i].Specific, step.Title)
+			}
+		}
+	}
+	return shared
+}
+
+// intermediate - шаги пути без концов
+func intermediate(path []PathStep) []PathStep {
+	if len(path) <= 2 {
+		return nil
+	}
+	return path[1 : len(path)-1]
+}
+
+// ComparePaths godoc
+// @Summary Сравнить пути в разных языковых разделах
+// @Description Ищет путь между одними понятиями отдельно в каждом разделе, без interwiki, и показывает, какие промежуточные понятия (по Wikidata) общие, а какие есть только в одном разделе
+// @Tags search
+// @Produce json
+// @Param from query string true "Начальное понятие: Wikidata ID или название" example(Q146)
+// @Param to query string true "Конечное понятие: Wikidata ID или название" example(Q144)
+// @Param langs query string false "Разделы через запятую" example(en,ru)
+// @Param from_en query string false "Название начала в разделе en (from_<lang> для любого раздела)" example(Cat)
+// @Param to_en query string false "Название конца в разделе en (to_<lang> для любого раздела)" example(Dog)
+// @Success 200 {object} CompareResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 502 {object} ErrorResponse
+// @Router /compare [get]
+func ComparePaths(c *fiber.Ctx) error {
+	var langs []string
+	seen := make(map[string]bool)
+	for _, lang := range strings.Split(c.Query("langs", "en,ru"), ",") {
+		lang = strings.TrimSpace(lang)
+		if _, ok := apiWikis[lang]; !ok {
+			return c.Status(400).JSON(ErrorResponse{
+				Success: false,
+				Error:   fmt.Sprintf("Неизвестный язык %q", lang),
+				Code:    "UNKNOWN_LANG",
+			})
+		}
+		if !seen[lang] {
+			seen[lang] = true
+			langs = append(langs, lang)
+		}
+	}
+	if len(langs) < 2 {
+		return c.Status(400).JSON(ErrorResponse{
+			Success: false,
+			Error:   "Для сравнения нужно минимум два раздела",
+			Code:    "MISSING_PARAMS",
+		})
+	}
+
+	ctx, cancel := requestContext(c)
+	defer cancel()
+	resolver := NewAPISearcher(ctx, "", "", "", "", defaultAPIOptions)
+	defer resolver.cancel()
+	from, errFrom := compareEnd(c, "from", langs, resolver)
+	to, errTo := compareEnd(c, "to", langs, resolver)
+	if errFrom != nil || errTo != nil {
+		return c.Status(502).JSON(ErrorResponse{
+			Success: false,
+			Error:   "Wikidata API недоступен",
+			Code:    "UPSTREAM_ERROR",
+		})
+	}
+	if len(from) == 0 || len(to) == 0 {
+		return c.Status(400).JSON(ErrorResponse{
+			Success: false,
+			Error:   "Необходимо указать параметры 'from' и 'to'",
+			Code:    "MISSING_PARAMS",
+		})
+	}
+
+	// Поиск в каждом разделе - как у /search/compare, только ссылки внутри
+	// раздела (cross_lang=false): interwiki сделали бы пути одинаковыми
+	editions := make([]CompareEdition, len(langs))
+	var reqs []SearchRequest
+	var searched []*CompareEdition
+	sameEdition := false
+	for i, lang := range langs {
+		editions[i] = CompareEdition{Lang: lang, From: from[lang], To: to[lang], Path: []PathStep{}}
+		if from[lang] == "" || to[lang] == "" {
+			editions[i].Error = "В разделе нет статьи для одного из понятий"
+			continue
+		}
+		reqs = append(reqs, SearchRequest{
+			From: from[lang], To: to[lang], Lang: lang,
+			FromLang: lang, ToLang: lang,
+			CrossLang: &sameEdition,
+			Wikidata:  true,
+			Format:    FormatJSON,
+			RankBy:    RankShortest,
+		})
+		searched = append(searched, &editions[i])
+	}
+	for i, r := range searchAll(ctx, reqs) {
+		e := searched[i]
+		switch {
+		case r.err != nil:
+			e.Error = r.err.Error
+		case r.resp.Partial:
+			e.Error = "Путь не найден за отведённое время"
+		default:
+			e.Success = true
+			e.Path = r.resp.Path
+		}
+	}
+
+	return c.JSON(CompareResponse{
+		Success:  true,
+		From:     c.Query("from"),
+		To:       c.Query("to"),
+		Editions: editions,
+		Shared:   compareEditions(editions),
+	})
+}
@@ -0,0 +1,203 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/http2"
+
+	"wikiracer/limiter"
+	"wikiracer/wikis"
+)
+
+// WikiConfig - параметры запросов к одному языковому разделу
+type WikiConfig struct {
+	APIURL    string
+	Limit     string // pllimit/lhlimit: "max" или число
+	Namespace string // plnamespace/lhnamespace, несколько - через "|"
+}
+
+// apiWikis - включённые разделы, заполняет loadLangs
+var apiWikis = map[string]*WikiConfig{}
+
+// defaultLang - язык запроса, если он не указан: ru, а если ru
+// не включён - первый раздел WIKI_LANGS
+var defaultLang = "ru"
+
+// loadLangs включает разделы из WIKI_LANGS (по умолчанию wikis.Default).
+// Вызывается до остальных load*: они проверяют языки по apiWikis.
+func loadLangs() error {
+	langs, err := wikis.FromEnv()
+	if err != nil {
+		return err
+	}
+	apiWikis = make(map[string]*WikiConfig, len(langs))
+	for _, l := range langs {
+		apiWikis[l] = &WikiConfig{APIURL: wikis.APIURL(l), Limit: "max", Namespace: "0"}
+	}
+	if _, ok := apiWikis[defaultLang]; !ok {
+		defaultLang = langs[0]
+	}
+	detectLangsAPI = wikis.Sort(langs, detectPriorityAPI)
+
+	// Языки по умолчанию из выключенных разделов отбрасываются
+	var bridge []string
+	for _, l := range defaultAPIOptions.BridgeLangs {
+		if _, ok := apiWikis[l]; ok {
+			bridge = append(bridge, l)
+		}
+	}
+	defaultAPIOptions.BridgeLangs = bridge
+	return nil
+}
+
+// maxLinkLimit - потолок pllimit/lhlimit у MediaWiki (для ботов)
+const maxLinkLimit = 5000
+
+// loadWikiConfigs переопределяет лимиты и пространства имён по языкам.
+// WIKI_LANG_LIMITS - например "en=200,de=300",
+// WIKI_LANG_NAMESPACES - например "en=0,uk=0|14".
+func loadWikiConfigs() error {
+	limits, err := envLangMap("WIKI_LANG_LIMITS")
+	if err != nil {
+		return err
+	}
+	for lang, v := range limits {
+		if n, err := strconv.Atoi(v); v != "max" && (err != nil || n < 1 || n > maxLinkLimit) {
+			return fmt.Errorf("WIKI_LANG_LIMITS: %s: нужен \"max\" или число от 1 до %d, получено %q", lang, maxLinkLimit, v)
+		}
+		apiWikis[lang].Limit = v
+	}
+
+	namespaces, err := envLangMap("WIKI_LANG_NAMESPACES")
+	if err != nil {
+		return err
+	}
+	for lang, v := range namespaces {
+		if _, err := parseNamespacesAPI(v); err != nil {
+			return fmt.Errorf("WIKI_LANG_NAMESPACES: %s: %w", lang, err)
+		}
+		apiWikis[lang].Namespace = v
+	}
+	return nil
+}
+
+// parseNamespacesAPI разбирает пространства имён через "|", как
+// plnamespace: "0|14" - статьи и категории
+func parseNamespacesAPI(v string) ([]int, error) {
+	var out []int
+	for _, ns := range strings.Split(v, "|") {
+		n, err := strconv.Atoi(strings.TrimSpace(ns))
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("неверное пространство имён %q", ns)
+		}
+		out = append(out, n)
+	}
+	return out, nil
+}
+
+// joinNamespacesAPI - обратно к виду "0|14"
+func joinNamespacesAPI(namespaces []int) string {
+	parts := make([]string, len(namespaces))
+	for i, n := range namespaces {
+		parts[i] = strconv.Itoa(n)
+	}
+	return strings.Join(parts, "|")
+}
+
+// envLangMap читает переменную вида "en=a,uk=b"; языки должны быть в apiWikis
+func envLangMap(name string) (map[string]string, error) {
+	m := make(map[string]string)
+	v := os.Getenv(name)
+	if v == "" {
+		return m, nil
+	}
+	for _, pair := range strings.Split(v, ",") {
+		lang, val, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || val == "" {
+			return nil, fmt.Errorf("%s: неверная пара %q", name, pair)
+		}
+		if _, known := apiWikis[lang]; !known {
+			return nil, fmt.Errorf("%s: неизвестный язык %q", name, lang)
+		}
+		m[lang] = val
+	}
+	return m, nil
+}
+
+// Глобальный HTTP клиент с прогретыми соединениями. Своего таймаута у
+// него нет: предел попытки - контекст запроса (RequestTimeout)
+var globalHTTPClient *http.Client
+
+func initGlobalClient() {
+	tr := &http.Transport{
+		MaxIdleConns:        1000,
+		MaxIdleConnsPerHost: 200,
+		MaxConnsPerHost:     0,
+		IdleConnTimeout:     90 * time.Second,
+		DisableCompression:  false,
+		ForceAttemptHTTP2:   true,
+	}
+	http2.ConfigureTransport(tr)
+	globalHTTPClient = &http.Client{Transport: tr}
+}
+
+// setMaxLagAPI добавляет maxlag к параметрам запроса; 0 - не добавлять
+func setMaxLagAPI(params url.Values, maxLag int) {
+	if maxLag > 0 {
+		params.Set("maxlag", strconv.Itoa(maxLag))
+	}
+}
+
+// setUserAgent ставит описательный User-Agent с контактом, как просит
+// Wikimedia, и его копию в Api-User-Agent (его видят и при проксировании)
+func setUserAgent(req *http.Request, ua string) {
+	req.Header.Set("User-Agent", ua)
+	req.Header.Set("Api-User-Agent", ua)
+}
+
+// globalLimiter - общий предел запросов (WIKI_MAX_INFLIGHT, WIKI_MAX_RPS)
+var globalLimiter = limiter.New(50, 0)
+
+// loadLimiter настраивает globalLimiter: WIKI_MAX_INFLIGHT - запросов
+// одновременно (по умолчанию 50), WIKI_MAX_RPS - запросов в секунду
+// (по умолчанию без предела)
+func loadLimiter() error {
+	inflight, perSecond := 50, 0
+	if err := envInt("WIKI_MAX_INFLIGHT", &inflight); err != nil {
+		return err
+	}
+	if err := envInt("WIKI_MAX_RPS", &perSecond); err != nil {
+		return err
+	}
+	if inflight < 0 || perSecond < 0 {
+		return fmt.Errorf("WIKI_MAX_INFLIGHT и WIKI_MAX_RPS не могут быть отрицательными")
+	}
+	globalLimiter = limiter.New(inflight, perSecond)
+	return nil
+}
+
+// Режимы обработки continue-токенов (plcontinue/lhcontinue/llcontinue)
+const (
+	ContinueFollow = "follow" // догружать продолжения всего батча, раскладывая ссылки по pageid
+	ContinueSplit  = "split"  // перезапрашивать обрезанные статьи батча по одной
+	ContinueOff    = "off"    // брать только первую страницу ответа
+)
+
+// Политики для конца пути с явным языком, которого в этом языке нет
+const (
+	LangConflictExplicit = "explicit" // доверять явному языку: поиска нет, 404 ARTICLE_NOT_FOUND
+	LangConflictDetect   = "detect"   // определить язык как без него, с предупреждением в warnings
+)
+
+// Что делать со страницей значений в середине пути (pageprops disambiguation)
+const (
+	DisambigHub  = "hub"  // раскрывать как обычную статью, со штрафом ListPenalty детям
+	DisambigSkip = "skip" // не раскрывать вовсе
+	DisambigBest = "best" // раскрыть только самую перспективную по эвристике ссылку
+)
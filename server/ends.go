@@ -0,0 +1,141 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// detectEnds определяет язык и настоящее название обоих концов пути;
+// если не определился ни один, оба остаются в языке lang
+func (s *APISearcher) detectEnds(start, end, lang string) (startLang, startTitle, endLang, endTitle string) {
+	startLang, startTitle = lang, start
+	endLang, endTitle = lang, end
+
+	var wgDetect sync.WaitGroup
+	var startOK, endOK bool
+	var startWarn, endWarn string
+	wgDetect.Add(2)
+
+	go func() {
+		defer wgDetect.Done()
+		if l, t, warn := s.resolveEnd("from", start, s.fromLang); l != "" {
+			startLang, startTitle = l, t
+			startOK = true
+			startWarn = warn
+		}
+	}()
+	go func() {
+		defer wgDetect.Done()
+		if l, t, warn := s.resolveEnd("to", end, s.toLang); l != "" {
+			endLang, endTitle = l, t
+			endOK = true
+			endWarn = warn
+		}
+	}()
+	wgDetect.Wait()
+
+	for _, warn := range []string{startWarn, endWarn} {
+		if warn != "" {
+			s.warnings = append(s.warnings, warn)
+		}
+	}
+
+	// Если определился только один конец, второй берём по символам,
+	// а не языком по умолчанию, и проверяем название в угаданном разделе.
+	// Отсутствующий конец уже в s.missing - поиск всё равно не пойдёт
+	if startOK != endOK && len(s.missing) == 0 {
+		if !startOK {
+			startLang, startTitle = s.guessEnd("from", start)
+		} else {
+			endLang, endTitle = s.guessEnd("to", end)
+		}
+	}
+	return
+}
+
+// guessEnd - язык конца пути по символам (guessLangAPI), когда detectLang
+// не успел его определить. Название сверяется с угаданным разделом: найдено -
+// настоящее название, статьи нет - конец в s.missing, раздел не ответил -
+// исходное название с предупреждением
+func (s *APISearcher) guessEnd(name, title string) (lang, realTitle string) {
+	lang = guessLangAPI(title)
+	if _, ok := apiWikis[lang]; !ok {
+		s.warnings = append(s.warnings, fmt.Sprintf("%s: язык '%s' не определён, а раздел %s выключен", name, title, lang))
+		return lang, title
+	}
+	ctx, cancel := context.WithTimeout(s.ctx, s.opts.DetectTimeout)
+	defer cancel()
+	realTitle, found, err := s.findTitle(ctx, lang, title)
+	switch {
+	case found:
+		return lang, realTitle
+	case err == nil:
+		s.endMissing(name, "", fmt.Sprintf("%s: язык '%s' не определён, а в %s статьи нет", name, title, lang))
+	default:
+		s.warnings = append(s.warnings, fmt.Sprintf("%s: язык '%s' не определён, название не проверено в %s", name, title, lang))
+	}
+	return lang, title
+}
+
+// resolveEnd определяет язык конца пути. Без явного языка - detectLang.
+// С явным языком статья ищется в нём; если её там нет, по политике
+// LangConflict поиск либо не идёт (s.missing), либо язык определяется
+// как обычно с предупреждением. Статьи нет ни в одном разделе - поиск
+// тоже не идёт. Пустой язык - не определился.
+func (s *APISearcher) resolveEnd(name, title, explicit string) (lang, realTitle, warning string) {
+	var missing bool
+	if explicit == "" {
+		lang, realTitle, missing = s.detectLang(title)
+		if missing {
+			s.endMissing(name, "", fmt.Sprintf("%s: статьи '%s' нет ни в одном разделе (%s)",
+				name, title, strings.Join(s.detectCandidates(guessLangAPI(title)), ", ")))
+		}
+		return lang, realTitle, ""
+	}
+
+	ctx, cancel := context.WithTimeout(s.ctx, s.opts.DetectTimeout)
+	defer cancel()
+	if t, ok := s.lookupTitle(ctx, explicit, title); ok {
+		return explicit, t, ""
+	}
+
+	if s.opts.LangConflict == LangConflictExplicit {
+		s.endMissing(name, "ARTICLE_NOT_FOUND", fmt.Sprintf("%s: статьи '%s' нет в %s", name, title, explicit))
+		return explicit, title, ""
+	}
+	lang, realTitle, missing = s.detectLang(title)
+	if missing {
+		s.endMissing(name, "", fmt.Sprintf("%s: статьи '%s' нет ни в %s, ни в других разделах", name, title, explicit))
+		return explicit, title, ""
+	}
+	if lang == "" {
+		return explicit, title, fmt.Sprintf("%s: статьи '%s' нет в %s, язык определить не удалось", name, title, explicit)
+	}
+	return lang, realTitle, fmt.Sprintf("%s: статьи '%s' нет в %s, использована %s:%s", name, title, explicit, lang, realTitle)
+}
+
+// endMissing запоминает отсутствующий конец пути ("from" или "to");
+// пустой code - START_NOT_FOUND или END_NOT_FOUND по концу
+func (s *APISearcher) endMissing(name, code, msg string) {
+	kind := ErrStartMissing
+	if name == "to" {
+		kind = ErrEndMissing
+	}
+	if code == "" {
+		code = "START_NOT_FOUND"
+		if name == "to" {
+			code = "END_NOT_FOUND"
+		}
+	}
+	s.missingMu.Lock()
+	s.missing = append(s.missing, &endError{kind, code, msg})
+	s.missingMu.Unlock()
+}
+
+// budgetExhausted сообщает, что бюджет запросов или раундов израсходован
+func (s *APISearcher) budgetExhausted() bool {
+	return (s.opts.MaxRequests > 0 && s.reqCount.Load() >= int64(s.opts.MaxRequests)) ||
+		(s.opts.MaxRounds > 0 && s.rounds >= s.opts.MaxRounds)
+}
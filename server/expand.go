@@ -0,0 +1,806 @@
+package server
+
+import (
+	"container/heap"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"wikiracer/metrics"
+	"wikiracer/wikis"
+)
+
+// fetch загружает статьи батча и раскрывает их
+func (s *APISearcher) fetch(titles []string, lang, dir string) []*APIWikiNode {
+	return s.process(s.load(titles, lang, dir), lang, dir)
+}
+
+// load возвращает статьи батча: из кеша или запросом к API
+func (s *APISearcher) load(titles []string, lang, dir string) map[string]APIWikiPage {
+	if s.found.Load() || len(titles) == 0 {
+		return nil
+	}
+
+	// Статьи из кеша не запрашиваем повторно
+	pages := make(map[string]APIWikiPage)
+	var missing []string
+	for _, title := range titles {
+		if page, ok := s.cached(lang, title, dir); ok {
+			pages["cache:"+title] = page
+			s.cacheHits.Add(1)
+			metrics.CacheLookups.WithLabelValues(lang, "hit").Inc()
+		} else {
+			missing = append(missing, title)
+		}
+	}
+	if s.cache != nil {
+		s.cacheMisses.Add(int64(len(missing)))
+		metrics.CacheLookups.WithLabelValues(lang, "miss").Add(float64(len(missing)))
+	}
+
+	if len(missing) > 0 {
+		fetched, err := s.fetchPages(missing, lang, dir)
+		// Ошибки после встречи или по истечении контекста - не потери графа
+		if err != nil && !s.found.Load() && s.ctx.Err() == nil {
+			s.failedFetches.Add(1)
+		}
+		if err != nil && len(pages) == 0 {
+			return nil
+		}
+		for id, page := range fetched {
+			pages[id] = page
+		}
+	}
+	return pages
+}
+
+// process раскрывает загруженные статьи: отмечает детей посещёнными,
+// ищет встречу фронтов и возвращает новые узлы для очереди
+func (s *APISearcher) process(pages map[string]APIWikiPage, lang, dir string) []*APIWikiNode {
+	if s.found.Load() || len(pages) == 0 {
+		return nil
+	}
+
+	var own, other *sync.Map
+	if dir == "F" {
+		own, other = &s.visitedF, &s.visitedB
+	} else {
+		own, other = &s.visitedB, &s.visitedF
+	}
+
+	var newNodes []*APIWikiNode
+
+	var reciprocal map[string]string
+	if s.opts.StrictInterwiki && s.expandLangLinks(dir) {
+		reciprocal = s.reciprocalLangLinks(pages, lang)
+	}
+
+	// Статьи - по названию: обход map случаен, а от порядка зависит,
+	// какая встреча найдётся первой
+	ids := make([]string, 0, len(pages))
+	for id := range pages {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if a, b := pages[ids[i]].Title, pages[ids[j]].Title; a != b {
+			return a < b
+		}
+		return ids[i] < ids[j]
+	})
+
+	for _, id := range ids {
+		page := pages[id]
+		if s.found.Load() {
+			return nil
+		}
+		parent := APIWikiNode{Title: page.Title, Lang: lang}
+
+		var links []APILink
+		if dir == "F" {
+			links = page.Links
+		} else {
+			links = page.LinksHere
+		}
+		s.capture.Record(captureEntry(lang, dir, page.Title, links, page.LangLinks))
+
+		// Статья запрошена под другим названием (редирект USA → United
+		// States) - посещён канонический ключ, и другой фронт мог уже
+		// до него дойти
+		if s.adoptAliases(page, lang, dir) && s.meetCanonical(parent, dir) && s.maxPaths <= 1 {
+			return nil
+		}
+
+		// Страница значений (pageprops): в жёстком режиме или со стратегией
+		// skip не раскрываем, со стратегией best берём одну ссылку, иначе
+		// штрафуем её детей. Концы пути раскрываются всегда.
+		penalty := 0
+		onlyBest := false
+		if _, ok := page.PageProps["disambiguation"]; ok {
+			key := parent.Key()
+			if key != s.startKey && key != s.endKey {
+				if s.skipsDisambig() {
+					continue
+				}
+				onlyBest = s.opts.DisambigStrategy == DisambigBest
+			}
+			penalty = s.opts.ListPenalty
+		}
+
+		// Кандидаты: ссылки той же вики и interwiki
+		candidates := make([]APIWikiNode, 0, len(links)+len(page.LangLinks))
+		for _, link := range links {
+			candidates = append(candidates, APIWikiNode{Title: link.Title, Lang: lang})
+		}
+		if s.expandLangLinks(dir) {
+			for _, ll := range s.selectLangLinks(page.LangLinks) {
+				node := APIWikiNode{Title: ll.Title, Lang: ll.Lang}
+				if reciprocal != nil && !strings.EqualFold(reciprocal[node.Key()], page.Title) {
+					s.interwikiRejected.Add(1)
+					continue
+				}
+				candidates = append(candidates, node)
+			}
+			if dropped := unconfiguredLangLinks(page.LangLinks); len(dropped) > 0 {
+				s.interwikiDropped.Add(int64(len(dropped)))
+				// Ни одного interwiki в подключённые разделы - ищем
+				// статью там через её версии в остальных
+				if s.opts.InterwikiFallback && dir == "F" && len(dropped) == len(page.LangLinks) {
+					candidates = append(candidates, s.fallbackLangLinks(parent, dropped)...)
+				}
+			}
+		}
+		if s.opts.CategoryBridges && dir == "F" {
+			candidates = append(candidates, s.categorySiblings(page, lang)...)
+		}
+		if onlyBest {
+			candidates = s.bestCandidate(candidates, dir)
+		}
+		var parentLangs []string
+		if s.maxLangs > 0 {
+			parentLangs = s.nodeLangs(parent.Key(), lang, dir)
+		}
+		childDepth := s.nodeDepth(parent.Key(), dir) + 1
+		firstNew := len(newNodes)
+
+		for _, cand := range candidates {
+			child := &APIWikiNode{
+				Title:    cand.Title,
+				Lang:     cand.Lang,
+				Priority: s.HeuristicFunc(cand.Title, cand.Lang, dir) + penalty,
+			}
+			if cand.Bridge != "" {
+				child.Priority += categoryBridgePenalty
+			}
+			if s.opts.Shortest {
+				child.Priority = shortestPriority(childDepth, child.Priority)
+			}
+			key := child.Key()
+
+			// Лимит языков: кандидат не может добавить языка сверх лимита,
+			// а встреча - дать путь, в сумме превышающий лимит
+			var childLangs []string
+			if s.maxLangs > 0 {
+				childLangs = withLang(parentLangs, cand.Lang)
+				if len(childLangs) > s.maxLangs {
+					s.langsDropped.Add(1)
+					continue
+				}
+			}
+
+			if _, exists := other.Load(key); exists {
+				// Другой фронт мог только найти узел, не раскрыв его, и
+				// pageprops страницы значений ещё никто не видел
+				if s.skipsDisambig() && key != s.startKey && key != s.endKey && s.disambigMeet(*child) {
+					s.blockedBy[blockedDisambig].Add(1)
+					continue
+				}
+				if s.maxLangs > 0 && len(unionLangs(childLangs, s.nodeLangs(key, cand.Lang, otherDir(dir)))) > s.maxLangs {
+					s.langsDropped.Add(1)
+					continue
+				}
+				if s.opts.MaxDepth > 0 && childDepth+s.nodeDepth(key, otherDir(dir)) > s.opts.MaxDepth {
+					s.depthPruned.Add(1)
+					continue
+				}
+				if s.opts.Shortest {
+					// Встреча не останавливает поиск: узел раскрывается
+					// дальше, чтобы слой оставался полным
+					if s.shortestMeet(dir, &parent, child, cand.Bridge, childDepth, childLangs) &&
+						(s.opts.MaxDepth == 0 || childDepth < s.opts.MaxDepth) {
+						newNodes = append(newNodes, child)
+					}
+					continue
+				}
+				if s.found.CompareAndSwap(false, true) {
+					// Уже посещённый своей стороной узел сохраняет прежнего
+					// родителя: перезапись могла бы замкнуть цепочку в петлю
+					if _, loaded := own.LoadOrStore(key, &parent); !loaded {
+						s.markBridge(key, cand.Bridge, dir)
+						s.explored.add(dir, &parent, child, cand.Bridge)
+					}
+					s.animate.add(AnimationEvent{Round: s.rounds, Type: "expand", Dir: dir, Node: parent.String()}, newNodes[firstNew:])
+					s.animate.add(AnimationEvent{Round: s.rounds, Type: "meet", Dir: dir, Node: child.String()}, nil)
+					s.resultMu.Lock()
+					s.result = s.buildPath(*child)
+					s.meet = *child
+					s.meets = append(s.meets, APIWikiNode{Title: child.Title, Lang: child.Lang, Via: dir})
+					s.resultMu.Unlock()
+					// Нужно несколько путей - даём раунду доиграть,
+					// остальные встречи соберёт ветка ниже
+					if s.maxPaths <= 1 {
+						s.cancel()
+						return nil
+					}
+					continue
+				}
+				if s.maxPaths > 1 {
+					s.addMeet(*child, &parent, cand.Bridge, dir)
+					continue
+				}
+			}
+
+			// Встреча с запрещённым узлом возможна только если это конец
+			// пути, остальные запреты не пускают кандидата в очередь
+			if reason := s.blocked(*child); reason != notBlocked {
+				s.blockedBy[reason].Add(1)
+				continue
+			}
+
+			// Отсекаем кандидатов намного хуже лучшего узла фронта
+			if s.prunedBySlack(child.Priority, dir) {
+				continue
+			}
+
+			if _, loaded := own.LoadOrStore(key, &parent); !loaded {
+				s.markBridge(key, cand.Bridge, dir)
+				s.explored.add(dir, &parent, child, cand.Bridge)
+				if s.maxLangs > 0 {
+					s.langsMap(dir).Store(key, childLangs)
+				}
+				if s.tracksDepth() {
+					s.depthMap(dir).Store(key, childDepth)
+					// Дети узла на пределе дали бы путь длиннее: узел
+					// остаётся посещённым для встреч, но не раскрывается
+					if s.opts.MaxDepth > 0 && childDepth >= s.opts.MaxDepth {
+						s.depthPruned.Add(1)
+						continue
+					}
+				}
+				newNodes = append(newNodes, child)
+			} else if key != parent.Key() && (s.opts.MaxDepth == 0 || childDepth < s.opts.MaxDepth) {
+				// Узел уже найден через другого родителя - если он ещё
+				// ждёт в очереди, expand снизит ему приоритет
+				s.rediscover(dir, rediscovery{
+					key: key, parent: parent, priority: child.Priority,
+					bridge: cand.Bridge, depth: childDepth, langs: childLangs,
+				})
+			}
+		}
+		if !s.found.Load() {
+			s.animate.add(AnimationEvent{Round: s.rounds, Type: "expand", Dir: dir, Node: parent.String()}, newNodes[firstNew:])
+		}
+	}
+
+	return newNodes
+}
+
+// adoptAliases переносит посещение статьи с названия, под которым её
+// запросили (page.Aliases), на каноническое: тот же родитель, мост,
+// языки и глубина. Без этого цепочка родителей рвётся на редиректе, а
+// фронты, дошедшие до статьи под разными названиями, не встречаются.
+// true - канонический ключ посещён впервые.
+func (s *APISearcher) adoptAliases(page APIWikiPage, lang, dir string) bool {
+	own := &s.visitedF
+	if dir == "B" {
+		own = &s.visitedB
+	}
+	key := APIWikiNode{Title: page.Title, Lang: lang}.Key()
+	for _, alias := range page.Aliases {
+		aliasKey := APIWikiNode{Title: alias, Lang: lang}.Key()
+		parent, ok := own.Load(aliasKey)
+		if !ok || aliasKey == key {
+			continue
+		}
+		if _, loaded := own.LoadOrStore(key, parent); loaded {
+			return false
+		}
+		if cat, ok := s.bridges.Load(aliasKey); ok && dir == "F" {
+			s.bridges.Store(key, cat)
+		}
+		if langs, ok := s.langsMap(dir).Load(aliasKey); ok {
+			s.langsMap(dir).Store(key, langs)
+		}
+		if depth, ok := s.depthMap(dir).Load(aliasKey); ok {
+			s.depthMap(dir).Store(key, depth)
+		}
+		return true
+	}
+	return false
+}
+
+// meetCanonical проверяет встречу на канонической статье, только что
+// принятой adoptAliases, с теми же ограничениями, что и встреча в fetch.
+// true - путь найден.
+func (s *APISearcher) meetCanonical(node APIWikiNode, dir string) bool {
+	other := &s.visitedB
+	if dir == "B" {
+		other = &s.visitedF
+	}
+	key := node.Key()
+	if _, ok := other.Load(key); !ok {
+		return false
+	}
+	if s.maxLangs > 0 && len(unionLangs(s.nodeLangs(key, node.Lang, dir), s.nodeLangs(key, node.Lang, otherDir(dir)))) > s.maxLangs {
+		s.langsDropped.Add(1)
+		return false
+	}
+	if s.opts.MaxDepth > 0 && s.nodeDepth(key, dir)+s.nodeDepth(key, otherDir(dir)) > s.opts.MaxDepth {
+		s.depthPruned.Add(1)
+		return false
+	}
+	if s.opts.Shortest {
+		s.recordShortest(node, dir)
+		return false
+	}
+	if !s.found.CompareAndSwap(false, true) {
+		if s.maxPaths > 1 {
+			s.addMeet(node, nil, "", dir)
+		}
+		return false
+	}
+	s.animate.add(AnimationEvent{Round: s.rounds, Type: "meet", Dir: dir, Node: node.String()}, nil)
+	s.resultMu.Lock()
+	s.result = s.buildPath(node)
+	s.meet = node
+	s.meets = append(s.meets, APIWikiNode{Title: node.Title, Lang: node.Lang, Via: dir})
+	s.resultMu.Unlock()
+	if s.maxPaths <= 1 {
+		s.cancel()
+	}
+	return true
+}
+
+// rediscovery - узел, снова найденный через другого родителя: приоритет,
+// родитель и всё, что от родителя зависит
+type rediscovery struct {
+	key      string
+	parent   APIWikiNode
+	priority int
+	bridge   string
+	depth    int
+	langs    []string
+}
+
+func (s *APISearcher) rediscover(dir string, r rediscovery) {
+	s.rediscMu.Lock()
+	defer s.rediscMu.Unlock()
+	if dir == "F" {
+		s.rediscF = append(s.rediscF, r)
+	} else {
+		s.rediscB = append(s.rediscB, r)
+	}
+}
+
+// enqueue кладёт узел в очередь dir и запоминает его для reprioritize.
+// Через enqueue проходят все узлы очередей, начиная с первого раунда:
+// иначе decrease-key не видит самый большой, первый фронт.
+func (s *APISearcher) enqueue(pq *APIPriorityQueue, n *APIWikiNode, dir string) {
+	if s.openF == nil {
+		s.openF = make(map[string]*APIWikiNode)
+		s.openB = make(map[string]*APIWikiNode)
+	}
+	heap.Push(pq, n)
+	if dir == "F" {
+		s.openF[n.Key()] = n
+	} else {
+		s.openB[n.Key()] = n
+	}
+}
+
+// reprioritize применяет повторные находки раунда к очереди dir
+// (decrease-key): узел, который ещё ждёт раскрытия и найден с лучшим
+// приоритетом, получает этот приоритет и нового родителя, heap.Fix
+// восстанавливает порядок по его Index. Корни фронтов не трогаются.
+// Вызывается из expand, когда fetch раунда закончены.
+func (s *APISearcher) reprioritize(pq *APIPriorityQueue, dir string) {
+	s.rediscMu.Lock()
+	found := s.rediscF
+	s.rediscF = nil
+	open := s.openF
+	own := &s.visitedF
+	if dir == "B" {
+		found = s.rediscB
+		s.rediscB = nil
+		open = s.openB
+		own = &s.visitedB
+	}
+	s.rediscMu.Unlock()
+
+	for _, r := range found {
+		node, ok := open[r.key]
+		if !ok || node.Index < 0 || r.priority >= node.Priority {
+			continue
+		}
+		if val, ok := own.Load(r.key); !ok || val.(*APIWikiNode) == nil {
+			continue
+		}
+		parent := r.parent
+		own.Store(r.key, &parent)
+		s.markBridge(r.key, r.bridge, dir)
+		if s.maxLangs > 0 {
+			s.langsMap(dir).Store(r.key, r.langs)
+		}
+		if s.tracksDepth() {
+			s.depthMap(dir).Store(r.key, r.depth)
+		}
+		node.Priority = r.priority
+		heap.Fix(pq, node.Index)
+		s.reprioritized.Add(1)
+	}
+}
+
+// reciprocalLangLinks узнаёт для interwiki статей pages, какая статья языка
+// lang на них ссылается обратно: ключ - Key() статьи на другом языке,
+// значение - название её interwiki на lang. Сначала кеш, остальное -
+// запросом prop=langlinks&lllang=lang, батчами по языкам.
+func (s *APISearcher) reciprocalLangLinks(pages map[string]APIWikiPage, lang string) map[string]string {
+	back := make(map[string]string)
+	missing := make(map[string][]string)
+	for _, page := range pages {
+		for _, ll := range s.selectLangLinks(page.LangLinks) {
+			key := APIWikiNode{Title: ll.Title, Lang: ll.Lang}.Key()
+			if _, ok := back[key]; ok {
+				continue
+			}
+			if title, ok := s.cachedLangLink(ll.Lang, ll.Title, lang); ok {
+				back[key] = title
+				continue
+			}
+			back[key] = ""
+			missing[ll.Lang] = append(missing[ll.Lang], ll.Title)
+		}
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for l, titles := range missing {
+		for i := 0; i < len(titles); i += 50 {
+			end := i + 50
+			if end > len(titles) {
+				end = len(titles)
+			}
+			wg.Add(1)
+			go func(l string, batch []string) {
+				defer wg.Done()
+				params := url.Values{
+					"action":    {"query"},
+					"format":    {"json"},
+					"prop":      {"langlinks"},
+					"lllang":    {lang},
+					"titles":    {strings.Join(batch, "|")},
+					"redirects": {"1"},
+				}
+				data, err := s.query(s.ctx, apiWikis[l].APIURL, params)
+				if err != nil {
+					return
+				}
+				mu.Lock()
+				defer mu.Unlock()
+				for _, title := range batch {
+					page, ok := data.pageByTitle(title)
+					if !ok {
+						continue
+					}
+					for _, ll := range page.LangLinks {
+						if ll.Lang == lang {
+							back[APIWikiNode{Title: title, Lang: l}.Key()] = ll.Title
+						}
+					}
+				}
+			}(l, titles[i:end])
+		}
+	}
+	wg.Wait()
+	return back
+}
+
+// cachedLangLink ищет в кеше interwiki статьи на язык lang. Статья могла
+// попасть в кеш из любого направления - langlinks запрашиваются в обоих.
+func (s *APISearcher) cachedLangLink(pageLang, title, lang string) (string, bool) {
+	for _, dir := range []string{"F", "B"} {
+		page, ok := s.cached(pageLang, title, dir)
+		if !ok {
+			continue
+		}
+		for _, ll := range page.LangLinks {
+			if ll.Lang == lang {
+				return ll.Title, true
+			}
+		}
+		return "", true
+	}
+	return "", false
+}
+
+// langsMap - языки узлов фронта dir
+func (s *APISearcher) langsMap(dir string) *sync.Map {
+	if dir == "F" {
+		return &s.langsF
+	}
+	return &s.langsB
+}
+
+// nodeLangs - языки на пути от корня фронта dir до узла; у корней и
+// неизвестных узлов - только их собственный язык
+func (s *APISearcher) nodeLangs(key, lang, dir string) []string {
+	if v, ok := s.langsMap(dir).Load(key); ok {
+		return v.([]string)
+	}
+	return []string{lang}
+}
+
+func (s *APISearcher) depthMap(dir string) *sync.Map {
+	if dir == "F" {
+		return &s.depthF
+	}
+	return &s.depthB
+}
+
+// tracksDepth - нужна ли глубина узлов: для MaxDepth и Shortest
+func (s *APISearcher) tracksDepth() bool {
+	return s.opts.MaxDepth > 0 || s.opts.Shortest
+}
+
+// nodeDepth - переходов от корня фронта dir до узла; корни и узлы,
+// посещённые без MaxDepth и Shortest, - 0
+func (s *APISearcher) nodeDepth(key, dir string) int {
+	if !s.tracksDepth() {
+		return 0
+	}
+	if v, ok := s.depthMap(dir).Load(key); ok {
+		return v.(int)
+	}
+	return 0
+}
+
+func otherDir(dir string) string {
+	if dir == "F" {
+		return "B"
+	}
+	return "F"
+}
+
+// withLang возвращает langs с добавленным lang; исходный срез не меняется
+func withLang(langs []string, lang string) []string {
+	for _, l := range langs {
+		if l == lang {
+			return langs
+		}
+	}
+	out := make([]string, len(langs), len(langs)+1)
+	copy(out, langs)
+	return append(out, lang)
+}
+
+// unionLangs - языки обоих срезов без повторов
+func unionLangs(a, b []string) []string {
+	for _, l := range b {
+		a = withLang(a, l)
+	}
+	return a
+}
+
+// expandLangLinks сообщает, раскрываются ли interwiki в направлении dir
+func (s *APISearcher) expandLangLinks(dir string) bool {
+	if dir == "F" {
+		return s.opts.LangLinksForward
+	}
+	return s.opts.LangLinksBackward
+}
+
+// selectLangLinks оставляет interwiki на поддерживаемые языки, а при
+// LangLinkLangs - только на них и в их порядке; затем режет до LangLinkLimit
+func (s *APISearcher) selectLangLinks(links []APILangLink) []APILangLink {
+	var selected []APILangLink
+	if len(s.opts.LangLinkLangs) > 0 {
+		byLang := make(map[string]APILangLink, len(links))
+		for _, ll := range links {
+			if ll.Title != "" {
+				byLang[ll.Lang] = ll
+			}
+		}
+		for _, lang := range s.opts.LangLinkLangs {
+			if ll, ok := byLang[lang]; ok {
+				selected = append(selected, ll)
+			}
+		}
+	} else {
+		for _, ll := range links {
+			if _, ok := apiWikis[ll.Lang]; ok && ll.Title != "" {
+				selected = append(selected, ll)
+			}
+		}
+	}
+
+	if s.opts.LangLinkLimit > 0 && len(selected) > s.opts.LangLinkLimit {
+		selected = selected[:s.opts.LangLinkLimit]
+	}
+	return selected
+}
+
+// interwikiFallbackLimit - сколько версий статьи в неподключённых разделах
+// опрашивать ради InterwikiFallback: каждая - отдельный запрос
+const interwikiFallbackLimit = 3
+
+// unconfiguredLangLinks - interwiki статьи в разделы не из apiWikis
+func unconfiguredLangLinks(links []APILangLink) []APILangLink {
+	var out []APILangLink
+	for _, ll := range links {
+		if _, ok := apiWikis[ll.Lang]; !ok && ll.Title != "" {
+			out = append(out, ll)
+		}
+	}
+	return out
+}
+
+// fallbackLangLinks ищет статью parent в подключённых разделах через её
+// версии в неподключённых (dropped): их interwiki могут вести туда, куда
+// у самой статьи ссылок нет. Опрашиваются первые interwikiFallbackLimit
+// версий. Если версии указывают в одном разделе на разные статьи,
+// берётся та, на которую указывает больше версий, и раздел с большим
+// числом голосов идёт первым. Дальше - как обычные interwiki через
+// selectLangLinks; переход запоминается в fallbackEdges для ответа.
+func (s *APISearcher) fallbackLangLinks(parent APIWikiNode, dropped []APILangLink) []APIWikiNode {
+	type vote struct {
+		link    APILangLink
+		through string // первая версия, которая указала на link
+		count   int
+	}
+	if len(dropped) > interwikiFallbackLimit {
+		dropped = dropped[:interwikiFallbackLimit]
+	}
+	votes := make(map[string]*vote)
+	var order []string
+	for _, via := range dropped {
+		for _, ll := range s.foreignLangLinks(via) {
+			if _, ok := apiWikis[ll.Lang]; !ok || ll.Title == "" || ll.Lang == parent.Lang {
+				continue
+			}
+			key := APIWikiNode{Title: ll.Title, Lang: ll.Lang}.Key()
+			v := votes[key]
+			if v == nil {
+				v = &vote{link: ll, through: via.Lang + ":" + via.Title}
+				votes[key] = v
+				order = append(order, key)
+			}
+			v.count++
+		}
+	}
+
+	best := make(map[string]*vote)
+	var langs []string
+	for _, key := range order {
+		v := votes[key]
+		b, ok := best[v.link.Lang]
+		if !ok {
+			langs = append(langs, v.link.Lang)
+		}
+		if !ok || v.count > b.count {
+			best[v.link.Lang] = v
+		}
+	}
+	sort.SliceStable(langs, func(i, j int) bool {
+		return best[langs[i]].count > best[langs[j]].count
+	})
+	links := make([]APILangLink, len(langs))
+	for i, lang := range langs {
+		links[i] = best[lang].link
+	}
+
+	var nodes []APIWikiNode
+	for _, ll := range s.selectLangLinks(links) {
+		node := APIWikiNode{Title: ll.Title, Lang: ll.Lang}
+		s.fallbackEdges.Store(parent.Key()+">"+node.Key(), best[ll.Lang].through)
+		s.interwikiFallback.Add(1)
+		nodes = append(nodes, node)
+	}
+	return nodes
+}
+
+// foreignLangLinks - interwiki статьи ll в её разделе. Ошибка запроса
+// поиску не мешает: кандидатов через эту версию просто нет.
+func (s *APISearcher) foreignLangLinks(ll APILangLink) []APILangLink {
+	params := url.Values{
+		"action":    {"query"},
+		"format":    {"json"},
+		"prop":      {"langlinks"},
+		"titles":    {ll.Title},
+		"lllimit":   {"max"},
+		"redirects": {"1"},
+	}
+	data, err := s.query(s.ctx, wikiAPIURL(ll.Lang), params)
+	if err != nil {
+		return nil
+	}
+	page, ok := data.pageByTitle(ll.Title)
+	if !ok {
+		return nil
+	}
+	return page.LangLinks
+}
+
+// fallbackThrough - версия "lang:title", через которую forward-фронт
+// перешёл из from в to (InterwikiFallback); "" - переход прямой
+func (s *APISearcher) fallbackThrough(from, to APIWikiNode) string {
+	through, _ := s.fallbackEdges.Load(from.Key() + ">" + to.Key())
+	str, _ := through.(string)
+	return str
+}
+
+// wikiAPIURL - адрес API раздела: из apiWikis, а для неподключённого
+// раздела (InterwikiFallback) - по коду языка
+func wikiAPIURL(lang string) string {
+	if wiki, ok := apiWikis[lang]; ok {
+		return wiki.APIURL
+	}
+	return wikis.APIURL(lang)
+}
+
+// categoryBridgePenalty - штраф шагу через категорию: он слабее ссылки
+// и не проходится одним кликом
+const categoryBridgePenalty = 10
+
+// categoryMembersLimit - сколько статей категории брать в кандидаты
+const categoryMembersLimit = 50
+
+// categorySiblings возвращает статьи из первых CategoryLimit категорий
+// страницы. Каждая категория раскрывается за поиск один раз, всего - не
+// больше CategoryBudget.
+func (s *APISearcher) categorySiblings(page APIWikiPage, lang string) []APIWikiNode {
+	var siblings []APIWikiNode
+	for i, cat := range page.Categories {
+		if i >= s.opts.CategoryLimit {
+			break
+		}
+		if _, done := s.expandedCats.LoadOrStore(lang+":"+cat.Title, true); done {
+			continue
+		}
+		if s.categoryBudget.Add(-1) < 0 {
+			break
+		}
+
+		params := url.Values{
+			"action":      {"query"},
+			"format":      {"json"},
+			"list":        {"categorymembers"},
+			"cmtitle":     {cat.Title},
+			"cmnamespace": {"0"},
+			"cmlimit":     {strconv.Itoa(categoryMembersLimit)},
+		}
+		data, err := s.query(s.ctx, apiWikis[lang].APIURL, params)
+		if err != nil {
+			continue
+		}
+		for _, m := range data.Query.CategoryMembers {
+			if m.Title != page.Title {
+				siblings = append(siblings, APIWikiNode{Title: m.Title, Lang: lang, Bridge: cat.Title})
+			}
+		}
+	}
+	return siblings
+}
+
+// markBridge запоминает, что узел key связан с forward-родителем категорией
+// (или что уже нет - если родитель перезаписан обычной ссылкой)
+func (s *APISearcher) markBridge(key, category, dir string) {
+	if dir != "F" {
+		return
+	}
+	if category != "" {
+		s.bridges.Store(key, category)
+	} else {
+		s.bridges.Delete(key)
+	}
+}
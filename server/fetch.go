@@ -0,0 +1,617 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"wikiracer/fixture"
+	"wikiracer/limiter"
+	"wikiracer/metrics"
+)
+
+// captureLimit - максимум статей в снимке capture=true, чтобы ответ оставался компактным
+const captureLimit = 5000
+
+// captureEntry переводит ссылки статьи в запись снимка
+func captureEntry(lang, dir, title string, links []APILink, langLinks []APILangLink) fixture.Entry {
+	e := fixture.Entry{Lang: lang, Dir: dir, Title: title, Links: make([]string, len(links))}
+	for i, link := range links {
+		e.Links[i] = link.Title
+	}
+	for _, ll := range langLinks {
+		e.LangLinks = append(e.LangLinks, fixture.LangLink{Lang: ll.Lang, Title: ll.Title})
+	}
+	return e
+}
+
+// wantsPageProps - поиску нужен флаг страницы значений (prop=pageprops)
+func (s *APISearcher) wantsPageProps() bool {
+	return s.opts.CheckDisambig || s.opts.DisambigStrategy != DisambigHub
+}
+
+// cacheProps - дополнительные prop, с которыми поиск запрашивает статьи
+// направления dir, в виде части ключа кеша ссылок
+func (s *APISearcher) cacheProps(dir string) string {
+	props := ""
+	if s.wantsPageProps() {
+		props += "+pageprops"
+	}
+	if s.opts.CategoryBridges && dir == "F" {
+		props += "+categories"
+	}
+	return props
+}
+
+// cached - ссылки статьи из кеша, загруженные с теми же props, что нужны
+// этому поиску. Без кеша (nil) - промах
+func (s *APISearcher) cached(lang, title, dir string) (APIWikiPage, bool) {
+	return s.cache.Get(lang, linkCacheKey(title, dir, s.cacheProps(dir)))
+}
+
+// fetchPages запрашивает ссылки статей батча с учётом continue-токенов
+// и кладёт полностью загруженные статьи в кеш
+func (s *APISearcher) fetchPages(titles []string, lang, dir string) (map[string]APIWikiPage, error) {
+	wiki := apiWikis[lang]
+	apiURL := wiki.APIURL
+	namespace := wiki.Namespace
+	if len(s.opts.Namespaces) > 0 {
+		namespace = joinNamespacesAPI(s.opts.Namespaces)
+	}
+	var params url.Values
+
+	if dir == "F" {
+		params = url.Values{
+			"action":      {"query"},
+			"format":      {"json"},
+			"prop":        {"links|langlinks"},
+			"titles":      {strings.Join(titles, "|")},
+			"pllimit":     {wiki.Limit},
+			"lllimit":     {"max"},
+			"plnamespace": {namespace},
+			"redirects":   {"1"},
+		}
+	} else {
+		params = url.Values{
+			"action":      {"query"},
+			"format":      {"json"},
+			"prop":        {"linkshere|langlinks"},
+			"titles":      {strings.Join(titles, "|")},
+			"lhlimit":     {wiki.Limit},
+			"lllimit":     {"max"},
+			"lhnamespace": {namespace},
+			"redirects":   {"1"},
+		}
+	}
+
+	// Флаг страницы значений - ещё один prop в том же запросе
+	if s.wantsPageProps() {
+		params.Set("prop", params.Get("prop")+"|pageprops")
+		params.Set("ppprop", "disambiguation")
+	}
+
+	// Категории статьи - для мостов через категории
+	if s.opts.CategoryBridges && dir == "F" {
+		params.Set("prop", params.Get("prop")+"|categories")
+		params.Set("clshow", "!hidden")
+		params.Set("cllimit", "max")
+	}
+
+	maxPages := s.opts.ContinuePages
+	if s.opts.ContinueMode != ContinueFollow {
+		maxPages = 0
+	}
+	pages, cont, err := s.queryAll(apiURL, params, maxPages)
+	// Сервер не принял длинный URL - делим батч пополам, а не теряем его
+	if errors.Is(err, errURITooLong) && len(titles) > 1 {
+		half := len(titles) / 2
+		pages, err := s.fetchPages(titles[:half], lang, dir)
+		if err != nil {
+			return nil, err
+		}
+		rest, err := s.fetchPages(titles[half:], lang, dir)
+		if err != nil {
+			return nil, err
+		}
+		for id, page := range rest {
+			pages[id] = page
+		}
+		return pages, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// continue-токен общий на весь батч: в split-режиме дозапрашиваем
+	// обрезанные статьи по одной, чтобы не потерять их ссылки
+	if cont != nil && s.opts.ContinueMode == ContinueSplit && len(titles) > 1 {
+		for _, id := range truncatedPages(pages, cont) {
+			single := url.Values{}
+			for k, v := range params {
+				single[k] = v
+			}
+			single.Set("titles", pages[id].Title)
+			full, _, err := s.queryAll(apiURL, single, s.opts.ContinuePages)
+			if err != nil {
+				continue
+			}
+			for fid, page := range full {
+				// Дозапрос шёл по каноническому названию - псевдонимы из батча
+				page.Aliases = pages[fid].Aliases
+				pages[fid] = page
+			}
+		}
+		cont = nil
+	}
+
+	// Обрезанные статьи в кеш не попадают - иначе кеш навсегда запомнит неполный список
+	incomplete := make(map[string]bool)
+	if cont != nil {
+		for _, id := range truncatedPages(pages, cont) {
+			incomplete[id] = true
+		}
+	}
+	for id, page := range pages {
+		if !incomplete[id] && !page.Clipped && !strings.HasPrefix(id, "-") {
+			s.cache.Set(lang, linkCacheKey(page.Title, dir, s.cacheProps(dir)), page)
+		}
+	}
+
+	return pages, nil
+}
+
+// query выполняет запрос к MediaWiki API. Временные ошибки MediaWiki
+// (transientAPIErrors) повторяются до TransientRetries раз, остальные
+// возвращаются как *APIError, а не как пустой ответ.
+func (s *APISearcher) query(ctx context.Context, apiURL string, params url.Values) (*APIWikiResponse, error) {
+	for attempt := 0; ; attempt++ {
+		data, err := s.queryOnce(ctx, apiURL, params)
+		if err != nil {
+			return nil, err
+		}
+		if data.Error == nil {
+			return data, nil
+		}
+
+		if !transientAPIErrors[data.Error.Code] || attempt >= s.opts.TransientRetries {
+			s.log.Warn("mediawiki error", "code", data.Error.Code, "info", data.Error.Info)
+			return nil, data.Error
+		}
+		s.log.Warn("mediawiki retry", "code", data.Error.Code, "info", data.Error.Info, "attempt", attempt+1)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(s.opts.TransientBackoff * time.Duration(attempt+1)):
+		}
+	}
+}
+
+// wikiLangOf - язык раздела по адресу его API; "" - адрес не из apiWikis
+func wikiLangOf(apiURL string) string {
+	for lang, wiki := range apiWikis {
+		if wiki.APIURL == apiURL {
+			return lang
+		}
+	}
+	return ""
+}
+
+// requestDirection - направление запроса для метрик: forward (links),
+// backward (linkshere) или other
+func requestDirection(params url.Values) string {
+	for _, prop := range strings.Split(params.Get("prop"), "|") {
+		switch prop {
+		case "links":
+			return "forward"
+		case "linkshere":
+			return "backward"
+		}
+	}
+	return "other"
+}
+
+// requestParams - параметры запроса: из URL или, для POST (длинные
+// titles, MaxGETURL), из копии тела
+func requestParams(req *http.Request) url.Values {
+	if req.Method != http.MethodPost || req.GetBody == nil {
+		return req.URL.Query()
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil
+	}
+	defer body.Close()
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil
+	}
+	params, _ := url.ParseQuery(string(data))
+	return params
+}
+
+// errURITooLong - сервер ответил 414: строка запроса слишком длинная
+var errURITooLong = errors.New("414: слишком длинный URL")
+
+// queryOnce выполняет один запрос к MediaWiki API. Если URL длиннее
+// MaxGETURL (50 длинных кириллических названий в titles легко дают
+// десятки килобайт), параметры уходят телом POST - MediaWiki принимает
+// action=query и так.
+func (s *APISearcher) queryOnce(ctx context.Context, apiURL string, params url.Values) (*APIWikiResponse, error) {
+	if s.opts.MaxLag > 0 && params.Get("maxlag") == "" {
+		// Копия: params вызывающего переиспользуются для continue
+		withLag := make(url.Values, len(params)+1)
+		for k, v := range params {
+			withLag[k] = v
+		}
+		setMaxLagAPI(withLag, s.opts.MaxLag)
+		params = withLag
+	}
+	encoded := params.Encode()
+	var req *http.Request
+	var err error
+	if s.opts.MaxGETURL > 0 && len(apiURL)+1+len(encoded) > s.opts.MaxGETURL {
+		req, err = http.NewRequestWithContext(ctx, "POST", apiURL, strings.NewReader(encoded))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+	} else {
+		req, err = http.NewRequestWithContext(ctx, "GET", apiURL+"?"+encoded, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.doWithRetry(req, s.opts.HTTPRetries+1)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusRequestURITooLong {
+		return nil, errURITooLong
+	}
+
+	body := &countingReader{r: resp.Body}
+	var data APIWikiResponse
+	if err := json.NewDecoder(body).Decode(&data); err != nil {
+		return nil, err
+	}
+	s.checkResponseSize(body.n, params, &data)
+	return &data, nil
+}
+
+// do выполняет HTTP-запрос к API: ставит User-Agent, притормаживает при
+// низкой квоте хоста и запоминает новую квоту из ответа. При debug=true
+// запрос попадает в s.trace.
+func (s *APISearcher) do(req *http.Request) (*http.Response, error) {
+	// Запрос отменённого поиска не уходит и не тратит бюджет
+	if err := req.Context().Err(); err != nil {
+		return nil, err
+	}
+	setUserAgent(req, s.opts.UserAgent)
+	if delay := limiter.Delay(req.URL.Host, s.opts.ThrottlePercent, time.Now()); delay > 0 {
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(delay):
+		}
+	}
+
+	// Предел поиска держат все его запросы, а не только загрузка батчей:
+	// interwiki, категории и страницы значений запрашиваются из process
+	if s.fetchSlots != nil {
+		select {
+		case s.fetchSlots <- struct{}{}:
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+	waitStart := time.Now()
+	release, err := globalLimiter.Acquire(req.Context())
+	s.limiterWait.Add(int64(time.Since(waitStart)))
+	if err != nil {
+		s.releaseFetchSlot()
+		return nil, err
+	}
+	if s.fetchSlots != nil {
+		global := release
+		release = func() {
+			global()
+			s.releaseFetchSlot()
+		}
+	}
+
+	// Предел попытки отсчитывается после ожидания лимитов и действует,
+	// пока тело не закрыто; повтор в doWithRetry получает новый
+	attempt, cancel := req, context.CancelFunc(func() {})
+	if s.opts.RequestTimeout > 0 {
+		var ctx context.Context
+		ctx, cancel = context.WithTimeout(req.Context(), s.opts.RequestTimeout)
+		attempt = req.WithContext(ctx)
+	}
+
+	// Считается каждая отправленная попытка, в том числе повторы и
+	// неудачные: по reqCount работают MaxRequests, stats и difficulty
+	s.reqCount.Add(1)
+	metrics.WikiRequests.WithLabelValues(wikiLangOf(req.URL.Scheme+"://"+req.URL.Host+req.URL.Path), requestDirection(requestParams(req))).Inc()
+
+	start := time.Now()
+	if s.opts.DrainTimeout > 0 {
+		s.inflight.Add(1)
+	}
+	resp, err := s.client.Do(attempt)
+	if err == nil {
+		limiter.Observe(req.URL.Host, resp.Header)
+		// Слот занят, пока тело не прочитано: соединение всё ещё работает
+		resp.Body = &doneBody{ReadCloser: resp.Body, done: func() {
+			release()
+			cancel()
+		}}
+	} else {
+		release()
+		cancel()
+	}
+	if s.opts.DrainTimeout > 0 {
+		if err != nil {
+			s.inflight.Done()
+		} else {
+			resp.Body = &doneBody{ReadCloser: resp.Body, done: s.inflight.Done}
+		}
+	}
+	if s.trace == nil {
+		return resp, err
+	}
+	if err != nil {
+		s.trace.add(RequestTrace{
+			URL:       req.URL.String(),
+			LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+			Error:     err.Error(),
+		})
+		return nil, err
+	}
+	resp.Body = &tracedBody{
+		ReadCloser: resp.Body,
+		trace:      RequestTrace{URL: req.URL.String(), Status: resp.StatusCode},
+		start:      start,
+		tracer:     s.trace,
+	}
+	return resp, nil
+}
+
+// releaseFetchSlot освобождает место запроса, занятое в do
+func (s *APISearcher) releaseFetchSlot() {
+	if s.fetchSlots != nil {
+		<-s.fetchSlots
+	}
+}
+
+// doWithRetry выполняет запрос через do, повторяя его при сетевой ошибке,
+// ответах 429/503 и ошибке maxlag - всего не больше attempts попыток. Пауза берётся из
+// Retry-After, иначе растёт экспоненциально со случайной добавкой.
+// Повторы прекращаются с отменой контекста запроса.
+func (s *APISearcher) doWithRetry(req *http.Request, attempts int) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		resp, err := s.do(req)
+		if attempt >= attempts || (err == nil && !retryableAPI(resp)) {
+			return resp, err
+		}
+
+		delay := backoffDelayAPI(s.opts.TransientBackoff, attempt)
+		if err == nil {
+			if d, ok := retryAfterAPI(resp.Header.Get("Retry-After"), time.Now()); ok {
+				delay = d
+			}
+			resp.Body.Close()
+			s.log.Warn("http retry", "status", resp.StatusCode, "host", req.URL.Host,
+				"attempt", attempt, "delay_ms", delay.Milliseconds())
+		}
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(delay):
+		}
+		// Тело POST уже прочитано первой попыткой
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+	}
+}
+
+// retryableAPI - ответы, после которых есть смысл повторить запрос: лимит
+// частоты, перегрузка (в том числе 503 с X-Database-Lag) и maxlag, который
+// MediaWiki отдаёт с кодом 200 и заголовком MediaWiki-API-Error
+func retryableAPI(resp *http.Response) bool {
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return true
+	}
+	return resp.Header.Get("MediaWiki-API-Error") == "maxlag"
+}
+
+// backoffDelayAPI - пауза перед повтором attempt: base * 2^(attempt-1)
+// плюс случайная добавка до половины этой величины
+func backoffDelayAPI(base time.Duration, attempt int) time.Duration {
+	d := base << (attempt - 1)
+	return d + time.Duration(rand.Int63n(int64(d)/2+1))
+}
+
+// retryAfterAPI разбирает Retry-After: секунды или HTTP-дата
+func retryAfterAPI(v string, now time.Time) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := t.Sub(now); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// doneBody вызывает done при первом закрытии тела
+type doneBody struct {
+	io.ReadCloser
+	once sync.Once
+	done func()
+}
+
+func (b *doneBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.done)
+	return err
+}
+
+// drain ждёт не дольше DrainTimeout, пока закроются все запросы поиска
+func (s *APISearcher) drain() {
+	if s.opts.DrainTimeout <= 0 {
+		return
+	}
+	done := make(chan struct{})
+	go func() {
+		s.inflight.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(s.opts.DrainTimeout):
+		s.log.Warn("drain timeout", "timeout_ms", s.opts.DrainTimeout.Milliseconds())
+	}
+}
+
+// countingReader считает прочитанные байты тела ответа
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// checkResponseSize запоминает самый большой ответ и, если ответ огромный,
+// пишет о нём в лог и урезает ссылки статей до LargeResponseLinks
+func (s *APISearcher) checkResponseSize(size int64, params url.Values, data *APIWikiResponse) {
+	for {
+		cur := s.largestResponse.Load()
+		if size <= cur || s.largestResponse.CompareAndSwap(cur, size) {
+			break
+		}
+	}
+
+	if s.opts.LargeResponseBytes <= 0 || size <= s.opts.LargeResponseBytes {
+		return
+	}
+	s.log.Warn("large response", "kb", size>>10, "prop", params.Get("prop"), "titles", params.Get("titles"))
+
+	limit := s.opts.LargeResponseLinks
+	if limit <= 0 {
+		return
+	}
+	for id, page := range data.Query.Pages {
+		if len(page.Links) > limit {
+			page.Links = page.Links[:limit]
+			page.Clipped = true
+		}
+		if len(page.LinksHere) > limit {
+			page.LinksHere = page.LinksHere[:limit]
+			page.Clipped = true
+		}
+		data.Query.Pages[id] = page
+	}
+}
+
+// queryAll выполняет запрос и догружает до maxPages страниц продолжения.
+// Ссылки со всех страниц сливаются по pageid, так что в батче каждая ссылка
+// остаётся привязанной к своей статье. Возвращает непустой continue, если
+// ответ так и остался обрезанным.
+func (s *APISearcher) queryAll(apiURL string, params url.Values, maxPages int) (map[string]APIWikiPage, map[string]string, error) {
+	data, err := s.query(s.ctx, apiURL, params)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Редиректы приходят в первом ответе, продолжения их только повторяют
+	data.attachAliases()
+	pages := data.Query.Pages
+	if pages == nil {
+		pages = make(map[string]APIWikiPage)
+	}
+	cont := data.Continue
+
+	for i := 0; i < maxPages && cont != nil && !s.found.Load(); i++ {
+		next := url.Values{}
+		for k, v := range params {
+			next[k] = v
+		}
+		for k, v := range cont {
+			next.Set(k, v)
+		}
+
+		data, err := s.query(s.ctx, apiURL, next)
+		if err != nil {
+			// Уже полученные страницы остаются полезными
+			return pages, cont, nil
+		}
+		for id, page := range data.Query.Pages {
+			merged, ok := pages[id]
+			if !ok {
+				pages[id] = page
+				continue
+			}
+			merged.Links = append(merged.Links, page.Links...)
+			merged.LinksHere = append(merged.LinksHere, page.LinksHere...)
+			merged.LangLinks = append(merged.LangLinks, page.LangLinks...)
+			merged.Clipped = merged.Clipped || page.Clipped
+			pages[id] = merged
+		}
+		cont = data.Continue
+	}
+
+	return pages, cont, nil
+}
+
+// truncatedPages возвращает pageid статей, чьи ссылки обрезаны continue-токеном.
+// MediaWiki отдаёт страницы батча по возрастанию pageid, а токены вида
+// "pageid|..." указывают первую недогруженную - она и все следующие неполные.
+func truncatedPages(pages map[string]APIWikiPage, cont map[string]string) []string {
+	minID := -1
+	for k, v := range cont {
+		if k == "continue" {
+			continue
+		}
+		id, err := strconv.Atoi(strings.SplitN(v, "|", 2)[0])
+		if err != nil {
+			continue
+		}
+		if minID < 0 || id < minID {
+			minID = id
+		}
+	}
+	if minID < 0 {
+		return nil
+	}
+
+	var ids []string
+	for id := range pages {
+		if n, err := strconv.Atoi(id); err == nil && n >= minID {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
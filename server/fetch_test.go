@@ -0,0 +1,383 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"wikiracer/limiter"
+)
+
+func TestTruncatedPages(t *testing.T) {
+	pages := map[string]APIWikiPage{"10": {}, "20": {}, "30": {}, "-1": {}}
+	tests := []struct {
+		name string
+		cont map[string]string
+		want []string
+	}{
+		{"без продолжения", nil, nil},
+		{"только continue", map[string]string{"continue": "||"}, nil},
+		{"середина батча", map[string]string{"plcontinue": "20|0|B2", "continue": "||"}, []string{"20", "30"}},
+		{"меньший из токенов", map[string]string{"plcontinue": "30|0|X", "llcontinue": "10|de"}, []string{"10", "20", "30"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := truncatedPages(pages, tt.cont)
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("truncatedPages = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFetchPagesLongTitles(t *testing.T) {
+	// 50 длинных кириллических названий: закодированные в URL, они дают
+	// больше 10 КБ. Сервер, как nginx перед MediaWiki, отвечает 414 на URL
+	// длиннее 4000 байт
+	graph := &graphWiki{links: map[string][]string{}}
+	var titles []string
+	for i := 0; i < 50; i++ {
+		title := fmt.Sprintf("Очень длинное название статьи про кошек номер %d", i)
+		titles = append(titles, title)
+		graph.links[title] = []string{"Кошка"}
+	}
+	var posts, rejected atomic.Int64
+	withFakeWiki(t, func(w http.ResponseWriter, r *http.Request) {
+		if len(r.RequestURI) > 4000 {
+			rejected.Add(1)
+			w.WriteHeader(http.StatusRequestURITooLong)
+			return
+		}
+		if r.Method == http.MethodPost {
+			posts.Add(1)
+		}
+		graph.ServeHTTP(w, r)
+	}, "ru")
+
+	tests := []struct {
+		name      string
+		maxGETURL int
+		post      bool // батч ушёл POST
+		split     bool // батч делился после 414
+	}{
+		{"POST для длинного URL", 4000, true, false},
+		{"всегда GET: деление на 414", 0, false, true},
+	}
+	for _, tt := range tests {
+		posts.Store(0)
+		rejected.Store(0)
+		opts := defaultAPIOptions
+		opts.MaxGETURL = tt.maxGETURL
+		s := newTestSearcher(t, opts)
+		pages, err := s.fetchPages(titles, "ru", "F")
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		got := map[string]bool{}
+		for _, page := range pages {
+			got[page.Title] = true
+		}
+		for _, title := range titles {
+			if !got[title] {
+				t.Errorf("%s: статья %q потеряна", tt.name, title)
+			}
+		}
+		if (posts.Load() > 0) != tt.post || (rejected.Load() > 0) != tt.split {
+			t.Errorf("%s: POST-запросов %d, ответов 414 %d", tt.name, posts.Load(), rejected.Load())
+		}
+	}
+}
+
+func TestFetchPagesSharedContinue(t *testing.T) {
+	tests := []struct {
+		mode   string
+		want   map[string][]string // pageid -> ссылки
+		cached []string
+	}{
+		{ContinueFollow, map[string][]string{"10": {"A1"}, "20": {"B1", "B2"}, "30": {"G1"}}, []string{"Alpha", "Beta", "Gamma"}},
+		{ContinueSplit, map[string][]string{"10": {"A1"}, "20": {"B1", "B2"}, "30": {"G1"}}, []string{"Alpha", "Beta", "Gamma"}},
+		{ContinueOff, map[string][]string{"10": {"A1"}, "20": {"B1"}, "30": {}}, []string{"Alpha"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			withFakeWiki(t, truncatingWiki(), "en")
+			opts := defaultAPIOptions
+			opts.ContinueMode = tt.mode
+			s := newTestSearcher(t, opts)
+
+			pages, err := s.fetchPages([]string{"Alpha", "Beta", "Gamma"}, "en", "F")
+			if err != nil {
+				t.Fatal(err)
+			}
+			for id, want := range tt.want {
+				if got := linkTitles(pages[id].Links); !reflect.DeepEqual(got, want) {
+					t.Errorf("ссылки %s (%s) = %v, want %v", id, pages[id].Title, got, want)
+				}
+			}
+
+			cached := map[string]bool{}
+			for _, title := range tt.cached {
+				cached[title] = true
+			}
+			for _, title := range []string{"Alpha", "Beta", "Gamma"} {
+				_, ok := s.cached("en", title, "F")
+				if ok != cached[title] {
+					t.Errorf("%s в кеше = %v, want %v", title, ok, cached[title])
+				}
+			}
+		})
+	}
+}
+
+func TestDoWithRetry(t *testing.T) {
+	tests := []struct {
+		name       string
+		attempts   int
+		wantStatus int
+		wantCalls  int64
+	}{
+		{"успех с третьей попытки", 3, http.StatusOK, 3},
+		{"попытки кончились", 2, http.StatusServiceUnavailable, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int64
+			srv := withFakeWiki(t, func(w http.ResponseWriter, r *http.Request) {
+				if calls.Add(1) <= 2 {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				io.WriteString(w, "ok")
+			}, "en")
+			opts := defaultAPIOptions
+			opts.TransientBackoff = time.Millisecond
+			s := newTestSearcher(t, opts)
+			logs := captureLog(s)
+
+			req, err := http.NewRequestWithContext(s.ctx, "GET", srv.URL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := s.doWithRetry(req, tt.attempts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus || calls.Load() != tt.wantCalls {
+				t.Errorf("статус %d после %d запросов, want %d после %d",
+					resp.StatusCode, calls.Load(), tt.wantStatus, tt.wantCalls)
+			}
+			// Бюджет MaxRequests считает каждую попытку, а не только удачную
+			if got := s.reqCount.Load(); got != tt.wantCalls {
+				t.Errorf("reqCount %d, want %d", got, tt.wantCalls)
+			}
+			retries := logEvents(t, logs, "http retry")
+			if want := int(tt.wantCalls) - 1; len(retries) != want {
+				t.Fatalf("событий http retry: %d, want %d", len(retries), want)
+			}
+			if e := retries[0]; e["level"] != "WARN" || e["status"] != float64(503) || e["attempt"] != float64(1) {
+				t.Errorf("событие повтора %v", e)
+			}
+		})
+	}
+}
+
+func TestRequestTimeout(t *testing.T) {
+	// Хаб отвечает 300мс, но ответ верный
+	graph := &graphWiki{links: map[string][]string{"Hub": {"Spoke"}}}
+	withFakeWiki(t, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(300 * time.Millisecond):
+		case <-r.Context().Done():
+			return
+		}
+		graph.ServeHTTP(w, r)
+	}, "en")
+
+	tests := []struct {
+		name     string
+		timeout  time.Duration
+		wantErr  bool
+		requests int64
+	}{
+		{"медленный ответ в пределах попытки", time.Second, false, 1},
+		{"без предела попытки", 0, false, 1},
+		// Каждая попытка упирается в предел и повторяется
+		{"ответ дольше предела", 50 * time.Millisecond, true, 3},
+	}
+	for _, tt := range tests {
+		globalLinkCache = newLinkCache(1000, nil, 0)
+		opts := defaultAPIOptions
+		opts.RequestTimeout = tt.timeout
+		opts.HTTPRetries = 2
+		opts.TransientBackoff = time.Millisecond
+		s := newTestSearcher(t, opts)
+		pages, err := s.fetchPages([]string{"Hub"}, "en", "F")
+
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: ошибка %v, want ошибка %v", tt.name, err, tt.wantErr)
+		}
+		if err == nil && len(pages) != 1 {
+			t.Errorf("%s: статей %d, want 1", tt.name, len(pages))
+		}
+		if got := s.reqCount.Load(); got != tt.requests {
+			t.Errorf("%s: попыток %d, want %d", tt.name, got, tt.requests)
+		}
+	}
+}
+
+func TestRequestLimiterInflight(t *testing.T) {
+	const limit = 2
+	var inflight, peak, calls atomic.Int64
+	withFakeWiki(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		n := inflight.Add(1)
+		defer inflight.Add(-1)
+		for {
+			old := peak.Load()
+			if n <= old || peak.CompareAndSwap(old, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		writeJSON(w, APIWikiResponse{})
+	}, "en")
+	saved := globalLimiter
+	globalLimiter = limiter.New(limit, 0)
+	t.Cleanup(func() { globalLimiter = saved })
+
+	// Два поиска одновременно: предел общий для всех APISearcher сервера
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		s := newTestSearcher(t, defaultAPIOptions)
+		for j := 0; j < 5; j++ {
+			wg.Add(1)
+			go func(j int) {
+				defer wg.Done()
+				params := url.Values{"action": {"query"}, "titles": {fmt.Sprint("Page ", j)}}
+				if _, err := s.query(s.ctx, apiWikis["en"].APIURL, params); err != nil {
+					t.Error(err)
+				}
+			}(j)
+		}
+	}
+	wg.Wait()
+
+	if got := calls.Load(); got != 10 {
+		t.Fatalf("запросов %d, want 10", got)
+	}
+	if got := peak.Load(); got != limit {
+		t.Errorf("одновременных запросов до %d, want ровно %d", got, limit)
+	}
+}
+
+func TestFetchConcurrencyLimit(t *testing.T) {
+	graph := &graphWiki{links: map[string][]string{}, categories: map[string][]string{}}
+	for i := 0; i < 200; i++ {
+		title := fmt.Sprintf("P%03d", i)
+		graph.links["Start"] = append(graph.links["Start"], title)
+		graph.categories[title] = []string{fmt.Sprintf("Category:C%03d", i)}
+	}
+	graph.links["Elsewhere"] = []string{"Target"}
+
+	var inflight, peak atomic.Int64
+	withFakeWiki(t, func(w http.ResponseWriter, r *http.Request) {
+		n := inflight.Add(1)
+		defer inflight.Add(-1)
+		for {
+			old := peak.Load()
+			if n <= old || peak.CompareAndSwap(old, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		graph.ServeHTTP(w, r)
+	}, "en")
+
+	// Раунд раскрывает 4 батча P000..P199; у каждой статьи категория,
+	// и запросы categorymembers идут уже из process, после загрузки батча
+	opts := defaultAPIOptions
+	opts.FetchConcurrency = 2
+	opts.CategoryBridges = true
+	opts.MaxRounds = 3
+	s := newTestSearcher(t, opts)
+	s.Search("Start", "Target", "en")
+
+	if s.opts.CategoryBudget-int(s.categoryBudget.Load()) == 0 {
+		t.Fatal("категории не раскрывались")
+	}
+	if got := peak.Load(); got > int64(opts.FetchConcurrency) {
+		t.Errorf("одновременных запросов поиска до %d, want не больше %d", got, opts.FetchConcurrency)
+	}
+}
+
+func TestMaxLag(t *testing.T) {
+	t.Run("параметр и User-Agent", func(t *testing.T) {
+		graph := &graphWiki{links: map[string][]string{"Start": {"Middle"}, "Middle": {"Target"}}}
+		var mu sync.Mutex
+		var bad []string
+		var total int
+		withFakeWiki(t, func(w http.ResponseWriter, r *http.Request) {
+			r.ParseForm()
+			mu.Lock()
+			total++
+			if r.Form.Get("maxlag") != "5" || r.Header.Get("User-Agent") != defaultAPIOptions.UserAgent {
+				bad = append(bad, r.Method+" "+r.Form.Encode())
+			}
+			mu.Unlock()
+			graph.ServeHTTP(w, r)
+		}, "en")
+
+		// Короткий MaxGETURL - часть запросов уходит POST с maxlag в теле
+		for _, maxGET := range []int{0, 64} {
+			globalLinkCache = newLinkCache(1000, nil, 0)
+			opts := defaultAPIOptions
+			opts.MaxGETURL = maxGET
+			s := newTestSearcher(t, opts)
+			if _, err := s.Search("Start", "Target", "en"); err != nil {
+				t.Fatal(err)
+			}
+			s.verifyEdge(en("Start"), en("Middle"))
+		}
+		if total == 0 {
+			t.Fatal("запросов не было")
+		}
+		for _, req := range bad {
+			t.Errorf("запрос без maxlag=5 или User-Agent: %s", req)
+		}
+	})
+
+	t.Run("повтор при ошибке maxlag", func(t *testing.T) {
+		var calls atomic.Int64
+		withFakeWiki(t, func(w http.ResponseWriter, r *http.Request) {
+			// Так MediaWiki отвечает при отставании реплик: 200 с ошибкой
+			if calls.Add(1) <= 2 {
+				w.Header().Set("MediaWiki-API-Error", "maxlag")
+				w.Header().Set("Retry-After", "0")
+				writeJSON(w, map[string]interface{}{"error": map[string]string{"code": "maxlag", "info": "Waiting for db: 6 seconds lagged"}})
+				return
+			}
+			writeJSON(w, map[string]interface{}{"query": map[string]interface{}{"pages": map[string]interface{}{
+				"1": map[string]interface{}{"title": "Start", "ns": 0},
+			}}})
+		}, "en")
+		opts := defaultAPIOptions
+		opts.HTTPRetries = 2
+		s := newTestSearcher(t, opts)
+		data, err := s.query(s.ctx, apiWikis["en"].APIURL, url.Values{"action": {"query"}, "titles": {"Start"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := data.pageByTitle("Start"); !ok || calls.Load() != 3 {
+			t.Errorf("ответ %+v после %d запросов, want Start после 3", data.Query.Pages, calls.Load())
+		}
+	})
+}
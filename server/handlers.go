@@ -0,0 +1,438 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// SearchPath godoc
+// @Summary Найти путь между статьями Wikipedia
+// @Description Ищет кратчайший путь между двумя статьями Wikipedia используя bidirectional search
+// @Tags search
+// @Accept json
+// @Produce json,plain,xml,markdown
+// @Param request body SearchRequest true "Параметры поиска"
+// @Success 200 {object} SearchResponse
+// @Success 206 {object} SearchResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 408 {object} ErrorResponse
+// @Failure 502 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /search [post]
+func SearchPath(c *fiber.Ctx) error {
+	var req SearchRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(ErrorResponse{
+			Success: false,
+			Error:   "Неверный формат запроса",
+			Code:    "INVALID_REQUEST",
+		})
+	}
+
+	req.From, req.To = normalizeTitleAPI(req.From), normalizeTitleAPI(req.To)
+	if req.From == "" || req.To == "" {
+		return c.Status(400).JSON(ErrorResponse{
+			Success: false,
+			Error:   "Необходимо указать 'from' и 'to'",
+			Code:    "MISSING_PARAMS",
+		})
+	}
+
+	if req.Format == "" {
+		req.Format = c.Query("format", FormatJSON)
+	}
+
+	return runSearch(c, req)
+}
+
+// maxBatchPairs - больше пар в одном /search/batch не принимаем
+const maxBatchPairs = 100
+
+// batchConcurrency - сколько поисков пакета идут одновременно
+// (WIKI_BATCH_CONCURRENCY): прогретых соединений хватает всем, а
+// Wikipedia не получает тысячи запросов разом
+var batchConcurrency = 4
+
+// SearchBatch godoc
+// @Summary Найти пути для нескольких пар статей
+// @Description Ищет пути для всех пар из pairs, одновременно - не больше WIKI_BATCH_CONCURRENCY. Ответ - массив в порядке pairs: SearchResponse для найденных путей, ErrorResponse для остальных
+// @Tags search
+// @Accept json
+// @Produce json
+// @Param request body BatchRequest true "Пары статей"
+// @Success 200 {array} SearchResponse
+// @Failure 400 {object} ErrorResponse
+// @Router /search/batch [post]
+func SearchBatch(c *fiber.Ctx) error {
+	var req BatchRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(ErrorResponse{
+			Success: false,
+			Error:   "Неверный формат запроса",
+			Code:    "INVALID_REQUEST",
+		})
+	}
+	if len(req.Pairs) == 0 {
+		return c.Status(400).JSON(ErrorResponse{
+			Success: false,
+			Error:   "Необходимо указать 'pairs'",
+			Code:    "MISSING_PARAMS",
+		})
+	}
+	if len(req.Pairs) > maxBatchPairs {
+		return c.Status(400).JSON(ErrorResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Не больше %d пар за запрос, получено %d", maxBatchPairs, len(req.Pairs)),
+			Code:    "BATCH_TOO_LARGE",
+		})
+	}
+
+	ctx, cancel := requestContext(c)
+	defer cancel()
+	results := make([]interface{}, len(req.Pairs))
+	sem := make(chan struct{}, batchConcurrency)
+	var wg sync.WaitGroup
+	for i, pair := range req.Pairs {
+		wg.Add(1)
+		go func(i int, pair SearchRequest) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			results[i] = batchSearch(ctx, pair)
+		}(i, pair)
+	}
+	wg.Wait()
+	return c.JSON(results)
+}
+
+// batchSearch - один поиск пакета: *SearchResponse или *ErrorResponse
+func batchSearch(ctx context.Context, req SearchRequest) interface{} {
+	req.From, req.To = normalizeTitleAPI(req.From), normalizeTitleAPI(req.To)
+	if req.From == "" || req.To == "" {
+		return &ErrorResponse{
+			Success: false,
+			Error:   "Необходимо указать 'from' и 'to'",
+			Code:    "MISSING_PARAMS",
+		}
+	}
+	req.Format = FormatJSON
+	if resp := validateSearch(&req); resp != nil {
+		return resp
+	}
+	r := execSearch(ctx, req)
+	if r.err != nil {
+		return r.err
+	}
+	return r.resp
+}
+
+// RandomResponse - поиск между двумя случайными статьями раздела
+type RandomResponse struct {
+	Success bool   `json:"success" example:"true"`
+	Lang    string `json:"lang" example:"ru"`
+	// From и To - выбранные концы; по ним поиск можно повторить
+	From string `json:"from" example:"Кошка"`
+	To   string `json:"to" example:"Теория относительности"`
+	// Result - SearchResponse, если путь найден (в том числе частичный),
+	// иначе ErrorResponse; различаются по success
+	Result interface{} `json:"result"`
+}
+
+// RandomTitles возвращает n разных случайных статей раздела lang
+// (list=random в пространстве имён статей)
+func (s *APISearcher) RandomTitles(lang string, n int) ([]string, error) {
+	params := url.Values{
+		"action":      {"query"},
+		"format":      {"json"},
+		"list":        {"random"},
+		"rnnamespace": {"0"},
+		"rnlimit":     {strconv.Itoa(n)},
+	}
+	data, err := s.query(s.ctx, apiWikis[lang].APIURL, params)
+	if err != nil {
+		return nil, err
+	}
+	titles := make([]string, 0, n)
+	seen := make(map[string]bool)
+	for _, page := range data.Query.Random {
+		if page.Title != "" && !seen[page.Title] {
+			seen[page.Title] = true
+			titles = append(titles, page.Title)
+		}
+	}
+	if len(titles) < n {
+		return nil, fmt.Errorf("list=random: нужно %d статей, получено %d", n, len(titles))
+	}
+	return titles, nil
+}
+
+// RandomSearch godoc
+// @Summary Путь между двумя случайными статьями
+// @Description Берёт две случайные статьи раздела (list=random) и ищет путь между ними, как GET /search. Для демонстраций и нагрузочных тестов. Ответ - 200 с выбранными концами; result - SearchResponse или ErrorResponse поиска.
+// @Tags search
+// @Produce json
+// @Param lang query string false "Язык" example(ru)
+// @Param timeout_ms query int false "Бюджет поиска в мс, до 60000" example(20000)
+// @Param shortest query bool false "Искать путь с наименьшим числом переходов (в ширину)"
+// @Success 200 {object} RandomResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 502 {object} ErrorResponse
+// @Router /random [get]
+func RandomSearch(c *fiber.Ctx) error {
+	req := SearchRequest{
+		Lang:      c.Query("lang", defaultLang),
+		Format:    FormatJSON,
+		TimeoutMs: c.QueryInt("timeout_ms"),
+		Shortest:  c.QueryBool("shortest"),
+	}
+	if resp := validateSearch(&req); resp != nil {
+		return c.Status(400).JSON(resp)
+	}
+
+	ctx, cancel := requestContext(c)
+	defer cancel()
+	s := NewAPISearcher(ctx, "", "", "", "", defaultAPIOptions)
+	titles, err := s.RandomTitles(req.Lang, 2)
+	s.cancel()
+	if err != nil {
+		s.log.Warn("random titles failed", "lang", req.Lang, "error", err.Error())
+		return c.Status(502).JSON(ErrorResponse{
+			Success: false,
+			Error:   "Wikipedia API недоступен",
+			Code:    "UPSTREAM_ERROR",
+		})
+	}
+	req.From, req.To = titles[0], titles[1]
+	// Раздел известен - концы не нужно искать в других языках
+	req.FromLang, req.ToLang = req.Lang, req.Lang
+
+	r := execSearch(ctx, req)
+	resp := RandomResponse{Success: true, Lang: req.Lang, From: req.From, To: req.To, Result: r.resp}
+	if r.err != nil {
+		resp.Result = r.err
+	}
+	return c.JSON(resp)
+}
+
+// CompareSearches godoc
+// @Summary Сравнить две конфигурации поиска на одной паре
+// @Description Запускает два поиска одной пары одновременно - с настройками a и b (по умолчанию обычный и mode=monolingual) - и возвращает оба ответа и разницу: длину пути, число запросов и время. Поиски идут мимо кеша неудач и не совмещаются с одинаковыми запросами других клиентов
+// @Tags search
+// @Accept json
+// @Produce json
+// @Param request body SearchCompareRequest true "Пара и две конфигурации"
+// @Success 200 {object} SearchCompareResponse
+// @Failure 400 {object} ErrorResponse
+// @Router /search/compare [post]
+func CompareSearches(c *fiber.Ctx) error {
+	var req SearchCompareRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(ErrorResponse{
+			Success: false,
+			Error:   "Неверный формат запроса",
+			Code:    "INVALID_REQUEST",
+		})
+	}
+	req.From, req.To = normalizeTitleAPI(req.From), normalizeTitleAPI(req.To)
+	if req.From == "" || req.To == "" {
+		return c.Status(400).JSON(ErrorResponse{
+			Success: false,
+			Error:   "Необходимо указать 'from' и 'to'",
+			Code:    "MISSING_PARAMS",
+		})
+	}
+	if req.A == nil {
+		req.A = &SearchRequest{}
+	}
+	if req.B == nil {
+		req.B = &SearchRequest{Mode: ModeMonolingual}
+	}
+
+	configs := [2]SearchRequest{*req.A, *req.B}
+	for i := range configs {
+		configs[i].From, configs[i].To, configs[i].Lang = req.From, req.To, req.Lang
+		configs[i].Format = FormatJSON
+		if resp := validateSearch(&configs[i]); resp != nil {
+			resp.Error = [2]string{"a", "b"}[i] + ": " + resp.Error
+			return c.Status(400).JSON(resp)
+		}
+	}
+
+	// Сравнению нужны два настоящих поиска: execSearch отдал бы
+	// закешированную неудачу или чужой результат той же пары
+	ctx, cancel := requestContext(c)
+	defer cancel()
+	results := searchAll(ctx, configs[:])
+
+	resp := SearchCompareResponse{Success: true, From: req.From, To: req.To}
+	resp.A, resp.B = compareSide(results[0]), compareSide(results[1])
+	if a, b := results[0].resp, results[1].resp; a != nil && b != nil {
+		resp.Diff = &SearchDiff{
+			PathLength:   b.PathLength - a.PathLength,
+			RequestCount: b.Stats.RequestCount - a.Stats.RequestCount,
+			DurationMs:   b.Stats.DurationMs - a.Stats.DurationMs,
+			SamePath:     pathKey(results[0].path) == pathKey(results[1].path),
+		}
+	}
+	return c.JSON(resp)
+}
+
+// compareSide - ответ одной конфигурации сравнения: *SearchResponse или *ErrorResponse
+// searchAll выполняет поиски reqs одновременно через searchOnce и ждёт
+// все: общая часть сравнений /search/compare и /compare
+func searchAll(ctx context.Context, reqs []SearchRequest) []searchResult {
+	results := make([]searchResult, len(reqs))
+	var wg sync.WaitGroup
+	for i := range reqs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = searchOnce(ctx, reqs[i])
+		}(i)
+	}
+	wg.Wait()
+	return results
+}
+
+func compareSide(r searchResult) interface{} {
+	if r.err != nil {
+		return r.err
+	}
+	return r.resp
+}
+
+// SearchPathGet godoc
+// @Summary Найти путь между статьями Wikipedia (GET)
+// @Description Ищет кратчайший путь между двумя статьями Wikipedia используя bidirectional search
+// @Tags search
+// @Produce json,plain,xml,markdown
+// @Param from query string true "Начальная статья" example(Кошка)
+// @Param to query string true "Конечная статья" example(Теория относительности)
+// @Param lang query string false "Язык по умолчанию" example(ru)
+// @Param format query string false "Формат ответа: json, text, xml, dot или markdown" example(json)
+// @Param wikidata query bool false "Добавить Wikidata Q-ID к каждому шагу пути"
+// @Param capture query bool false "Вернуть снимок графа ссылок для офлайн-воспроизведения"
+// @Param summary query bool false "Добавить объяснение, что связывает статьи"
+// @Param forbidden query string false "Запрещённые статьи через |" example(Млекопитающие|Животные)
+// @Param exclude query string false "Регулярки названий, через которые путь не идёт, через запятую" example(^[0-9]+$,^Список)
+// @Param exclude_disambig query bool false "Не проводить путь через страницы значений"
+// @Param categories query bool false "Разрешить шаги через общую категорию (экспериментально)"
+// @Param prose query bool false "Проверить, что ссылки пути стоят в тексте статей, а не только в навбоксах"
+// @Param paths query int false "Сколько путей собрать, до 5" example(3)
+// @Param rank_by query string false "Порядок путей: shortest, interwiki или prominence" example(shortest)
+// @Param debug query bool false "Вернуть статус, размер и время каждого запроса к API"
+// @Param canonical query bool false "Заменить названия статей пути на цели редиректов"
+// @Param max_languages query int false "Сколько разных языков может пройти путь, 0 - без ограничения" example(2)
+// @Param from_lang query string false "Явный язык начальной статьи" example(de)
+// @Param to_lang query string false "Явный язык конечной статьи" example(en)
+// @Param animate query bool false "Вернуть журнал раскрытий для покадровой анимации"
+// @Param verify_meet query bool false "Проверить ребро встречи фронтов запросом к API"
+// @Param timeout_ms query int false "Бюджет поиска в мс, до 60000" example(20000)
+// @Param max_depth query int false "Предел длины пути в переходах" example(6)
+// @Param shortest query bool false "Искать путь с наименьшим числом переходов (в ширину)"
+// @Param namespaces query string false "Пространства имён пути через |, 0 - статьи, 14 - категории" example(0|14)
+// @Param with_context query bool false "Найти предложение и раздел, где стоит каждая ссылка пути"
+// @Param verify query bool false "Проверить каждую ссылку пути по живому графу и исправить direction"
+// @Param mode query string false "Эвристика: default или monolingual - путь без смены языка" Enums(default, monolingual)
+// @Success 200 {object} SearchResponse
+// @Success 206 {object} SearchResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 408 {object} ErrorResponse
+// @Failure 502 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Router /search [get]
+func SearchPathGet(c *fiber.Ctx) error {
+	req := SearchRequest{
+		From:     normalizeTitleAPI(c.Query("from")),
+		To:       normalizeTitleAPI(c.Query("to")),
+		Lang:     c.Query("lang", defaultLang),
+		Format:   c.Query("format", FormatJSON),
+		Wikidata: c.QueryBool("wikidata"),
+		Capture:  c.QueryBool("capture"),
+		Summary:  c.QueryBool("summary"),
+
+		ExcludeDisambig: c.QueryBool("exclude_disambig"),
+
+		Categories: c.QueryBool("categories"),
+		Prose:      c.QueryBool("prose"),
+		Paths:      c.QueryInt("paths", 1),
+		RankBy:     c.Query("rank_by", RankShortest),
+		Debug:      c.QueryBool("debug"),
+		Canonical:  c.QueryBool("canonical"),
+
+		MaxLanguages: c.QueryInt("max_languages"),
+		MaxDepth:     c.QueryInt("max_depth"),
+		Shortest:     c.QueryBool("shortest"),
+		FromLang:     c.Query("from_lang"),
+		ToLang:       c.Query("to_lang"),
+		Animate:      c.QueryBool("animate"),
+		Explored:     c.QueryBool("explored"),
+		VerifyMeet:   c.QueryBool("verify_meet"),
+		TimeoutMs:    c.QueryInt("timeout_ms"),
+		CrossLang:    queryCrossLang(c),
+		WithContext:  c.QueryBool("with_context"),
+		Verify:       c.QueryBool("verify"),
+		Mode:         c.Query("mode"),
+	}
+	if v := c.Query("forbidden"); v != "" {
+		// Как в MediaWiki titles: несколько названий через "|"
+		req.Forbidden = strings.Split(v, "|")
+	}
+	if v := c.Query("exclude"); v != "" {
+		// "|" - часть синтаксиса регулярок, поэтому через запятую
+		req.Exclude = strings.Split(v, ",")
+	}
+	if v := c.Query("namespaces"); v != "" {
+		namespaces, err := parseNamespacesAPI(v)
+		if err != nil {
+			return c.Status(400).JSON(ErrorResponse{
+				Success: false,
+				Error:   "namespaces: " + err.Error(),
+				Code:    "INVALID_NAMESPACES",
+			})
+		}
+		req.Namespaces = namespaces
+	}
+
+	if req.From == "" || req.To == "" {
+		return c.Status(400).JSON(ErrorResponse{
+			Success: false,
+			Error:   "Необходимо указать параметры 'from' и 'to'",
+			Code:    "MISSING_PARAMS",
+		})
+	}
+
+	return runSearch(c, req)
+}
+
+// SearchPathDOT godoc
+// @Summary Исследованный подграф в Graphviz DOT
+// @Description Тот же поиск, что GET /search, с format=dot и explored=true по умолчанию: дерево поиска обоих фронтов (до WIKI_EXPLORED_NODES рёбер), путь выделен красным. Рисуется через dot -Tsvg.
+// @Tags search
+// @Produce plain
+// @Param from query string true "Начальная статья"
+// @Param to query string true "Конечная статья"
+// @Param lang query string false "Язык" default(ru)
+// @Param explored query bool false "Добавить дерево поиска" default(true)
+// @Param capture query bool false "Добавить все рёбра снимка"
+// @Success 200 {string} string "digraph"
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /search.dot [get]
+func SearchPathDOT(c *fiber.Ctx) error {
+	args := c.Request().URI().QueryArgs()
+	args.Set("format", FormatDOT)
+	if !args.Has("explored") {
+		args.Set("explored", "true")
+	}
+	return SearchPathGet(c)
+}
@@ -0,0 +1,834 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// scrapeMetric - значение серии /metrics, строка которой начинается с
+// series (имя и метки как в выводе Prometheus); 0 - серии нет
+func scrapeMetric(t *testing.T, app *fiber.App, series string) float64 {
+	t.Helper()
+	resp, err := app.Test(httptest.NewRequest("GET", "/metrics", nil), 5000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	for _, line := range strings.Split(string(body), "\n") {
+		if value, ok := strings.CutPrefix(line, series+" "); ok {
+			v, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				t.Fatalf("%s: %v", line, err)
+			}
+			return v
+		}
+	}
+	return 0
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	withFakeWiki(t, (&graphWiki{links: map[string][]string{
+		"Seed":   {"Sprout"},
+		"Sprout": {"Tree"},
+	}}).ServeHTTP, "en")
+	app := NewApp()
+
+	series := []string{
+		`wikiracer_searches_total{outcome="found"}`,
+		`wikiracer_search_duration_seconds_count{outcome="found"}`,
+		`wikiracer_wiki_requests_total{direction="forward",lang="en"}`,
+		`wikiracer_wiki_requests_total{direction="backward",lang="en"}`,
+		`wikiracer_link_cache_lookups_total{lang="en",result="miss"}`,
+	}
+	before := make(map[string]float64)
+	for _, name := range series {
+		before[name] = scrapeMetric(t, app, name)
+	}
+
+	if status, path := postSearch(t, app, `{"from":"Seed","to":"Tree","lang":"en"}`); status != http.StatusOK {
+		t.Fatalf("поиск: %d %q", status, path)
+	}
+
+	for _, name := range series {
+		if after := scrapeMetric(t, app, name); after <= before[name] {
+			t.Errorf("%s: %v → %v, want рост", name, before[name], after)
+		}
+	}
+	if got := scrapeMetric(t, app, "wikiracer_searches_in_flight"); got != 0 {
+		t.Errorf("wikiracer_searches_in_flight = %v после поиска, want 0", got)
+	}
+}
+
+func TestSearchNeighbors(t *testing.T) {
+	// Ссылки Hub приходят двумя страницами (plcontinue), interwiki - с первой
+	withFakeWiki(t, func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("titles") != "Hub" {
+			writeJSON(w, map[string]interface{}{"query": map[string]interface{}{"pages": map[string]interface{}{
+				"-1": map[string]interface{}{"title": r.Form.Get("titles"), "missing": true},
+			}}})
+			return
+		}
+		page := map[string]interface{}{"pageid": 7, "ns": 0, "title": "Hub"}
+		resp := map[string]interface{}{}
+		first := r.Form.Get("plcontinue") == ""
+		if first && strings.Contains(r.Form.Get("prop"), "langlinks") {
+			page["langlinks"] = []map[string]string{{"lang": "de", "*": "Nabe"}}
+		}
+		switch {
+		case strings.Contains(r.Form.Get("prop"), "linkshere"):
+			page["linkshere"] = links("Referrer")
+		case first:
+			page["links"] = links("First", "Category:Hubs")
+			resp["continue"] = map[string]string{"plcontinue": "7|0|Second", "continue": "||"}
+		default:
+			page["links"] = links("Second")
+		}
+		resp["query"] = map[string]interface{}{"pages": map[string]interface{}{"7": page}}
+		writeJSON(w, resp)
+	}, "en", "de")
+	app := NewApp()
+
+	get := func(target string) (int, NeighborsResponse) {
+		resp, err := app.Test(httptest.NewRequest("GET", target, nil), 5000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var data NeighborsResponse
+		json.NewDecoder(resp.Body).Decode(&data)
+		return resp.StatusCode, data
+	}
+
+	tests := []struct {
+		dir  string
+		want []string
+	}{
+		{"both", []string{"backward en:Referrer 0", "forward en:Category:Hubs 14", "forward en:First 0", "forward en:Second 0", "interwiki de:Nabe 0"}},
+		{"forward", []string{"forward en:Category:Hubs 14", "forward en:First 0", "forward en:Second 0", "interwiki de:Nabe 0"}},
+		{"backward", []string{"backward en:Referrer 0", "interwiki de:Nabe 0"}},
+	}
+	for _, tt := range tests {
+		status, data := get("/api/v1/neighbors?title=Hub&lang=en&dir=" + tt.dir)
+		var got []string
+		for _, n := range data.Neighbors {
+			got = append(got, fmt.Sprintf("%s %s:%s %d", n.Direction, n.Lang, n.Title, n.Namespace))
+		}
+		sort.Strings(got)
+		if status != http.StatusOK || data.Title != "Hub" || data.Count != len(tt.want) || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("dir=%s: %d %s count=%d %q, want %q", tt.dir, status, data.Title, data.Count, got, tt.want)
+		}
+	}
+
+	for _, tt := range []struct {
+		target string
+		status int
+	}{
+		{"/api/v1/neighbors?title=Nowhere&lang=en", http.StatusNotFound},
+		{"/api/v1/neighbors?lang=en", http.StatusBadRequest},
+		{"/api/v1/neighbors?title=Hub&lang=en&dir=sideways", http.StatusBadRequest},
+	} {
+		if status, _ := get(tt.target); status != tt.status {
+			t.Errorf("%s: %d, want %d", tt.target, status, tt.status)
+		}
+	}
+}
+
+func TestExplainHeuristic(t *testing.T) {
+	withFakeWiki(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("explain не ходит в Wikipedia: %s", r.URL)
+	}, "ru")
+	app := NewApp()
+
+	target := "/api/v1/explain?title=" + url.QueryEscape("Домашняя кошка") + "&lang=ru&dir=F&target=" + url.QueryEscape("Кошка")
+	resp, err := app.Test(httptest.NewRequest("GET", target, nil), 5000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var data ExplainResponse
+	json.NewDecoder(resp.Body).Decode(&data)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("статус %d", resp.StatusCode)
+	}
+
+	// Язык цели (-25) и ru (-10), слово "кошка" целиком (-40) и подстрокой
+	// (-20), короткое название (-5)
+	b := data.Breakdown
+	want := HeuristicBreakdown{Base: 100, LangBonus: -35, WordBonus: -40, SubstringBonus: -20, LengthAdj: -5, Similarity: b.Similarity}
+	want.Total = 100 - 35 - 40 - 20 - 5 + b.Similarity
+	if b != want {
+		t.Errorf("breakdown %+v, want %+v", b, want)
+	}
+	if b.Similarity > 0 {
+		t.Errorf("similarity %d: бонус не может быть штрафом", b.Similarity)
+	}
+	if data.Title != "Домашняя кошка" || data.Target != "Кошка" || data.Dir != "F" {
+		t.Errorf("ответ %+v", data)
+	}
+}
+
+func TestSuggestTitles(t *testing.T) {
+	var params url.Values
+	withFakeWiki(t, func(w http.ResponseWriter, r *http.Request) {
+		params = r.URL.Query()
+		if params.Get("search") == "Broken" {
+			io.WriteString(w, `{"error":"not an array"}`)
+			return
+		}
+		// Ссылка есть только у первой подсказки: вторую строит сервер
+		io.WriteString(w, `["Кош",["Кошка","Кошице"],["",""],["https://ru.wikipedia.org/wiki/%D0%9A%D0%BE%D1%88%D0%BA%D0%B0"]]`)
+	}, "ru")
+	app := NewApp()
+
+	get := func(target string) (int, []byte) {
+		resp, err := app.Test(httptest.NewRequest("GET", target, nil), 5000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, body
+	}
+
+	status, body := get("/api/v1/suggest?q=" + url.QueryEscape("Кош") + "&lang=ru&limit=5")
+	if status != http.StatusOK {
+		t.Fatalf("статус %d: %s", status, body)
+	}
+	var data SuggestResponse
+	json.Unmarshal(body, &data)
+	want := []TitleSuggestion{
+		{Title: "Кошка", URL: "https://ru.wikipedia.org/wiki/%D0%9A%D0%BE%D1%88%D0%BA%D0%B0"},
+		{Title: "Кошице", URL: buildWikiURL("ru", "Кошице")},
+	}
+	if !reflect.DeepEqual(data.Suggestions, want) {
+		t.Errorf("подсказки %+v, want %+v", data.Suggestions, want)
+	}
+	if params.Get("action") != "opensearch" || params.Get("search") != "Кош" || params.Get("limit") != "5" {
+		t.Errorf("параметры запроса %v", params)
+	}
+
+	for _, tt := range []struct {
+		target string
+		status int
+	}{
+		{"/api/v1/suggest?lang=ru", http.StatusBadRequest},
+		{"/api/v1/suggest?q=%20&lang=ru", http.StatusBadRequest},
+		{"/api/v1/suggest?q=Paris&lang=zz", http.StatusBadRequest},
+		{"/api/v1/suggest?q=Broken&lang=ru", http.StatusBadGateway},
+	} {
+		if status, body := get(tt.target); status != tt.status {
+			t.Errorf("%s: %d %s, want %d", tt.target, status, body, tt.status)
+		}
+	}
+}
+
+func TestRandomSearch(t *testing.T) {
+	graph := &graphWiki{links: map[string][]string{"Ferret": {"Burrow"}, "Burrow": {"Volcano"}}}
+	var randomParams url.Values
+	withFakeWiki(t, func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("list") == "random" {
+			randomParams = r.Form
+			writeJSON(w, map[string]interface{}{"query": map[string]interface{}{
+				"random": []map[string]interface{}{{"id": 1, "ns": 0, "title": "Ferret"}, {"id": 2, "ns": 0, "title": "Volcano"}},
+			}})
+			return
+		}
+		graph.ServeHTTP(w, r)
+	}, "en")
+	app := NewApp()
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/api/v1/random?lang=en", nil), 5000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var data struct {
+		RandomResponse
+		Result SearchResponse `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusOK || data.Lang != "en" || data.From != "Ferret" || data.To != "Volcano" {
+		t.Fatalf("%d: lang=%q from=%q to=%q, want en Ferret Volcano", resp.StatusCode, data.Lang, data.From, data.To)
+	}
+	if !data.Result.Success || pathTitles(data.Result) != "Ferret Burrow Volcano" {
+		t.Errorf("result: success=%v путь %q", data.Result.Success, pathTitles(data.Result))
+	}
+	if randomParams.Get("rnnamespace") != "0" || randomParams.Get("rnlimit") != "2" {
+		t.Errorf("параметры list=random: %v", randomParams)
+	}
+}
+
+// captureSearchLog направляет журнал новых поисков в буфер до конца теста
+func captureSearchLog(t *testing.T) *bytes.Buffer {
+	var buf syncBuffer
+	old := searchLog
+	searchLog = slog.New(slog.NewJSONHandler(&buf, nil))
+	t.Cleanup(func() { searchLog = old })
+	return &buf.Buffer
+}
+
+// syncBuffer - bytes.Buffer для записи из нескольких горутин
+type syncBuffer struct {
+	mu sync.Mutex
+	bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.Buffer.Write(p)
+}
+
+func TestSearchSingleflight(t *testing.T) {
+	graph := &graphWiki{links: map[string][]string{
+		"Ignition": {"Spark"},
+		"Spark":    {"Engine"},
+	}}
+	gate := make(chan struct{})
+	var requests atomic.Int64
+	withFakeWiki(t, func(w http.ResponseWriter, r *http.Request) {
+		<-gate
+		requests.Add(1)
+		graph.ServeHTTP(w, r)
+	}, "en")
+	logs := captureSearchLog(t)
+	app := NewApp()
+
+	const clients = 10
+	type result struct {
+		status int
+		path   string
+	}
+	results := make(chan result, clients)
+	for i := 0; i < clients; i++ {
+		go func() {
+			status, path := postSearch(t, app, `{"from":"Ignition","to":"Engine","lang":"en"}`)
+			results <- result{status, path}
+		}()
+	}
+
+	// Backend держит первый запрос, пока все клиенты не подключатся к поиску
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		sharesMu.Lock()
+		waiters := 0
+		for _, sh := range shares {
+			waiters += sh.waiters
+		}
+		sharesMu.Unlock()
+		if waiters == clients {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("к поиску подключились %d клиентов из %d", waiters, clients)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	close(gate)
+
+	for i := 0; i < clients; i++ {
+		if r := <-results; r.status != http.StatusOK || r.path != "Ignition Spark Engine" {
+			t.Errorf("клиент получил %d %q", r.status, r.path)
+		}
+	}
+	if got := len(logEvents(t, logs, "search start")); got != 1 {
+		t.Errorf("поисков %d, want 1", got)
+	}
+
+	// Запросов к API столько же, сколько у одного поиска с холодным кешем
+	shared := requests.Load()
+	requests.Store(0)
+	globalLinkCache = newLinkCache(1000, nil, 0)
+	if status, _ := postSearch(t, app, `{"from":"Ignition","to":"Engine","lang":"en"}`); status != http.StatusOK {
+		t.Fatalf("одиночный поиск: %d", status)
+	}
+	if single := requests.Load(); shared != single {
+		t.Errorf("запросов у %d клиентов %d, у одного %d", clients, shared, single)
+	}
+}
+
+func TestNegativeCache(t *testing.T) {
+	graph := &graphWiki{links: map[string][]string{
+		"Island":   {"Lagoon"},
+		"Harbor":   {"Mainland"},
+		"Mainland": {"Harbor"},
+	}}
+	var requests atomic.Int64
+	withFakeWiki(t, func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		graph.ServeHTTP(w, r)
+	}, "en")
+	app := NewApp()
+
+	search := func(timeoutMs int) (int, ErrorResponse) {
+		t.Helper()
+		body := fmt.Sprintf(`{"from":"Island","to":"Mainland","lang":"en","timeout_ms":%d}`, timeoutMs)
+		req := httptest.NewRequest("POST", "/api/v1/search", strings.NewReader(body))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+		resp, err := app.Test(req, 5000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var e ErrorResponse
+		json.NewDecoder(resp.Body).Decode(&e)
+		return resp.StatusCode, e
+	}
+
+	status, e := search(1000)
+	if status != http.StatusNotFound || e.Cached {
+		t.Fatalf("первый поиск: %d cached=%v, want 404 без кеша", status, e.Cached)
+	}
+	if requests.Load() == 0 {
+		t.Fatal("первый поиск не ходил в API")
+	}
+
+	requests.Store(0)
+	status, e = search(1000)
+	if status != http.StatusNotFound || !e.Cached || e.Code != "PATH_NOT_FOUND" {
+		t.Errorf("повтор: %d %s cached=%v, want 404 PATH_NOT_FOUND из кеша", status, e.Code, e.Cached)
+	}
+	if got := requests.Load(); got != 0 {
+		t.Errorf("повтор из кеша: запросов к API %d, want 0", got)
+	}
+
+	// Путь появился: поиск с бюджетом больше идёт мимо кеша, а успех
+	// удаляет запись - и повтор с прежним бюджетом тоже ищет заново
+	graph.links["Lagoon"] = []string{"Mainland"}
+	globalLinkCache = newLinkCache(1000, nil, 0)
+	if status, _ := search(2000); status != http.StatusOK {
+		t.Fatalf("поиск с большим бюджетом: %d, want 200", status)
+	}
+	requests.Store(0)
+	if status, e := search(1000); status != http.StatusOK {
+		t.Errorf("после успеха: %d cached=%v, want 200", status, e.Cached)
+	}
+	if requests.Load() == 0 {
+		t.Error("после успеха поиск отдан из кеша, не ходя в API")
+	}
+}
+
+func TestSearchRequestID(t *testing.T) {
+	withFakeWiki(t, (&graphWiki{links: equalPaths["соседи"]}).ServeHTTP, "en")
+	logs := captureSearchLog(t)
+	app := NewApp()
+
+	req := httptest.NewRequest("GET", "/api/v1/search?from=Start&to=Target&lang=en", nil)
+	req.Header.Set(fiber.HeaderXRequestID, "req-42")
+	resp, err := app.Test(req, 5000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("статус %d", resp.StatusCode)
+	}
+	if id := resp.Header.Get(fiber.HeaderXRequestID); id != "req-42" {
+		t.Errorf("X-Request-ID = %q, want req-42", id)
+	}
+
+	done := logEvents(t, logs, "search done")
+	if len(done) != 1 {
+		t.Fatalf("событий search done: %d, want 1\n%s", len(done), logs)
+	}
+	if e := done[0]; e["request_id"] != "req-42" || e["outcome"] != "found" || e["path_length"] != float64(2) {
+		t.Errorf("search done = %v", e)
+	}
+
+	// Без заголовка клиента ID генерируется
+	resp, err = app.Test(httptest.NewRequest("GET", "/api/v1/search?from=Start&to=Elder&lang=en", nil), 5000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if id := resp.Header.Get(fiber.HeaderXRequestID); id == "" || id == "req-42" {
+		t.Errorf("сгенерированный X-Request-ID = %q", id)
+	}
+}
+
+// postSearch отправляет POST /api/v1/search и возвращает статус и путь
+// ответа названиями статей через пробел
+func postSearch(t *testing.T, app *fiber.App, body string) (int, string) {
+	t.Helper()
+	status, data := postSearchResponse(t, app, body)
+	return status, pathTitles(data)
+}
+
+// postSearchResponse отправляет POST /api/v1/search и возвращает статус и
+// разобранный ответ целиком
+func postSearchResponse(t *testing.T, app *fiber.App, body string) (int, SearchResponse) {
+	t.Helper()
+	req := httptest.NewRequest("POST", "/api/v1/search", strings.NewReader(body))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	resp, err := app.Test(req, 5000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var data SearchResponse
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, data
+}
+
+// pathTitles - путь ответа названиями статей через пробел
+func pathTitles(resp SearchResponse) string {
+	titles := make([]string, len(resp.Path))
+	for i, step := range resp.Path {
+		titles[i] = step.Title
+	}
+	return strings.Join(titles, " ")
+}
+
+func TestSearchExclude(t *testing.T) {
+	withFakeWiki(t, (&graphWiki{
+		links: map[string][]string{
+			"Begin":   {"Bad", "Good"},
+			"Bad":     {"Finish"},
+			"Good":    {"Middle"},
+			"Middle":  {"Finish"},
+			"Opening": {"Hub", "Detour"},
+			"Hub":     {"Closing"},
+			"Detour":  {"Bypass"},
+			"Bypass":  {"Closing"},
+		},
+		disambig: map[string]bool{"Hub": true},
+	}).ServeHTTP, "en")
+	app := NewApp()
+
+	tests := []struct {
+		name, body, want string
+		reason           string // причина в stats.blocked_by, пусто - ничего не отсеяно
+	}{
+		{"без исключений", `{"from":"Begin","to":"Finish","lang":"en"}`, "Begin Bad Finish", ""},
+		{"ссылка на исключённую статью", `{"from":"Begin","to":"Finish","lang":"en","exclude":["^Bad$"]}`, "Begin Good Middle Finish", "blocklist"},
+		// Фронты встречаются на Hub, не раскрыв его: флаг страницы значений
+		// нужно узнать отдельно, а кеш уже согрет первым поиском без pageprops
+		{"страница значений как хаб", `{"from":"Opening","to":"Closing","lang":"en"}`, "Opening Hub Closing", ""},
+		{"exclude_disambig после хаба", `{"from":"Opening","to":"Closing","lang":"en","exclude_disambig":true}`, "Opening Detour Bypass Closing", "disambig"},
+	}
+	for _, tt := range tests {
+		status, data := postSearchResponse(t, app, tt.body)
+		if path := pathTitles(data); status != http.StatusOK || path != tt.want {
+			t.Errorf("%s: %d %q, want 200 %q", tt.name, status, path, tt.want)
+		}
+		var want map[string]int64
+		if tt.reason != "" {
+			want = map[string]int64{tt.reason: data.Stats.BlockedNodes}
+		}
+		if data.Stats.BlockedNodes == 0 && tt.reason != "" || !reflect.DeepEqual(data.Stats.BlockedBy, want) {
+			t.Errorf("%s: blocked_nodes %d, blocked_by %v, want только %q", tt.name, data.Stats.BlockedNodes, data.Stats.BlockedBy, tt.reason)
+		}
+	}
+}
+
+func TestCompareSearches(t *testing.T) {
+	withFakeWiki(t, (&graphWiki{links: map[string][]string{
+		"Begin": {"Bad", "Good"}, "Bad": {"Finish"}, "Good": {"Middle"}, "Middle": {"Finish"},
+	}}).ServeHTTP, "en")
+	app := NewApp()
+
+	// A - настройки по умолчанию, B - без Bad: путь на статью длиннее
+	body := `{"from":"Begin","to":"Finish","lang":"en","a":{},"b":{"exclude":["^Bad$"]}}`
+	req := httptest.NewRequest("POST", "/api/v1/search/compare", strings.NewReader(body))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	resp, err := app.Test(req, 5000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var data struct {
+		Success bool
+		A, B    SearchResponse
+		Diff    *SearchDiff
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusOK || !data.Success {
+		t.Fatalf("статус %d, success=%v", resp.StatusCode, data.Success)
+	}
+	if got := pathTitles(data.A); !data.A.Success || got != "Begin Bad Finish" {
+		t.Errorf("a: success=%v путь %q", data.A.Success, got)
+	}
+	if got := pathTitles(data.B); !data.B.Success || got != "Begin Good Middle Finish" {
+		t.Errorf("b: success=%v путь %q", data.B.Success, got)
+	}
+	if data.A.Stats.RequestCount == 0 || data.B.Stats.RequestCount == 0 {
+		t.Errorf("request_count a=%d b=%d: поиски не шли", data.A.Stats.RequestCount, data.B.Stats.RequestCount)
+	}
+	d := data.Diff
+	if d == nil {
+		t.Fatal("diff не посчитан")
+	}
+	want := SearchDiff{
+		PathLength:   1,
+		RequestCount: data.B.Stats.RequestCount - data.A.Stats.RequestCount,
+		DurationMs:   data.B.Stats.DurationMs - data.A.Stats.DurationMs,
+	}
+	if *d != want {
+		t.Errorf("diff %+v, want %+v", *d, want)
+	}
+}
+
+func TestComparePaths(t *testing.T) {
+	// В обоих разделах путь идёт через одно понятие Q2; interwiki на
+	// короткий путь в другом разделе не используется
+	withFakeWikis(t, map[string]http.Handler{
+		"en": &graphWiki{
+			links:     map[string][]string{"Cat": {"Mammal", "Pet"}, "Mammal": {"Dog"}, "Pet": {"Leash"}, "Leash": {"Dog"}},
+			langlinks: map[string][]string{"Cat": {"de:Katze"}},
+			wikidata:  map[string]string{"Cat": "Q1", "Mammal": "Q2", "Dog": "Q3"},
+		},
+		"de": &graphWiki{
+			links:    map[string][]string{"Katze": {"Säugetiere"}, "Säugetiere": {"Haushund"}},
+			wikidata: map[string]string{"Katze": "Q1", "Säugetiere": "Q2", "Haushund": "Q3"},
+		},
+	})
+	app := NewApp()
+
+	target := "/api/v1/compare?langs=en,de&from_en=Cat&to_en=Dog&from_de=Katze&to_de=Haushund"
+	resp, err := app.Test(httptest.NewRequest("GET", target, nil), 5000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var data CompareResponse
+	json.NewDecoder(resp.Body).Decode(&data)
+	if resp.StatusCode != http.StatusOK || len(data.Editions) != 2 {
+		t.Fatalf("статус %d, разделов %d", resp.StatusCode, len(data.Editions))
+	}
+
+	want := map[string]string{"en": "Cat Mammal Dog", "de": "Katze Säugetiere Haushund"}
+	for _, e := range data.Editions {
+		titles := make([]string, len(e.Path))
+		for i, step := range e.Path {
+			titles[i] = step.Title
+			if step.Lang != e.Lang {
+				t.Errorf("%s: шаг %s в разделе %s", e.Lang, step.Title, step.Lang)
+			}
+		}
+		if got := strings.Join(titles, " "); !e.Success || got != want[e.Lang] {
+			t.Errorf("%s: success=%v путь %q (%s), want %q", e.Lang, e.Success, got, e.Error, want[e.Lang])
+		}
+	}
+	if len(data.Shared) != 1 || data.Shared[0].WikidataID != "Q2" {
+		t.Errorf("общие понятия %+v, want Q2", data.Shared)
+	}
+}
+
+func TestSearchForbidden(t *testing.T) {
+	withFakeWiki(t, (&graphWiki{
+		links: map[string][]string{
+			"Kickoff":  {"Shortcut", "Scenic"},
+			"Shortcut": {"Whistle"},
+			"Scenic":   {"Route"},
+			"Route":    {"Whistle"},
+		},
+	}).ServeHTTP, "en", "de")
+	app := NewApp()
+
+	tests := []struct {
+		name, forbidden string
+		status          int
+		want            string
+	}{
+		{"без запретов", `[]`, http.StatusOK, "Kickoff Shortcut Whistle"},
+		{"запрет короткого пути", `["shortcut"]`, http.StatusOK, "Kickoff Scenic Route Whistle"},
+		{"запрет с языком", `["en:Shortcut"]`, http.StatusOK, "Kickoff Scenic Route Whistle"},
+		// Статья с тем же названием в другом разделе - другой узел
+		{"запрет в другом разделе", `["de:Shortcut"]`, http.StatusOK, "Kickoff Shortcut Whistle"},
+		{"обхода нет", `["Shortcut","en:Route"]`, http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		body := `{"from":"Kickoff","to":"Whistle","lang":"en","forbidden":` + tt.forbidden + `}`
+		req := httptest.NewRequest("POST", "/api/v1/search", strings.NewReader(body))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+		resp, err := app.Test(req, 5000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s: статус %d, want %d: %s", tt.name, resp.StatusCode, tt.status, raw)
+			continue
+		}
+		if tt.status != http.StatusOK {
+			var e ErrorResponse
+			if json.Unmarshal(raw, &e); e.Code != "FORBIDDEN_PATH_NOT_FOUND" {
+				t.Errorf("%s: код %q, want FORBIDDEN_PATH_NOT_FOUND", tt.name, e.Code)
+			}
+			continue
+		}
+		var data SearchResponse
+		json.Unmarshal(raw, &data)
+		if got := pathTitles(data); got != tt.want {
+			t.Errorf("%s: путь %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSearchHint(t *testing.T) {
+	wiki := &graphWiki{links: map[string][]string{"Lamp": {"Light bulb", "Table", "Light"}}}
+	withFakeWiki(t, wiki.ServeHTTP, "en")
+	app := NewApp()
+
+	tests := []struct {
+		name     string
+		query    string
+		status   int
+		code     string
+		titles   string
+		direct   bool
+		requests int64
+	}{
+		{"неизвестный язык", "from=Lamp&to=Light&lang=xx", http.StatusBadRequest, "UNKNOWN_LANG", "", false, 0},
+		{"без цели", "from=Lamp&lang=en", http.StatusBadRequest, "MISSING_PARAMS", "", false, 0},
+		{"цель среди ссылок", "from=Lamp&to=Light&lang=en", http.StatusOK, "", "Light", true, 1},
+		{"лучшие ссылки", "from=Lamp&to=Bulb&lang=en&limit=1", http.StatusOK, "", "Light bulb", false, 1},
+	}
+	for _, tt := range tests {
+		before := wiki.requests.Load()
+		resp, err := app.Test(httptest.NewRequest("GET", "/api/v1/hint?"+tt.query, nil), 5000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var data struct {
+			HintResponse
+			Code string `json:"code"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		titles := make([]string, len(data.Suggestions))
+		for i, sg := range data.Suggestions {
+			titles[i] = sg.Title
+		}
+		if resp.StatusCode != tt.status || data.Code != tt.code || strings.Join(titles, " ") != tt.titles || data.Direct != tt.direct {
+			t.Errorf("%s: %d %s %q direct=%v, want %d %s %q direct=%v",
+				tt.name, resp.StatusCode, data.Code, titles, data.Direct, tt.status, tt.code, tt.titles, tt.direct)
+		}
+		if got := wiki.requests.Load() - before; got > tt.requests {
+			t.Errorf("%s: запросов к API %d, want не больше %d", tt.name, got, tt.requests)
+		}
+	}
+}
+
+func TestSearchBatch(t *testing.T) {
+	withFakeWiki(t, (&graphWiki{links: map[string][]string{
+		"Harbor": {"Lighthouse"}, "Lighthouse": {"Beacon"},
+		"Island": {"Sand"}, "Lonely rock": {"Sea"},
+	}}).ServeHTTP, "en")
+	app := NewApp()
+
+	// Неразрешимая пара первой: ответы - в порядке pairs, а не завершения
+	body := `{"pairs":[
+		{"from":"Island","to":"Lonely rock","lang":"en"},
+		{"from":"Harbor","to":"Beacon","lang":"en"},
+		{"from":"Harbor","lang":"en"}
+	]}`
+	req := httptest.NewRequest("POST", "/api/v1/search/batch", strings.NewReader(body))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	resp, err := app.Test(req, 5000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("статус %d", resp.StatusCode)
+	}
+	var results []struct {
+		SearchResponse
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&results); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		success bool
+		code    string
+		path    string
+	}{
+		{false, "PATH_NOT_FOUND", ""},
+		{true, "", "Harbor Lighthouse Beacon"},
+		{false, "MISSING_PARAMS", ""},
+	}
+	if len(results) != len(want) {
+		t.Fatalf("результатов %d, want %d", len(results), len(want))
+	}
+	for i, w := range want {
+		got := results[i]
+		if got.Success != w.success || got.Code != w.code || pathTitles(got.SearchResponse) != w.path {
+			t.Errorf("пара %d: success=%v code=%s путь %q, want success=%v code=%s путь %q",
+				i, got.Success, got.Code, pathTitles(got.SearchResponse), w.success, w.code, w.path)
+		}
+	}
+}
+
+func TestSearchCategoryPath(t *testing.T) {
+	wiki := &graphWiki{
+		links:      map[string][]string{"Apprentice": {"Workshop"}, "Workshop": {"Anvil"}},
+		categories: map[string][]string{"Anvil": {"Category:Tools"}},
+	}
+	withFakeWiki(t, wiki.ServeHTTP, "en")
+	app := NewApp()
+
+	tests := []struct {
+		name, query string
+		status      int
+		code        string
+		path        string
+	}{
+		// Статьи нет ни в одном разделе, а lang неизвестен: раньше
+		// запрос к apiWikis["zz"] ронял сервер
+		{"неизвестный язык", "from=Nonexistent&category=Tools&lang=zz", http.StatusBadRequest, "UNKNOWN_LANG", ""},
+		{"без категории", "from=Apprentice&lang=en", http.StatusBadRequest, "MISSING_PARAMS", ""},
+		{"до статьи категории", "from=Apprentice&category=Tools&lang=en", http.StatusOK, "", "Apprentice Workshop Anvil"},
+		{"пустая категория", "from=Apprentice&category=Nothing&lang=en", http.StatusNotFound, "CATEGORY_EMPTY", ""},
+	}
+	for _, tt := range tests {
+		resp, err := app.Test(httptest.NewRequest("GET", "/api/v1/search/category?"+tt.query, nil), 5000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var data struct {
+			CategorySearchResponse
+			Code string `json:"code"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if got := pathTitles(data.SearchResponse); resp.StatusCode != tt.status || data.Code != tt.code || got != tt.path {
+			t.Errorf("%s: %d %s %q, want %d %s %q", tt.name, resp.StatusCode, data.Code, got, tt.status, tt.code, tt.path)
+		}
+	}
+}
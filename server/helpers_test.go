@@ -0,0 +1,284 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// withFakeWiki подменяет API разделов langs тестовым сервером с обработчиком h,
+// а HTTP-клиент и кеш ссылок - свежими; после теста всё возвращается
+func withFakeWiki(t testing.TB, h http.HandlerFunc, langs ...string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(h)
+	oldWikis, oldDetect, oldClient, oldCache := apiWikis, detectLangsAPI, globalHTTPClient, globalLinkCache
+	apiWikis = make(map[string]*WikiConfig, len(langs))
+	for _, lang := range langs {
+		apiWikis[lang] = &WikiConfig{APIURL: srv.URL, Limit: "max", Namespace: "0"}
+	}
+	detectLangsAPI = langs
+	globalHTTPClient = srv.Client()
+	globalLinkCache = newLinkCache(1000, nil, 0)
+	t.Cleanup(func() {
+		srv.Close()
+		apiWikis, detectLangsAPI, globalHTTPClient, globalLinkCache = oldWikis, oldDetect, oldClient, oldCache
+	})
+	return srv
+}
+
+// withFakeWikis - withFakeWiki с отдельным API для каждого раздела:
+// запросы раздела lang приходят в wikis[lang]
+func withFakeWikis(t testing.TB, wikis map[string]http.Handler) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	var langs []string
+	for lang, h := range wikis {
+		mux.Handle("/"+lang+"/", h)
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	srv := withFakeWiki(t, mux.ServeHTTP, langs...)
+	for _, lang := range langs {
+		apiWikis[lang].APIURL = srv.URL + "/" + lang + "/api.php"
+	}
+	return srv
+}
+
+// routeWikipedia переводит запросы к <lang>.wikipedia.org на раздел lang
+// тестового сервера withFakeWikis: так видны и разделы по настоящим
+// адресам (loadLangs, wikiAPIURL)
+func routeWikipedia(srv *httptest.Server) {
+	base := srv.Client().Transport
+	globalHTTPClient = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if lang, ok := strings.CutSuffix(r.URL.Host, ".wikipedia.org"); ok {
+			r = r.Clone(r.Context())
+			r.URL.Scheme, r.URL.Host, r.URL.Path = "http", srv.Listener.Addr().String(), "/"+lang+"/api.php"
+		}
+		return base.RoundTrip(r)
+	})}
+}
+
+// roundTripFunc - http.RoundTripper из функции
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+// newTestSearcher - поиск Start -> Target в en с настройками opts
+func newTestSearcher(t *testing.T, opts APISearchOptions) *APISearcher {
+	t.Helper()
+	s := NewAPISearcher(context.Background(), "en", "Start", "en", "Target", opts)
+	t.Cleanup(s.cancel)
+	return s
+}
+
+// writeJSON отвечает v как MediaWiki: JSON с кодом 200
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// linkTitles - названия ссылок по порядку
+func linkTitles(links []APILink) []string {
+	titles := make([]string, len(links))
+	for i, l := range links {
+		titles[i] = l.Title
+	}
+	return titles
+}
+
+// links - ссылки на статьи titles; "Category:..." - в пространстве 14,
+// остальные - в основном
+func links(titles ...string) []APILink {
+	result := make([]APILink, len(titles))
+	for i, title := range titles {
+		result[i] = APILink{Ns: titleNs(title), Title: title}
+	}
+	return result
+}
+
+// titleNs - пространство имён названия в тестовых графах
+func titleNs(title string) int {
+	if strings.HasPrefix(title, "Category:") {
+		return 14
+	}
+	return 0
+}
+
+// inNamespaces оставляет из titles статьи пространств spec ("0|14",
+// как plnamespace); пустой spec - все
+func inNamespaces(titles []string, spec string) []string {
+	if spec == "" {
+		return titles
+	}
+	var out []string
+	for _, title := range titles {
+		for _, ns := range strings.Split(spec, "|") {
+			if ns == strconv.Itoa(titleNs(title)) {
+				out = append(out, title)
+			}
+		}
+	}
+	return out
+}
+
+// graphWiki - фейковый MediaWiki API поверх графа ссылок: отвечает на
+// prop=links, linkshere, langlinks, pageprops и categories, list=categorymembers, редиректы и на проверку, что статья есть. Статьи -
+// ключи links и все, на кого они ссылаются; pageid - место в алфавитном порядке.
+type graphWiki struct {
+	links      map[string][]string
+	disambig   map[string]bool     // страницы значений: pageprops.disambiguation
+	categories map[string][]string // категории статей для prop=categories
+	langlinks  map[string][]string // interwiki статей, "de:Titel"
+	redirects  map[string]string   // редиректы: название -> статья, query.redirects
+	wikidata   map[string]string   // элементы Wikidata статей: pageprops.wikibase_item
+	requests   atomic.Int64
+}
+
+func (g *graphWiki) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g.requests.Add(1)
+	r.ParseForm()
+
+	back := map[string][]string{}
+	exists := map[string]bool{}
+	for from, targets := range g.links {
+		exists[from] = true
+		for _, to := range targets {
+			exists[to] = true
+			back[to] = append(back[to], from)
+		}
+	}
+	var all []string
+	for title := range exists {
+		all = append(all, title)
+	}
+	sort.Strings(all)
+	ids := map[string]int{}
+	for i, title := range all {
+		ids[title] = 100 + i
+	}
+
+	// Статьи категории - те, у кого она есть в categories
+	if r.Form.Get("list") == "categorymembers" {
+		var members []map[string]string
+		for _, title := range all {
+			for _, cat := range g.categories[title] {
+				if cat == r.Form.Get("cmtitle") {
+					members = append(members, map[string]string{"title": title})
+				}
+			}
+		}
+		writeJSON(w, map[string]interface{}{"query": map[string]interface{}{"categorymembers": members}})
+		return
+	}
+
+	props := "|" + r.Form.Get("prop") + "|"
+	pages := map[string]interface{}{}
+	var redirects []map[string]string
+	for i, title := range strings.Split(r.Form.Get("titles"), "|") {
+		title = normalizeTitleAPI(title)
+		if title == "" {
+			continue
+		}
+		if to, ok := g.redirects[title]; ok && r.Form.Get("redirects") != "" {
+			redirects = append(redirects, map[string]string{"from": title, "to": to})
+			title = to
+		}
+		if !exists[title] {
+			pages[strconv.Itoa(-1-i)] = map[string]interface{}{"title": title, "missing": true}
+			continue
+		}
+		page := map[string]interface{}{"title": title, "ns": 0}
+		if strings.Contains(props, "|links|") {
+			out := inNamespaces(g.links[title], r.Form.Get("plnamespace"))
+			// pltitles - только ссылки на эти статьи (проверка перехода)
+			if only := r.Form.Get("pltitles"); only != "" {
+				out = nil
+				for _, to := range inNamespaces(g.links[title], r.Form.Get("plnamespace")) {
+					for _, want := range strings.Split(only, "|") {
+						if to == normalizeTitleAPI(want) {
+							out = append(out, to)
+						}
+					}
+				}
+			}
+			page["links"] = links(out...)
+		}
+		if strings.Contains(props, "|linkshere|") {
+			sort.Strings(back[title])
+			page["linkshere"] = links(inNamespaces(back[title], r.Form.Get("lhnamespace"))...)
+		}
+		if strings.Contains(props, "|langlinks|") {
+			var lls []map[string]string
+			for _, ll := range g.langlinks[title] {
+				lang, other, _ := strings.Cut(ll, ":")
+				if want := r.Form.Get("lllang"); want == "" || want == lang {
+					lls = append(lls, map[string]string{"lang": lang, "*": other})
+				}
+			}
+			page["langlinks"] = lls
+		}
+		if strings.Contains(props, "|pageprops|") {
+			pp := map[string]string{}
+			if g.disambig[title] {
+				pp["disambiguation"] = ""
+			}
+			if item, ok := g.wikidata[title]; ok {
+				pp["wikibase_item"] = item
+			}
+			if len(pp) > 0 {
+				page["pageprops"] = pp
+			}
+		}
+		if strings.Contains(props, "|categories|") {
+			cats := make([]map[string]string, len(g.categories[title]))
+			for i, cat := range g.categories[title] {
+				cats[i] = map[string]string{"title": cat}
+			}
+			page["categories"] = cats
+		}
+		pages[strconv.Itoa(ids[title])] = page
+	}
+	writeJSON(w, map[string]interface{}{"query": map[string]interface{}{"pages": pages, "redirects": redirects}})
+}
+
+// truncatingWiki отвечает на батч Alpha|Beta|Gamma (pageid 10, 20, 30) с общим
+// plcontinue, который обрезает ссылки Beta; Gamma до продолжения не дошла.
+// Одиночные запросы (split-режим) получают статью целиком.
+func truncatingWiki() http.HandlerFunc {
+	full := map[string]APIWikiPage{
+		"Alpha": {Title: "Alpha", Links: links("A1")},
+		"Beta":  {Title: "Beta", Links: links("B1", "B2")},
+		"Gamma": {Title: "Gamma", Links: links("G1")},
+	}
+	ids := map[string]string{"Alpha": "10", "Beta": "20", "Gamma": "30"}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		titles := q.Get("titles")
+		var resp APIWikiResponse
+		switch {
+		case titles != "Alpha|Beta|Gamma":
+			resp.Query.Pages = map[string]APIWikiPage{ids[titles]: full[titles]}
+		case q.Get("plcontinue") == "":
+			resp.Query.Pages = map[string]APIWikiPage{
+				"10": full["Alpha"],
+				"20": {Title: "Beta", Links: links("B1")},
+				"30": {Title: "Gamma"},
+			}
+			resp.Continue = map[string]string{"plcontinue": "20|0|B2", "continue": "||"}
+		default:
+			resp.Query.Pages = map[string]APIWikiPage{
+				"10": {Title: "Alpha"},
+				"20": {Title: "Beta", Links: links("B2")},
+				"30": {Title: "Gamma", Links: links("G1")},
+			}
+		}
+		writeJSON(w, resp)
+	}
+}
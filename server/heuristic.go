@@ -0,0 +1,266 @@
+package server
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"wikiracer/tokenize"
+)
+
+func (s *APISearcher) heuristic(title, lang, dir string) int {
+	return s.heuristicBreakdown(title, lang, dir).Total
+}
+
+// HeuristicBreakdown - приоритет heuristic по слагаемым (меньше - лучше):
+// Total = Base + остальные поля, отрицательный вклад поднимает статью
+// в очереди
+type HeuristicBreakdown struct {
+	Total int `json:"total" example:"-10"`
+	Base  int `json:"base" example:"100"`
+	// LangBonus - язык цели (-25) или языка-моста, плюс -10 разделам en и ru
+	LangBonus int `json:"lang_bonus" example:"-35"`
+	// WordBonus - -40 за каждое слово названия, которое есть в цели
+	WordBonus int `json:"word_bonus" example:"-40"`
+	// SubstringBonus - -20 за каждое слово цели, входящее в название подстрокой
+	SubstringBonus int `json:"substring_bonus" example:"-20"`
+	// LengthAdj - -5 коротким названиям (до 20 символов), +15 длинным (от 61)
+	LengthAdj int `json:"length_adj" example:"-5"`
+	// ListPenalty - штраф спискам и страницам значений (WIKI_LIST_PENALTY)
+	ListPenalty int `json:"list_penalty" example:"0"`
+	// Similarity - бонус за похожесть на цель (WIKI_SIMILARITY_WEIGHT)
+	Similarity int `json:"similarity" example:"-10"`
+}
+
+// heuristicBreakdown считает heuristic по слагаемым; dir - фронт, для
+// F цель - конечная статья, для B - начальная
+func (s *APISearcher) heuristicBreakdown(title, lang, dir string) HeuristicBreakdown {
+	b := HeuristicBreakdown{Base: 100}
+	titleLower := strings.ToLower(stripNamespaceAPI(title))
+
+	var words map[string]bool
+	var targetLang, targetLower string
+	if dir == "F" {
+		words = s.targetWords
+		targetLang = s.targetLang
+		targetLower = s.targetLower
+	} else {
+		words = s.startWords
+		targetLang = s.startLang
+		targetLower = s.startLower
+	}
+
+	if lang == targetLang {
+		b.LangBonus -= 25
+	} else if s.opts.BridgeBonus > 0 && s.startLang == s.targetLang && s.isBridgeLang(lang) {
+		// Оба конца на одном языке - промежуточный хаб на другом тоже полезен
+		b.LangBonus -= s.opts.BridgeBonus
+	}
+
+	for _, word := range tokenize.Words(titleLower) {
+		if words[word] {
+			b.WordBonus -= 40
+		}
+	}
+
+	for word := range words {
+		if strings.Contains(titleLower, word) {
+			b.SubstringBonus -= 20
+		}
+	}
+
+	if lang == "en" || lang == "ru" {
+		b.LangBonus -= 10
+	}
+
+	// Длина в символах, а не байтах - иначе кириллица "длиннее" латиницы вдвое
+	titleLen := utf8.RuneCountInString(title)
+	if titleLen < 20 {
+		b.LengthAdj -= 5
+	}
+
+	if titleLen > 60 {
+		b.LengthAdj += 15
+	}
+
+	// Списки и страницы значений - валидные, но скучные переходы
+	if isListTitle(title) {
+		b.ListPenalty = s.opts.ListPenalty
+	}
+
+	if s.opts.SimilarityWeight > 0 {
+		b.Similarity = -int(float64(s.opts.SimilarityWeight) * titleSimilarity(titleLower, targetLower, words))
+	}
+
+	b.Total = b.Base + b.LangBonus + b.WordBonus + b.SubstringBonus + b.LengthAdj + b.ListPenalty + b.Similarity
+	return b
+}
+
+// Режимы эвристики (SearchRequest.Mode)
+const (
+	ModeDefault     = "default"     // heuristic
+	ModeMonolingual = "monolingual" // heuristic со штрафом за чужой язык
+)
+
+// monolingualPenalty - штраф узлу не на языке start в режиме monolingual:
+// больше любого бонуса heuristic, так что другой язык раскрывается,
+// только когда на своём раскрывать нечего
+const monolingualPenalty = 200
+
+// validModeAPI - известен ли режим; пустой - default
+func validModeAPI(mode string) bool {
+	return mode == "" || mode == ModeDefault || mode == ModeMonolingual
+}
+
+// setMode выбирает HeuristicFunc по режиму; неизвестный - default
+func (s *APISearcher) setMode(mode string) {
+	if mode == ModeMonolingual {
+		s.HeuristicFunc = s.monolingualHeuristic
+		return
+	}
+	s.HeuristicFunc = s.heuristic
+}
+
+// monolingualHeuristic - heuristic, но узлы не на языке start сильно
+// штрафуются: путь уходит в interwiki, только если без этого никак
+func (s *APISearcher) monolingualHeuristic(title, lang, dir string) int {
+	score := s.heuristic(title, lang, dir)
+	if lang != s.startLang {
+		score += monolingualPenalty
+	}
+	return score
+}
+
+// similarityMaxRunes - длиннее этого edit distance не считаем:
+// он квадратичный, а эвристика вызывается для каждого кандидата
+const similarityMaxRunes = 64
+
+// titleSimilarity - похожесть названий от 0 до 1: среднее Jaccard по
+// словам и нормированного расстояния Левенштейна. Для длинных названий
+// остаётся только Jaccard. Оба названия - в нижнем регистре.
+func titleSimilarity(title, target string, targetWords map[string]bool) float64 {
+	// Jaccard по тем же словам, что и targetWords
+	common, total := 0, len(targetWords)
+	seen := make(map[string]bool)
+	for _, word := range tokenize.Words(title) {
+		if seen[word] {
+			continue
+		}
+		seen[word] = true
+		if targetWords[word] {
+			common++
+		} else {
+			total++
+		}
+	}
+	jaccard := 0.0
+	if total > 0 {
+		jaccard = float64(common) / float64(total)
+	}
+
+	a, b := []rune(title), []rune(target)
+	if len(a) > similarityMaxRunes || len(b) > similarityMaxRunes {
+		return jaccard
+	}
+	longest := len(a)
+	if len(b) > longest {
+		longest = len(b)
+	}
+	if longest == 0 {
+		return jaccard
+	}
+	edit := 1 - float64(levenshtein(a, b))/float64(longest)
+	return (jaccard + edit) / 2
+}
+
+// levenshtein - расстояние редактирования по рунам, две строки DP
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+// isBridgeLang сообщает, что язык из BridgeLangs
+func (s *APISearcher) isBridgeLang(lang string) bool {
+	for _, l := range s.opts.BridgeLangs {
+		if l == lang {
+			return true
+		}
+	}
+	return false
+}
+
+// Признаки списков и страниц значений в названии статьи
+var (
+	listTitlePrefixes = []string{
+		"list of ", "lists of ", "список ", "списки ", "liste der ", "liste des ",
+		"liste de ", "lista de ", "lista dos ", "lista das ", "elenco di ", "перелік ",
+	}
+	disambigTitleSuffixes = []string{
+		"(disambiguation)", "(значения)", "(значення)", "(begriffsklärung)",
+		"(homonymie)", "(desambiguación)", "(disambigua)", "(desambiguação)",
+	}
+)
+
+// bestCandidate оставляет от ссылок страницы значений одну - самую
+// перспективную по эвристике: "наиболее вероятное" значение для этой цели
+func (s *APISearcher) bestCandidate(candidates []APIWikiNode, dir string) []APIWikiNode {
+	best, bestScore := -1, 0
+	for i, cand := range candidates {
+		if isListTitle(cand.Title) {
+			continue
+		}
+		if score := s.HeuristicFunc(cand.Title, cand.Lang, dir); best < 0 || score < bestScore {
+			best, bestScore = i, score
+		}
+	}
+	if best < 0 {
+		return nil
+	}
+	return candidates[best : best+1]
+}
+
+// stripNamespaceAPI убирает префикс пространства имён ("Категория:Кошки" -
+// "Кошки"), чтобы эвристика сравнивала слова самого названия. Префикс -
+// до двоеточия без пробела после: "Звёздные войны: Эпизод IV" не трогается.
+func stripNamespaceAPI(title string) string {
+	if i := strings.IndexByte(title, ':'); i > 0 && i+1 < len(title) && title[i+1] != ' ' {
+		return title[i+1:]
+	}
+	return title
+}
+
+// isListTitle - дешёвая проверка по названию: список или страница значений
+func isListTitle(title string) bool {
+	lower := strings.ToLower(title)
+	for _, p := range listTitlePrefixes {
+		if strings.HasPrefix(lower, p) {
+			return true
+		}
+	}
+	return isDisambigTitle(lower)
+}
+
+// isDisambigTitle - страница значений по пометке в названии
+func isDisambigTitle(title string) bool {
+	lower := strings.ToLower(title)
+	for _, suf := range disambigTitleSuffixes {
+		if strings.HasSuffix(lower, suf) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestHeuristicRuneLength(t *testing.T) {
+	// Цель в третьем языке и без общих слов: оценки отличает только длина
+	s := NewAPISearcher(context.Background(), "de", "Anfang", "de", "Ziel", defaultAPIOptions)
+	defer s.cancel()
+	tests := []struct {
+		en, ru    string
+		lengthAdj int
+	}{
+		{"Saint-Peterburg", "Санкт-Петербург", -5},
+		{strings.Repeat("Cat ", 11) + "x", strings.Repeat("Кот ", 11) + "ы", 0},
+		{strings.Repeat("Cat ", 16) + "x", strings.Repeat("Кот ", 16) + "ы", 15},
+	}
+	for _, tt := range tests {
+		en, ru := s.heuristicBreakdown(tt.en, "en", "F"), s.heuristicBreakdown(tt.ru, "ru", "F")
+		if en.LengthAdj != tt.lengthAdj || ru.LengthAdj != tt.lengthAdj || en.Total != ru.Total {
+			t.Errorf("%d символов: en %+v, ru %+v, want length_adj %d и равные total", len([]rune(tt.en)), en, ru, tt.lengthAdj)
+		}
+	}
+}
+
+func TestHeuristicFuncInjected(t *testing.T) {
+	withFakeWiki(t, (&graphWiki{links: map[string][]string{
+		"Start": {"Apple", "Banana split"},
+	}}).ServeHTTP, "en")
+
+	s := newTestSearcher(t, defaultAPIOptions)
+	var mu sync.Mutex
+	var calls []string
+	s.HeuristicFunc = func(title, lang, dir string) int {
+		mu.Lock()
+		calls = append(calls, dir+":"+lang+":"+title)
+		mu.Unlock()
+		return 7 * len(title)
+	}
+	nodes := s.fetch([]string{"Start"}, "en", "F")
+
+	if len(nodes) != 2 {
+		t.Fatalf("узлов %d, want 2", len(nodes))
+	}
+	for _, n := range nodes {
+		if n.Priority != 7*len(n.Title) {
+			t.Errorf("%s: приоритет %d, want %d от HeuristicFunc", n.Title, n.Priority, 7*len(n.Title))
+		}
+	}
+	sort.Strings(calls)
+	if got, want := strings.Join(calls, " "), "F:en:Apple F:en:Banana split"; got != want {
+		t.Errorf("вызовы HeuristicFunc %q, want %q", got, want)
+	}
+}
+
+// heuristicTitles - названия разной длины и похожести на цель эвристики
+var heuristicTitles = []string{
+	"Physics", "Theory of relativity", "General relativity", "Special relativity",
+	"Albert Einstein", "List of physicists", "Relativity (disambiguation)", "1905",
+	"Annus mirabilis papers", "History of physics in the twentieth century and its influence on philosophy",
+}
+
+func BenchmarkHeuristic(b *testing.B) {
+	for _, weight := range []int{0, 20} {
+		b.Run("similarity="+strconv.Itoa(weight), func(b *testing.B) {
+			opts := defaultAPIOptions
+			opts.SimilarityWeight = weight
+			s := NewAPISearcher(context.Background(), "en", "Albert Einstein", "en", "Theory of relativity", opts)
+			defer s.cancel()
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				title := heuristicTitles[i%len(heuristicTitles)]
+				s.HeuristicFunc(title, "en", "F")
+			}
+		})
+	}
+}
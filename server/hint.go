@@ -0,0 +1,238 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// HintSuggestion - один предложенный следующий шаг
+type HintSuggestion struct {
+	Title string `json:"title" example:"Млекопитающие"`
+	Lang  string `json:"lang" example:"ru"`
+	URL   string `json:"url" example:"https://ru.wikipedia.org/wiki/Млекопитающие"`
+	Score int    `json:"score" example:"35"` // приоритет эвристики, меньше - лучше
+}
+
+// HintResponse - подсказка следующего шага
+type HintResponse struct {
+	Success bool   `json:"success" example:"true"`
+	From    string `json:"from" example:"Кошка"`
+	To      string `json:"to" example:"Собака"`
+	// Direct - цель есть среди ссылок текущей статьи, она и предложена
+	Direct      bool             `json:"direct" example:"false"`
+	Suggestions []HintSuggestion `json:"suggestions"`
+}
+
+// Hint раскрывает одну статью вперёд и ранжирует её ссылки эвристикой
+// к цели, не строя полный путь. Если цель среди ссылок, возвращает её.
+func (s *APISearcher) Hint(current, target, lang string, limit int) (suggestions []APIWikiNode, direct bool) {
+	current, target = normalizeTitleAPI(current), normalizeTitleAPI(target)
+	s.seed(lang, current, lang, target)
+
+	nodes := s.fetch([]string{current}, lang, "F")
+	if s.found.Load() {
+		s.resultMu.Lock()
+		defer s.resultMu.Unlock()
+		return []APIWikiNode{s.result[len(s.result)-1]}, true
+	}
+
+	sort.SliceStable(nodes, func(i, j int) bool { return nodes[i].Priority < nodes[j].Priority })
+	if len(nodes) > limit {
+		nodes = nodes[:limit]
+	}
+	for _, n := range nodes {
+		suggestions = append(suggestions, *n)
+	}
+	return suggestions, false
+}
+
+// SearchHint godoc
+// @Summary Подсказка следующего шага
+// @Description Раскрывает текущую статью и возвращает лучшие по эвристике ссылки в сторону цели, без поиска полного пути
+// @Tags search
+// @Produce json
+// @Param from query string true "Текущая статья игрока" example(Кошка)
+// @Param to query string true "Целевая статья" example(Собака)
+// @Param lang query string false "Язык" example(ru)
+// @Param limit query int false "Сколько подсказок вернуть (1-20)" example(3)
+// @Success 200 {object} HintResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /hint [get]
+func SearchHint(c *fiber.Ctx) error {
+	from := normalizeTitleAPI(c.Query("from"))
+	to := normalizeTitleAPI(c.Query("to"))
+	lang := c.Query("lang", defaultLang)
+	limit := c.QueryInt("limit", 3)
+	if limit < 1 || limit > 20 {
+		limit = 3
+	}
+
+	if from == "" || to == "" {
+		return c.Status(400).JSON(ErrorResponse{
+			Success: false,
+			Error:   "Необходимо указать параметры 'from' и 'to'",
+			Code:    "MISSING_PARAMS",
+		})
+	}
+
+	if _, ok := apiWikis[lang]; !ok {
+		return c.Status(400).JSON(ErrorResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Неизвестный язык %q", lang),
+			Code:    "UNKNOWN_LANG",
+		})
+	}
+
+	// Клиент ушёл - запросы подсказки отменяются вместе с ним
+	ctx, cancel := requestContext(c)
+	defer cancel()
+	s := NewAPISearcher(ctx, lang, from, lang, to, defaultAPIOptions)
+	defer s.cancel()
+	nodes, direct := s.Hint(from, to, lang, limit)
+	if len(nodes) == 0 {
+		return c.Status(404).JSON(ErrorResponse{
+			Success: false,
+			Error:   "У статьи нет подходящих ссылок",
+			Code:    "PATH_NOT_FOUND",
+		})
+	}
+
+	resp := HintResponse{Success: true, From: from, To: to, Direct: direct}
+	for _, n := range nodes {
+		resp.Suggestions = append(resp.Suggestions, HintSuggestion{
+			Title: n.Title,
+			Lang:  n.Lang,
+			URL:   buildWikiURL(n.Lang, n.Title),
+			Score: n.Priority,
+		})
+	}
+	return c.JSON(resp)
+}
+
+// TitleSuggestion - статья, предложенная автодополнением
+type TitleSuggestion struct {
+	Title string `json:"title" example:"Кошка"`
+	URL   string `json:"url" example:"https://ru.wikipedia.org/wiki/Кошка"`
+}
+
+// SuggestResponse - варианты названий для введённого начала
+type SuggestResponse struct {
+	Success     bool              `json:"success" example:"true"`
+	Query       string            `json:"query" example:"Кош"`
+	Lang        string            `json:"lang" example:"ru"`
+	Suggestions []TitleSuggestion `json:"suggestions"`
+}
+
+// suggestMaxLimit - предел limit в /suggest
+const suggestMaxLimit = 50
+
+// Suggest возвращает статьи раздела lang, названия которых начинаются с q,
+// через action=opensearch. Редиректы разрешаются в статьи.
+func (s *APISearcher) Suggest(lang, q string, limit int) ([]TitleSuggestion, error) {
+	params := url.Values{
+		"action":    {"opensearch"},
+		"format":    {"json"},
+		"search":    {q},
+		"limit":     {strconv.Itoa(limit)},
+		"namespace": {"0"},
+		"redirects": {"resolve"},
+	}
+	setMaxLagAPI(params, s.opts.MaxLag)
+
+	req, err := http.NewRequestWithContext(s.ctx, "GET", apiWikis[lang].APIURL+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.doWithRetry(req, s.opts.HTTPRetries+1)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("opensearch: HTTP %d", resp.StatusCode)
+	}
+
+	// Ответ - массив [запрос, [названия], [описания], [ссылки]]
+	var data []json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return nil, fmt.Errorf("opensearch: %w", err)
+	}
+	if len(data) < 2 {
+		return nil, fmt.Errorf("opensearch: неожиданный ответ из %d элементов", len(data))
+	}
+	var titles, urls []string
+	if err := json.Unmarshal(data[1], &titles); err != nil {
+		return nil, fmt.Errorf("opensearch: %w", err)
+	}
+	if len(data) > 3 {
+		// Ссылки необязательны: без них URL строится сам
+		json.Unmarshal(data[3], &urls)
+	}
+
+	suggestions := make([]TitleSuggestion, len(titles))
+	for i, title := range titles {
+		u := buildWikiURL(lang, title)
+		if i < len(urls) && urls[i] != "" {
+			u = urls[i]
+		}
+		suggestions[i] = TitleSuggestion{Title: title, URL: u}
+	}
+	return suggestions, nil
+}
+
+// SuggestTitles godoc
+// @Summary Автодополнение названий статей
+// @Description Статьи, названия которых начинаются с q (MediaWiki opensearch), для подсказок при вводе from и to. Редиректы разрешаются в статьи.
+// @Tags graph
+// @Produce json
+// @Param q query string true "Начало названия" example(Кош)
+// @Param lang query string false "Язык" example(ru)
+// @Param limit query int false "Сколько вариантов вернуть (1-50)" example(10)
+// @Success 200 {object} SuggestResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 502 {object} ErrorResponse
+// @Router /suggest [get]
+func SuggestTitles(c *fiber.Ctx) error {
+	q := strings.TrimSpace(c.Query("q"))
+	lang := c.Query("lang", defaultLang)
+	limit := c.QueryInt("limit", 10)
+	if limit < 1 || limit > suggestMaxLimit {
+		limit = 10
+	}
+	if q == "" {
+		return c.Status(400).JSON(ErrorResponse{
+			Success: false,
+			Error:   "Необходимо указать параметр 'q'",
+			Code:    "MISSING_PARAMS",
+		})
+	}
+	if _, ok := apiWikis[lang]; !ok {
+		return c.Status(400).JSON(ErrorResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Неизвестный язык %q", lang),
+			Code:    "UNKNOWN_LANG",
+		})
+	}
+
+	ctx, cancel := requestContext(c)
+	defer cancel()
+	s := NewAPISearcher(ctx, "", "", "", "", defaultAPIOptions)
+	defer s.cancel()
+	suggestions, err := s.Suggest(lang, q, limit)
+	if err != nil {
+		return c.Status(502).JSON(ErrorResponse{
+			Success: false,
+			Error:   "Wikipedia API недоступен",
+			Code:    "UPSTREAM_ERROR",
+		})
+	}
+	return c.JSON(SuggestResponse{Success: true, Query: q, Lang: lang, Suggestions: suggestions})
+}
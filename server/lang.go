@@ -0,0 +1,202 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// normalizeTitleAPI приводит название к виду MediaWiki: "_" - пробел,
+// пробелы по краям убираются, внутренние схлопываются, первая буква
+// заглавная. Регистр остальных букв значим: "new_york" - это "New york",
+// а не "New York", "eBay" - "EBay"
+func normalizeTitleAPI(title string) string {
+	title = strings.Join(strings.Fields(strings.ReplaceAll(title, "_", " ")), " ")
+	r, size := utf8.DecodeRuneInString(title)
+	if up := unicode.ToUpper(r); up != r {
+		title = string(up) + title[size:]
+	}
+	return title
+}
+
+// langLettersAPI - буквы, по которым узнаётся язык названия. Проверяются
+// по порядку: ї - украинский, хотя рядом может быть и общая кириллица;
+// ã в "São Paulo" - португальский, хотя ç бывает и во французском
+var langLettersAPI = []struct{ lang, letters string }{
+	{"uk", "іїєґ"},
+	{"ru", "ыэъё"},
+	{"de", "ßäöü"},
+	{"pt", "ãõ"},
+	{"es", "ñ¿¡"},
+	{"fr", "çœæéèêëîïûùÿâô"},
+	{"it", "ìòà"},
+	{"es", "áíóú"},
+}
+
+// guessLangAPI - быстрое определение языка по символам: характерные буквы
+// языков, затем письменность (любая кириллица - ru), "en" - если
+// ничего не подошло
+func guessLangAPI(title string) string {
+	lower := strings.ToLower(title)
+	for _, l := range langLettersAPI {
+		if strings.ContainsAny(lower, l.letters) {
+			return l.lang
+		}
+	}
+	for _, r := range lower {
+		if unicode.Is(unicode.Cyrillic, r) {
+			return "ru"
+		}
+	}
+	return "en"
+}
+
+// detectLang определяет язык статьи. Пустой язык - не определился;
+// missing - все языки успели ответить, и статьи нет ни в одном
+// (а не сеть подвела или окно DetectTimeout истекло).
+func (s *APISearcher) detectLang(title string) (lang, realTitle string, missing bool) {
+	langs := s.detectCandidates(guessLangAPI(title))
+
+	type result struct {
+		lang      string
+		realTitle string
+		found     bool
+		err       error
+	}
+
+	results := make(chan result, len(langs))
+	// От контекста поиска: отменённый поиск не определяет язык
+	ctx, cancel := context.WithTimeout(s.ctx, s.opts.DetectTimeout)
+	defer cancel()
+
+	for _, lang := range langs {
+		go func(l string) {
+			realTitle, found, err := s.findTitle(ctx, l, title)
+			results <- result{l, realTitle, found, err}
+		}(lang)
+	}
+
+	// По истечении окна работаем с тем, что успело прийти
+	foundLangs := make(map[string]string)
+	done := make(map[string]bool)
+	absent := 0
+collect:
+	for i := 0; i < len(langs); i++ {
+		select {
+		case r := <-results:
+			done[r.lang] = true
+			if r.found {
+				foundLangs[r.lang] = r.realTitle
+			} else if r.err == nil {
+				absent++
+			}
+		case <-ctx.Done():
+			break collect
+		}
+		// Все языки приоритетнее найденного ответили - остальных не ждём
+		for _, lang := range langs {
+			if !done[lang] {
+				break
+			}
+			if realTitle, ok := foundLangs[lang]; ok {
+				return lang, realTitle, false
+			}
+		}
+	}
+
+	for _, lang := range langs {
+		if realTitle, ok := foundLangs[lang]; ok {
+			return lang, realTitle, false
+		}
+	}
+	return "", "", len(langs) > 0 && absent == len(langs)
+}
+
+// detectPriorityAPI - порядок языков при определении без DetectLangs
+var detectPriorityAPI = []string{"ru", "en", "uk", "de", "fr", "es", "it", "pt"}
+
+// cyrillicLangsAPI - разделы на кириллице
+var cyrillicLangsAPI = map[string]bool{"ru": true, "uk": true}
+
+// detectLangsAPI - включённые разделы в порядке detectPriorityAPI
+var detectLangsAPI []string
+
+// detectCandidates - языки, в которых detectLang ищет статью, в порядке
+// приоритета: сначала угаданный по символам, затем DetectLangs. Без
+// DetectLangs - все разделы: той же письменности, что угаданный, затем
+// остальные.
+func (s *APISearcher) detectCandidates(guessed string) []string {
+	var langs []string
+	if _, ok := apiWikis[guessed]; ok {
+		langs = append(langs, guessed)
+	}
+	if len(s.opts.DetectLangs) == 0 {
+		for _, sameScript := range []bool{true, false} {
+			for _, l := range detectLangsAPI {
+				if l != guessed && (cyrillicLangsAPI[l] == cyrillicLangsAPI[guessed]) == sameScript {
+					langs = append(langs, l)
+				}
+			}
+		}
+		return langs
+	}
+	for _, lang := range s.opts.DetectLangs {
+		if lang != guessed {
+			langs = append(langs, lang)
+		}
+	}
+	return langs
+}
+
+// lookupTitle проверяет, что статья есть в разделе lang, и возвращает
+// её настоящее название после нормализации и редиректов
+func (s *APISearcher) lookupTitle(ctx context.Context, lang, title string) (string, bool) {
+	realTitle, found, _ := s.findTitle(ctx, lang, title)
+	return realTitle, found
+}
+
+// findTitle - lookupTitle с ошибкой: found=false без ошибки значит, что
+// Wikipedia ответила и статьи нет
+func (s *APISearcher) findTitle(ctx context.Context, lang, title string) (string, bool, error) {
+	params := url.Values{
+		"action":    {"query"},
+		"format":    {"json"},
+		"titles":    {title},
+		"redirects": {"1"},
+	}
+	setMaxLagAPI(params, s.opts.MaxLag)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", apiWikis[lang].APIURL+"?"+params.Encode(), nil)
+	if err != nil {
+		return "", false, err
+	}
+
+	resp, err := s.doWithRetry(req, s.opts.HTTPRetries+1)
+	if err != nil {
+		return "", false, err
+	}
+	defer resp.Body.Close()
+
+	var data struct {
+		Query struct {
+			Pages map[string]struct {
+				Title   string `json:"title"`
+				Missing bool   `json:"missing"`
+			} `json:"pages"`
+		} `json:"query"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return "", false, err
+	}
+
+	for id, page := range data.Query.Pages {
+		if id != "-1" && !page.Missing {
+			return page.Title, true, nil
+		}
+	}
+	return "", false, nil
+}
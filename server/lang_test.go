@@ -0,0 +1,165 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"wikiracer/wikis"
+)
+
+func TestNormalizeTitleAPI(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"iPhone", "IPhone"},
+		{"new york", "New york"},
+		{"eBay", "EBay"},
+		{"New_York", "New York"},
+		{"  New   York ", "New York"},
+		{"париж", "Париж"},
+		{"ñandú", "Ñandú"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := normalizeTitleAPI(tt.in); got != tt.want {
+			t.Errorf("normalizeTitleAPI(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSearchTitleWhitespace(t *testing.T) {
+	withFakeWiki(t, (&graphWiki{links: map[string][]string{
+		"Кошка": {"Мышь"}, "Мышь": {"Сыр"}, "Сыр": {"Сырная корка"},
+	}}).ServeHTTP, "ru")
+	app := NewApp()
+
+	// Варианты с пробелами по краям и двойными пробелами внутри сходятся
+	// на тех же статьях, что и канонические названия
+	for _, pair := range [][2]string{
+		{"Кошка", "Сырная корка"},
+		{" Кошка", "Сырная корка "},
+		{"Кошка\t", "Сырная  корка"},
+		{"  Кошка  ", " Сырная_ корка"},
+	} {
+		status, path := postSearch(t, app, fmt.Sprintf(`{"from":%q,"to":%q,"lang":"ru"}`, pair[0], pair[1]))
+		if status != http.StatusOK || path != "Кошка Мышь Сыр Сырная корка" {
+			t.Errorf("%q → %q: %d %q, want 200 путь через Мышь и Сыр", pair[0], pair[1], status, path)
+		}
+	}
+}
+
+func TestGuessLangAPI(t *testing.T) {
+	tests := []struct{ title, want string }{
+		{"Ña", "es"},
+		{"Köln", "de"},
+		{"Київ", "uk"},
+		{"São Paulo", "pt"},
+		{"Москва", "ru"},
+		{"Подъезд", "ru"},
+		{"Français", "fr"},
+		{"Città", "it"},
+		{"Paris", "en"},
+		{"", "en"},
+	}
+	for _, tt := range tests {
+		if got := guessLangAPI(tt.title); got != tt.want {
+			t.Errorf("guessLangAPI(%q) = %q, want %q", tt.title, got, tt.want)
+		}
+	}
+}
+
+func TestDetectLangAllWikisAPI(t *testing.T) {
+	wiki := func(titles ...string) *graphWiki {
+		g := &graphWiki{links: map[string][]string{}}
+		for _, title := range titles {
+			g.links[title] = nil
+		}
+		return g
+	}
+	withFakeWikis(t, map[string]http.Handler{
+		"ru": wiki("Кошка"), "en": wiki("Cat"),
+		"uk": wiki("Київ", "Одеса"), "de": wiki("Straße", "Berlin Hauptbahnhof"),
+		"fr": wiki(), "es": wiki(), "it": wiki(), "pt": wiki(),
+	})
+	detectLangsAPI = wikis.Sort(detectLangsAPI, detectPriorityAPI)
+
+	tests := []struct{ title, want string }{
+		{"Straße", "de"},
+		{"Berlin Hauptbahnhof", "de"},
+		{"Київ", "uk"},
+		{"Одеса", "uk"},
+	}
+	for _, tt := range tests {
+		s := newTestSearcher(t, defaultAPIOptions)
+		lang, realTitle, missing := s.detectLang(tt.title)
+		if lang != tt.want || realTitle != tt.title || missing {
+			t.Errorf("detectLang(%q) = %s, %q, missing=%v, want %s", tt.title, lang, realTitle, missing, tt.want)
+		}
+	}
+}
+
+func TestDetectLangPriority(t *testing.T) {
+	// Berlin угадывается как en; в en её нет, есть в de и fr. Madrid есть
+	// и в en - угаданный язык проверяется первым при любом порядке
+	withFakeWikis(t, map[string]http.Handler{
+		"en": &graphWiki{links: map[string][]string{"Madrid": {"Spain"}}},
+		"de": &graphWiki{links: map[string][]string{"Berlin": {"Spree"}, "Madrid": {"Spanien"}}},
+		"fr": &graphWiki{links: map[string][]string{"Berlin": {"Allemagne"}, "Madrid": {"Espagne"}}},
+	})
+
+	tests := []struct {
+		detect      []string
+		title, want string
+	}{
+		{[]string{"de", "fr"}, "Berlin", "de"},
+		{[]string{"fr", "de"}, "Berlin", "fr"},
+		{[]string{"fr", "de"}, "Madrid", "en"},
+	}
+	for _, tt := range tests {
+		opts := defaultAPIOptions
+		opts.DetectLangs = tt.detect
+		s := newTestSearcher(t, opts)
+		lang, realTitle, missing := s.detectLang(tt.title)
+		if lang != tt.want || realTitle != tt.title || missing {
+			t.Errorf("DetectLangs=%v: %s определена как %s:%s (missing=%v), want %s", tt.detect, tt.title, lang, realTitle, missing, tt.want)
+		}
+	}
+}
+
+func TestGuessEnd(t *testing.T) {
+	// Köln угадывается как de, где раздел не отвечает; Москва - как ru,
+	// который выключен
+	withFakeWikis(t, map[string]http.Handler{
+		"en": &graphWiki{
+			links:     map[string][]string{"Paris": {"France"}},
+			redirects: map[string]string{"Paris city": "Paris"},
+		},
+		"de": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}),
+	})
+
+	tests := []struct {
+		title, wantLang, wantTitle string
+		missing, warned            bool
+	}{
+		{"paris city", "en", "Paris", false, false},
+		{"Nowhere", "en", "Nowhere", true, false},
+		{"Köln", "de", "Köln", false, true},
+		{"Москва", "ru", "Москва", false, true},
+	}
+	for _, tt := range tests {
+		opts := defaultAPIOptions
+		opts.HTTPRetries = 0
+		s := newTestSearcher(t, opts)
+		lang, title := s.guessEnd("to", tt.title)
+		if lang != tt.wantLang || title != tt.wantTitle {
+			t.Errorf("guessEnd(%q) = %s:%s, want %s:%s", tt.title, lang, title, tt.wantLang, tt.wantTitle)
+		}
+		if missing := len(s.missing) > 0; missing != tt.missing {
+			t.Errorf("%s: missing %v, want %v", tt.title, s.missing, tt.missing)
+		}
+		if warned := len(s.warnings) > 0; warned != tt.warned {
+			t.Errorf("%s: предупреждения %q, want %v", tt.title, s.warnings, tt.warned)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Neighbor - сосед статьи в графе ссылок
+type Neighbor struct {
+	Title     string `json:"title" example:"Собака"`
+	Lang      string `json:"lang" example:"ru"`
+	Namespace int    `json:"namespace" example:"0"`
+	// Direction - forward (статья ссылается на соседа), backward (сосед
+	// ссылается на статью) или interwiki
+	Direction string `json:"direction" example:"forward"`
+	Score     int    `json:"score" example:"85"` // приоритет эвристики без цели, меньше - лучше
+}
+
+// NeighborsResponse - соседи статьи
+type NeighborsResponse struct {
+	Success   bool       `json:"success" example:"true"`
+	Title     string     `json:"title" example:"Кошка"` // после редиректов
+	Lang      string     `json:"lang" example:"ru"`
+	Count     int        `json:"count" example:"412"`
+	Neighbors []Neighbor `json:"neighbors"`
+}
+
+// neighborsMaxPages - сколько страниц продолжения догружать в /neighbors:
+// нужны все соседи, а не первые страницы, как при поиске
+const neighborsMaxPages = 100
+
+// Neighbors возвращает ссылки статьи в направлениях dirs ("F", "B") и её
+// interwiki с оценкой эвристики без цели. Пустое название - статьи нет.
+func (s *APISearcher) Neighbors(title, lang string, dirs []string) (string, []Neighbor, error) {
+	var resolved string
+	neighbors := []Neighbor{}
+	for i, dir := range dirs {
+		page, ok := s.cached(lang, title, dir)
+		if !ok {
+			pages, err := s.fetchPages([]string{title}, lang, dir)
+			if err != nil {
+				return "", nil, err
+			}
+			for id, p := range pages {
+				if !strings.HasPrefix(id, "-") {
+					page, ok = p, true
+				}
+			}
+			if !ok {
+				return "", nil, nil
+			}
+		}
+		resolved = page.Title
+
+		links, direction := page.Links, "forward"
+		if dir == "B" {
+			links, direction = page.LinksHere, "backward"
+		}
+		for _, link := range links {
+			neighbors = append(neighbors, Neighbor{
+				Title:     link.Title,
+				Lang:      lang,
+				Namespace: link.Ns,
+				Direction: direction,
+				Score:     s.HeuristicFunc(link.Title, lang, dir),
+			})
+		}
+		// interwiki приходят в ответе любого направления - берём один раз
+		if i == 0 {
+			for _, ll := range page.LangLinks {
+				neighbors = append(neighbors, Neighbor{
+					Title:     ll.Title,
+					Lang:      ll.Lang,
+					Namespace: page.Ns,
+					Direction: "interwiki",
+					Score:     s.HeuristicFunc(ll.Title, ll.Lang, dir),
+				})
+			}
+		}
+	}
+	return resolved, neighbors, nil
+}
+
+// SearchNeighbors godoc
+// @Summary Соседи статьи в графе ссылок
+// @Description Все исходящие и/или входящие ссылки статьи и её interwiki, с продолжениями (continue) и оценкой эвристики без цели
+// @Tags graph
+// @Produce json
+// @Param title query string true "Статья" example(Кошка)
+// @Param lang query string false "Язык" example(ru)
+// @Param dir query string false "Направление: forward, backward или both" Enums(forward, backward, both)
+// @Param namespaces query string false "Пространства имён соседей через |, 0 - статьи, 14 - категории" example(0|14)
+// @Success 200 {object} NeighborsResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 502 {object} ErrorResponse
+// @Router /neighbors [get]
+func SearchNeighbors(c *fiber.Ctx) error {
+	title := normalizeTitleAPI(c.Query("title"))
+	lang := c.Query("lang", defaultLang)
+	if title == "" {
+		return c.Status(400).JSON(ErrorResponse{
+			Success: false,
+			Error:   "Необходимо указать параметр 'title'",
+			Code:    "MISSING_PARAMS",
+		})
+	}
+	if _, ok := apiWikis[lang]; !ok {
+		return c.Status(400).JSON(ErrorResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Неизвестный язык %q", lang),
+			Code:    "UNKNOWN_LANG",
+		})
+	}
+
+	var dirs []string
+	switch c.Query("dir", "both") {
+	case "forward":
+		dirs = []string{"F"}
+	case "backward":
+		dirs = []string{"B"}
+	case "both":
+		dirs = []string{"F", "B"}
+	default:
+		return c.Status(400).JSON(ErrorResponse{
+			Success: false,
+			Error:   "dir: ожидается forward, backward или both",
+			Code:    "INVALID_DIR",
+		})
+	}
+
+	opts := defaultAPIOptions
+	opts.ContinueMode = ContinueFollow
+	opts.ContinuePages = neighborsMaxPages
+	opts.LargeResponseLinks = 0
+	if v := c.Query("namespaces"); v != "" {
+		namespaces, err := parseNamespacesAPI(v)
+		if err != nil {
+			return c.Status(400).JSON(ErrorResponse{
+				Success: false,
+				Error:   "namespaces: " + err.Error(),
+				Code:    "INVALID_NAMESPACES",
+			})
+		}
+		opts.Namespaces = namespaces
+	}
+
+	// Без концов пути: оценка соседей - только общие предпочтения эвристики
+	ctx, cancel := requestContext(c)
+	defer cancel()
+	s := NewAPISearcher(ctx, "", "", "", "", opts)
+	defer s.cancel()
+	resolved, neighbors, err := s.Neighbors(title, lang, dirs)
+	if err != nil {
+		return c.Status(502).JSON(ErrorResponse{
+			Success: false,
+			Error:   "Wikipedia API недоступен",
+			Code:    "UPSTREAM_ERROR",
+		})
+	}
+	if resolved == "" {
+		return c.Status(404).JSON(ErrorResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Статьи '%s' нет в %s", title, lang),
+			Code:    "ARTICLE_NOT_FOUND",
+		})
+	}
+	return c.JSON(NeighborsResponse{
+		Success:   true,
+		Title:     resolved,
+		Lang:      lang,
+		Count:     len(neighbors),
+		Neighbors: neighbors,
+	})
+}
+
+// ExplainResponse - разбор приоритета статьи для фронта dir
+type ExplainResponse struct {
+	Success    bool               `json:"success" example:"true"`
+	Title      string             `json:"title" example:"Домашняя кошка"`
+	Lang       string             `json:"lang" example:"ru"`
+	Dir        string             `json:"dir" example:"F"`
+	Target     string             `json:"target" example:"Кошка"`
+	TargetLang string             `json:"target_lang" example:"ru"`
+	Breakdown  HeuristicBreakdown `json:"breakdown"`
+}
+
+// ExplainHeuristic godoc
+// @Summary Разбор оценки эвристики
+// @Description Приоритет, который эвристика поиска дала бы статье title на пути к target, по слагаемым. Запросов к Wikipedia не делает. Для dir=F target - конечная статья поиска, для dir=B - начальная.
+// @Tags graph
+// @Produce json
+// @Param title query string true "Оцениваемая статья" example(Домашняя кошка)
+// @Param lang query string false "Язык статьи" example(ru)
+// @Param dir query string false "Фронт: F (forward) или B (backward)" Enums(F, B)
+// @Param target query string true "Цель фронта" example(Кошка)
+// @Param target_lang query string false "Язык цели, по умолчанию lang" example(ru)
+// @Success 200 {object} ExplainResponse
+// @Failure 400 {object} ErrorResponse
+// @Router /explain [get]
+func ExplainHeuristic(c *fiber.Ctx) error {
+	title := normalizeTitleAPI(c.Query("title"))
+	target := normalizeTitleAPI(c.Query("target"))
+	lang := c.Query("lang", defaultLang)
+	targetLang := c.Query("target_lang", lang)
+	if title == "" || target == "" {
+		return c.Status(400).JSON(ErrorResponse{
+			Success: false,
+			Error:   "Необходимо указать параметры 'title' и 'target'",
+			Code:    "MISSING_PARAMS",
+		})
+	}
+	for _, l := range []string{lang, targetLang} {
+		if _, ok := apiWikis[l]; !ok {
+			return c.Status(400).JSON(ErrorResponse{
+				Success: false,
+				Error:   fmt.Sprintf("Неизвестный язык %q", l),
+				Code:    "UNKNOWN_LANG",
+			})
+		}
+	}
+
+	ctx, cancel := requestContext(c)
+	defer cancel()
+	var s *APISearcher
+	dir := c.Query("dir", "F")
+	switch dir {
+	case "F", "forward":
+		dir = "F"
+		s = NewAPISearcher(ctx, lang, "", targetLang, target, defaultAPIOptions)
+	case "B", "backward":
+		dir = "B"
+		s = NewAPISearcher(ctx, targetLang, target, lang, "", defaultAPIOptions)
+	default:
+		return c.Status(400).JSON(ErrorResponse{
+			Success: false,
+			Error:   "dir: ожидается F или B",
+			Code:    "INVALID_DIR",
+		})
+	}
+	defer s.cancel()
+
+	return c.JSON(ExplainResponse{
+		Success:    true,
+		Title:      title,
+		Lang:       lang,
+		Dir:        dir,
+		Target:     target,
+		TargetLang: targetLang,
+		Breakdown:  s.heuristicBreakdown(title, lang, dir),
+	})
+}
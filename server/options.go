@@ -0,0 +1,445 @@
+package server
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// APISearchOptions - настройки APISearcher
+type APISearchOptions struct {
+	ContinueMode  string // режим обработки continue-токенов
+	ContinuePages int    // максимум дополнительных страниц на один запрос
+	ListPenalty   int    // штраф эвристики для списков и страниц значений
+	SkipLists     bool   // не раскрывать списки и страницы значений вовсе
+	CheckDisambig bool   // запрашивать pageprops, чтобы находить страницы значений без пометки в названии
+
+	// DisambigStrategy - как раскрывать страницы значений в середине пути.
+	// Кроме hub требует pageprops, как CheckDisambig.
+	DisambigStrategy string
+
+	DetectTimeout time.Duration // окно на запросы detectLang
+	Timeout       time.Duration // бюджет всего поиска, 0 - defaultSearchTimeout
+
+	// MaxGETURL - запросы с URL длиннее этого уходят POST. 0 - всегда GET.
+	MaxGETURL int
+
+	// DrainTimeout - сколько Search после встречи ждёт, пока запросы,
+	// начатые до cancel, вернутся и закроют тела ответов. Тогда после
+	// возврата Search у поиска нет живых запросов. 0 - не ждать.
+	DrainTimeout time.Duration
+
+	// DetectLangs - в каких языках и в каком порядке detectLang ищет статью
+	// после угаданного по символам. Пусто - все разделы, сначала той же
+	// письменности, что угаданный.
+	DetectLangs []string
+
+	// EnqueueSlack - в очередь попадают только дети, чей приоритет не хуже
+	// лучшего узла фронта + slack. Большое значение - без отсечения.
+	EnqueueSlack int
+
+	// Blocklist - регулярки по названиям; совпавшие статьи не попадают в путь.
+	// Компилируются один раз при старте.
+	Blocklist []*regexp.Regexp
+
+	// DegradedThreshold - при большем числе сорвавшихся запросов батчей
+	// результат помечается degraded: путь найден по неполному графу
+	DegradedThreshold int
+
+	// LargeResponseBytes - ответ больше этого размера считается огромным:
+	// о нём пишется в лог. 0 - не проверять.
+	LargeResponseBytes int64
+	// LargeResponseLinks - сколько первых ссылок статьи оставлять в огромном
+	// ответе. 0 - обрабатывать все.
+	LargeResponseLinks int
+
+	// TransientRetries - сколько раз повторять запрос при readonly, maxlag
+	// и ratelimited от MediaWiki; TransientBackoff - пауза перед первым
+	// повтором, дальше растёт линейно
+	TransientRetries int
+	TransientBackoff time.Duration
+	// HTTPRetries - сколько раз повторять запрос при сетевой ошибке и
+	// ответах 429/503. Пауза - Retry-After, иначе TransientBackoff,
+	// удваиваемый с каждой попыткой, со случайной добавкой
+	HTTPRetries int
+	// RequestTimeout - предел одной попытки запроса к API, от отправки до
+	// конца чтения тела. Не связан с Timeout всего поиска: попытка, не
+	// уложившаяся в него, повторяется по HTTPRetries. 0 - без предела.
+	RequestTimeout time.Duration
+
+	// CategoryBridges - экспериментально: forward-фронт переходит ещё и
+	// к статьям из тех же категорий. Такие шаги - не клик по ссылке.
+	// CategoryLimit - сколько категорий статьи раскрывать,
+	// CategoryBudget - сколько категорий всего раскрыть за поиск.
+	CategoryBridges bool
+	CategoryLimit   int
+	CategoryBudget  int
+
+	// LangLinksForward и LangLinksBackward - раскрывать ли interwiki в
+	// каждом направлении. Запрос не меняется, чтобы кеш оставался полным.
+	LangLinksForward  bool
+	LangLinksBackward bool
+
+	// LangLinkLangs - если задан, из interwiki статьи раскрываются только
+	// эти языки, в этом порядке. LangLinkLimit - сколько interwiki статьи
+	// раскрывать (0 - все).
+	LangLinkLangs []string
+	LangLinkLimit int
+
+	// StrictInterwiki - раскрывать interwiki, только если статья на другом
+	// языке ссылается обратно на эту же статью: тогда это одно понятие.
+	// Обратная ссылка берётся из кеша или одним запросом на язык.
+	StrictInterwiki bool
+
+	// InterwikiFallback - у статьи только interwiki в неподключённые
+	// разделы: прочитать interwiki этих версий и перейти в подключённые
+	// разделы через них (только forward, до interwikiFallbackLimit
+	// запросов на статью)
+	InterwikiFallback bool
+
+	// MaxRequests и MaxRounds - бюджет поиска: запросов к API и раундов.
+	// Исчерпав бюджет, поиск возвращает частичный путь (HTTP 206). 0 - без лимита.
+	MaxRequests int
+	MaxRounds   int
+	// Shortest - поиск в ширину от обоих концов: раунд раскрывает один
+	// слой фронта, эвристика только упорядочивает узлы внутри слоя. Путь
+	// минимален по числу переходов, но стоит больше запросов, чем жадный.
+	Shortest bool
+	// MaxDepth - предел длины пути в переходах: узел на глубине MaxDepth
+	// не раскрывается, встреча с суммарной глубиной больше - не путь.
+	// Пары, недостижимые в пределах, кончаются 404 DEPTH_EXCEEDED, а не
+	// таймаутом. 0 - без предела.
+	MaxDepth int
+	// Namespaces - пространства имён ссылок для всех языков (plnamespace/
+	// lhnamespace), например [0, 14] - ещё и категории. Пусто - по
+	// WIKI_LANG_NAMESPACES, по умолчанию 0. Кеш ссылок хранит только
+	// ответы по умолчанию, поиск с Namespaces идёт мимо него.
+	Namespaces []int
+
+	// BridgeBonus - бонус эвристики узлам на языках BridgeLangs, когда оба
+	// конца на одном языке: путь ru→en→ru через хорошо связанный английский
+	// хаб бывает короче. 0 - выключено.
+	BridgeBonus int
+	BridgeLangs []string
+
+	// SimilarityWeight - целый множитель похожести названия на цель в
+	// эвристике. Сама похожесть - от 0 до 1 (titleSimilarity), приоритет
+	// уменьшается на weight*similarity, так что weight - наибольший бонус
+	// в единицах приоритета. 0 - компонент выключен.
+	SimilarityWeight int
+
+	// DebugRequests - сколько первых запросов к API записывать в debug.requests
+	// при debug=true; остальные только считаются
+	DebugRequests int
+	// AnimateEvents - сколько событий журнала анимации хранить при animate=true
+	AnimateEvents int
+	// ExploredNodes - сколько рёбер дерева поиска хранить при explored=true
+	ExploredNodes int
+	// FetchConcurrency - сколько батчей раунда раскрываются одновременно
+	// и сколько запросов поиска идут одновременно, включая дозапросы
+	// interwiki, категорий и страниц значений. Остальные ждут свободного
+	// места, так что число горутин раунда не растёт с числом языков и продолжений.
+	FetchConcurrency int
+
+	// UserAgent - User-Agent и Api-User-Agent всех запросов к API.
+	// Wikimedia просит один описательный UA с контактом.
+	UserAgent string
+	// MaxLag - maxlag запросов в секундах: при большем отставании реплик
+	// MediaWiki отвечает ошибкой maxlag, и запрос повторяется как при 429.
+	// 0 - не передавать.
+	MaxLag int
+	// ThrottlePercent - когда остаток квоты X-RateLimit ниже этой доли
+	// лимита (в процентах), запросы к хосту замедляются. 0 - выключено.
+	ThrottlePercent int
+
+	// LangConflict - что делать, если статьи нет в явно заданном
+	// from_lang/to_lang: LangConflictExplicit или LangConflictDetect
+	LangConflict string
+}
+
+// defaultAPIOptions - настройки по умолчанию, переопределяются через окружение в loadAPIOptions
+var defaultAPIOptions = APISearchOptions{
+	ContinueMode:     ContinueFollow,
+	DisambigStrategy: DisambigHub,
+	ContinuePages:    5,
+	ListPenalty:      15,
+	DetectTimeout:    500 * time.Millisecond,
+	MaxGETURL:        4000,
+	EnqueueSlack:     1000,
+
+	LargeResponseBytes: 2 << 20, // 2 MB
+	TransientRetries:   2,
+	TransientBackoff:   300 * time.Millisecond,
+	HTTPRetries:        2,
+	RequestTimeout:     800 * time.Millisecond,
+	CategoryLimit:      2,
+	CategoryBudget:     20,
+	LangLinksForward:   true,
+	LangLinksBackward:  true,
+	BridgeLangs:        []string{"en"},
+	DebugRequests:      100,
+	AnimateEvents:      500,
+	ExploredNodes:      2000,
+	FetchConcurrency:   20,
+	UserAgent:          "WikiRacer/5.0 (https://github.com/Prost0Name/wiki-search)",
+	MaxLag:             5,
+	ThrottlePercent:    10,
+	LangConflict:       LangConflictExplicit,
+}
+
+// loadAPIOptions читает настройки из переменных окружения WIKI_*
+func loadAPIOptions() error {
+	if v := os.Getenv("WIKI_CONTINUE_MODE"); v != "" {
+		switch v {
+		case ContinueFollow, ContinueSplit, ContinueOff:
+			defaultAPIOptions.ContinueMode = v
+		default:
+			return fmt.Errorf("WIKI_CONTINUE_MODE: неизвестный режим %q", v)
+		}
+	}
+	if err := envInt("WIKI_CONTINUE_PAGES", &defaultAPIOptions.ContinuePages); err != nil {
+		return err
+	}
+	if err := envInt("WIKI_LIST_PENALTY", &defaultAPIOptions.ListPenalty); err != nil {
+		return err
+	}
+	if err := envBool("WIKI_SKIP_LISTS", &defaultAPIOptions.SkipLists); err != nil {
+		return err
+	}
+	if err := envBool("WIKI_CHECK_DISAMBIG", &defaultAPIOptions.CheckDisambig); err != nil {
+		return err
+	}
+	if v := os.Getenv("WIKI_DISAMBIG_STRATEGY"); v != "" {
+		switch v {
+		case DisambigHub, DisambigSkip, DisambigBest:
+			defaultAPIOptions.DisambigStrategy = v
+		default:
+			return fmt.Errorf("WIKI_DISAMBIG_STRATEGY: неизвестная стратегия %q", v)
+		}
+	}
+	if err := envMillis("WIKI_DETECT_TIMEOUT_MS", &defaultAPIOptions.DetectTimeout); err != nil {
+		return err
+	}
+	if err := envMillis("WIKI_DRAIN_TIMEOUT_MS", &defaultAPIOptions.DrainTimeout); err != nil {
+		return err
+	}
+	if err := envMillis("WIKI_SEARCH_TIMEOUT_MS", &defaultAPIOptions.Timeout); err != nil {
+		return err
+	}
+	if err := envInt("WIKI_MAX_GET_URL", &defaultAPIOptions.MaxGETURL); err != nil {
+		return err
+	}
+	if err := envInt("WIKI_ENQUEUE_SLACK", &defaultAPIOptions.EnqueueSlack); err != nil {
+		return err
+	}
+	if err := envInt("WIKI_DEGRADED_THRESHOLD", &defaultAPIOptions.DegradedThreshold); err != nil {
+		return err
+	}
+	if err := envInt("WIKI_SIMILARITY_WEIGHT", &defaultAPIOptions.SimilarityWeight); err != nil {
+		return err
+	}
+	if err := envInt("WIKI_DEBUG_REQUESTS", &defaultAPIOptions.DebugRequests); err != nil {
+		return err
+	}
+	if err := envInt("WIKI_ANIMATE_EVENTS", &defaultAPIOptions.AnimateEvents); err != nil {
+		return err
+	}
+	if err := envInt("WIKI_EXPLORED_NODES", &defaultAPIOptions.ExploredNodes); err != nil {
+		return err
+	}
+	if err := envInt("WIKI_FETCH_CONCURRENCY", &defaultAPIOptions.FetchConcurrency); err != nil {
+		return err
+	}
+	if defaultAPIOptions.FetchConcurrency < 1 {
+		return fmt.Errorf("WIKI_FETCH_CONCURRENCY: нужно не меньше 1, получено %d", defaultAPIOptions.FetchConcurrency)
+	}
+	if v := os.Getenv("WIKI_USER_AGENT"); v != "" {
+		defaultAPIOptions.UserAgent = v
+	}
+	if err := envInt("WIKI_MAXLAG", &defaultAPIOptions.MaxLag); err != nil {
+		return err
+	}
+	if err := envInt("WIKI_THROTTLE_PERCENT", &defaultAPIOptions.ThrottlePercent); err != nil {
+		return err
+	}
+	if v := os.Getenv("WIKI_LANG_CONFLICT"); v != "" {
+		switch v {
+		case LangConflictExplicit, LangConflictDetect:
+			defaultAPIOptions.LangConflict = v
+		default:
+			return fmt.Errorf("WIKI_LANG_CONFLICT: неизвестная политика %q", v)
+		}
+	}
+	largeKB := int(defaultAPIOptions.LargeResponseBytes >> 10)
+	if err := envInt("WIKI_LARGE_RESPONSE_KB", &largeKB); err != nil {
+		return err
+	}
+	defaultAPIOptions.LargeResponseBytes = int64(largeKB) << 10
+	if err := envInt("WIKI_LARGE_RESPONSE_LINKS", &defaultAPIOptions.LargeResponseLinks); err != nil {
+		return err
+	}
+	if err := envInt("WIKI_TRANSIENT_RETRIES", &defaultAPIOptions.TransientRetries); err != nil {
+		return err
+	}
+	if err := envInt("WIKI_HTTP_RETRIES", &defaultAPIOptions.HTTPRetries); err != nil {
+		return err
+	}
+	if err := envMillis("WIKI_REQUEST_TIMEOUT_MS", &defaultAPIOptions.RequestTimeout); err != nil {
+		return err
+	}
+	if err := envBool("WIKI_CATEGORY_BRIDGES", &defaultAPIOptions.CategoryBridges); err != nil {
+		return err
+	}
+	if err := envInt("WIKI_CATEGORY_LIMIT", &defaultAPIOptions.CategoryLimit); err != nil {
+		return err
+	}
+	if err := envInt("WIKI_CATEGORY_BUDGET", &defaultAPIOptions.CategoryBudget); err != nil {
+		return err
+	}
+	if err := envInt("WIKI_MAX_REQUESTS", &defaultAPIOptions.MaxRequests); err != nil {
+		return err
+	}
+	if err := envBool("WIKI_LANGLINKS_FORWARD", &defaultAPIOptions.LangLinksForward); err != nil {
+		return err
+	}
+	if err := envBool("WIKI_LANGLINKS_BACKWARD", &defaultAPIOptions.LangLinksBackward); err != nil {
+		return err
+	}
+	if err := envInt("WIKI_LANGLINK_LIMIT", &defaultAPIOptions.LangLinkLimit); err != nil {
+		return err
+	}
+	if err := envBool("WIKI_STRICT_INTERWIKI", &defaultAPIOptions.StrictInterwiki); err != nil {
+		return err
+	}
+	if err := envBool("WIKI_INTERWIKI_FALLBACK", &defaultAPIOptions.InterwikiFallback); err != nil {
+		return err
+	}
+	if err := envInt("WIKI_BRIDGE_BONUS", &defaultAPIOptions.BridgeBonus); err != nil {
+		return err
+	}
+	if v := os.Getenv("WIKI_BRIDGE_LANGS"); v != "" {
+		defaultAPIOptions.BridgeLangs = nil
+		for _, lang := range strings.Split(v, ",") {
+			lang = strings.TrimSpace(lang)
+			if _, ok := apiWikis[lang]; !ok {
+				return fmt.Errorf("WIKI_BRIDGE_LANGS: неизвестный язык %q", lang)
+			}
+			defaultAPIOptions.BridgeLangs = append(defaultAPIOptions.BridgeLangs, lang)
+		}
+	}
+	if v := os.Getenv("WIKI_DETECT_LANGS"); v != "" {
+		for _, lang := range strings.Split(v, ",") {
+			lang = strings.TrimSpace(lang)
+			if _, ok := apiWikis[lang]; !ok {
+				return fmt.Errorf("WIKI_DETECT_LANGS: неизвестный язык %q", lang)
+			}
+			defaultAPIOptions.DetectLangs = append(defaultAPIOptions.DetectLangs, lang)
+		}
+	}
+	if v := os.Getenv("WIKI_LANGLINK_LANGS"); v != "" {
+		for _, lang := range strings.Split(v, ",") {
+			lang = strings.TrimSpace(lang)
+			if _, ok := apiWikis[lang]; !ok {
+				return fmt.Errorf("WIKI_LANGLINK_LANGS: неизвестный язык %q", lang)
+			}
+			defaultAPIOptions.LangLinkLangs = append(defaultAPIOptions.LangLinkLangs, lang)
+		}
+	}
+	if err := envInt("WIKI_MAX_ROUNDS", &defaultAPIOptions.MaxRounds); err != nil {
+		return err
+	}
+	if err := envInt("WIKI_MAX_DEPTH", &defaultAPIOptions.MaxDepth); err != nil {
+		return err
+	}
+	if err := envMillis("WIKI_TRANSIENT_BACKOFF_MS", &defaultAPIOptions.TransientBackoff); err != nil {
+		return err
+	}
+	if err := envInt("WIKI_BATCH_CONCURRENCY", &batchConcurrency); err != nil {
+		return err
+	}
+	if batchConcurrency < 1 {
+		return fmt.Errorf("WIKI_BATCH_CONCURRENCY: нужно не меньше 1, получено %d", batchConcurrency)
+	}
+	if err := envMillis("WIKI_NEGATIVE_CACHE_TTL_MS", &globalNegativeCache.ttl); err != nil {
+		return err
+	}
+	if err := envMillis("WIKI_SHUTDOWN_TIMEOUT_MS", &shutdownTimeout); err != nil {
+		return err
+	}
+	if v := os.Getenv("WIKI_LOG_LEVEL"); v != "" {
+		if err := searchLogLevel.UnmarshalText([]byte(v)); err != nil {
+			return fmt.Errorf("WIKI_LOG_LEVEL: ожидается debug, info, warn или error, получено %q", v)
+		}
+	}
+	if path := os.Getenv("WIKI_BLOCKLIST_FILE"); path != "" {
+		blocklist, err := loadBlocklist(path)
+		if err != nil {
+			return fmt.Errorf("WIKI_BLOCKLIST_FILE: %w", err)
+		}
+		defaultAPIOptions.Blocklist = blocklist
+	}
+	return nil
+}
+
+// envMillis читает длительность в миллисекундах из переменной окружения, если она задана
+func envMillis(name string, dst *time.Duration) error {
+	ms := int(*dst / time.Millisecond)
+	if err := envInt(name, &ms); err != nil {
+		return err
+	}
+	*dst = time.Duration(ms) * time.Millisecond
+	return nil
+}
+
+// loadBlocklist читает регулярки из файла: по одной на строку,
+// пустые строки и строки с # пропускаются
+func loadBlocklist(path string) ([]*regexp.Regexp, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var blocklist []*regexp.Regexp
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		re, err := regexp.Compile(line)
+		if err != nil {
+			return nil, fmt.Errorf("строка %d: %w", i+1, err)
+		}
+		blocklist = append(blocklist, re)
+	}
+	return blocklist, nil
+}
+
+// envInt читает неотрицательное число из переменной окружения, если она задана
+func envInt(name string, dst *int) error {
+	v := os.Getenv(name)
+	if v == "" {
+		return nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return fmt.Errorf("%s: ожидается неотрицательное число, получено %q", name, v)
+	}
+	*dst = n
+	return nil
+}
+
+// envBool читает флаг из переменной окружения, если она задана
+func envBool(name string, dst *bool) error {
+	v := os.Getenv(name)
+	if v == "" {
+		return nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return fmt.Errorf("%s: ожидается true/false, получено %q", name, v)
+	}
+	*dst = b
+	return nil
+}
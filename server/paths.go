@@ -0,0 +1,206 @@
+package server
+
+import (
+	"sort"
+	"strings"
+
+	"wikiracer/tokenize"
+)
+
+// Политики ранжирования путей (rank_by)
+const (
+	RankShortest   = "shortest"   // меньше статей
+	RankInterwiki  = "interwiki"  // меньше interwiki-переходов
+	RankProminence = "prominence" // больше средний размер статей
+)
+
+// maxPathsLimit - больше путей за один поиск не собираем
+const maxPathsLimit = 5
+
+// interwikiHops - сколько переходов пути меняют язык
+func interwikiHops(path []APIWikiNode) int {
+	hops := 0
+	for i := 1; i < len(path); i++ {
+		if path[i].Lang != path[i-1].Lang {
+			hops++
+		}
+	}
+	return hops
+}
+
+// prominence - средний размер статей пути в байтах; статьи без размера
+// в lengths считаются нулевыми
+func prominence(path []APIWikiNode, lengths map[string]int) float64 {
+	if len(path) == 0 {
+		return 0
+	}
+	total := 0
+	for _, n := range path {
+		total += lengths[n.Key()]
+	}
+	return float64(total) / float64(len(path))
+}
+
+// rankPaths сортирует пути по политике by, лучший первым. При равенстве
+// короче путь, затем меньше interwiki; равные пути сохраняют порядок.
+// lengths нужен только для RankProminence.
+func rankPaths(paths [][]APIWikiNode, by string, lengths map[string]int) {
+	sort.SliceStable(paths, func(i, j int) bool {
+		a, b := paths[i], paths[j]
+		switch by {
+		case RankInterwiki:
+			if ha, hb := interwikiHops(a), interwikiHops(b); ha != hb {
+				return ha < hb
+			}
+		case RankProminence:
+			if pa, pb := prominence(a, lengths), prominence(b, lengths); pa != pb {
+				return pa > pb
+			}
+		}
+		if len(a) != len(b) {
+			return len(a) < len(b)
+		}
+		return interwikiHops(a) < interwikiHops(b)
+	})
+}
+
+// buildPath собирает путь от start через meet к end. Via каждого узла
+// отмечает, из какой половины поиска пришло ребро к нему.
+func (s *APISearcher) buildPath(meet APIWikiNode) []APIWikiNode {
+	var fwd []APIWikiNode
+	curr := meet
+	for {
+		curr.Via = "F"
+		if cat, ok := s.bridges.Load(curr.Key()); ok {
+			curr.Via, curr.Bridge = "C", cat.(string)
+		}
+		fwd = append([]APIWikiNode{curr}, fwd...)
+		val, ok := s.visitedF.Load(curr.Key())
+		if !ok || val == nil {
+			break
+		}
+		p := val.(*APIWikiNode)
+		if p == nil {
+			break
+		}
+		curr = *p
+	}
+
+	var bwd []APIWikiNode
+	if val, ok := s.visitedB.Load(meet.Key()); ok && val.(*APIWikiNode) != nil {
+		curr = *val.(*APIWikiNode)
+		for {
+			curr.Via = "B"
+			bwd = append(bwd, curr)
+			val, ok := s.visitedB.Load(curr.Key())
+			if !ok || val == nil {
+				break
+			}
+			p := val.(*APIWikiNode)
+			if p == nil {
+				break
+			}
+			curr = *p
+		}
+	}
+
+	fwd[0].Via = ""
+	return joinPathAPI(fwd, bwd)
+}
+
+// joinPathAPI склеивает половины пути так, чтобы каждая статья была в нём
+// один раз. Параллельные fetch могут успеть записать узел в обе половины,
+// тогда путь проходит через него дважды - петля между вхождениями
+// вырезается, путь только короче. Via первого вхождения сохраняется.
+func joinPathAPI(fwd, bwd []APIWikiNode) []APIWikiNode {
+	path := make([]APIWikiNode, 0, len(fwd)+len(bwd))
+	pos := make(map[string]int, len(fwd)+len(bwd))
+	for _, half := range [][]APIWikiNode{fwd, bwd} {
+		for _, n := range half {
+			if i, ok := pos[n.Key()]; ok {
+				for _, cut := range path[i+1:] {
+					delete(pos, cut.Key())
+				}
+				path = path[:i+1]
+				continue
+			}
+			pos[n.Key()] = len(path)
+			path = append(path, n)
+		}
+	}
+	return path
+}
+
+// addMeet запоминает ещё одну встречу фронтов, пока не набрано maxPaths.
+// Путь строится позже, в paths: родители уже встреченных узлов не меняются.
+func (s *APISearcher) addMeet(node APIWikiNode, parent *APIWikiNode, bridge, dir string) {
+	s.resultMu.Lock()
+	defer s.resultMu.Unlock()
+	if len(s.meets) >= s.maxPaths {
+		return
+	}
+	key := node.Key()
+	for _, m := range s.meets {
+		if m.Key() == key {
+			return
+		}
+	}
+	own := &s.visitedF
+	if dir == "B" {
+		own = &s.visitedB
+	}
+	if _, loaded := own.LoadOrStore(key, parent); !loaded {
+		s.markBridge(key, bridge, dir)
+	}
+	node.Via = dir
+	s.meets = append(s.meets, node)
+}
+
+// paths строит пути через все найденные встречи, без повторов.
+// Первый - путь из s.result.
+func (s *APISearcher) paths() [][]APIWikiNode {
+	s.resultMu.Lock()
+	defer s.resultMu.Unlock()
+	if len(s.result) == 0 {
+		return nil
+	}
+	paths := [][]APIWikiNode{s.result}
+	seen := map[string]bool{pathKey(s.result): true}
+	for _, m := range s.meets {
+		p := s.buildPath(m)
+		if k := pathKey(p); !seen[k] {
+			seen[k] = true
+			paths = append(paths, p)
+		}
+	}
+	return paths
+}
+
+func pathKey(path []APIWikiNode) string {
+	keys := make([]string, len(path))
+	for i, n := range path {
+		keys[i] = n.Key()
+	}
+	return strings.Join(keys, "|")
+}
+
+// seed задаёт концы поиска: языки и слова для эвристики и корни обоих фронтов.
+// Возвращает начальный узел.
+func (s *APISearcher) seed(startLang, startTitle, endLang, endTitle string) *APIWikiNode {
+	s.startLang = startLang
+	s.targetLang = endLang
+	s.startWords = tokenize.Set(stripNamespaceAPI(startTitle))
+	s.targetWords = tokenize.Set(stripNamespaceAPI(endTitle))
+	s.startLower = strings.ToLower(startTitle)
+	s.targetLower = strings.ToLower(endTitle)
+
+	startNode := &APIWikiNode{Title: startTitle, Lang: startLang, Priority: 0}
+	endNode := &APIWikiNode{Title: endTitle, Lang: endLang, Priority: 0}
+
+	s.startKey = startNode.Key()
+	s.endKey = endNode.Key()
+	s.visitedF.Store(s.startKey, (*APIWikiNode)(nil))
+	s.visitedB.Store(s.endKey, (*APIWikiNode)(nil))
+
+	return startNode
+}
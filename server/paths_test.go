@@ -0,0 +1,119 @@
+package server
+
+import (
+	"strings"
+	"sync"
+	"testing"
+)
+
+// en - узел английского раздела
+func en(title string) APIWikiNode { return APIWikiNode{Title: title, Lang: "en"} }
+
+// pathString записывает путь как "A F:B B:C": Via и название каждого узла
+func pathString(path []APIWikiNode) string {
+	parts := make([]string, len(path))
+	for i, n := range path {
+		parts[i] = n.Title
+		if n.Via != "" {
+			parts[i] = n.Via + ":" + n.Title
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// nodeTitles - названия узлов пути через пробел, без языков и направлений
+func nodeTitles(path []APIWikiNode) string {
+	titles := make([]string, len(path))
+	for i, n := range path {
+		titles[i] = n.Title
+	}
+	return strings.Join(titles, " ")
+}
+
+func TestJoinPathAPI(t *testing.T) {
+	via := func(dir string, titles ...string) []APIWikiNode {
+		nodes := make([]APIWikiNode, len(titles))
+		for i, title := range titles {
+			nodes[i] = en(title)
+			nodes[i].Via = dir
+		}
+		return nodes
+	}
+	tests := []struct {
+		name     string
+		fwd, bwd []APIWikiNode
+		want     string
+	}{
+		{"без повторов", via("F", "A", "B", "M"), via("B", "C", "T"), "F:A F:B F:M B:C B:T"},
+		{"пустая обратная половина", via("F", "A", "T"), nil, "F:A F:T"},
+		{"встреча в обеих половинах", via("F", "A", "M"), via("B", "M", "T"), "F:A F:M B:T"},
+		{"узел в обеих половинах", via("F", "A", "X", "M"), via("B", "X", "T"), "F:A F:X B:T"},
+		{"петля внутри прямой половины", via("F", "A", "B", "C", "B", "M"), via("B", "T"), "F:A F:B F:M B:T"},
+		{"то же название в другом написании", via("F", "A", "New_york", "M"), via("B", "New york", "T"), "F:A F:New_york B:T"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := pathString(joinPathAPI(tt.fwd, tt.bwd)); got != tt.want {
+				t.Errorf("joinPathAPI = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildPath(t *testing.T) {
+	// parents: узел -> родитель, "" - корень фронта
+	type parents map[string]string
+	tests := []struct {
+		name     string
+		fwd, bwd parents
+		meet     string
+		want     string
+	}{
+		{
+			name: "встреча найдена прямым фронтом",
+			fwd:  parents{"A": "", "B": "A", "M": "B"},
+			bwd:  parents{"T": "", "C": "T", "M": "C"},
+			meet: "M",
+			want: "A F:B F:M B:C B:T",
+		},
+		{
+			name: "прямой фронт дошёл до цели",
+			fwd:  parents{"A": "", "B": "A", "T": "B"},
+			bwd:  parents{"T": ""},
+			meet: "T",
+			want: "A F:B F:T",
+		},
+		{
+			name: "обратный фронт дошёл до старта",
+			fwd:  parents{"A": ""},
+			bwd:  parents{"T": "", "C": "T", "A": "C"},
+			meet: "A",
+			want: "A B:C B:T",
+		},
+		{
+			name: "узел в обеих половинах",
+			fwd:  parents{"A": "", "X": "A", "M": "X"},
+			bwd:  parents{"T": "", "X": "T", "M": "X"},
+			meet: "M",
+			want: "A F:X B:T",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestSearcher(t, defaultAPIOptions)
+			for visited, ps := range map[*sync.Map]parents{&s.visitedF: tt.fwd, &s.visitedB: tt.bwd} {
+				for title, parent := range ps {
+					var p *APIWikiNode
+					if parent != "" {
+						n := en(parent)
+						p = &n
+					}
+					visited.Store(en(title).Key(), p)
+				}
+			}
+			if got := pathString(s.buildPath(en(tt.meet))); got != tt.want {
+				t.Errorf("buildPath = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,201 @@
+package server
+
+import (
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// requestTracer собирает RequestTrace параллельных запросов поиска.
+// Нулевой *requestTracer ничего не записывает.
+type requestTracer struct {
+	mu       sync.Mutex
+	limit    int
+	requests []RequestTrace
+	dropped  int
+}
+
+func newRequestTracer(limit int) *requestTracer {
+	return &requestTracer{limit: limit, requests: []RequestTrace{}}
+}
+
+func (t *requestTracer) add(r RequestTrace) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.requests) >= t.limit {
+		t.dropped++
+		return
+	}
+	t.requests = append(t.requests, r)
+}
+
+// info возвращает копию собранного; nil, если трассировка выключена
+func (t *requestTracer) info() *DebugInfo {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return &DebugInfo{Requests: append([]RequestTrace(nil), t.requests...), Dropped: t.dropped}
+}
+
+// Animation - журнал поиска для покадровой анимации (animate=true):
+// события в порядке времени, последнее - встреча фронтов
+type Animation struct {
+	Events []AnimationEvent `json:"events"`
+	// Truncated - журнал упёрся в WIKI_ANIMATE_EVENTS, встреча могла не попасть
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// AnimationEvent - раскрытие статьи (expand) или встреча фронтов (meet)
+type AnimationEvent struct {
+	T        float64          `json:"t" example:"182.4"` // мс от начала поиска
+	Round    int              `json:"round" example:"1"` // 0 - раскрытие концов
+	Type     string           `json:"type" example:"expand"`
+	Dir      string           `json:"dir" example:"F"`
+	Node     string           `json:"node" example:"ru:Кошка"`
+	Children []AnimationChild `json:"children,omitempty"`
+}
+
+// AnimationChild - найденный сосед с приоритетом эвристики (меньше - лучше)
+type AnimationChild struct {
+	Node  string `json:"node" example:"ru:Млекопитающие"`
+	Score int    `json:"score" example:"35"`
+}
+
+// animationChildren - сколько лучших детей на событие попадает в журнал
+const animationChildren = 20
+
+// animationRecorder собирает события анимации параллельных fetch.
+// Нулевой *animationRecorder ничего не записывает.
+type animationRecorder struct {
+	mu        sync.Mutex
+	start     time.Time
+	limit     int
+	events    []AnimationEvent
+	truncated bool
+}
+
+func newAnimationRecorder(limit int) *animationRecorder {
+	return &animationRecorder{start: time.Now(), limit: limit, events: []AnimationEvent{}}
+}
+
+// add дописывает событие; дети сортируются по приоритету и режутся
+// до animationChildren
+func (a *animationRecorder) add(e AnimationEvent, children []*APIWikiNode) {
+	if a == nil {
+		return
+	}
+	sorted := append([]*APIWikiNode(nil), children...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Priority < sorted[j].Priority })
+	if len(sorted) > animationChildren {
+		sorted = sorted[:animationChildren]
+	}
+	for _, c := range sorted {
+		e.Children = append(e.Children, AnimationChild{Node: c.String(), Score: c.Priority})
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.events) >= a.limit {
+		a.truncated = true
+		return
+	}
+	e.T = float64(time.Since(a.start).Microseconds()) / 1000
+	a.events = append(a.events, e)
+}
+
+// animation возвращает копию журнала; nil, если анимация выключена
+func (a *animationRecorder) animation() *Animation {
+	if a == nil {
+		return nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return &Animation{Events: append([]AnimationEvent(nil), a.events...), Truncated: a.truncated}
+}
+
+// Explored - дерево поиска (explored=true): для каждой посещённой статьи
+// ребро от родителя, через которого фронт до неё дошёл
+type Explored struct {
+	Edges []ExploredEdge `json:"edges"`
+	// Truncated - дерево упёрлось в WIKI_EXPLORED_NODES
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// ExploredEdge - ребро дерева поиска; для dir=B статья to ссылается на from
+type ExploredEdge struct {
+	From string `json:"from" example:"ru:Кошка"`
+	To   string `json:"to" example:"ru:Млекопитающие"`
+	Type string `json:"type" example:"link"` // link, interwiki, category
+	Dir  string `json:"dir" example:"F"`
+}
+
+// exploredRecorder собирает дерево поиска параллельных fetch.
+// Нулевой *exploredRecorder ничего не записывает.
+type exploredRecorder struct {
+	mu        sync.Mutex
+	limit     int
+	edges     []ExploredEdge
+	truncated bool
+}
+
+func newExploredRecorder(limit int) *exploredRecorder {
+	return &exploredRecorder{limit: limit, edges: []ExploredEdge{}}
+}
+
+// add записывает, что фронт dir впервые дошёл до child из parent
+func (x *exploredRecorder) add(dir string, parent, child *APIWikiNode, bridge string) {
+	if x == nil {
+		return
+	}
+	e := ExploredEdge{From: parent.String(), To: child.String(), Type: "link", Dir: dir}
+	switch {
+	case bridge != "":
+		e.Type = "category"
+	case parent.Lang != child.Lang:
+		e.Type = "interwiki"
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if len(x.edges) >= x.limit {
+		x.truncated = true
+		return
+	}
+	x.edges = append(x.edges, e)
+}
+
+// explored возвращает копию дерева; nil, если запись выключена
+func (x *exploredRecorder) explored() *Explored {
+	if x == nil {
+		return nil
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	return &Explored{Edges: append([]ExploredEdge(nil), x.edges...), Truncated: x.truncated}
+}
+
+// tracedBody дописывает RequestTrace при закрытии тела: к этому моменту
+// известны размер ответа и полное время запроса
+type tracedBody struct {
+	io.ReadCloser
+	trace  RequestTrace
+	start  time.Time
+	tracer *requestTracer
+}
+
+func (b *tracedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.trace.Bytes += int64(n)
+	return n, err
+}
+
+func (b *tracedBody) Close() error {
+	b.trace.LatencyMs = float64(time.Since(b.start).Microseconds()) / 1000
+	b.tracer.add(b.trace)
+	return b.ReadCloser.Close()
+}
@@ -0,0 +1,377 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"wikiracer/fixture"
+	"wikiracer/metrics"
+	"wikiracer/render"
+	"wikiracer/store"
+	"wikiracer/verify"
+)
+
+// responseGraph переводит ответ в граф: статьи и переходы пути, при
+// explored=true - дерево поиска, при capture=true - все рёбра снимка
+func responseGraph(resp SearchResponse) render.Graph {
+	g := render.Graph{From: resp.From, To: resp.To}
+	nodes := make(map[string]int)
+	addNode := func(lang, title string, onPath bool) string {
+		id := lang + ":" + title
+		if i, ok := nodes[id]; ok {
+			g.Nodes[i].Path = g.Nodes[i].Path || onPath
+			return id
+		}
+		nodes[id] = len(g.Nodes)
+		g.Nodes = append(g.Nodes, render.GraphNode{ID: id, Title: title, Lang: lang, Path: onPath})
+		return id
+	}
+
+	edges := make(map[string]bool)
+	addEdge := func(e render.GraphEdge) {
+		key := e.From + "\x00" + e.To
+		if edges[key] {
+			return
+		}
+		edges[key] = true
+		g.Edges = append(g.Edges, e)
+	}
+
+	for i, step := range resp.Path {
+		addNode(step.Lang, step.Title, true)
+		if i == 0 {
+			continue
+		}
+		prev := resp.Path[i-1]
+		t := resp.Transitions[i-1]
+		addEdge(render.GraphEdge{
+			From:      prev.Lang + ":" + prev.Title,
+			To:        step.Lang + ":" + step.Title,
+			Type:      t.Type,
+			Direction: t.Direction,
+			Path:      true,
+		})
+	}
+
+	if resp.Explored != nil {
+		// "lang:title" - язык до первого двоеточия, в названии они бывают
+		nodeByID := func(id string) string {
+			lang, title, _ := strings.Cut(id, ":")
+			return addNode(lang, title, false)
+		}
+		for _, e := range resp.Explored.Edges {
+			parent := nodeByID(e.From)
+			child := nodeByID(e.To)
+			if e.Dir == "B" {
+				addEdge(render.GraphEdge{From: child, To: parent, Type: e.Type, Direction: "backward"})
+			} else {
+				addEdge(render.GraphEdge{From: parent, To: child, Type: e.Type, Direction: "forward"})
+			}
+		}
+	}
+
+	if resp.Capture == nil {
+		return g
+	}
+	for _, e := range resp.Capture.Entries {
+		page := addNode(e.Lang, e.Title, false)
+		for _, title := range e.Links {
+			link := addNode(e.Lang, title, false)
+			if e.Dir == "F" {
+				addEdge(render.GraphEdge{From: page, To: link, Type: "link", Direction: "forward"})
+			} else {
+				addEdge(render.GraphEdge{From: link, To: page, Type: "link", Direction: "backward"})
+			}
+		}
+		for _, ll := range e.LangLinks {
+			other := addNode(ll.Lang, ll.Title, false)
+			addEdge(render.GraphEdge{From: page, To: other, Type: "interwiki"})
+		}
+	}
+	return g
+}
+
+// markdownTrail - путь ответа строкой markdown. У частичного пути (206)
+// между догадками от обоих концов стоит многоточие - разрыв.
+func markdownTrail(resp SearchResponse, locale string) string {
+	toSteps := func(path []PathStep) []render.Step {
+		steps := make([]render.Step, len(path))
+		for i, step := range path {
+			steps[i] = render.Step{Title: step.Title, Lang: step.Lang}
+		}
+		return steps
+	}
+	types := make([]string, len(resp.Transitions))
+	for i, t := range resp.Transitions {
+		types[i] = t.Type
+	}
+
+	trail := render.Markdown(toSteps(resp.Path), types, locale)
+	if resp.Gap != nil && len(resp.Tail) > 0 {
+		trail += " → … → " + render.Markdown(toSteps(resp.Tail), nil, locale)
+	}
+	return trail
+}
+
+// response собирает JSON-ответ по найденному пути
+func (s *APISearcher) response(req SearchRequest, path []APIWikiNode, duration time.Duration) SearchResponse {
+	var wikidata map[string]map[string]string
+	if req.Wikidata {
+		wikidata = s.pageProps(path, "wikibase_item")
+	}
+
+	pathSteps := stepsOf(path, wikidata)
+	meet := meetNode(path)
+
+	transitions := make([]Transition, 0, len(path)-1)
+	for i := 0; i < len(path)-1; i++ {
+		from := path[i]
+		to := path[i+1]
+
+		t := Transition{
+			From:      from.Title,
+			To:        to.Title,
+			Direction: "forward",
+			CheckURL:  buildWikiURL(from.Lang, from.Title),
+		}
+		// Ребро из backward-поиска (linkshere): ссылка стоит в следующей
+		// статье на предыдущую, а не наоборот
+		if to.Via == "B" {
+			t.Direction = "backward"
+		}
+
+		switch {
+		case to.Via == "C":
+			t.Type = "category"
+			t.Description = fmt.Sprintf("Обе статьи в '%s' - прямой ссылки нет, одним кликом не пройти", to.Bridge)
+			t.CheckURL = buildWikiURL(to.Lang, to.Bridge)
+		case from.Lang != to.Lang:
+			t.Type = "interwiki"
+			t.Description = fmt.Sprintf("Перейти на %s версию через меню Languages", to.Lang)
+			if through := s.fallbackThrough(from, to); through != "" && to.Via != "B" {
+				t.Description = fmt.Sprintf("Прямого interwiki нет: через меню Languages перейти на %s, оттуда - на %s версию", through, to.Lang)
+			}
+		default:
+			t.Type = "link"
+			setLinkDirection(&t, from, to, t.Direction == "backward")
+		}
+
+		transitions = append(transitions, t)
+	}
+
+	nodes := verifyPath(path)
+	if req.Verify {
+		// Проверка решает направление: если в статье from нет ссылки
+		// на to, поиск прошёл по обратной ссылке (linkshere) - и наоборот
+		ctx, cancel := s.postSearchContext()
+		checked := verify.Links(ctx, apiQuerier{s}, nodes)
+		cancel()
+		for i, ok := range checked {
+			ok := ok
+			transitions[i].Verified = &ok
+			setLinkDirection(&transitions[i], path[i], path[i+1], !ok)
+		}
+	}
+
+	var proseOnly *bool
+	if req.Prose {
+		all := true
+		ctx, cancel := s.postSearchContext()
+		prose := verify.Prose(ctx, apiQuerier{s}, nodes)
+		cancel()
+		for i, ok := range prose {
+			ok := ok
+			transitions[i].Prose = &ok
+			all = all && ok
+		}
+		proseOnly = &all
+	}
+
+	if req.WithContext {
+		ctx, cancel := s.postSearchContext()
+		contexts := verify.Contexts(ctx, apiQuerier{s}, nodes)
+		cancel()
+		for i, lc := range contexts {
+			lc := lc
+			transitions[i].Context = &lc
+		}
+	}
+
+	var meetTransition *MeetTransition
+	idx := s.meetTransitionIndex(path)
+	if s.meetIdx != nil {
+		idx = *s.meetIdx
+	}
+	if idx >= 0 && idx < len(transitions) {
+		t := transitions[idx]
+		meetTransition = &MeetTransition{Index: idx, Type: t.Type, Direction: t.Direction}
+		if req.VerifyMeet {
+			meetTransition.Verified = s.verifyEdge(path[idx], path[idx+1])
+		}
+	}
+
+	var connection *verify.Connection
+	if req.Summary {
+		// Связь объясняет узел встречи, если она не на конце пути
+		var via verify.Node
+		if key := s.meet.Key(); s.meet.Title != "" && key != s.startKey && key != s.endKey {
+			via = verifyNode(s.meet)
+		}
+		ctx, cancel := s.postSearchContext()
+		connection = verify.Summarize(ctx, apiQuerier{s}, nodes, via)
+		cancel()
+	}
+
+	var capture *fixture.Capture
+	if req.Capture {
+		snapshot := s.capture.Capture()
+		capture = &snapshot
+	}
+
+	return SearchResponse{
+		Success:     true,
+		From:        req.From,
+		To:          req.To,
+		PathLength:  len(path),
+		Direct:      s.direct,
+		Path:        pathSteps,
+		Transitions: transitions,
+		Stats:       s.stats(duration),
+		Capture:     capture,
+		Connection:  connection,
+		Difficulty:  difficulty(len(path), s.reqCount.Load(), s.rounds, s.peakFrontier),
+		ProseOnly:   proseOnly,
+		Complete:    true,
+
+		MeetTransition: meetTransition,
+		ForwardHops:    meet,
+		BackwardHops:   len(path) - 1 - meet,
+	}
+}
+
+// meetNode возвращает индекс статьи, где встретились фронты. buildPath
+// помечает узлы backward-половины Via "B", так что встреча - последний узел
+// перед ней; без backward-половины (цель найдена forward-поиском) - конец пути.
+func meetNode(path []APIWikiNode) int {
+	for i := 1; i < len(path); i++ {
+		if path[i].Via == "B" {
+			return i - 1
+		}
+	}
+	return len(path) - 1
+}
+
+// setLinkDirection заполняет направление, описание и ссылку для проверки
+// перехода-ссылки: backward - ссылка стоит в статье to на from
+func setLinkDirection(t *Transition, from, to APIWikiNode, backward bool) {
+	if backward {
+		t.Direction = "backward"
+		t.Description = fmt.Sprintf("Найти '%s' в статье '%s' (обратная ссылка)", from.Title, to.Title)
+		t.CheckURL = buildWikiURL(to.Lang, to.Title)
+		return
+	}
+	t.Direction = "forward"
+	t.Description = fmt.Sprintf("Найти '%s' в статье '%s'", to.Title, from.Title)
+	t.CheckURL = buildWikiURL(from.Lang, from.Title)
+}
+
+// stepsOf переводит путь в шаги ответа; wikidata - результат pageProps или nil
+func stepsOf(path []APIWikiNode, wikidata map[string]map[string]string) []PathStep {
+	steps := make([]PathStep, len(path))
+	meet := meetNode(path)
+	for i, node := range path {
+		steps[i] = PathStep{
+			Step:      i + 1,
+			Title:     node.Title,
+			Lang:      node.Lang,
+			URL:       buildWikiURL(node.Lang, node.Title),
+			FullName:  node.String(),
+			Direction: "forward",
+		}
+		switch {
+		case i == meet:
+			steps[i].Direction = "meet"
+		case i > meet:
+			steps[i].Direction = "backward"
+		}
+		if id, ok := wikidata[node.Key()]["wikibase_item"]; ok {
+			steps[i].WikidataID = &id
+		}
+	}
+	return steps
+}
+
+// stats собирает статистику завершённого поиска
+func (s *APISearcher) stats(duration time.Duration) SearchStats {
+	blocked, blockedBy := s.blockedStats()
+	return SearchStats{
+		Duration:             duration.String(),
+		DurationMs:           float64(duration.Milliseconds()) + float64(duration.Microseconds()%1000)/1000,
+		RequestCount:         s.reqCount.Load(),
+		BlockedNodes:         blocked,
+		BlockedBy:            blockedBy,
+		FailedFetches:        s.failedFetches.Load(),
+		Degraded:             s.failedFetches.Load() > int64(s.opts.DegradedThreshold),
+		Rounds:               s.rounds,
+		PeakFrontier:         s.peakFrontier,
+		LargestResponseBytes: s.largestResponse.Load(),
+		InterwikiRejected:    s.interwikiRejected.Load(),
+		InterwikiDropped:     s.interwikiDropped.Load(),
+		InterwikiFallback:    s.interwikiFallback.Load(),
+		CacheHits:            s.cacheHits.Load(),
+		CacheMisses:          s.cacheMisses.Load(),
+		DepthPruned:          s.depthPruned.Load(),
+		Reprioritized:        s.reprioritized.Load(),
+		LimiterWaitMs:        float64(s.limiterWait.Load()/1000) / 1000,
+		NodesVisitedForward:  syncMapLen(&s.visitedF),
+		NodesVisitedBackward: syncMapLen(&s.visitedB),
+	}
+}
+
+// persist отдаёт завершённый поиск в globalSink и метрики; запись не
+// задерживает ответ
+func (s *APISearcher) persist(req SearchRequest, path []APIWikiNode, duration time.Duration, outcome string) {
+	metrics.ObserveSearch(outcome, duration)
+	if _, off := globalSink.(store.Nop); off {
+		return
+	}
+	stats, _ := json.Marshal(s.stats(duration))
+	r := store.Record{
+		From:     req.From,
+		To:       req.To,
+		Lang:     req.Lang,
+		FromLang: s.startLang,
+		ToLang:   s.targetLang,
+		Path:     make([]string, len(path)),
+		Stats:    stats,
+		Outcome:  outcome,
+	}
+	for i, node := range path {
+		r.Path[i] = node.String()
+	}
+	globalSink.Save(r)
+}
+
+// difficulty оценивает сложность пары по статистике поиска, от 1 до 10.
+// Формула - часть контракта API: чтобы оценки были сравнимы между
+// версиями, её нельзя менять без смены имени поля.
+//
+// Каждый компонент приводится к [0, 1]:
+//   - длина пути: (len-2)/5 - прямая ссылка 0, 7 статей и длиннее 1;
+//   - запросы: log2(1+n)/log2(201) - 200 запросов и больше 1;
+//   - раунды: rounds/10;
+//   - пик очередей: log2(1+n)/log2(20001) - 20000 узлов и больше 1.
+//
+// Взвешенная сумма 0.35, 0.30, 0.15 и 0.20 переводится в 1 + round(9*sum).
+func difficulty(pathLen int, requests int64, rounds, peakFrontier int) int {
+	clamp := func(x float64) float64 { return math.Max(0, math.Min(1, x)) }
+	length := clamp(float64(pathLen-2) / 5)
+	reqs := clamp(math.Log2(1+float64(requests)) / math.Log2(201))
+	rnds := clamp(float64(rounds) / 10)
+	peak := clamp(math.Log2(1+float64(peakFrontier)) / math.Log2(20001))
+	sum := 0.35*length + 0.30*reqs + 0.15*rnds + 0.20*peak
+	return 1 + int(math.Round(9*sum))
+}
@@ -0,0 +1,509 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"golang.org/x/sync/singleflight"
+
+	"wikiracer/fixture"
+	"wikiracer/metrics"
+	"wikiracer/render"
+	"wikiracer/store"
+)
+
+func buildWikiURL(lang, title string) string {
+	return render.WikiURL(lang, title)
+}
+
+// Форматы ответа поиска
+const (
+	FormatJSON = "json"
+	FormatText = "text" // нумерованный рецепт text/plain, как в CLI
+	FormatXML  = "xml"  // граф пути (и снимка при capture=true) в XML
+	FormatDOT  = "dot"  // тот же граф для Graphviz
+
+	FormatMarkdown = "markdown" // путь одной строкой ссылок text/markdown
+)
+
+// runSearch выполняет поиск по уже проверенному запросу и отдаёт ответ
+// в запрошенном формате. Общая часть GET и POST обработчиков.
+func runSearch(c *fiber.Ctx, req SearchRequest) error {
+	if resp := validateSearch(&req); resp != nil {
+		return c.Status(400).JSON(resp)
+	}
+
+	ctx, cancel := requestContext(c)
+	defer cancel()
+	r := execSearch(ctx, req)
+	if r.err != nil {
+		return c.Status(r.status).JSON(r.err)
+	}
+
+	if req.Format == FormatText {
+		steps := make([]render.Step, len(r.path))
+		for i, node := range r.path {
+			steps[i] = render.Step{Title: node.Title, Lang: node.Lang}
+		}
+		c.Set(fiber.HeaderContentType, fiber.MIMETextPlainCharsetUTF8)
+		return c.Status(r.status).SendString(render.Recipe(steps, req.Lang))
+	}
+
+	if req.Format == FormatXML {
+		out, err := render.XML(responseGraph(*r.resp))
+		if err != nil {
+			return c.Status(500).JSON(ErrorResponse{
+				Success: false,
+				Error:   "Не удалось сформировать XML",
+				Code:    "INTERNAL_ERROR",
+			})
+		}
+		c.Set(fiber.HeaderContentType, fiber.MIMEApplicationXMLCharsetUTF8)
+		return c.Status(r.status).Send(out)
+	}
+	if req.Format == FormatDOT {
+		c.Set(fiber.HeaderContentType, "text/vnd.graphviz; charset=utf-8")
+		return c.Status(r.status).Send(render.DOT(responseGraph(*r.resp)))
+	}
+	if req.Format == FormatMarkdown {
+		c.Set(fiber.HeaderContentType, "text/markdown; charset=utf-8")
+		return c.Status(r.status).SendString(markdownTrail(*r.resp, req.Lang) + "\n")
+	}
+	return c.Status(r.status).JSON(r.resp)
+}
+
+// validateSearch подставляет значения по умолчанию и проверяет параметры
+// запроса; не nil - ответ 400
+func validateSearch(req *SearchRequest) *ErrorResponse {
+	if req.Lang == "" {
+		req.Lang = defaultLang
+	}
+	switch req.RankBy {
+	case "":
+		req.RankBy = RankShortest
+	case RankShortest, RankInterwiki, RankProminence:
+	default:
+		return &ErrorResponse{
+			Success: false,
+			Error:   "rank_by: ожидается shortest, interwiki или prominence",
+			Code:    "INVALID_RANK_BY",
+		}
+	}
+	if req.Paths > maxPathsLimit {
+		req.Paths = maxPathsLimit
+	}
+	if !validModeAPI(req.Mode) {
+		return &ErrorResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Неизвестный mode %q, допустимо: default, monolingual", req.Mode),
+			Code:    "INVALID_MODE",
+		}
+	}
+	if _, err := compileExclude(req.Exclude); err != nil {
+		return &ErrorResponse{
+			Success: false,
+			Error:   "exclude: " + err.Error(),
+			Code:    "INVALID_EXCLUDE",
+		}
+	}
+	for _, ns := range req.Namespaces {
+		if ns < 0 {
+			return &ErrorResponse{
+				Success: false,
+				Error:   fmt.Sprintf("namespaces: неверное пространство имён %d", ns),
+				Code:    "INVALID_NAMESPACES",
+			}
+		}
+	}
+	if req.sameEdition() {
+		// Оба конца - в одном разделе: явный язык любого из них или lang
+		if req.FromLang != "" && req.ToLang != "" && req.FromLang != req.ToLang {
+			return &ErrorResponse{
+				Success: false,
+				Error:   "cross_lang=false: from_lang и to_lang должны совпадать",
+				Code:    "CROSS_LANG_CONFLICT",
+			}
+		}
+		edition := req.Lang
+		if req.FromLang != "" {
+			edition = req.FromLang
+		} else if req.ToLang != "" {
+			edition = req.ToLang
+		}
+		req.FromLang, req.ToLang = edition, edition
+	}
+	for _, lang := range []string{req.Lang, req.FromLang, req.ToLang} {
+		if _, ok := apiWikis[lang]; lang != "" && !ok {
+			return &ErrorResponse{
+				Success: false,
+				Error:   fmt.Sprintf("Неизвестный язык %q", lang),
+				Code:    "UNKNOWN_LANG",
+			}
+		}
+	}
+	return nil
+}
+
+// forbiddenKey - Key() узла из элемента forbidden: "lang:Название" для
+// известного раздела lang, иначе название в разделе lang поиска. Двоеточие
+// с другим префиксом - часть названия ("Звёздные войны: Эпизод 4").
+func forbiddenKey(ref, lang string) string {
+	if l, title, ok := strings.Cut(ref, ":"); ok {
+		if _, known := apiWikis[l]; known {
+			return APIWikiNode{Title: title, Lang: l}.Key()
+		}
+	}
+	return APIWikiNode{Title: ref, Lang: lang}.Key()
+}
+
+// maxExcludePatterns - больше регулярок exclude в одном запросе не принимаем:
+// каждую проверяет каждый кандидат
+const maxExcludePatterns = 50
+
+// compileExclude компилирует регулярки exclude запроса; пустые пропускаются
+func compileExclude(patterns []string) ([]*regexp.Regexp, error) {
+	if len(patterns) > maxExcludePatterns {
+		return nil, fmt.Errorf("не больше %d регулярок, получено %d", maxExcludePatterns, len(patterns))
+	}
+	var res []*regexp.Regexp
+	for _, p := range patterns {
+		if p = strings.TrimSpace(p); p == "" {
+			continue
+		}
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("%q: %w", p, err)
+		}
+		res = append(res, re)
+	}
+	return res, nil
+}
+
+// searchResult - итог поиска до выбора формата ответа: либо resp
+// (для format=text - только path), либо err
+type searchResult struct {
+	status int
+	path   []APIWikiNode
+	resp   *SearchResponse
+	err    *ErrorResponse
+}
+
+// searchGroup совмещает одинаковые одновременные поиски: популярную пару
+// от нескольких клиентов ищем один раз, результат получают все
+var searchGroup singleflight.Group
+
+// searchShare - контекст общего поиска searchGroup и сколько запросов его
+// ждут: один ушедший клиент не должен отменять поиск остальным
+type searchShare struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	waiters int
+}
+
+var (
+	sharesMu sync.Mutex
+	shares   = make(map[string]*searchShare)
+)
+
+// joinSearch подключает запрос с контекстом ctx к общему поиску key и
+// возвращает контекст поиска. Запрос уходит из поиска при отмене ctx или
+// вызове leave (обязателен); ушли все - поиск отменяется, а следующий
+// запрос той же пары начнёт новый, а не получит отменённый.
+func joinSearch(ctx context.Context, key string) (context.Context, func()) {
+	sharesMu.Lock()
+	sh := shares[key]
+	if sh == nil {
+		// Логи общего поиска идут с ID запроса, который его начал
+		shared, cancel := context.WithCancel(withRequestID(serverCtx, requestIDFrom(ctx)))
+		sh = &searchShare{ctx: shared, cancel: cancel}
+		shares[key] = sh
+	}
+	sh.waiters++
+	sharesMu.Unlock()
+
+	var once sync.Once
+	left := make(chan struct{})
+	leave := func() {
+		once.Do(func() {
+			close(left)
+			sharesMu.Lock()
+			defer sharesMu.Unlock()
+			if sh.waiters--; sh.waiters > 0 {
+				return
+			}
+			sh.cancel()
+			if shares[key] == sh {
+				delete(shares, key)
+				searchGroup.Forget(key)
+			}
+		})
+	}
+	go func() {
+		select {
+		case <-ctx.Done():
+			leave()
+		case <-left:
+		}
+	}()
+	return sh.ctx, leave
+}
+
+// searchKey - ключ searchGroup: концы нормализуются как в APIWikiNode.Key,
+// остальные параметры запроса входят в ключ как есть - поиски с разными
+// опциями не совмещаются
+func searchKey(req SearchRequest) string {
+	from := APIWikiNode{Title: normalizeTitleAPI(req.From), Lang: req.Lang}.Key()
+	to := APIWikiNode{Title: normalizeTitleAPI(req.To), Lang: req.Lang}.Key()
+	rest := req
+	rest.From, rest.To, rest.Lang = "", "", ""
+	opts, _ := json.Marshal(rest)
+	return from + "|" + to + "|" + string(opts)
+}
+
+// negativeCache помнит неудачные поиски (404 и 408): клиенты повторяют
+// недостижимые пары и каждый раз ждут весь таймаут. Запись хранит
+// бюджет, с которым поиск не удался, - запрос с бюджетом больше идёт
+// в обход кеша, а его успех запись удаляет.
+type negativeCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration // 0 - кеш выключен
+	entries map[string]negativeEntry
+}
+
+type negativeEntry struct {
+	result  searchResult
+	timeout time.Duration
+	expires time.Time
+}
+
+// negativeCacheMax - предел записей; заполненный кеш сначала чистит
+// устаревшие, а если и это не помогло - новые неудачи не запоминает
+const negativeCacheMax = 10000
+
+// globalNegativeCache - кеш неудач; WIKI_NEGATIVE_CACHE_TTL_MS - срок записи
+var globalNegativeCache = &negativeCache{ttl: 5 * time.Minute, entries: make(map[string]negativeEntry)}
+
+// get возвращает неудачу, если она не устарела и получена с бюджетом
+// не меньше timeout
+func (n *negativeCache) get(key string, timeout time.Duration) (searchResult, bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	e, ok := n.entries[key]
+	if !ok || n.ttl <= 0 || timeout > e.timeout {
+		return searchResult{}, false
+	}
+	if time.Now().After(e.expires) {
+		delete(n.entries, key)
+		return searchResult{}, false
+	}
+	resp := *e.result.err
+	resp.Cached = true
+	return searchResult{status: e.result.status, err: &resp}, true
+}
+
+// put запоминает неудачу поиска с бюджетом timeout
+func (n *negativeCache) put(key string, timeout time.Duration, r searchResult) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.ttl <= 0 {
+		return
+	}
+	if _, ok := n.entries[key]; !ok && len(n.entries) >= negativeCacheMax {
+		now := time.Now()
+		for k, e := range n.entries {
+			if now.After(e.expires) {
+				delete(n.entries, k)
+			}
+		}
+		if len(n.entries) >= negativeCacheMax {
+			return
+		}
+	}
+	n.entries[key] = negativeEntry{result: r, timeout: timeout, expires: time.Now().Add(n.ttl)}
+}
+
+// forget удаляет неудачу: пара всё-таки достижима
+func (n *negativeCache) forget(key string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	delete(n.entries, key)
+}
+
+// negativeKey - ключ кеша неудач: searchKey без бюджета, он хранится в записи
+func negativeKey(req SearchRequest) string {
+	req.TimeoutMs = 0
+	return searchKey(req)
+}
+
+// searchTimeout - бюджет, с которым пойдёт поиск запроса
+func searchTimeout(req SearchRequest) time.Duration {
+	if t := withTimeout(defaultAPIOptions, req.TimeoutMs).Timeout; t > 0 {
+		return t
+	}
+	return defaultSearchTimeout
+}
+
+// execSearch ищет путь по проверенному validateSearch запросу и
+// сохраняет поиск в store. Одинаковые одновременные запросы делят
+// один поиск (searchGroup), недавние неудачи отдаются из globalNegativeCache.
+// Отмена ctx (клиент ушёл) прерывает поиск, если его не ждут другие.
+func execSearch(ctx context.Context, req SearchRequest) searchResult {
+	key, timeout := negativeKey(req), searchTimeout(req)
+	if r, ok := globalNegativeCache.get(key, timeout); ok {
+		return r
+	}
+
+	group := searchKey(req)
+	shared, leave := joinSearch(ctx, group)
+	defer leave()
+	v, _, _ := searchGroup.Do(group, func() (interface{}, error) {
+		return searchOnce(shared, req), nil
+	})
+	r := v.(searchResult)
+	switch {
+	case r.status == fiber.StatusOK:
+		globalNegativeCache.forget(key)
+	case r.status == fiber.StatusNotFound || r.status == fiber.StatusRequestTimeout:
+		// Сбои Wikipedia (502) и отмена (503) - не свойство пары
+		globalNegativeCache.put(key, timeout, r)
+	}
+	return r
+}
+
+// searchOnce - сам поиск execSearch
+func searchOnce(ctx context.Context, req SearchRequest) searchResult {
+	metrics.InFlight.Inc()
+	defer metrics.InFlight.Dec()
+	t0 := time.Now()
+	opts := withCrossLang(withTimeout(defaultAPIOptions, req.TimeoutMs), req)
+	if req.Categories {
+		opts.CategoryBridges = true
+	}
+	if req.MaxDepth > 0 {
+		opts.MaxDepth = req.MaxDepth
+	}
+	opts.Shortest = req.Shortest
+	if exclude, _ := compileExclude(req.Exclude); len(exclude) > 0 {
+		// Копия: Blocklist из настроек общий для всех поисков
+		opts.Blocklist = append(opts.Blocklist[:len(opts.Blocklist):len(opts.Blocklist)], exclude...)
+	}
+	if req.ExcludeDisambig {
+		opts.DisambigStrategy = DisambigSkip
+	}
+	if len(req.Namespaces) > 0 {
+		opts.Namespaces = req.Namespaces
+	}
+	s := NewAPISearcher(ctx, req.Lang, req.From, req.Lang, req.To, opts)
+	defer s.cancel()
+	s.setMode(req.Mode)
+	if req.Capture {
+		s.capture = fixture.NewRecorder(captureLimit)
+	}
+	if len(req.Forbidden) > 0 {
+		s.forbidden = make(map[string]bool, len(req.Forbidden))
+		for _, ref := range req.Forbidden {
+			s.forbidden[forbiddenKey(ref, req.Lang)] = true
+		}
+	}
+	s.maxPaths = req.Paths
+	s.maxLangs = req.MaxLanguages
+	s.fromLang, s.toLang = req.FromLang, req.ToLang
+	if req.Debug {
+		s.trace = newRequestTracer(opts.DebugRequests)
+	}
+	if req.Animate {
+		s.animate = newAnimationRecorder(opts.AnimateEvents)
+	}
+	if req.Explored {
+		s.explored = newExploredRecorder(opts.ExploredNodes)
+	}
+	path, err := s.Search(req.From, req.To, req.Lang)
+	duration := time.Since(t0)
+
+	// Несколько путей - лучший по rank_by становится основным
+	var paths [][]APIWikiNode
+	if req.Paths > 1 {
+		paths = s.paths()
+		var lengths map[string]int
+		if req.RankBy == RankProminence && len(paths) > 1 {
+			var nodes []APIWikiNode
+			for _, p := range paths {
+				nodes = append(nodes, p...)
+			}
+			lengths = s.pageLengths(nodes)
+		}
+		rankPaths(paths, req.RankBy, lengths)
+		if len(paths) > 0 {
+			path = paths[0]
+			s.direct = len(path) == 2
+		}
+	}
+
+	// Бюджет или время исчерпаны, но фронты продвинулись - 206 с
+	// цепочками-догадками, а не 404 или 408
+	status, outcome := fiber.StatusOK, store.OutcomeFound
+	if len(path) == 0 && len(s.partial) > 0 && (len(s.partial) > 1 || len(s.partialTail) > 1) {
+		path = s.partial
+		status, outcome = fiber.StatusPartialContent, store.OutcomePartial
+	}
+
+	if len(path) == 0 {
+		code, resp, outcome := s.failure(err)
+		resp.Debug = s.trace.info()
+		s.persist(req, path, duration, outcome)
+		return searchResult{status: code, err: &resp}
+	}
+	if req.Canonical {
+		// Встреча ищется по ключам узлов - до того, как названия поменяются
+		idx := s.meetTransitionIndex(path)
+		s.meetIdx = &idx
+		path = s.canonicalize(path)
+	}
+	s.persist(req, path, duration, outcome)
+
+	// Рецепту хватает пути - остальной ответ (wikidata, summary...) не собираем
+	if req.Format == FormatText {
+		return searchResult{status: status, path: path}
+	}
+
+	resp := s.response(req, path, duration)
+	resp.Debug = s.trace.info()
+	resp.Warnings = s.warnings
+	resp.Animation = s.animate.animation()
+	resp.Explored = s.explored.explored()
+	if len(paths) > 0 {
+		resp.Paths = make([][]PathStep, len(paths))
+		for i, p := range paths {
+			resp.Paths[i] = stepsOf(p, nil)
+		}
+		resp.Paths[0] = resp.Path
+	}
+	if status == fiber.StatusPartialContent {
+		resp.Partial = true
+		resp.Complete = false
+		resp.Success = false
+		// Частичный путь целиком из forward-фронта, встречи не было
+		for i := range resp.Path {
+			resp.Path[i].Direction = "forward"
+		}
+		last := path[len(path)-1]
+		gap := &PathGap{From: last.Title, FromLang: last.Lang, To: req.To, ToLang: s.targetLang}
+		if len(s.partialTail) > 0 {
+			gap.To, gap.ToLang = s.partialTail[0].Title, s.partialTail[0].Lang
+		}
+		if len(s.partialTail) > 1 {
+			resp.Tail = stepsOf(s.partialTail, nil)
+			for i := range resp.Tail {
+				resp.Tail[i].Direction = "backward"
+			}
+		}
+		resp.Gap = gap
+	}
+	return searchResult{status: status, path: path, resp: &resp}
+}
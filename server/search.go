@@ -0,0 +1,583 @@
+package server
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"wikiracer/store"
+)
+
+// Ошибки Search: пустой путь всегда объясняется одной из них
+var (
+	ErrStartMissing = errors.New("начальной статьи нет")
+	ErrEndMissing   = errors.New("конечной статьи нет")
+	ErrTimeout      = errors.New("время поиска истекло")
+	ErrCancelled    = errors.New("поиск отменён")
+	ErrShuttingDown = errors.New("сервер останавливается")
+	ErrUpstream     = errors.New("Wikipedia API недоступен")
+	ErrNoPath       = errors.New("путь не найден")
+
+	// Путь мог существовать, но только вне ограничений запроса
+	ErrDepthExceeded = fmt.Errorf("%w в пределах глубины", ErrNoPath)
+	ErrLanguageLimit = fmt.Errorf("%w в пределах языков", ErrNoPath)
+	ErrForbiddenPath = fmt.Errorf("%w в обход запрещённых статей", ErrNoPath)
+)
+
+// endError - конца пути нет: errors.Is узнаёт kind (ErrStartMissing или
+// ErrEndMissing), текст - подробности для ответа, code - код ErrorResponse
+type endError struct {
+	kind error
+	code string
+	msg  string
+}
+
+func (e *endError) Error() string { return e.msg }
+func (e *endError) Unwrap() error { return e.kind }
+
+// Search ищет путь от start к end. Пустой путь объясняет ошибка - одна
+// из Err* выше, оба отсутствующих конца - через errors.Join.
+// Бюджет MaxRequests/MaxRounds ошибкой не считается: s.exhausted и s.partial.
+func (s *APISearcher) Search(start, end, lang string) ([]APIWikiNode, error) {
+	s.log.Info("search start", "from", start, "to", end, "lang", lang, "shortest", s.opts.Shortest)
+	path := s.search(start, end, lang)
+	var err error
+	if len(path) == 0 {
+		err = s.searchErr()
+	}
+	s.logDone(path, err)
+	return path, err
+}
+
+// logDone пишет событие search done: исход и сколько поиск стоил
+func (s *APISearcher) logDone(path []APIWikiNode, err error) {
+	attrs := []interface{}{
+		"rounds", s.rounds,
+		"requests", s.reqCount.Load(),
+		"duration_ms", float64(time.Since(s.started).Microseconds()) / 1000,
+	}
+	if err != nil {
+		_, resp, outcome := s.failure(err)
+		s.log.Info("search done", append(attrs, "outcome", outcome, "code", resp.Code)...)
+		return
+	}
+	s.log.Info("search done", append(attrs, "outcome", store.OutcomeFound, "path_length", len(path)-1)...)
+}
+
+// searchErr объясняет пустой результат search по состоянию поиска
+func (s *APISearcher) searchErr() error {
+	s.missingMu.Lock()
+	missing := s.missing
+	s.missingMu.Unlock()
+
+	switch {
+	case len(missing) > 0:
+		// Концы определяются параллельно - начало всегда первым
+		sort.SliceStable(missing, func(i, j int) bool {
+			return errors.Is(missing[i], ErrStartMissing) && !errors.Is(missing[j], ErrStartMissing)
+		})
+		return errors.Join(missing...)
+	// Поиск прерван остановкой сервера - клиенту стоит повторить его
+	// на другом экземпляре
+	case serverCtx.Err() != nil:
+		return ErrShuttingDown
+	// Отменённый (не истёкший) контекст - поиск прерван, а не безуспешен:
+	// встреча фронтов отменяет контекст только с путём
+	case errors.Is(s.ctx.Err(), context.Canceled):
+		return ErrCancelled
+	case errors.Is(s.ctx.Err(), context.DeadlineExceeded):
+		return ErrTimeout
+	// Не удалось раскрыть даже концы пути - проблема на стороне Wikipedia
+	case s.failedFetches.Load() > 0 && s.rounds == 0:
+		return ErrUpstream
+	case s.depthPruned.Load() > 0:
+		return ErrDepthExceeded
+	case s.langsDropped.Load() > 0:
+		return ErrLanguageLimit
+	case s.blockedBy[blockedForbidden].Load() > 0:
+		return ErrForbiddenPath
+	}
+	return ErrNoPath
+}
+
+// failure переводит ошибку Search в HTTP-статус, тело ответа и исход
+// для store; nil - как ErrNoPath
+func (s *APISearcher) failure(err error) (int, ErrorResponse, string) {
+	resp := ErrorResponse{Success: false}
+	switch {
+	case errors.Is(err, ErrStartMissing), errors.Is(err, ErrEndMissing):
+		var end *endError
+		errors.As(err, &end)
+		resp.Error, resp.Code = strings.ReplaceAll(err.Error(), "\n", "; "), end.code
+		return 404, resp, store.OutcomeNotFound
+	case errors.Is(err, ErrCancelled):
+		resp.Error, resp.Code = "Поиск отменён", "SEARCH_CANCELLED"
+		return 503, resp, store.OutcomeCancelled
+	case errors.Is(err, ErrShuttingDown):
+		resp.Error, resp.Code = "Сервер останавливается, повторите запрос", "SHUTTING_DOWN"
+		return 503, resp, store.OutcomeCancelled
+	case errors.Is(err, ErrTimeout):
+		resp.Error, resp.Code = "Время поиска истекло", "SEARCH_TIMEOUT"
+		return 408, resp, store.OutcomeTimeout
+	case errors.Is(err, ErrUpstream):
+		resp.Error, resp.Code = "Wikipedia API недоступен", "UPSTREAM_ERROR"
+		return 502, resp, store.OutcomeUpstream
+	case errors.Is(err, ErrDepthExceeded):
+		resp.Error, resp.Code = fmt.Sprintf("Путь не длиннее %d переходов не найден", s.opts.MaxDepth), "DEPTH_EXCEEDED"
+	case errors.Is(err, ErrLanguageLimit):
+		resp.Error, resp.Code = fmt.Sprintf("Путь в пределах %d языков не найден", s.maxLangs), "LANGUAGE_LIMIT_PATH_NOT_FOUND"
+	case errors.Is(err, ErrForbiddenPath):
+		resp.Error, resp.Code = "Путь в обход запрещённых статей не найден", "FORBIDDEN_PATH_NOT_FOUND"
+	default:
+		resp.Error, resp.Code = "Путь не найден", "PATH_NOT_FOUND"
+	}
+	return 404, resp, store.OutcomeNotFound
+}
+
+func (s *APISearcher) search(start, end, lang string) []APIWikiNode {
+	// Запросы, начатые до встречи, успевают вернуться до выхода (DrainTimeout)
+	defer s.drain()
+	start, end = normalizeTitleAPI(start), normalizeTitleAPI(end)
+
+	// Контекст мог закончиться ещё до старта - не шлём заведомо
+	// бесполезных запросов
+	if s.ctx.Err() != nil {
+		return nil
+	}
+
+	startLang, startTitle, endLang, endTitle := s.detectEnds(start, end, lang)
+	// Статьи нет ни в одном разделе или в явно заданном языке
+	// (LangConflictExplicit) - искать нечего
+	if len(s.missing) > 0 {
+		return nil
+	}
+
+	startNode := s.seed(startLang, startTitle, endLang, endTitle)
+
+	if startTitle == endTitle && startLang == endLang {
+		return []APIWikiNode{*startNode}
+	}
+
+	if s.ctx.Err() != nil {
+		return nil
+	}
+
+	pqF := &APIPriorityQueue{}
+	pqB := &APIPriorityQueue{}
+	heap.Init(pqF)
+	heap.Init(pqB)
+
+	// Концы загружаются параллельно, а раскрываются по очереди: start, затем end
+	var wg0 sync.WaitGroup
+	var initF, initB []*APIWikiNode
+	var order inOrder
+
+	wg0.Add(2)
+	waitF, doneF := order.next()
+	go func() {
+		defer wg0.Done()
+		defer doneF()
+		pages := s.load([]string{startTitle}, startLang, "F")
+		<-waitF
+		initF = s.process(pages, startLang, "F")
+	}()
+	waitB, doneB := order.next()
+	go func() {
+		defer wg0.Done()
+		defer doneB()
+		pages := s.load([]string{endTitle}, endLang, "B")
+		<-waitB
+		initB = s.process(pages, endLang, "B")
+	}()
+	wg0.Wait()
+
+	if s.found.Load() {
+		s.resultMu.Lock()
+		defer s.resultMu.Unlock()
+		// Встреча уже после первых двух запросов - start и end соседние
+		s.direct = len(s.result) == 2
+		return s.result
+	}
+
+	for _, n := range initF {
+		s.enqueue(pqF, n, "F")
+	}
+	for _, n := range initB {
+		s.enqueue(pqB, n, "B")
+	}
+
+	return s.expand(pqF, pqB)
+}
+
+// guessPartial запоминает лучшую догадку, когда поиск остановлен без
+// встречи: путь от start до самого перспективного узла forward-фронта и
+// от самого перспективного узла backward-фронта до end. Между ними -
+// разрыв, который остаётся пройти вручную.
+func (s *APISearcher) guessPartial(pqF, pqB *APIPriorityQueue) {
+	if pqF.Len() > 0 {
+		s.partial = s.buildPath(*(*pqF)[0])
+	}
+	if pqB.Len() > 0 {
+		// Узла нет в visitedF - buildPath даёт только backward-половину
+		s.partialTail = s.buildPath(*(*pqB)[0])
+	}
+}
+
+// shortestLayer - вес слоя в приоритете Shortest: эвристика узла
+// сжимается в [0, shortestLayer) и решает только порядок внутри слоя
+const shortestLayer = 1 << 20
+
+// shortestPriority - приоритет узла в режиме Shortest: сначала глубина,
+// потом эвристика
+func shortestPriority(depth, heuristic int) int {
+	h := heuristic + shortestLayer/2
+	if h < 0 {
+		h = 0
+	}
+	if h >= shortestLayer {
+		h = shortestLayer - 1
+	}
+	return depth*shortestLayer + h
+}
+
+// frontLayer - глубина лучшего узла очереди в режиме Shortest, -1 -
+// очередь пуста
+func frontLayer(pq *APIPriorityQueue) int {
+	if pq.Len() == 0 {
+		return -1
+	}
+	return (*pq)[0].Priority / shortestLayer
+}
+
+// shortestMeet запоминает встречу в режиме Shortest, если путь через неё
+// короче найденного раньше. Узел помечается посещённым своей стороной, как
+// обычный кандидат; true - посещён впервые и его нужно раскрыть.
+func (s *APISearcher) shortestMeet(dir string, parent, child *APIWikiNode, bridge string, depth int, langs []string) bool {
+	own := &s.visitedF
+	if dir == "B" {
+		own = &s.visitedB
+	}
+	key := child.Key()
+	_, loaded := own.LoadOrStore(key, parent)
+	if !loaded {
+		s.markBridge(key, bridge, dir)
+		s.explored.add(dir, parent, child, bridge)
+		if s.maxLangs > 0 {
+			s.langsMap(dir).Store(key, langs)
+		}
+		s.depthMap(dir).Store(key, depth)
+	}
+	s.recordShortest(*child, dir)
+	return !loaded
+}
+
+// recordShortest делает путь через узел встречи результатом, если он
+// короче найденного раньше
+func (s *APISearcher) recordShortest(node APIWikiNode, dir string) {
+	key := node.Key()
+	hops := s.nodeDepth(key, "F") + s.nodeDepth(key, "B")
+	s.resultMu.Lock()
+	defer s.resultMu.Unlock()
+	if s.shortestHops != 0 && hops >= s.shortestHops {
+		return
+	}
+	s.shortestHops = hops
+	s.result = s.buildPath(node)
+	s.meet = node
+	s.meets = []APIWikiNode{{Title: node.Title, Lang: node.Lang, Via: dir}}
+	s.animate.add(AnimationEvent{Round: s.rounds, Type: "meet", Dir: dir, Node: node.String()}, nil)
+}
+
+// shortestDone - можно ли остановить поиск Shortest: путь найден, и
+// любой ещё не найденный прошёл бы через нераскрытые слои обоих фронтов,
+// то есть был бы не короче. Пустая очередь - фронт раскрыт целиком.
+func (s *APISearcher) shortestDone(pqF, pqB *APIPriorityQueue) bool {
+	s.resultMu.Lock()
+	hops := s.shortestHops
+	s.resultMu.Unlock()
+	if hops == 0 {
+		return false
+	}
+	layerF, layerB := frontLayer(pqF), frontLayer(pqB)
+	if layerF >= 0 && layerB >= 0 && hops > layerF+layerB+1 {
+		return false
+	}
+	s.found.Store(true)
+	s.direct = hops == 1
+	return true
+}
+
+// expand - основной цикл: раунд за раундом раскрывает лучшие узлы обоих
+// фронтов, пока они не встретятся, не кончатся или не выйдет бюджет
+func (s *APISearcher) expand(pqF, pqB *APIPriorityQueue) []APIWikiNode {
+	const batchSize = 50
+	const maxPerRound = 250
+
+	// Пул на весь поиск: занятое место освобождается по завершении fetch
+	workers := make(chan struct{}, s.opts.FetchConcurrency)
+
+	for !s.found.Load() && (pqF.Len() > 0 || pqB.Len() > 0) {
+		select {
+		case <-s.ctx.Done():
+			if !s.found.Load() && errors.Is(s.ctx.Err(), context.DeadlineExceeded) {
+				s.guessPartial(pqF, pqB)
+			}
+			return s.result
+		default:
+		}
+
+		if s.opts.Shortest && s.shortestDone(pqF, pqB) {
+			break
+		}
+
+		// Бюджет исчерпан - лучшая догадка вместо пути
+		if s.budgetExhausted() {
+			s.exhausted = true
+			s.guessPartial(pqF, pqB)
+			break
+		}
+
+		s.rounds++
+		if n := pqF.Len() + pqB.Len(); n > s.peakFrontier {
+			s.peakFrontier = n
+		}
+
+		var wg sync.WaitGroup
+		var muF, muB sync.Mutex
+		var nextF, nextB []*APIWikiNode
+		// Батчи загружаются параллельно, а раскрываются в порядке запуска
+		var order inOrder
+
+		// Лучший приоритет фронта до раскрытия - опорная точка для EnqueueSlack
+		if pqF.Len() > 0 {
+			s.bestF.Store(int64((*pqF)[0].Priority))
+			s.bestFSet.Store(true)
+		}
+		if pqB.Len() > 0 {
+			s.bestB.Store(int64((*pqB)[0].Priority))
+			s.bestBSet.Store(true)
+		}
+
+		var round RoundInfo
+		budgetF, budgetB := roundBudgetsAPI(pqF.Len(), pqB.Len(), maxPerRound)
+		byLangF := make(map[string][]string)
+		count := 0
+		layerF := frontLayer(pqF)
+		for pqF.Len() > 0 && count < budgetF && (!s.opts.Shortest || frontLayer(pqF) == layerF) {
+			node := heap.Pop(pqF).(*APIWikiNode)
+			byLangF[node.Lang] = append(byLangF[node.Lang], node.Title)
+			if s.OnRound != nil {
+				round.PoppedF = append(round.PoppedF, node.String())
+			}
+			count++
+		}
+		poppedF := count
+
+		for _, lang := range sortedLangsAPI(byLangF) {
+			titles := byLangF[lang]
+			for i := 0; i < len(titles); i += batchSize {
+				end := i + batchSize
+				if end > len(titles) {
+					end = len(titles)
+				}
+				batch := titles[i:end]
+				workers <- struct{}{}
+				wg.Add(1)
+				wait, done := order.next()
+				go func(t []string, l string) {
+					defer wg.Done()
+					defer done()
+					pages := s.load(t, l, "F")
+					<-workers
+					<-wait
+					nodes := s.process(pages, l, "F")
+					if len(nodes) > 0 {
+						muF.Lock()
+						nextF = append(nextF, nodes...)
+						muF.Unlock()
+					}
+				}(batch, lang)
+			}
+		}
+
+		byLangB := make(map[string][]string)
+		count = 0
+		layerB := frontLayer(pqB)
+		for pqB.Len() > 0 && count < budgetB && (!s.opts.Shortest || frontLayer(pqB) == layerB) {
+			node := heap.Pop(pqB).(*APIWikiNode)
+			byLangB[node.Lang] = append(byLangB[node.Lang], node.Title)
+			if s.OnRound != nil {
+				round.PoppedB = append(round.PoppedB, node.String())
+			}
+			count++
+		}
+		poppedB := count
+
+		for _, lang := range sortedLangsAPI(byLangB) {
+			titles := byLangB[lang]
+			for i := 0; i < len(titles); i += batchSize {
+				end := i + batchSize
+				if end > len(titles) {
+					end = len(titles)
+				}
+				batch := titles[i:end]
+				workers <- struct{}{}
+				wg.Add(1)
+				wait, done := order.next()
+				go func(t []string, l string) {
+					defer wg.Done()
+					defer done()
+					pages := s.load(t, l, "B")
+					<-workers
+					<-wait
+					nodes := s.process(pages, l, "B")
+					if len(nodes) > 0 {
+						muB.Lock()
+						nextB = append(nextB, nodes...)
+						muB.Unlock()
+					}
+				}(batch, lang)
+			}
+		}
+
+		wg.Wait()
+		s.log.Info("search round",
+			"round", s.rounds,
+			"popped_f", poppedF, "popped_b", poppedB,
+			"next_f", len(nextF), "next_b", len(nextB),
+			"requests", s.reqCount.Load(),
+			"met", s.found.Load())
+
+		if s.OnRound != nil {
+			round.Round = s.rounds
+			round.VisitedF = syncMapLen(&s.visitedF)
+			round.VisitedB = syncMapLen(&s.visitedB)
+			if s.found.Load() {
+				s.resultMu.Lock()
+				meet := s.meet.String()
+				s.resultMu.Unlock()
+				round.Meet = &meet
+			}
+			s.OnRound(round)
+		}
+
+		if s.found.Load() {
+			break
+		}
+
+		for _, n := range nextF {
+			s.enqueue(pqF, n, "F")
+		}
+		for _, n := range nextB {
+			s.enqueue(pqB, n, "B")
+		}
+		s.reprioritize(pqF, "F")
+		s.reprioritize(pqB, "B")
+		s.emitProgress(pqF.Len(), pqB.Len())
+	}
+
+	s.resultMu.Lock()
+	defer s.resultMu.Unlock()
+	return s.result
+}
+
+// inOrder выстраивает раскрытие батчей в порядке их запуска: запросы идут
+// параллельно, но граф меняется всегда в одном порядке, и одинаковые
+// ответы API дают одинаковую встречу
+type inOrder struct {
+	prev chan struct{}
+}
+
+// next занимает очередь за последним батчем: wait закрывается, когда
+// предыдущий батч раскрыт, done передаёт очередь следующему
+func (o *inOrder) next() (wait <-chan struct{}, done func()) {
+	if o.prev == nil {
+		o.prev = make(chan struct{})
+		close(o.prev)
+	}
+	wait = o.prev
+	turn := make(chan struct{})
+	o.prev = turn
+	return wait, func() { close(turn) }
+}
+
+// sortedLangsAPI - языки раунда по алфавиту: обход map случаен, а от
+// порядка запуска батчей зависит, чья встреча найдётся первой
+func sortedLangsAPI(byLang map[string][]string) []string {
+	langs := make([]string, 0, len(byLang))
+	for lang := range byLang {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// roundBudgetsAPI делит раунд между фронтами: каждому по perSide узлов,
+// а то, что фронт потратить не может (в очереди меньше), достаётся
+// другому. Опустевший фронт отдаёт весь бюджет - поиск идёт
+// однонаправленным.
+func roundBudgetsAPI(lenF, lenB, perSide int) (budgetF, budgetB int) {
+	budgetF, budgetB = perSide, perSide
+	if lenB < perSide {
+		budgetF += perSide - lenB
+	}
+	if lenF < perSide {
+		budgetB += perSide - lenF
+	}
+	return budgetF, budgetB
+}
+
+// RoundInfo - что произошло за раунд (кадр round у /ws/search):
+// какие узлы раскрыты каждым фронтом и сколько узлов уже посещено
+type RoundInfo struct {
+	Round    int      `json:"round" example:"2"`
+	PoppedF  []string `json:"popped_f" example:"ru:Кошка"` // "lang:title", раскрытые forward
+	PoppedB  []string `json:"popped_b" example:"ru:Собака"`
+	VisitedF int      `json:"visited_f" example:"1840"`
+	VisitedB int      `json:"visited_b" example:"920"`
+	Meet     *string  `json:"meet,omitempty" example:"ru:Млекопитающие"` // узел встречи, если фронты сошлись в этом раунде
+}
+
+// syncMapLen считает записи sync.Map; линейно, только для OnRound и stats
+func syncMapLen(m *sync.Map) int {
+	n := 0
+	m.Range(func(_, _ interface{}) bool {
+		n++
+		return true
+	})
+	return n
+}
+
+// ProgressEvent - состояние поиска после очередного раунда
+// (событие progress у /search/stream)
+type ProgressEvent struct {
+	Round     int     `json:"round" example:"3"`
+	FrontierF int     `json:"frontier_f" example:"512"` // узлов в очереди forward
+	FrontierB int     `json:"frontier_b" example:"430"` // узлов в очереди backward
+	Requests  int64   `json:"requests" example:"18"`
+	ElapsedMs float64 `json:"elapsed_ms" example:"640.5"`
+}
+
+// emitProgress отправляет состояние раунда, не блокируя поиск: если
+// читатель не успевает, событие пропускается
+func (s *APISearcher) emitProgress(frontierF, frontierB int) {
+	if s.progress == nil {
+		return
+	}
+	elapsed := time.Since(s.started)
+	select {
+	case s.progress <- ProgressEvent{
+		Round:     s.rounds,
+		FrontierF: frontierF,
+		FrontierB: frontierB,
+		Requests:  s.reqCount.Load(),
+		ElapsedMs: float64(elapsed.Microseconds()) / 1000,
+	}:
+	default:
+	}
+}
@@ -0,0 +1,1112 @@
+package server
+
+import (
+	"bytes"
+	"container/heap"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// popTitles опустошает очередь и возвращает названия в порядке извлечения
+func popTitles(pq *APIPriorityQueue) []string {
+	var titles []string
+	for pq.Len() > 0 {
+		titles = append(titles, heap.Pop(pq).(*APIWikiNode).Title)
+	}
+	return titles
+}
+
+func TestAPIPriorityQueueFix(t *testing.T) {
+	pq := &APIPriorityQueue{}
+	nodes := map[string]*APIWikiNode{}
+	for i, title := range []string{"A", "B", "C", "D", "E"} {
+		n := &APIWikiNode{Title: title, Lang: "en", Priority: (i + 1) * 10}
+		nodes[title] = n
+		heap.Push(pq, n)
+	}
+	for i, n := range *pq {
+		if n.Index != i {
+			t.Fatalf("%s: Index = %d, want %d", n.Title, n.Index, i)
+		}
+	}
+
+	// Уменьшение ключа: E из хвоста поднимается в корень
+	nodes["E"].Priority = 5
+	heap.Fix(pq, nodes["E"].Index)
+	if (*pq)[0] != nodes["E"] || nodes["E"].Index != 0 {
+		t.Fatalf("после Fix корень %s, Index E = %d", (*pq)[0].Title, nodes["E"].Index)
+	}
+	// Равный приоритет: порядок по ключу, а не по вставке
+	nodes["D"].Priority = 20
+	heap.Fix(pq, nodes["D"].Index)
+
+	want := []string{"E", "A", "B", "D", "C"}
+	if got := popTitles(pq); !reflect.DeepEqual(got, want) {
+		t.Errorf("порядок извлечения %v, want %v", got, want)
+	}
+	if nodes["E"].Index != -1 {
+		t.Errorf("извлечённый узел: Index = %d, want -1", nodes["E"].Index)
+	}
+}
+
+func TestReprioritize(t *testing.T) {
+	s := newTestSearcher(t, defaultAPIOptions)
+	pq := &APIPriorityQueue{}
+	for i, title := range []string{"A", "B", "C", "D"} {
+		n := &APIWikiNode{Title: title, Lang: "en", Priority: (i + 1) * 10}
+		s.enqueue(pq, n, "F")
+		parent := en("Old")
+		s.visitedF.Store(n.Key(), &parent)
+	}
+	// Старт фронта: родителя нет, приоритет не меняется
+	root := &APIWikiNode{Title: "Root", Lang: "en", Priority: 50}
+	s.enqueue(pq, root, "F")
+	s.visitedF.Store(root.Key(), (*APIWikiNode)(nil))
+
+	s.rediscover("F", rediscovery{key: en("D").Key(), parent: en("New"), priority: 5})
+	s.rediscover("F", rediscovery{key: en("B").Key(), parent: en("Worse"), priority: 25})
+	s.rediscover("F", rediscovery{key: root.Key(), parent: en("New"), priority: 1})
+	s.rediscover("F", rediscovery{key: en("Unknown").Key(), parent: en("New"), priority: 1})
+	s.reprioritize(pq, "F")
+
+	if got := s.reprioritized.Load(); got != 1 {
+		t.Errorf("reprioritized = %d, want 1", got)
+	}
+	for title, want := range map[string]string{"D": "New", "B": "Old"} {
+		val, _ := s.visitedF.Load(en(title).Key())
+		if got := val.(*APIWikiNode).Title; got != want {
+			t.Errorf("родитель %s = %s, want %s", title, got, want)
+		}
+	}
+	if got, want := popTitles(pq), []string{"D", "A", "B", "C", "Root"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("порядок извлечения %v, want %v", got, want)
+	}
+	if len(s.rediscF) != 0 {
+		t.Errorf("находки не сброшены: %d", len(s.rediscF))
+	}
+}
+
+func TestReprioritizeFirstFrontier(t *testing.T) {
+	// Start - страница значений: её дети, первый фронт, получают штраф.
+	// 600 детей не раскрыть за раунд - N599 ждёт в очереди, когда N000
+	// находит его без штрафа
+	graph := map[string][]string{"N000": {"N599"}, "Elsewhere": {"Target"}}
+	for i := 0; i < 600; i++ {
+		graph["Start"] = append(graph["Start"], fmt.Sprintf("N%03d", i))
+	}
+	withFakeWiki(t, (&graphWiki{links: graph, disambig: map[string]bool{"Start": true}}).ServeHTTP, "en")
+
+	opts := defaultAPIOptions
+	opts.CheckDisambig, opts.ListPenalty, opts.MaxRounds = true, 30, 1
+	s := newTestSearcher(t, opts)
+	s.Search("Start", "Target", "en")
+
+	if got := s.reprioritized.Load(); got != 1 {
+		t.Errorf("reprioritized = %d, want 1", got)
+	}
+	if val, _ := s.visitedF.Load(en("N599").Key()); val == nil || val.(*APIWikiNode).Title != "N000" {
+		t.Errorf("родитель N599 = %v, want N000", val)
+	}
+}
+
+// equalPaths - графы с несколькими одинаково короткими путями Start -> Target
+// и равными приоритетами промежуточных статей: встреча после первых
+// запросов и встреча в раунде, где раскрываются батчи обоих фронтов
+var equalPaths = map[string]map[string][]string{
+	"соседи": {
+		"Start":  {"Cherry", "Apple", "Banana", "Durian"},
+		"Apple":  {"Target", "Banana"},
+		"Banana": {"Target", "Apple"},
+		"Cherry": {"Target"},
+		"Durian": {"Elder"},
+		"Elder":  {"Target"},
+	},
+	"раунды": {
+		"Start": {"Cherry", "Apple", "Banana"},
+		"Apple": {"Apple two"}, "Banana": {"Banana two"}, "Cherry": {"Cherry two"},
+		"Apple two": {"Target"}, "Banana two": {"Target"}, "Cherry two": {"Target"},
+	},
+}
+
+// chainWiki - цепочка, которую фронты проходят за два раунда: в первом
+// встречи нет
+var chainWiki = map[string][]string{
+	"Source": {"Link one"}, "Link one": {"Link two"}, "Link two": {"Link three"},
+	"Link three": {"Link four"}, "Link four": {"Sink"},
+}
+
+const chainPath = "Source Link one Link two Link three Link four Sink"
+
+func TestSearchDeterministic(t *testing.T) {
+	for name, graph := range equalPaths {
+		t.Run(name, func(t *testing.T) {
+			var first string
+			for i := 0; i < 10; i++ {
+				// Свежий кеш и сервер: каждый поиск начинается с нуля
+				withFakeWiki(t, (&graphWiki{links: graph}).ServeHTTP, "en")
+				s := newTestSearcher(t, defaultAPIOptions)
+				path, err := s.Search("Start", "Target", "en")
+				if err != nil {
+					t.Fatalf("поиск %d: %v", i, err)
+				}
+				got := pathString(path)
+				if i == 0 {
+					first = got
+					continue
+				}
+				if got != first {
+					t.Fatalf("поиск %d: путь %q, первый был %q", i, got, first)
+				}
+			}
+			if !strings.HasPrefix(first, "Start ") || !strings.HasSuffix(first, ":Target") {
+				t.Errorf("путь %q не из Start в Target", first)
+			}
+		})
+	}
+}
+
+func TestSearchCancelledBeforeStart(t *testing.T) {
+	wiki := &graphWiki{links: equalPaths["соседи"]}
+	withFakeWiki(t, wiki.ServeHTTP, "en")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	s := NewAPISearcher(ctx, "en", "Start", "en", "Target", defaultAPIOptions)
+	defer s.cancel()
+	path, err := s.Search("Start", "Target", "en")
+	if path != nil || !errors.Is(err, ErrCancelled) {
+		t.Errorf("Search = %v, %v; want nil, ErrCancelled", path, err)
+	}
+	if n := s.reqCount.Load(); n != 0 {
+		t.Errorf("reqCount = %d, want 0", n)
+	}
+	if n := wiki.requests.Load(); n != 0 {
+		t.Errorf("запросов к API: %d, want 0", n)
+	}
+}
+
+// endlessWiki - бесконечный граф: у каждой статьи 20 ссылок и 20 обратных
+// ссылок на новые статьи, так что фронты не встречаются до конца бюджета
+func endlessWiki(requests *atomic.Int64) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		r.ParseForm()
+		time.Sleep(5 * time.Millisecond)
+		pages := map[string]interface{}{}
+		for i, title := range strings.Split(r.Form.Get("titles"), "|") {
+			var out []string
+			for j := 0; j < 20; j++ {
+				out = append(out, fmt.Sprintf("%s %d", title, j))
+			}
+			pages[strconv.Itoa(1000+i)] = map[string]interface{}{
+				"title": title, "ns": 0, "links": links(out...), "linkshere": links(out...),
+			}
+		}
+		writeJSON(w, map[string]interface{}{"query": map[string]interface{}{"pages": pages}})
+	}
+}
+
+func TestSearchParentCancel(t *testing.T) {
+	var requests atomic.Int64
+	withFakeWiki(t, endlessWiki(&requests), "en")
+
+	parent, cancel := context.WithCancel(context.Background())
+	opts := defaultAPIOptions
+	opts.Timeout = 30 * time.Second
+	s := NewAPISearcher(parent, "en", "Start", "en", "Target", opts)
+	defer s.cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := s.Search("Start", "Target", "en")
+		done <- err
+	}()
+	time.Sleep(200 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrCancelled) {
+			t.Errorf("Search: %v, want ErrCancelled", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("поиск не остановился после отмены родителя")
+	}
+	if requests.Load() == 0 {
+		t.Fatal("поиск не успел начаться")
+	}
+
+	// После остановки запросы не идут - ни из поиска, ни из проверок пути
+	// после него: их контекст тоже от родителя. Запросы, отправленные до
+	// отмены, могут дойти до сервера чуть позже - ждём, пока они осядут
+	time.Sleep(100 * time.Millisecond)
+	before, counted := requests.Load(), s.reqCount.Load()
+	if got := s.verifyEdge(en("Start"), en("Start 1")); got != "unconfirmed" {
+		t.Errorf("verifyEdge = %q, want unconfirmed", got)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if got := requests.Load(); got != before {
+		t.Errorf("запросов после отмены: %d, want 0", got-before)
+	}
+	if got := s.reqCount.Load(); got != counted {
+		t.Errorf("reqCount вырос после отмены: %d → %d", counted, got)
+	}
+}
+
+// captureLog направляет журнал поиска в буфер и возвращает его
+func captureLog(s *APISearcher) *bytes.Buffer {
+	var buf bytes.Buffer
+	s.log = slog.New(slog.NewJSONHandler(&buf, nil))
+	return &buf
+}
+
+// logEvents - события журнала с сообщением msg
+func logEvents(t *testing.T, buf *bytes.Buffer, msg string) []map[string]interface{} {
+	t.Helper()
+	var events []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var e map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatalf("строка журнала %q: %v", line, err)
+		}
+		if e["msg"] == msg {
+			events = append(events, e)
+		}
+	}
+	return events
+}
+
+func TestSearchDrain(t *testing.T) {
+	graph := &graphWiki{links: map[string][]string{"Start": {"Target"}}}
+	srv := withFakeWikis(t, map[string]http.Handler{"en": graph, "de": graph})
+
+	// Транспорт замечает отмену не сразу: запрос detectLang в de живёт
+	// 300мс, хотя en уже ответил и поиск давно нашёл путь. active - запросы,
+	// чьи тела ещё не закрыты
+	var active atomic.Int64
+	base := srv.Client().Transport
+	globalHTTPClient = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		active.Add(1)
+		if strings.Contains(r.URL.Path, "/de/") {
+			time.Sleep(300 * time.Millisecond)
+		}
+		resp, err := base.RoundTrip(r)
+		if err != nil {
+			active.Add(-1)
+			return nil, err
+		}
+		resp.Body = &doneBody{ReadCloser: resp.Body, done: func() { active.Add(-1) }}
+		return resp, nil
+	})}
+
+	tests := []struct {
+		drain    time.Duration
+		outlived bool
+	}{
+		{0, true},
+		{2 * time.Second, false},
+	}
+	for _, tt := range tests {
+		opts := defaultAPIOptions
+		opts.DrainTimeout = tt.drain
+		s := newTestSearcher(t, opts)
+		if _, err := s.Search("Start", "Target", "en"); err != nil {
+			t.Fatalf("drain=%v: %v", tt.drain, err)
+		}
+		if got := active.Load() > 0; got != tt.outlived {
+			t.Errorf("drain=%v: после Search живых запросов %d", tt.drain, active.Load())
+		}
+		// Следующий случай начинается без хвостов предыдущего
+		for active.Load() > 0 {
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+}
+
+func TestStrictInterwiki(t *testing.T) {
+	// Короткий путь идёт через de:Falsch, но её interwiki ведёт обратно
+	// не на Start, а на другую статью - это другое понятие. Честный путь
+	// на одну статью длиннее.
+	enWiki := &graphWiki{
+		links: map[string][]string{
+			"Start":      {"Step one"},
+			"Step one":   {"Step two"},
+			"Step two":   {"Step three"},
+			"Step three": {"Target"},
+		},
+		langlinks: map[string][]string{
+			"Start":  {"de:Falsch"},
+			"Target": {"de:Ziel"},
+		},
+	}
+	deWiki := &graphWiki{
+		links: map[string][]string{"Falsch": {"Ziel"}},
+		langlinks: map[string][]string{
+			"Falsch": {"en:Something else"},
+			"Ziel":   {"en:Target"},
+		},
+	}
+	withFakeWikis(t, map[string]http.Handler{"en": enWiki, "de": deWiki})
+
+	tests := []struct {
+		strict   bool
+		want     string
+		rejected bool
+	}{
+		{false, "Start Falsch Ziel Target", false},
+		{true, "Start Step one Step two Step three Target", true},
+	}
+	for _, tt := range tests {
+		globalLinkCache = newLinkCache(1000, nil, 0)
+		opts := defaultAPIOptions
+		opts.StrictInterwiki = tt.strict
+		s := newTestSearcher(t, opts)
+		path, err := s.Search("Start", "Target", "en")
+		if err != nil {
+			t.Fatalf("strict=%v: %v", tt.strict, err)
+		}
+		if got := nodeTitles(path); got != tt.want {
+			t.Errorf("strict=%v: путь %q, want %q", tt.strict, got, tt.want)
+		}
+		if got := s.interwikiRejected.Load() > 0; got != tt.rejected {
+			t.Errorf("strict=%v: отклонено interwiki %d", tt.strict, s.interwikiRejected.Load())
+		}
+	}
+}
+
+func TestLangLinksDirection(t *testing.T) {
+	withFakeWikis(t, map[string]http.Handler{
+		"en": &graphWiki{
+			links:     map[string][]string{"Start": {"Middle"}, "Middle": {"Target"}},
+			langlinks: map[string][]string{"Start": {"de:Anfang"}, "Target": {"de:Ziel"}},
+		},
+		"de": &graphWiki{links: map[string][]string{"Anfang": {"Ziel"}}},
+	})
+
+	// fetchKeys - ключи узлов, найденных раскрытием title в направлении
+	// dir; поиск свой на каждый вызов, чтобы фронты не встретились на Middle
+	fetchKeys := func(opts APISearchOptions, title, dir string) string {
+		var keys []string
+		for _, n := range newTestSearcher(t, opts).fetch([]string{title}, "en", dir) {
+			keys = append(keys, n.Key())
+		}
+		sort.Strings(keys)
+		return strings.Join(keys, " ")
+	}
+	tests := []struct {
+		forward, backward bool
+		wantF, wantB      string
+	}{
+		{true, true, "de:Anfang en:Middle", "de:Ziel en:Middle"},
+		{true, false, "de:Anfang en:Middle", "en:Middle"},
+		{false, true, "en:Middle", "de:Ziel en:Middle"},
+		{false, false, "en:Middle", "en:Middle"},
+	}
+	for _, tt := range tests {
+		opts := defaultAPIOptions
+		opts.LangLinksForward, opts.LangLinksBackward = tt.forward, tt.backward
+		if got := fetchKeys(opts, "Start", "F"); got != tt.wantF {
+			t.Errorf("forward=%v backward=%v: вперёд от Start %q, want %q", tt.forward, tt.backward, got, tt.wantF)
+		}
+		if got := fetchKeys(opts, "Target", "B"); got != tt.wantB {
+			t.Errorf("forward=%v backward=%v: назад от Target %q, want %q", tt.forward, tt.backward, got, tt.wantB)
+		}
+	}
+}
+
+func TestSearchDegraded(t *testing.T) {
+	// Батч de:Kaputt второго раунда сбоит failures раз подряд, en отвечает
+	// медленнее, чтобы сбой случился до встречи фронтов
+	enWiki := &graphWiki{
+		links:     map[string][]string{"Start": {"Alpha"}, "Alpha": {"Beta"}, "Beta": {"Target"}},
+		langlinks: map[string][]string{"Start": {"de:Kaputt"}},
+	}
+	tests := []struct {
+		name      string
+		failures  int64
+		threshold int
+		failed    int64
+		degraded  bool
+	}{
+		{"разовый сбой, повтор помог", 1, 0, 0, false},
+		{"сбой на каждой попытке", 100, 0, 1, true},
+		{"сбой в пределах порога", 100, 1, 1, false},
+	}
+	for _, tt := range tests {
+		var calls atomic.Int64
+		deWiki := &graphWiki{links: map[string][]string{"Kaputt": {"Irgendwo"}}}
+		withFakeWikis(t, map[string]http.Handler{
+			"en": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				time.Sleep(50 * time.Millisecond)
+				enWiki.ServeHTTP(w, r)
+			}),
+			"de": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if calls.Add(1) <= tt.failures {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				deWiki.ServeHTTP(w, r)
+			}),
+		})
+
+		opts := defaultAPIOptions
+		opts.HTTPRetries = 1
+		opts.TransientBackoff = time.Millisecond
+		opts.DegradedThreshold = tt.threshold
+		s := newTestSearcher(t, opts)
+		path, err := s.Search("Start", "Target", "en")
+		if err != nil || nodeTitles(path) != "Start Alpha Beta Target" {
+			t.Fatalf("%s: путь %s, %v", tt.name, pathString(path), err)
+		}
+		stats := s.stats(time.Second)
+		if stats.FailedFetches != tt.failed || stats.Degraded != tt.degraded {
+			t.Errorf("%s: failed_fetches=%d degraded=%v, want %d %v",
+				tt.name, stats.FailedFetches, stats.Degraded, tt.failed, tt.degraded)
+		}
+	}
+}
+
+func TestSearchMissingEnds(t *testing.T) {
+	graph := func() http.Handler {
+		return &graphWiki{links: map[string][]string{"Present": {"Also present"}}}
+	}
+	withFakeWikis(t, map[string]http.Handler{"en": graph(), "de": graph(), "ru": graph()})
+	app := NewApp()
+
+	tests := []struct {
+		from, to, code string
+	}{
+		{"Nowhere at all", "Also present", "START_NOT_FOUND"},
+		{"Present", "Nowhere at all", "END_NOT_FOUND"},
+		{"Nowhere at all", "Nowhere either", "START_NOT_FOUND"},
+	}
+	for _, tt := range tests {
+		body := fmt.Sprintf(`{"from":%q,"to":%q,"lang":"en","timeout_ms":30000}`, tt.from, tt.to)
+		req := httptest.NewRequest("POST", "/api/v1/search", strings.NewReader(body))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+		start := time.Now()
+		resp, err := app.Test(req, 5000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var e ErrorResponse
+		json.NewDecoder(resp.Body).Decode(&e)
+		resp.Body.Close()
+
+		bad := tt.from
+		if tt.code == "END_NOT_FOUND" {
+			bad = tt.to
+		}
+		if resp.StatusCode != http.StatusNotFound || e.Code != tt.code || !strings.Contains(e.Error, bad) {
+			t.Errorf("%s → %s: %d %s %q, want 404 %s с %q", tt.from, tt.to, resp.StatusCode, e.Code, e.Error, tt.code, bad)
+		}
+		// Сразу после проверки концов, а не по бюджету поиска
+		if d := time.Since(start); d > 2*time.Second {
+			t.Errorf("%s → %s: ответ через %v", tt.from, tt.to, d)
+		}
+	}
+}
+
+func TestSearchErrors(t *testing.T) {
+	var requests atomic.Int64
+	graph := &graphWiki{links: map[string][]string{
+		"Island": {"Shore"}, "Rock": {"Sea"},
+	}}
+	tests := []struct {
+		name     string
+		wiki     http.HandlerFunc
+		from, to string
+		want     error
+		status   int
+		code     string
+	}{
+		{"нет начала", graph.ServeHTTP, "Ghost town", "Rock", ErrStartMissing, 404, "START_NOT_FOUND"},
+		{"нет конца", graph.ServeHTTP, "Island", "Ghost town", ErrEndMissing, 404, "END_NOT_FOUND"},
+		{"нет пути", graph.ServeHTTP, "Island", "Rock", ErrNoPath, 404, "PATH_NOT_FOUND"},
+		{"бюджет истёк", endlessWiki(&requests), "Start", "Target", ErrTimeout, 408, "SEARCH_TIMEOUT"},
+	}
+	for _, tt := range tests {
+		withFakeWiki(t, tt.wiki, "en")
+		opts := defaultAPIOptions
+		opts.Timeout = 300 * time.Millisecond
+		s := NewAPISearcher(context.Background(), "en", tt.from, "en", tt.to, opts)
+		path, err := s.Search(tt.from, tt.to, "en")
+		s.cancel()
+		if len(path) != 0 || !errors.Is(err, tt.want) {
+			t.Errorf("%s: путь %q, ошибка %v, want %v", tt.name, nodeTitles(path), err, tt.want)
+			continue
+		}
+		if status, resp, _ := s.failure(err); status != tt.status || resp.Code != tt.code {
+			t.Errorf("%s: %d %s, want %d %s", tt.name, status, resp.Code, tt.status, tt.code)
+		}
+	}
+}
+
+func TestSearchStarvedBackward(t *testing.T) {
+	graph := &graphWiki{links: map[string][]string{"N599": {"Target"}}}
+	for i := 0; i < 600; i++ {
+		graph.links["Start"] = append(graph.links["Start"], fmt.Sprintf("N%03d", i))
+	}
+	// linkshere всегда пуст: backward-фронт кончается на Target
+	withFakeWiki(t, func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		r.Form.Set("prop", strings.ReplaceAll(r.Form.Get("prop"), "linkshere", ""))
+		graph.ServeHTTP(w, r)
+	}, "en")
+
+	s := newTestSearcher(t, defaultAPIOptions)
+	var rounds []RoundInfo
+	s.OnRound = func(r RoundInfo) { rounds = append(rounds, r) }
+	path, err := s.Search("Start", "Target", "en")
+	if err != nil || nodeTitles(path) != "Start N599 Target" {
+		t.Fatalf("путь %q, %v; want Start N599 Target", nodeTitles(path), err)
+	}
+	// Весь бюджет раунда (2 x 250) достаётся forward-фронту: 500 из 600
+	// соседей Start, остальные - в следующем раунде
+	if len(rounds) != 2 || len(rounds[0].PoppedB) != 0 || len(rounds[0].PoppedF) != 500 {
+		for _, r := range rounds {
+			t.Logf("раунд %d: forward %d, backward %d", r.Round, len(r.PoppedF), len(r.PoppedB))
+		}
+		t.Error("в первом раунде forward должен раскрыть 500 узлов, backward - ни одного")
+	}
+}
+
+func TestSearchRedirectMeet(t *testing.T) {
+	// Start ссылается на редирект USA, а на Target ссылается статья под
+	// каноническим названием: фронты должны узнать в них одну статью
+	withFakeWiki(t, (&graphWiki{
+		links: map[string][]string{
+			"Start":         {"USA", "Detour"},
+			"Detour":        {"Detour two"},
+			"Detour two":    {"Detour three"},
+			"Detour three":  {"Target"},
+			"United States": {"Target"},
+		},
+		redirects: map[string]string{"USA": "United States"},
+	}).ServeHTTP, "en")
+
+	s := newTestSearcher(t, defaultAPIOptions)
+	path, err := s.Search("Start", "Target", "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := nodeTitles(path); got != "Start United States Target" {
+		t.Errorf("путь %q, want Start United States Target", got)
+	}
+}
+
+// detourWiki - граф, где эвристика находит путь длиннее кратчайшего:
+// короткий Start → Zz a → Zz b → Target прячется в хвостах фронтов по
+// 600 статей, а длинный через Target route и Start route эвристика
+// раскрывает первым
+func detourWiki() *graphWiki {
+	graph := &graphWiki{links: map[string][]string{
+		"Start":        {"Target route", "Zz a"},
+		"Target route": {"Junction"},
+		"Junction":     {"Start route"},
+		"Start route":  {"Target"},
+		"Zz a":         {"Zz b"},
+		"Zz b":         {"Target"},
+	}}
+	for i := 0; i < 600; i++ {
+		p, r := fmt.Sprintf("P%03d", i), fmt.Sprintf("R%03d", i)
+		graph.links["Start"] = append(graph.links["Start"], p)
+		graph.links[r] = []string{"Target"}
+	}
+	return graph
+}
+
+func TestSearchShortest(t *testing.T) {
+	withFakeWiki(t, detourWiki().ServeHTTP, "en")
+
+	tests := []struct {
+		shortest bool
+		want     string
+	}{
+		{false, "Start Target route Junction Start route Target"},
+		{true, "Start Zz a Zz b Target"},
+	}
+	for _, tt := range tests {
+		opts := defaultAPIOptions
+		opts.Shortest = tt.shortest
+		s := newTestSearcher(t, opts)
+		path, err := s.Search("Start", "Target", "en")
+		if err != nil {
+			t.Fatalf("shortest=%v: %v", tt.shortest, err)
+		}
+		if got := nodeTitles(path); got != tt.want {
+			t.Errorf("shortest=%v: путь %q, want %q", tt.shortest, got, tt.want)
+		}
+	}
+}
+
+func TestSearchTimeoutPartial(t *testing.T) {
+	var requests atomic.Int64
+	withFakeWiki(t, endlessWiki(&requests), "en")
+	app := NewApp()
+
+	req := httptest.NewRequest("POST", "/api/v1/search",
+		strings.NewReader(`{"from":"Start","to":"Target","lang":"en","timeout_ms":300}`))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	resp, err := app.Test(req, 5000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var data SearchResponse
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusPartialContent || !data.Partial || data.Complete {
+		t.Fatalf("статус %d, partial=%v complete=%v; want 206, true, false", resp.StatusCode, data.Partial, data.Complete)
+	}
+	if len(data.Path) < 2 || data.Path[0].Title != "Start" {
+		t.Fatalf("path %q: want от Start вглубь forward-фронта", pathTitles(data))
+	}
+	if len(data.Tail) < 2 || data.Tail[len(data.Tail)-1].Title != "Target" {
+		t.Fatalf("tail %v: want до Target", data.Tail)
+	}
+	// Разрыв - между концом path и началом tail
+	last := data.Path[len(data.Path)-1]
+	if g := data.Gap; g == nil || g.From != last.Title || g.To != data.Tail[0].Title || g.FromLang != "en" || g.ToLang != "en" {
+		t.Errorf("gap %+v, want %s → %s", data.Gap, last.Title, data.Tail[0].Title)
+	}
+}
+
+func TestInterwikiFallback(t *testing.T) {
+	// У Start единственный interwiki - в неподключённый eo, а уже версия
+	// eo ссылается на de:Anfang, откуда есть путь до Target
+	srv := withFakeWikis(t, map[string]http.Handler{
+		"en": &graphWiki{
+			links:     map[string][]string{"Start": {}, "Target": {}},
+			langlinks: map[string][]string{"Start": {"eo:Komenco"}, "Target": {"de:Ziel"}},
+		},
+		"de": &graphWiki{
+			links:     map[string][]string{"Anfang": {"Ziel"}},
+			langlinks: map[string][]string{"Ziel": {"en:Target"}, "Anfang": {"eo:Komenco"}},
+		},
+		"eo": &graphWiki{
+			links:     map[string][]string{"Komenco": {}},
+			langlinks: map[string][]string{"Komenco": {"en:Start", "de:Anfang"}},
+		},
+	})
+	// eo не подключён: его API - по адресу раздела Wikipedia
+	delete(apiWikis, "eo")
+	detectLangsAPI = []string{"de", "en"}
+	routeWikipedia(srv)
+
+	tests := []struct {
+		fallback bool
+		want     string
+	}{
+		{false, ""},
+		{true, "Start Anfang Ziel Target"},
+	}
+	for _, tt := range tests {
+		globalLinkCache = newLinkCache(1000, nil, 0)
+		opts := defaultAPIOptions
+		opts.InterwikiFallback = tt.fallback
+		s := newTestSearcher(t, opts)
+		path, _ := s.Search("Start", "Target", "en")
+		if got := nodeTitles(path); got != tt.want {
+			t.Errorf("fallback=%v: путь %q, want %q", tt.fallback, got, tt.want)
+		}
+		if tt.fallback {
+			if s.interwikiFallback.Load() == 0 {
+				t.Error("переход через eo не засчитан в interwiki_fallback")
+			}
+			if through := s.fallbackThrough(en("Start"), APIWikiNode{Title: "Anfang", Lang: "de"}); through != "eo:Komenco" {
+				t.Errorf("переход Start → de:Anfang через %q, want eo:Komenco", through)
+			}
+		}
+	}
+}
+
+func TestSearchMaxDepth(t *testing.T) {
+	graph := &graphWiki{links: chainWiki}
+	var mu sync.Mutex
+	var loaded map[string]bool // "F:Название" - раскрытые статьи
+	withFakeWiki(t, func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		dir := ""
+		switch prop := "|" + r.Form.Get("prop") + "|"; {
+		case strings.Contains(prop, "|links|"):
+			dir = "F"
+		case strings.Contains(prop, "|linkshere|"):
+			dir = "B"
+		}
+		if dir != "" {
+			mu.Lock()
+			for _, title := range strings.Split(r.Form.Get("titles"), "|") {
+				loaded[dir+":"+title] = true
+			}
+			mu.Unlock()
+		}
+		graph.ServeHTTP(w, r)
+	}, "en")
+
+	tests := []struct {
+		maxDepth  int
+		err       error
+		notLoaded []string
+	}{
+		// Путь в 5 переходов в предел не влезает: узлы на глубине 2 от
+		// своего конца не раскрываются
+		{2, ErrDepthExceeded, []string{"F:Link two", "F:Link three", "B:Link three", "B:Link two"}},
+		{4, ErrDepthExceeded, []string{"F:Link four", "B:Link one"}},
+		{5, nil, nil},
+	}
+	for _, tt := range tests {
+		loaded = map[string]bool{}
+		globalLinkCache = newLinkCache(1000, nil, 0)
+		opts := defaultAPIOptions
+		opts.MaxDepth = tt.maxDepth
+		s := NewAPISearcher(context.Background(), "en", "Source", "en", "Sink", opts)
+		path, err := s.Search("Source", "Sink", "en")
+		s.cancel()
+		if !errors.Is(err, tt.err) && !(tt.err == nil && err == nil) {
+			t.Errorf("max_depth=%d: ошибка %v, want %v", tt.maxDepth, err, tt.err)
+		}
+		if tt.err == nil && nodeTitles(path) != chainPath {
+			t.Errorf("max_depth=%d: путь %q, want %q", tt.maxDepth, nodeTitles(path), chainPath)
+		}
+		for _, key := range tt.notLoaded {
+			if loaded[key] {
+				t.Errorf("max_depth=%d: раскрыт %s за пределом глубины", tt.maxDepth, key)
+			}
+		}
+	}
+}
+
+func TestSearchMeetPoint(t *testing.T) {
+	withFakeWiki(t, (&graphWiki{links: map[string][]string{
+		"Alpha": {"Bravo"}, "Bravo": {"Charlie"}, "Charlie": {"Delta"}, "Delta": {"Echo"},
+	}}).ServeHTTP, "en")
+	app := NewApp()
+
+	req := httptest.NewRequest("POST", "/api/v1/search", strings.NewReader(`{"from":"Alpha","to":"Echo","lang":"en"}`))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	resp, err := app.Test(req, 5000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var data SearchResponse
+	json.NewDecoder(resp.Body).Decode(&data)
+
+	// Фронты раскрывают по одному узлу и сходятся на Charlie, посередине
+	if got := pathTitles(data); got != "Alpha Bravo Charlie Delta Echo" {
+		t.Fatalf("путь %q", got)
+	}
+	var dirs []string
+	for _, step := range data.Path {
+		dirs = append(dirs, step.Direction)
+	}
+	if got, want := strings.Join(dirs, " "), "forward forward meet backward backward"; got != want {
+		t.Errorf("direction шагов %q, want %q", got, want)
+	}
+	if data.ForwardHops != 2 || data.BackwardHops != 2 {
+		t.Errorf("forward_hops=%d backward_hops=%d, want 2 и 2", data.ForwardHops, data.BackwardHops)
+	}
+	// path_length считает статьи, переходов на один меньше
+	if data.ForwardHops+data.BackwardHops != data.PathLength-1 {
+		t.Errorf("forward_hops + backward_hops = %d при path_length %d", data.ForwardHops+data.BackwardHops, data.PathLength)
+	}
+	// По одной новой статье на фронт за раунд: в очередях не больше двух
+	st := data.Stats
+	if st.NodesVisitedForward != 3 || st.NodesVisitedBackward != 3 || st.PeakFrontier != 2 {
+		t.Errorf("nodes_visited_forward=%d nodes_visited_backward=%d peak_frontier=%d, want 3, 3, 2", st.NodesVisitedForward, st.NodesVisitedBackward, st.PeakFrontier)
+	}
+}
+
+func TestSearchDirect(t *testing.T) {
+	withFakeWiki(t, (&graphWiki{links: map[string][]string{
+		"Anchor": {"Harbor", "Dock"}, "Dock": {"Pier"},
+	}}).ServeHTTP, "en")
+	app := NewApp()
+
+	tests := []struct {
+		from, to, path string
+		direct         bool
+	}{
+		{"Anchor", "Harbor", "Anchor Harbor", true},
+		{"Anchor", "Pier", "Anchor Dock Pier", false},
+	}
+	for _, tt := range tests {
+		status, data := postSearchResponse(t, app, fmt.Sprintf(`{"from":%q,"to":%q,"lang":"en"}`, tt.from, tt.to))
+		if status != http.StatusOK || pathTitles(data) != tt.path {
+			t.Fatalf("%s → %s: %d %q, want 200 %q", tt.from, tt.to, status, pathTitles(data), tt.path)
+		}
+		if data.Direct != tt.direct {
+			t.Errorf("%s → %s: direct=%v, want %v", tt.from, tt.to, data.Direct, tt.direct)
+		}
+	}
+}
+
+func TestSearchCanonical(t *testing.T) {
+	// Start ссылается на редирект Kitty; статья - Cat
+	withFakeWiki(t, (&graphWiki{
+		links:     map[string][]string{"Start": {"Kitty"}, "Kitty": {"Target"}, "Cat": {"Target"}},
+		redirects: map[string]string{"Kitty": "Cat"},
+	}).ServeHTTP, "en")
+	app := NewApp()
+
+	tests := []struct {
+		canonical bool
+		want      string
+	}{
+		{false, "Kitty"},
+		{true, "Cat"},
+	}
+	for _, tt := range tests {
+		status, data := postSearchResponse(t, app, fmt.Sprintf(`{"from":"Start","to":"Target","lang":"en","canonical":%v}`, tt.canonical))
+		if status != http.StatusOK || len(data.Path) != 3 {
+			t.Fatalf("canonical=%v: %d %q, want 200 и три статьи", tt.canonical, status, pathTitles(data))
+		}
+		mid := data.Path[1]
+		if mid.Title != tt.want || !strings.HasSuffix(mid.URL, "/wiki/"+tt.want) {
+			t.Errorf("canonical=%v: промежуточная статья %s (%s), want %s", tt.canonical, mid.Title, mid.URL, tt.want)
+		}
+		if data.Path[0].Title != "Start" || data.Path[2].Title != "Target" {
+			t.Errorf("canonical=%v: концы пути %q", tt.canonical, pathTitles(data))
+		}
+	}
+}
+
+func TestSearchVisitedStats(t *testing.T) {
+	// Start ведёт к Target двумя шагами через Left; у Start ещё два тупика,
+	// на Target ещё ссылается Far
+	withFakeWiki(t, (&graphWiki{links: map[string][]string{
+		"Start": {"Left", "Dead end", "Cul-de-sac"}, "Left": {"Target"}, "Far": {"Target"},
+	}}).ServeHTTP, "en")
+	app := NewApp()
+
+	for i := 0; i < 3; i++ {
+		globalLinkCache = newLinkCache(1000, nil, 0)
+		status, data := postSearchResponse(t, app, `{"from":"Start","to":"Target","lang":"en"}`)
+		if status != http.StatusOK || pathTitles(data) != "Start Left Target" {
+			t.Fatalf("%d %q", status, pathTitles(data))
+		}
+		// forward: Start и три ссылки; backward: Target и его обратные Left, Far
+		st := data.Stats
+		if st.NodesVisitedForward != 4 || st.NodesVisitedBackward != 3 {
+			t.Errorf("прогон %d: nodes_visited_forward=%d nodes_visited_backward=%d, want 4 и 3", i, st.NodesVisitedForward, st.NodesVisitedBackward)
+		}
+		// Встреча при раскрытии концов - до первого раунда, очереди не росли
+		if st.PeakFrontier != 0 {
+			t.Errorf("прогон %d: peak_frontier=%d, want 0", i, st.PeakFrontier)
+		}
+	}
+}
+
+func TestSearchVerify(t *testing.T) {
+	// Поиск видит Mid → Target, а в живой статье Mid ссылки нет: на Mid
+	// ссылается Target, переход найден по linkshere
+	search := &graphWiki{links: map[string][]string{"Kitten": {"Mid"}, "Mid": {"Lion"}}}
+	live := &graphWiki{links: map[string][]string{"Kitten": {"Mid"}, "Lion": {"Mid"}}}
+	withFakeWiki(t, func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("pltitles") != "" {
+			live.ServeHTTP(w, r)
+			return
+		}
+		search.ServeHTTP(w, r)
+	}, "en")
+	app := NewApp()
+
+	for _, verify := range []bool{false, true} {
+		body := fmt.Sprintf(`{"from":"Kitten","to":"Lion","lang":"en","verify":%v}`, verify)
+		req := httptest.NewRequest("POST", "/api/v1/search", strings.NewReader(body))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+		resp, err := app.Test(req, 5000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var data SearchResponse
+		json.NewDecoder(resp.Body).Decode(&data)
+		resp.Body.Close()
+		if len(data.Transitions) != 2 {
+			t.Fatalf("verify=%v: переходов %d, want 2", verify, len(data.Transitions))
+		}
+
+		first, second := data.Transitions[0], data.Transitions[1]
+		if !verify {
+			if first.Verified != nil || second.Verified != nil {
+				t.Error("verify=false: verified заполнен")
+			}
+			if live.requests.Load() != 0 {
+				t.Errorf("verify=false: проверочных запросов %d", live.requests.Load())
+			}
+			continue
+		}
+		if first.Verified == nil || !*first.Verified || first.Direction != "forward" {
+			t.Errorf("Kitten → Mid: verified=%v direction=%s, want true forward", first.Verified, first.Direction)
+		}
+		if second.Verified == nil || *second.Verified || second.Direction != "backward" {
+			t.Errorf("Mid → Lion: verified=%v direction=%s, want false backward", second.Verified, second.Direction)
+		}
+		if !strings.Contains(second.CheckURL, "Lion") {
+			t.Errorf("Mid → Lion: check_url %s, want статья Lion", second.CheckURL)
+		}
+	}
+}
+
+func TestConfiguredLangCrawled(t *testing.T) {
+	ja := &graphWiki{
+		links:     map[string][]string{"始まり": {"終わり"}},
+		langlinks: map[string][]string{"始まり": {"en:Start"}, "終わり": {"en:Target"}},
+	}
+	srv := withFakeWikis(t, map[string]http.Handler{
+		"en": &graphWiki{
+			links:     map[string][]string{"Start": {}, "Target": {}},
+			langlinks: map[string][]string{"Start": {"ja:始まり"}, "Target": {"ja:終わり"}},
+		},
+		"de": &graphWiki{},
+		"ja": ja,
+	})
+	routeWikipedia(srv)
+	oldDefault, oldBridge := defaultLang, defaultAPIOptions.BridgeLangs
+	t.Cleanup(func() { defaultLang, defaultAPIOptions.BridgeLangs = oldDefault, oldBridge })
+
+	tests := []struct {
+		langs string
+		want  string
+	}{
+		{"en,de", ""},
+		{"en,de,ja", "Start 始まり 終わり Target"},
+	}
+	for _, tt := range tests {
+		t.Setenv("WIKI_LANGS", tt.langs)
+		if err := loadLangs(); err != nil {
+			t.Fatal(err)
+		}
+		ja.requests.Store(0)
+		globalLinkCache = newLinkCache(1000, nil, 0)
+
+		s := newTestSearcher(t, defaultAPIOptions)
+		path, _ := s.Search("Start", "Target", "en")
+		if got := nodeTitles(path); got != tt.want {
+			t.Errorf("WIKI_LANGS=%s: путь %q, want %q", tt.langs, got, tt.want)
+		}
+		// Раздел вне WIKI_LANGS не запрашивается: его interwiki отброшены
+		if crawled := ja.requests.Load() > 0; crawled != strings.Contains(tt.langs, "ja") {
+			t.Errorf("WIKI_LANGS=%s: запросов к ja %d", tt.langs, ja.requests.Load())
+		}
+	}
+}
+
+func TestSearchNamespaces(t *testing.T) {
+	withFakeWiki(t, (&graphWiki{links: map[string][]string{
+		"Girder":           {"Category:Bridges"},
+		"Category:Bridges": {"Suspension"},
+	}}).ServeHTTP, "en")
+	app := NewApp()
+
+	tests := []struct {
+		namespaces string
+		status     int
+		want       string
+	}{
+		{"", http.StatusNotFound, ""},
+		{"0|14", http.StatusOK, "Girder Category:Bridges Suspension"},
+	}
+	for _, tt := range tests {
+		target := "/api/v1/search?from=Girder&to=Suspension&lang=en"
+		if tt.namespaces != "" {
+			target += "&namespaces=" + url.QueryEscape(tt.namespaces)
+		}
+		resp, err := app.Test(httptest.NewRequest("GET", target, nil), 5000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var data SearchResponse
+		json.NewDecoder(resp.Body).Decode(&data)
+		resp.Body.Close()
+		if resp.StatusCode != tt.status || pathTitles(data) != tt.want {
+			t.Errorf("namespaces=%q: %d %q, want %d %q", tt.namespaces, resp.StatusCode, pathTitles(data), tt.status, tt.want)
+		}
+	}
+}
+
+func TestSearchCrossLangDisabled(t *testing.T) {
+	de := &graphWiki{
+		links:     map[string][]string{"Anfang": {"Ziel"}},
+		langlinks: map[string][]string{"Anfang": {"en:Start"}, "Ziel": {"en:Target"}},
+	}
+	// Раскрытия статей de; определение языка концов спрашивает de отдельно
+	var crawledDe atomic.Int64
+	withFakeWikis(t, map[string]http.Handler{
+		"en": &graphWiki{
+			links: map[string][]string{
+				"Start": {"Step one"}, "Step one": {"Step two"}, "Step two": {"Step three"}, "Step three": {"Target"},
+			},
+			langlinks: map[string][]string{"Start": {"de:Anfang"}, "Target": {"de:Ziel"}},
+		},
+		"de": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.ParseForm()
+			if prop := r.Form.Get("prop"); strings.Contains(prop, "links") {
+				crawledDe.Add(1)
+			}
+			de.ServeHTTP(w, r)
+		}),
+	})
+
+	off, on := false, true
+	tests := []struct {
+		crossLang *bool
+		want      string
+	}{
+		{nil, "Start Anfang Ziel Target"},
+		{&on, "Start Anfang Ziel Target"},
+		{&off, "Start Step one Step two Step three Target"},
+	}
+	for _, tt := range tests {
+		globalLinkCache = newLinkCache(1000, nil, 0)
+		crawledDe.Store(0)
+		req := SearchRequest{From: "Start", To: "Target", Lang: "en", CrossLang: tt.crossLang}
+		s := newTestSearcher(t, withCrossLang(defaultAPIOptions, req))
+		path, err := s.Search("Start", "Target", "en")
+		name := "cross_lang не задан"
+		if tt.crossLang != nil {
+			name = fmt.Sprint("cross_lang=", *tt.crossLang)
+		}
+		if err != nil || nodeTitles(path) != tt.want {
+			t.Errorf("%s: путь %q, %v; want %q", name, nodeTitles(path), err, tt.want)
+		}
+		if !req.sameEdition() {
+			continue
+		}
+		// Ни один interwiki-узел не попал во фронты, de не запрашивался
+		for _, visited := range []*sync.Map{&s.visitedF, &s.visitedB} {
+			visited.Range(func(k, _ interface{}) bool {
+				if key := k.(string); !strings.HasPrefix(key, "en:") {
+					t.Errorf("%s: во фронте %s", name, key)
+				}
+				return true
+			})
+		}
+		if got := crawledDe.Load(); got != 0 {
+			t.Errorf("%s: раскрытий статей de %d, want 0", name, got)
+		}
+	}
+}
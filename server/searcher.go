@@ -0,0 +1,422 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"wikiracer/fixture"
+	"wikiracer/peer"
+	"wikiracer/tokenize"
+)
+
+type APIWikiNode struct {
+	Title    string
+	Lang     string
+	Priority int
+	Index    int
+	Via      string // в пути: откуда ребро из предыдущего узла, "F" - links, "B" - linkshere, "C" - категория
+	Bridge   string // категория, через которую найден узел (CategoryBridges)
+	key      string // Key(), запомненный при Push: Less не нормализует название на каждом сравнении
+}
+
+func (n APIWikiNode) String() string { return n.Lang + ":" + n.Title }
+func (n APIWikiNode) Key() string    { return n.Lang + ":" + normalizeTitleAPI(n.Title) }
+
+type APIPriorityQueue []*APIWikiNode
+
+func (pq APIPriorityQueue) Len() int { return len(pq) }
+
+// Less при равном приоритете сравнивает ключи: порядок раскрытия не
+// зависит от порядка вставки, и одинаковый ввод даёт одинаковый путь
+func (pq APIPriorityQueue) Less(i, j int) bool {
+	if pq[i].Priority != pq[j].Priority {
+		return pq[i].Priority < pq[j].Priority
+	}
+	return pq[i].key < pq[j].key
+}
+func (pq APIPriorityQueue) Swap(i, j int) {
+	pq[i], pq[j] = pq[j], pq[i]
+	pq[i].Index = i
+	pq[j].Index = j
+}
+func (pq *APIPriorityQueue) Push(x interface{}) {
+	n := len(*pq)
+	item := x.(*APIWikiNode)
+	item.Index = n
+	item.key = item.Key()
+	*pq = append(*pq, item)
+}
+func (pq *APIPriorityQueue) Pop() interface{} {
+	old := *pq
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	item.Index = -1
+	*pq = old[0 : n-1]
+	return item
+}
+
+type APILangLink struct {
+	Lang  string
+	Title string
+}
+
+func (l *APILangLink) UnmarshalJSON(data []byte) error {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if lang, ok := raw["lang"].(string); ok {
+		l.Lang = lang
+	}
+	if title, ok := raw["*"].(string); ok {
+		l.Title = title
+	}
+	return nil
+}
+
+type APIWikiPage struct {
+	Title      string                   `json:"title"`
+	Ns         int                      `json:"ns"`
+	Links      []APILink                `json:"links"`
+	LinksHere  []APILink                `json:"linkshere"`
+	LangLinks  []APILangLink            `json:"langlinks"`
+	PageProps  map[string]string        `json:"pageprops"`
+	Extract    string                   `json:"extract"`
+	Categories []struct{ Title string } `json:"categories"`
+	Length     int                      `json:"length"` // prop=info: размер статьи в байтах
+	Clipped    bool                     `json:"-"`      // ссылки урезаны LargeResponseLinks, в кеш не класть
+	// Aliases - названия, под которыми статью запросили, если они другие:
+	// нормализация и редиректы (redirects=1), например USA для United States
+	Aliases []string `json:"-"`
+}
+
+// APILink - ссылка prop=links/linkshere; Ns - пространство имён статьи
+type APILink struct {
+	Ns    int    `json:"ns"`
+	Title string `json:"title"`
+}
+
+// APITitleMapping - элемент query.normalized / query.redirects
+type APITitleMapping struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type APIWikiResponse struct {
+	Continue map[string]string `json:"continue"`
+	Error    *APIError         `json:"error"`
+	Query    struct {
+		Normalized []APITitleMapping      `json:"normalized"`
+		Redirects  []APITitleMapping      `json:"redirects"`
+		Pages      map[string]APIWikiPage `json:"pages"`
+		// list=categorymembers
+		CategoryMembers []struct{ Title string } `json:"categorymembers"`
+		// list=random
+		Random []struct{ Title string } `json:"random"`
+	} `json:"query"`
+	// action=parse&prop=wikitext&formatversion=2
+	Parse struct {
+		Title    string `json:"title"`
+		Wikitext string `json:"wikitext"`
+	} `json:"parse"`
+}
+
+// APIError - объект error, который MediaWiki отдаёт с кодом 200
+type APIError struct {
+	Code string `json:"code"`
+	Info string `json:"info"`
+}
+
+func (e *APIError) Error() string { return "mediawiki: " + e.Code + ": " + e.Info }
+
+// transientAPIErrors - коды, которые проходят сами: техработы (readonly),
+// отставание реплик (maxlag) и лимит частоты (ratelimited)
+var transientAPIErrors = map[string]bool{
+	"readonly":    true,
+	"maxlag":      true,
+	"ratelimited": true,
+}
+
+// attachAliases записывает в страницы ответа названия, под которыми их
+// запросили (query.normalized и query.redirects, в том числе цепочкой:
+// нормализация, затем редирект)
+func (r *APIWikiResponse) attachAliases() {
+	resolve := make(map[string]string)
+	for _, m := range r.Query.Normalized {
+		resolve[m.From] = m.To
+	}
+	for _, m := range r.Query.Redirects {
+		resolve[m.From] = m.To
+	}
+	if len(resolve) == 0 {
+		return
+	}
+	byTitle := make(map[string]string, len(r.Query.Pages))
+	for id, page := range r.Query.Pages {
+		if !strings.HasPrefix(id, "-") {
+			byTitle[page.Title] = id
+		}
+	}
+	for from := range resolve {
+		to := from
+		for i := 0; i < len(resolve); i++ {
+			next, ok := resolve[to]
+			if !ok {
+				break
+			}
+			to = next
+		}
+		if id, ok := byTitle[to]; ok {
+			page := r.Query.Pages[id]
+			page.Aliases = append(page.Aliases, from)
+			r.Query.Pages[id] = page
+		}
+	}
+}
+
+// pageByTitle находит страницу ответа для запрошенного названия,
+// проходя по нормализации и редиректам
+func (r *APIWikiResponse) pageByTitle(title string) (APIWikiPage, bool) {
+	for _, m := range r.Query.Normalized {
+		if m.From == title {
+			title = m.To
+			break
+		}
+	}
+	for _, m := range r.Query.Redirects {
+		if m.From == title {
+			title = m.To
+			break
+		}
+	}
+	for id, page := range r.Query.Pages {
+		if page.Title == title && !strings.HasPrefix(id, "-") {
+			return page, true
+		}
+	}
+	return APIWikiPage{}, false
+}
+
+type APISearcher struct {
+	client          *http.Client
+	visitedF        sync.Map
+	visitedB        sync.Map
+	found           atomic.Bool
+	result          []APIWikiNode
+	resultMu        sync.Mutex
+	reqCount        atomic.Int64
+	ctx             context.Context
+	cancel          context.CancelFunc
+	parent          context.Context // родитель ctx: запросы после встречи фронтов
+	targetLang      string
+	startLang       string
+	startWords      map[string]bool
+	targetWords     map[string]bool
+	startLower      string // названия концов в нижнем регистре для SimilarityWeight
+	targetLower     string
+	direct          bool // встреча на первом шаге: статьи связаны напрямую
+	opts            APISearchOptions
+	cache           *linkCache
+	startKey        string
+	endKey          string
+	capture         *fixture.Recorder    // nil, если снимок не нужен
+	meet            APIWikiNode          // узел, на котором встретились фронты
+	failedFetches   atomic.Int64         // сколько батчей потеряно из-за ошибок запроса
+	disambigMeets   sync.Map             // Key() → bool: страница значений ли нераскрытый узел встречи
+	largestResponse atomic.Int64         // самый большой ответ API в байтах
+	rounds          int                  // раундов основного цикла (пишет только Search)
+	peakFrontier    int                  // максимум узлов в обеих очередях на начало раунда
+	exhausted       bool                 // поиск остановлен бюджетом MaxRequests/MaxRounds
+	partial         []APIWikiNode        // при exhausted или таймауте: цепочка от start к лучшему узлу forward-фронта
+	partialTail     []APIWikiNode        // то же для backward-фронта: от его лучшего узла до end
+	maxPaths        int                  // сколько путей собрать (SearchRequest.Paths), <= 1 - один
+	meets           []APIWikiNode        // узлы встречи найденных путей, первый - s.meet (под resultMu); Via - фронт, нашедший встречу
+	meetIdx         *int                 // индекс перехода встречи, посчитанный до canonicalize
+	trace           *requestTracer       // nil, если debug выключен
+	animate         *animationRecorder   // nil, если animate выключен
+	explored        *exploredRecorder    // nil, если explored выключен
+	progress        chan<- ProgressEvent // состояние после каждого раунда, nil - не отправлять
+	OnRound         func(RoundInfo)      // вызывается после каждого раунда из горутины поиска, nil - не вызывать
+	log             *slog.Logger         // события поиска с request_id запроса
+	// HeuristicFunc - приоритет узла в очереди (меньше - раньше), по
+	// умолчанию s.heuristic; вызывается из параллельных fetch
+	HeuristicFunc func(title, lang, dir string) int
+	started       time.Time      // создание поиска, от него считается elapsed_ms
+	inflight      sync.WaitGroup // запросы к API, чьи тела ещё не закрыты (при DrainTimeout)
+	fromLang      string         // явный язык начала (from_lang), пусто - определять
+	toLang        string         // явный язык конца (to_lang)
+	warnings      []string       // предупреждения для ответа (пишет только Search)
+	missing       []error        // отсутствующие концы пути (ErrStartMissing, ErrEndMissing)
+	missingMu     sync.Mutex
+
+	// Запреты кандидатов (blocked): Blocklist с exclude, forbidden,
+	// SkipLists и DisambigSkip
+	forbidden map[string]bool               // запрещённые в этом поиске узлы, по Key()
+	blockedBy [numBlockReasons]atomic.Int64 // сколько кандидатов отсеяно, по причинам
+
+	// Лимит языков пути (SearchRequest.MaxLanguages)
+	maxLangs     int
+	langsF       sync.Map     // ключ узла -> []string: языки от start до узла
+	langsB       sync.Map     // ключ узла -> []string: языки от узла до end
+	langsDropped atomic.Int64 // сколько кандидатов и встреч отсеяно лимитом языков
+
+	// Предел глубины (MaxDepth)
+	depthF      sync.Map     // ключ узла -> int: переходов от start до узла
+	depthB      sync.Map     // ключ узла -> int: переходов от узла до end
+	depthPruned atomic.Int64 // сколько узлов не раскрыто и встреч отброшено пределом
+
+	// Shortest: длина лучшего найденного пути в переходах (под resultMu),
+	// 0 - встреч ещё не было
+	shortestHops int
+
+	// Decrease-key: узлы в очередях по ключу (пишет enqueue, читает
+	// reprioritize - оба из горутины поиска) и повторные находки раунда из fetch
+	openF, openB  map[string]*APIWikiNode
+	rediscMu      sync.Mutex
+	rediscF       []rediscovery
+	rediscB       []rediscovery
+	reprioritized atomic.Int64  // сколько узлов очереди получили лучший приоритет
+	limiterWait   atomic.Int64  // суммарное ожидание globalLimiter всеми запросами, нс
+	fetchSlots    chan struct{} // запросы поиска в работе, не больше FetchConcurrency; nil - без предела
+
+	interwikiRejected atomic.Int64 // interwiki без обратной ссылки (StrictInterwiki)
+	interwikiDropped  atomic.Int64 // interwiki в разделы не из apiWikis
+	interwikiFallback atomic.Int64 // кандидаты, найденные через такие разделы (InterwikiFallback)
+	fallbackEdges     sync.Map     // "родитель>кандидат" -> "lang:title" версии, через которую найден кандидат
+	cacheHits         atomic.Int64 // статьи этого поиска, взятые из кеша ссылок
+	cacheMisses       atomic.Int64 // статьи этого поиска, которых не было в кеше
+
+	// Мосты через категории (CategoryBridges)
+	bridges        sync.Map     // ключ узла -> категория, если родитель связан с ним категорией
+	expandedCats   sync.Map     // уже раскрытые категории
+	categoryBudget atomic.Int64 // сколько категорий ещё можно раскрыть
+
+	// Лучший приоритет на фронте в текущем раунде (для EnqueueSlack)
+	bestF, bestB       atomic.Int64
+	bestFSet, bestBSet atomic.Bool
+}
+
+// serverCtx - родитель контекстов всех поисков; stopSearches отменяет
+// его при остановке сервера, и поиски заканчиваются 503 SHUTTING_DOWN
+var serverCtx, stopSearches = context.WithCancel(context.Background())
+
+// shutdownTimeout - сколько текущие поиски могут доигрывать после
+// SIGINT/SIGTERM (WIKI_SHUTDOWN_TIMEOUT_MS). shutdownGrace - сколько ещё
+// ждать после их отмены, чтобы ответы 503 успели уйти клиентам.
+var shutdownTimeout = 10 * time.Second
+
+const shutdownGrace = 2 * time.Second
+
+// disconnectPoll - как часто поиск проверяет, не ушёл ли клиент
+const disconnectPoll = 200 * time.Millisecond
+
+// requestContext - родитель поисков запроса c: отменяется при остановке
+// сервера и когда клиент закрыл соединение, не дождавшись ответа, и
+// несёт ID запроса для логов поиска. cancel обязателен (обычно defer).
+func requestContext(c *fiber.Ctx) (context.Context, context.CancelFunc) {
+	return peer.Watch(withRequestID(serverCtx, requestID(c)), c.Context().Conn(), disconnectPoll)
+}
+
+// searchLog - события поисков JSON-строками в stdout (search start,
+// search round, search done и предупреждения поиска), уровень -
+// WIKI_LOG_LEVEL. Текстовый журнал доступа Fiber пишется в stderr, чтобы
+// не смешиваться с JSON; связывает их request_id.
+var (
+	searchLogLevel slog.LevelVar
+	searchLog      = slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: &searchLogLevel}))
+)
+
+// requestIDKey - ключ ID запроса в контексте поиска
+type requestIDKey struct{}
+
+// requestID - ID запроса c: X-Request-ID клиента или сгенерированный
+// middleware requestid; тот же ID уходит в заголовок ответа
+func requestID(c *fiber.Ctx) string {
+	id, _ := c.Locals("requestid").(string)
+	return id
+}
+
+// withRequestID кладёт ID запроса в контекст поиска; "" - без ID
+func withRequestID(ctx context.Context, id string) context.Context {
+	if id == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// requestIDFrom - ID запроса из контекста поиска, "" - не задан
+func requestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// Бюджет поиска: по умолчанию и верхняя граница для timeout_ms запроса
+const (
+	defaultSearchTimeout = 10 * time.Second
+	maxSearchTimeout     = 60 * time.Second
+)
+
+// withTimeout возвращает opts с бюджетом поиска из timeout_ms запроса,
+// урезанным до maxSearchTimeout. ms <= 0 - бюджет из настроек.
+func withTimeout(opts APISearchOptions, ms int) APISearchOptions {
+	if ms <= 0 {
+		return opts
+	}
+	opts.Timeout = time.Duration(ms) * time.Millisecond
+	if opts.Timeout > maxSearchTimeout {
+		opts.Timeout = maxSearchTimeout
+	}
+	return opts
+}
+
+// NewAPISearcher готовит поиск с бюджетом opts.Timeout; отмена parent
+// (обычно requestContext или serverCtx) прерывает его раньше.
+func NewAPISearcher(parent context.Context, startLang, startTitle, targetLang, targetTitle string, opts APISearchOptions) *APISearcher {
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = defaultSearchTimeout
+	}
+	ctx, cancel := context.WithTimeout(parent, timeout)
+
+	startWords := tokenize.Set(stripNamespaceAPI(startTitle))
+	targetWords := tokenize.Set(stripNamespaceAPI(targetTitle))
+
+	s := &APISearcher{
+		client:      globalHTTPClient,
+		ctx:         ctx,
+		cancel:      cancel,
+		parent:      parent,
+		startLang:   startLang,
+		startWords:  startWords,
+		targetLang:  targetLang,
+		targetWords: targetWords,
+		startLower:  strings.ToLower(startTitle),
+		targetLower: strings.ToLower(targetTitle),
+		opts:        opts,
+		cache:       globalLinkCache,
+		started:     time.Now(),
+		log:         searchLog,
+	}
+	if id := requestIDFrom(parent); id != "" {
+		s.log = s.log.With("request_id", id)
+	}
+	if len(opts.Namespaces) > 0 {
+		s.cache = nil
+	}
+	s.HeuristicFunc = s.heuristic
+	s.categoryBudget.Store(int64(opts.CategoryBudget))
+	if opts.FetchConcurrency > 0 {
+		s.fetchSlots = make(chan struct{}, opts.FetchConcurrency)
+	}
+	return s
+}
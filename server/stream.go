@@ -0,0 +1,286 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/contrib/websocket"
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+
+	"wikiracer/metrics"
+	"wikiracer/store"
+)
+
+// SearchStream godoc
+// @Summary Двухфазный поиск с потоковой выдачей (SSE)
+// @Description Пока идёт поиск - событие progress после каждого раунда (ProgressEvent). Фаза 1: событие path с первым найденным путём. Фаза 2 (optimize=true): поиск shortest между найденными концами, событие optimized, если путь короче. В конце - событие done.
+// @Tags search
+// @Produce text/event-stream
+// @Param from query string true "Начальная статья" example(Кошка)
+// @Param to query string true "Конечная статья" example(Теория относительности)
+// @Param lang query string false "Язык по умолчанию" example(ru)
+// @Param optimize query bool false "После первого пути искать более короткий"
+// @Param timeout_ms query int false "Бюджет поиска в мс, до 60000" example(20000)
+// @Param mode query string false "Эвристика: default или monolingual - путь без смены языка" Enums(default, monolingual)
+// @Success 200 {object} SearchResponse
+// @Failure 400 {object} ErrorResponse
+// @Router /search/stream [get]
+func SearchStream(c *fiber.Ctx) error {
+	req := SearchRequest{
+		From:      normalizeTitleAPI(c.Query("from")),
+		To:        normalizeTitleAPI(c.Query("to")),
+		Lang:      c.Query("lang", defaultLang),
+		TimeoutMs: c.QueryInt("timeout_ms"),
+		Mode:      c.Query("mode"),
+		CrossLang: queryCrossLang(c),
+	}
+	optimize := c.QueryBool("optimize")
+
+	if req.From == "" || req.To == "" {
+		return c.Status(400).JSON(ErrorResponse{
+			Success: false,
+			Error:   "Необходимо указать параметры 'from' и 'to'",
+			Code:    "MISSING_PARAMS",
+		})
+	}
+	// Те же проверки, что у /search: неизвестный язык или mode - 400 до
+	// начала потока, а не ошибка посреди него
+	if resp := validateSearch(&req); resp != nil {
+		return c.Status(400).JSON(resp)
+	}
+
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderConnection, "keep-alive")
+
+	// c недоступен в StreamWriter - ID запроса берём заранее
+	parent := withRequestID(serverCtx, requestID(c))
+	c.Context().SetBodyStreamWriter(fasthttp.StreamWriter(func(w *bufio.Writer) {
+		metrics.InFlight.Inc()
+		defer metrics.InFlight.Dec()
+		t0 := time.Now()
+		s := NewAPISearcher(parent, req.Lang, req.From, req.Lang, req.To, withCrossLang(withTimeout(defaultAPIOptions, req.TimeoutMs), req))
+		s.setMode(req.Mode)
+		s.fromLang, s.toLang = req.FromLang, req.ToLang
+
+		// Ход поиска - события progress после каждого раунда
+		events := make(chan ProgressEvent, 16)
+		s.progress = events
+		done := make(chan []APIWikiNode, 1)
+		var searchErr error // пишется до отправки в done
+		go func() {
+			path, err := s.Search(req.From, req.To, req.Lang)
+			searchErr = err
+			done <- path
+		}()
+
+		var path []APIWikiNode
+		gone := false
+	progress:
+		for {
+			select {
+			case p := <-events:
+				if !gone && writeEvent(w, "progress", p) != nil {
+					// Клиент отключился - поиск больше не нужен
+					gone = true
+					s.cancel()
+				}
+			case path = <-done:
+				// События последних раундов могли остаться в буфере
+				for len(events) > 0 && !gone {
+					gone = writeEvent(w, "progress", <-events) != nil
+				}
+				break progress
+			}
+		}
+		if gone {
+			s.persist(req, path, time.Since(t0), store.OutcomeCancelled)
+			return
+		}
+
+		if len(path) == 0 {
+			_, resp, outcome := s.failure(searchErr)
+			s.persist(req, path, time.Since(t0), outcome)
+			writeEvent(w, "error", resp)
+			return
+		}
+		s.persist(req, path, time.Since(t0), store.OutcomeFound)
+		if err := writeEvent(w, "path", s.response(req, path, time.Since(t0))); err != nil {
+			return // клиент отключился
+		}
+
+		optimized := false
+		if optimize {
+			// Вторая фаза - поиск shortest между уже найденными концами:
+			// ссылки первой фазы в кеше, так что запросов к API почти нет
+			second := req
+			second.From, second.FromLang = path[0].Title, path[0].Lang
+			second.To, second.ToLang = path[len(path)-1].Title, path[len(path)-1].Lang
+			second.Lang = path[0].Lang
+			second.Shortest = true
+			r := searchOnce(parent, second)
+			if r.resp != nil && r.status == fiber.StatusOK && len(r.resp.Path) < len(path) {
+				if writeEvent(w, "optimized", r.resp) != nil {
+					return
+				}
+				optimized = true
+			}
+		}
+		writeEvent(w, "done", fiber.Map{"optimized": optimized})
+	}))
+	return nil
+}
+
+// WSFrame - кадр /ws/search: round после каждого раунда, в конце
+// result с ответом как у /search или error
+type WSFrame struct {
+	Type   string          `json:"type" example:"round"` // round, result или error
+	Round  *RoundInfo      `json:"round,omitempty"`
+	Result *SearchResponse `json:"result,omitempty"`
+	Error  *ErrorResponse  `json:"error,omitempty"`
+}
+
+// wsSearchKey - ключ Locals, под которым SearchWebSocket передаёт
+// проверенный запрос в wsUpgrade
+const wsSearchKey = "ws_search"
+
+// wsCloseWait - сколько ждать ответного close от клиента
+const wsCloseWait = time.Second
+
+// wsJob - проверенный запрос /ws/search и контекст, от которого идёт поиск
+type wsJob struct {
+	parent context.Context
+	req    SearchRequest
+}
+
+// SearchWebSocket godoc
+// @Summary Поиск с покадровыми данными фронтов (WebSocket)
+// @Description Апгрейд до WebSocket. Сервер шлёт JSON-кадры WSFrame: round после каждого раунда (раскрытые узлы обоих фронтов, размеры посещённых множеств, узел встречи), в конце - result с ответом как у /search или error, затем закрывает соединение. Сообщения клиента не читаются; закрытие соединения клиентом отменяет поиск.
+// @Tags search
+// @Param from query string true "Начальная статья" example(Кошка)
+// @Param to query string true "Конечная статья" example(Теория относительности)
+// @Param lang query string false "Язык по умолчанию" example(ru)
+// @Param timeout_ms query int false "Бюджет поиска в мс, до 60000" example(20000)
+// @Param mode query string false "Эвристика: default или monolingual - путь без смены языка" Enums(default, monolingual)
+// @Success 101 {object} WSFrame
+// @Failure 400 {object} ErrorResponse
+// @Failure 426 {object} ErrorResponse
+// @Router /ws/search [get]
+func SearchWebSocket(c *fiber.Ctx) error {
+	req := SearchRequest{
+		From:      normalizeTitleAPI(c.Query("from")),
+		To:        normalizeTitleAPI(c.Query("to")),
+		Lang:      c.Query("lang", defaultLang),
+		TimeoutMs: c.QueryInt("timeout_ms"),
+		Mode:      c.Query("mode"),
+		CrossLang: queryCrossLang(c),
+	}
+	if req.From == "" || req.To == "" {
+		return c.Status(400).JSON(ErrorResponse{
+			Success: false,
+			Error:   "Необходимо указать параметры 'from' и 'to'",
+			Code:    "MISSING_PARAMS",
+		})
+	}
+	// Проверки /search - до апгрейда: после него ответить 400 уже нельзя
+	if resp := validateSearch(&req); resp != nil {
+		return c.Status(400).JSON(resp)
+	}
+
+	if !websocket.IsWebSocketUpgrade(c) {
+		return c.Status(fiber.StatusUpgradeRequired).JSON(ErrorResponse{
+			Success: false,
+			Error:   "Нужен WebSocket (Upgrade: websocket, Sec-WebSocket-Version: 13)",
+			Code:    "WEBSOCKET_REQUIRED",
+		})
+	}
+
+	c.Locals(wsSearchKey, wsJob{parent: withRequestID(serverCtx, requestID(c)), req: req})
+	return wsUpgrade(c)
+}
+
+// wsUpgrade переводит соединение на WebSocket и ведёт по нему поиск
+var wsUpgrade = websocket.New(func(conn *websocket.Conn) {
+	job := conn.Locals(wsSearchKey).(wsJob)
+	wsSearch(job.parent, conn, job.req)
+})
+
+// wsSearch ведёт поиск по уже открытому WebSocket
+func wsSearch(parent context.Context, conn *websocket.Conn, req SearchRequest) {
+	metrics.InFlight.Inc()
+	defer metrics.InFlight.Dec()
+	t0 := time.Now()
+	s := NewAPISearcher(parent, req.Lang, req.From, req.Lang, req.To, withCrossLang(withTimeout(defaultAPIOptions, req.TimeoutMs), req))
+	s.setMode(req.Mode)
+	s.fromLang, s.toLang = req.FromLang, req.ToLang
+
+	// Сообщения клиента не нужны: читаем до close или обрыва. Закрытое
+	// клиентом соединение - поиск больше не нужен
+	gone := make(chan struct{})
+	go func() {
+		defer close(gone)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				s.cancel()
+				return
+			}
+		}
+	}()
+	// Закрытие по RFC 6455: close с кодом 1000 и ответный close от клиента.
+	// Читающая горутина должна выйти до возврата: после него conn уходит в пул
+	defer func() {
+		deadline := time.Now().Add(wsCloseWait)
+		conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), deadline)
+		conn.SetReadDeadline(deadline)
+		<-gone
+	}()
+
+	// OnRound и итоговый кадр пишутся из одной горутины: Search синхронен
+	s.OnRound = wsRounds(s, conn)
+	path, err := s.Search(req.From, req.To, req.Lang)
+
+	select {
+	case <-gone:
+		s.persist(req, path, time.Since(t0), store.OutcomeCancelled)
+		return
+	default:
+	}
+	if len(path) == 0 {
+		_, resp, outcome := s.failure(err)
+		s.persist(req, path, time.Since(t0), outcome)
+		conn.WriteJSON(WSFrame{Type: "error", Error: &resp})
+	} else {
+		s.persist(req, path, time.Since(t0), store.OutcomeFound)
+		resp := s.response(req, path, time.Since(t0))
+		conn.WriteJSON(WSFrame{Type: "result", Result: &resp})
+	}
+}
+
+// wsFrameWriter - запись JSON-кадров в WebSocket (*websocket.Conn)
+type wsFrameWriter interface {
+	WriteJSON(v interface{}) error
+}
+
+// wsRounds - OnRound для /ws/search: кадр round после каждого раунда;
+// не удалось отправить - клиента нет, поиск отменяется
+func wsRounds(s *APISearcher, w wsFrameWriter) func(RoundInfo) {
+	return func(r RoundInfo) {
+		if w.WriteJSON(WSFrame{Type: "round", Round: &r}) != nil {
+			s.cancel()
+		}
+	}
+}
+
+// writeEvent пишет одно SSE-событие с JSON-данными и сразу отправляет его
+func writeEvent(w *bufio.Writer, event string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+	return w.Flush()
+}
@@ -0,0 +1,239 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	fastws "github.com/fasthttp/websocket"
+	"github.com/gofiber/fiber/v2"
+)
+
+// frameRecorder - wsFrameWriter, который запоминает кадры; err - ошибка записи
+type frameRecorder struct {
+	frames []WSFrame
+	err    error
+}
+
+func (f *frameRecorder) WriteJSON(v interface{}) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.frames = append(f.frames, v.(WSFrame))
+	return nil
+}
+
+func TestWSRounds(t *testing.T) {
+	s := newTestSearcher(t, defaultAPIOptions)
+	rec := &frameRecorder{}
+	s.OnRound = wsRounds(s, rec)
+
+	meet := "en:Apple"
+	s.OnRound(RoundInfo{Round: 1, PoppedF: []string{"en:Start"}, PoppedB: []string{"en:Target"}, VisitedF: 4, VisitedB: 3})
+	s.OnRound(RoundInfo{Round: 2, VisitedF: 9, VisitedB: 7, Meet: &meet})
+	if len(rec.frames) != 2 {
+		t.Fatalf("кадров %d, want 2", len(rec.frames))
+	}
+	for i, f := range rec.frames {
+		if f.Type != "round" || f.Round == nil || f.Round.Round != i+1 || f.Result != nil || f.Error != nil {
+			t.Errorf("кадр %d = %+v", i, f)
+		}
+	}
+	// Кадр - копия: следующий раунд не меняет уже отправленный
+	if r := rec.frames[0].Round; r.VisitedF != 4 || r.PoppedF[0] != "en:Start" || r.Meet != nil {
+		t.Errorf("первый раунд = %+v", r)
+	}
+	if r := rec.frames[1].Round; r.Meet == nil || *r.Meet != meet {
+		t.Errorf("встреча во втором раунде = %v", r.Meet)
+	}
+	if s.ctx.Err() != nil {
+		t.Fatal("поиск отменён при успешной записи")
+	}
+
+	// Клиент пропал - поиск отменяется
+	rec.err = errors.New("broken pipe")
+	s.OnRound(RoundInfo{Round: 3})
+	if !errors.Is(s.ctx.Err(), context.Canceled) {
+		t.Errorf("ctx.Err() = %v, want context.Canceled", s.ctx.Err())
+	}
+}
+
+// sseEvent - событие text/event-stream: имя и JSON из data
+type sseEvent struct {
+	name string
+	data json.RawMessage
+}
+
+// readEvents разбирает поток SSE на события
+func readEvents(t *testing.T, body io.Reader) []sseEvent {
+	t.Helper()
+	raw, err := io.ReadAll(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var events []sseEvent
+	for _, block := range strings.Split(strings.TrimSpace(string(raw)), "\n\n") {
+		var e sseEvent
+		for _, line := range strings.Split(block, "\n") {
+			if v, ok := strings.CutPrefix(line, "event: "); ok {
+				e.name = v
+			} else if v, ok := strings.CutPrefix(line, "data: "); ok {
+				e.data = json.RawMessage(v)
+			}
+		}
+		events = append(events, e)
+	}
+	return events
+}
+
+func TestSearchStream(t *testing.T) {
+	withFakeWiki(t, (&graphWiki{links: chainWiki}).ServeHTTP, "en")
+	app := NewApp()
+
+	// Ошибки запроса - 400 до начала потока
+	for query, code := range map[string]string{
+		"from=Start&to=Target&lang=xx":           "UNKNOWN_LANG",
+		"from=Start&to=Target&lang=en&mode=fast": "INVALID_MODE",
+		"from=Start&lang=en":                     "MISSING_PARAMS",
+	} {
+		resp, err := app.Test(httptest.NewRequest("GET", "/api/v1/search/stream?"+query, nil), 5000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var data ErrorResponse
+		json.NewDecoder(resp.Body).Decode(&data)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest || data.Code != code {
+			t.Errorf("%s: %d %s, want 400 %s", query, resp.StatusCode, data.Code, code)
+		}
+	}
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/api/v1/search/stream?from=Source&to=Sink&lang=en", nil), 5000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get(fiber.HeaderContentType); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+
+	// progress после каждого раунда, затем path и done
+	events := readEvents(t, resp.Body)
+	var names []string
+	for _, e := range events {
+		names = append(names, e.name)
+	}
+	if len(events) < 3 || names[0] != "progress" || names[len(names)-2] != "path" || names[len(names)-1] != "done" {
+		t.Fatalf("события %v", names)
+	}
+	for _, name := range names[:len(names)-2] {
+		if name != "progress" {
+			t.Errorf("событие %q до path, want progress: %v", name, names)
+		}
+	}
+	var found SearchResponse
+	if err := json.Unmarshal(events[len(events)-2].data, &found); err != nil {
+		t.Fatal(err)
+	}
+	if got := pathTitles(found); got != chainPath {
+		t.Errorf("путь %q", got)
+	}
+}
+
+func TestSearchStreamOptimize(t *testing.T) {
+	withFakeWiki(t, detourWiki().ServeHTTP, "en")
+	app := NewApp()
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/api/v1/search/stream?from=Start&to=Target&lang=en&optimize=true", nil), 10000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	// Вторая фаза - поиск shortest: path с обходом, затем optimized с коротким
+	got := map[string]string{}
+	var done struct{ Optimized bool }
+	for _, e := range readEvents(t, resp.Body) {
+		switch e.name {
+		case "path", "optimized":
+			var data SearchResponse
+			json.Unmarshal(e.data, &data)
+			got[e.name] = pathTitles(data)
+		case "done":
+			json.Unmarshal(e.data, &done)
+		}
+	}
+	want := map[string]string{
+		"path":      "Start Target route Junction Start route Target",
+		"optimized": "Start Zz a Zz b Target",
+	}
+	if !reflect.DeepEqual(got, want) || !done.Optimized {
+		t.Errorf("события %v, optimized=%v, want %v", got, done.Optimized, want)
+	}
+}
+
+func TestSearchWebSocketEndpoint(t *testing.T) {
+	withFakeWiki(t, (&graphWiki{links: chainWiki}).ServeHTTP, "en")
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := NewApp()
+	go app.Listener(ln)
+	t.Cleanup(func() { app.Shutdown() })
+	base := "ws://" + ln.Addr().String() + "/api/v1/ws/search?"
+
+	// Ошибка запроса - 400 вместо апгрейда
+	conn, resp, err := fastws.DefaultDialer.Dial(base+"from=Start&to=Target&lang=xx", nil)
+	if err == nil {
+		conn.Close()
+		t.Fatal("апгрейд с неизвестным языком")
+	}
+	if resp == nil || resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("ответ на неизвестный язык: %v, %v", resp, err)
+	}
+	resp.Body.Close()
+
+	conn, _, err = fastws.DefaultDialer.Dial(base+"from=Source&to=Sink&lang=en", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	// Кадры round, затем result; сервер закрывает соединение кодом 1000
+	var frames []WSFrame
+	for {
+		var frame WSFrame
+		if err = conn.ReadJSON(&frame); err != nil {
+			break
+		}
+		frames = append(frames, frame)
+	}
+	if !fastws.IsCloseError(err, fastws.CloseNormalClosure) {
+		t.Errorf("соединение закрыто с %v, want close 1000", err)
+	}
+	if len(frames) < 3 {
+		t.Fatalf("кадров %d", len(frames))
+	}
+	for i, frame := range frames[:len(frames)-1] {
+		if frame.Type != "round" || frame.Round == nil || frame.Round.Round != i+1 {
+			t.Errorf("кадр %d: %+v, want round %d", i, frame, i+1)
+		}
+	}
+	last := frames[len(frames)-1]
+	if last.Type != "result" || last.Result == nil {
+		t.Fatalf("последний кадр %+v, want result", last)
+	}
+	if got := pathTitles(*last.Result); got != chainPath {
+		t.Errorf("путь %q", got)
+	}
+}
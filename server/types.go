@@ -0,0 +1,302 @@
+package server
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"wikiracer/fixture"
+	"wikiracer/verify"
+)
+
+// SearchRequest - запрос на поиск пути
+type SearchRequest struct {
+	From     string `json:"from" example:"Кошка" validate:"required"`
+	To       string `json:"to" example:"Теория относительности" validate:"required"`
+	Lang     string `json:"lang,omitempty" example:"ru"`
+	Format   string `json:"format,omitempty" example:"json"`
+	Wikidata bool   `json:"wikidata,omitempty" example:"false"`
+	Capture  bool   `json:"capture,omitempty" example:"false"`
+	Summary  bool   `json:"summary,omitempty" example:"false"`
+	// Forbidden - статьи, через которые путь проходить не может
+	// (только для этого поиска, в отличие от WIKI_BLOCKLIST_FILE):
+	// "Название" - в разделе lang, "lang:Название" - в другом
+	Forbidden []string `json:"forbidden,omitempty" example:"Млекопитающие"`
+	// Exclude - регулярки по названиям, как в WIKI_BLOCKLIST_FILE, только
+	// для этого поиска: даты, списки и прочие неинтересные хабы
+	Exclude []string `json:"exclude,omitempty" example:"^[0-9]+$,^Список"`
+	// ExcludeDisambig - не проводить путь через страницы значений
+	// (как WIKI_DISAMBIG_STRATEGY=skip для этого поиска)
+	ExcludeDisambig bool `json:"exclude_disambig,omitempty" example:"false"`
+	// Categories - разрешить шаги через общую категорию (экспериментально)
+	Categories bool `json:"categories,omitempty" example:"false"`
+	// Prose - проверить, что ссылки найденного пути стоят в тексте статьи,
+	// а не только в навигационных шаблонах (на сам поиск не влияет)
+	Prose bool `json:"prose,omitempty" example:"false"`
+	// Paths - сколько путей собрать (до 5); встречи фронтов в последнем
+	// раунде дают разные пути
+	Paths int `json:"paths,omitempty" example:"3"`
+	// RankBy - как упорядочить пути: shortest, interwiki или prominence
+	RankBy string `json:"rank_by,omitempty" example:"shortest"`
+	// Debug - вернуть статус, размер и время каждого запроса к API
+	Debug bool `json:"debug,omitempty" example:"false"`
+	// Canonical - заменить названия статей пути на цели редиректов,
+	// чтобы каждая ссылка вела на статью, а не на редирект
+	Canonical bool `json:"canonical,omitempty" example:"false"`
+	// MaxLanguages - сколько разных языковых разделов может пройти путь,
+	// 0 - без ограничения
+	MaxLanguages int `json:"max_languages,omitempty" example:"2"`
+	// MaxDepth - предел длины пути в переходах, 0 - WIKI_MAX_DEPTH
+	MaxDepth int `json:"max_depth,omitempty" example:"6"`
+	// Shortest - искать путь с наименьшим числом переходов (в ширину),
+	// а не первый найденный эвристикой
+	Shortest bool `json:"shortest,omitempty" example:"false"`
+	// Namespaces - пространства имён, через которые может идти путь:
+	// 0 - статьи, 14 - категории, 100 - порталы (номера зависят от раздела)
+	Namespaces []int `json:"namespaces,omitempty" example:"0,14"`
+	// FromLang и ToLang - явный язык концов вместо определения по названию
+	FromLang string `json:"from_lang,omitempty" example:"de"`
+	ToLang   string `json:"to_lang,omitempty" example:"en"`
+	// Animate - вернуть журнал поиска для покадровой анимации
+	Animate bool `json:"animate,omitempty" example:"false"`
+	// Explored - вернуть дерево поиска: рёбра родитель → статья, по
+	// которым фронты дошли до каждой посещённой статьи
+	Explored bool `json:"explored,omitempty" example:"false"`
+	// VerifyMeet - проверить запросом к API ребро встречи фронтов
+	VerifyMeet bool `json:"verify_meet,omitempty" example:"false"`
+	// WithContext - найти для каждой ссылки пути предложение и раздел,
+	// где она стоит (по запросу action=parse на статью)
+	WithContext bool `json:"with_context,omitempty" example:"false"`
+	// Mode - эвристика поиска: default или monolingual (путь по
+	// возможности без смены языка)
+	Mode string `json:"mode,omitempty" example:"default"`
+	// Verify - проверить каждую ссылку пути запросом prop=links и
+	// исправить direction там, где ссылка на самом деле обратная
+	Verify bool `json:"verify,omitempty" example:"false"`
+	// TimeoutMs - бюджет поиска в миллисекундах (до 60000), 0 - из настроек
+	TimeoutMs int `json:"timeout_ms,omitempty" example:"20000"`
+	// CrossLang - false: путь целиком в одном разделе, interwiki не
+	// раскрываются, концы ищутся в from_lang/to_lang или lang. nil - true
+	CrossLang *bool `json:"cross_lang,omitempty" example:"false"`
+}
+
+// sameEdition - путь должен остаться в одном разделе (cross_lang=false)
+func (r SearchRequest) sameEdition() bool {
+	return r.CrossLang != nil && !*r.CrossLang
+}
+
+// withCrossLang возвращает opts без раскрытия interwiki для запроса с
+// cross_lang=false; ответ API не меняется, так что кеш остаётся полным
+func withCrossLang(opts APISearchOptions, req SearchRequest) APISearchOptions {
+	if req.sameEdition() {
+		opts.LangLinksForward = false
+		opts.LangLinksBackward = false
+	}
+	return opts
+}
+
+// queryCrossLang читает cross_lang из query; нет параметра - nil
+func queryCrossLang(c *fiber.Ctx) *bool {
+	if c.Query("cross_lang") == "" {
+		return nil
+	}
+	v := c.QueryBool("cross_lang")
+	return &v
+}
+
+// BatchRequest - несколько поисков одним запросом (POST /search/batch)
+type BatchRequest struct {
+	// Pairs - поиски; у каждого те же поля, что у POST /search, format
+	// всегда json
+	Pairs []SearchRequest `json:"pairs"`
+}
+
+// SearchCompareRequest - одна пара статей и две конфигурации поиска для
+// A/B-сравнения эвристик
+type SearchCompareRequest struct {
+	From string `json:"from" example:"Кошка" validate:"required"`
+	To   string `json:"to" example:"Теория относительности" validate:"required"`
+	Lang string `json:"lang,omitempty" example:"ru"`
+	// A и B - поля POST /search для каждой конфигурации, from, to и lang
+	// общие. Без a - настройки по умолчанию, без b - mode=monolingual
+	A *SearchRequest `json:"a,omitempty"`
+	B *SearchRequest `json:"b,omitempty"`
+}
+
+// SearchCompareResponse - результаты обеих конфигураций и разница между ними
+type SearchCompareResponse struct {
+	Success bool   `json:"success" example:"true"`
+	From    string `json:"from" example:"Кошка"`
+	To      string `json:"to" example:"Теория относительности"`
+	// A и B - SearchResponse, если путь найден (в том числе частичный),
+	// иначе ErrorResponse; различаются по success
+	A interface{} `json:"a"`
+	B interface{} `json:"b"`
+	// Diff - nil, если хотя бы одна конфигурация не вернула путь
+	Diff *SearchDiff `json:"diff"`
+}
+
+// SearchDiff - B минус A: отрицательные значения - B лучше
+type SearchDiff struct {
+	PathLength   int     `json:"path_length" example:"-1"`
+	RequestCount int64   `json:"request_count" example:"12"`
+	DurationMs   float64 `json:"duration_ms" example:"-140.5"`
+	SamePath     bool    `json:"same_path" example:"false"`
+}
+
+// PathStep - один шаг в пути
+type PathStep struct {
+	Step       int     `json:"step" example:"1"`
+	Title      string  `json:"title" example:"Кошка"`
+	Lang       string  `json:"lang" example:"ru"`
+	URL        string  `json:"url" example:"https://ru.wikipedia.org/wiki/Кошка"`
+	FullName   string  `json:"full_name" example:"ru:Кошка"`
+	WikidataID *string `json:"wikidata_id" example:"Q146"`
+	// Direction - какой фронт дошёл до статьи: forward - от from,
+	// backward - от to, meet - статья, где фронты встретились
+	Direction string `json:"direction" example:"forward"`
+}
+
+// Transition - переход между статьями
+type Transition struct {
+	From        string `json:"from" example:"Кошка"`
+	To          string `json:"to" example:"Квантовая механика"`
+	Type        string `json:"type" example:"link"`
+	Direction   string `json:"direction" example:"forward"`
+	Description string `json:"description" example:"Ссылка через 'кот Шрёдингера'"`
+	CheckURL    string `json:"check_url" example:"https://ru.wikipedia.org/wiki/Кошка"`
+	// Prose - ссылка есть в тексте статьи (prose=true); nil - не проверялась
+	// или проверить не удалось, interwiki и категории не проверяются
+	Prose *bool `json:"prose,omitempty" example:"true"`
+	// Context - где в статье стоит ссылка (with_context=true); nil - не
+	// запрашивалось, ссылка не нашлась в вики-тексте или это не ссылка
+	Context *verify.LinkContext `json:"context,omitempty"`
+	// Verified - в статье from есть ссылка на to (verify=true); false -
+	// ссылка обратная, direction исправлен. nil - не проверялась
+	Verified *bool `json:"verified,omitempty" example:"true"`
+}
+
+// SearchResponse - ответ с найденным путём
+type SearchResponse struct {
+	Success     bool         `json:"success" example:"true"`
+	From        string       `json:"from" example:"Кошка"`
+	To          string       `json:"to" example:"Теория относительности"`
+	PathLength  int          `json:"path_length" example:"3"`
+	Direct      bool         `json:"direct" example:"false"`
+	Path        []PathStep   `json:"path"`
+	Transitions []Transition `json:"transitions"`
+	Stats       SearchStats  `json:"stats"`
+	// Capture - снимок графа ссылок для офлайн-воспроизведения (capture=true)
+	Capture *fixture.Capture `json:"capture,omitempty"`
+	// Connection - что связывает статьи (summary=true)
+	Connection *verify.Connection `json:"connection,omitempty"`
+	// Difficulty - сложность пары от 1 до 10, см. difficulty
+	Difficulty int `json:"difficulty" example:"4"`
+	// Partial - бюджет или время исчерпаны, path - лучшая догадка, до цели
+	// не доходит (HTTP 206)
+	Partial bool `json:"partial,omitempty" example:"false"`
+	// Complete - path доходит до to; false вместе с partial
+	Complete bool `json:"complete" example:"true"`
+	// Tail - при partial: цепочка backward-фронта от его лучшей статьи до to
+	Tail []PathStep `json:"tail,omitempty"`
+	// Gap - при partial: разрыв между концом path и началом tail (или to),
+	// который осталось пройти вручную
+	Gap *PathGap `json:"gap,omitempty"`
+	// ProseOnly - все проверенные ссылки пути стоят в тексте статей (prose=true)
+	ProseOnly *bool `json:"prose_only,omitempty" example:"true"`
+	// Paths - все найденные пути по rank_by, лучший - он же path (paths > 1)
+	Paths [][]PathStep `json:"paths,omitempty"`
+	// Debug - запросы к API: статус, размер, время (debug=true)
+	Debug *DebugInfo `json:"debug,omitempty"`
+	// MeetTransition - переход, на котором встретились фронты
+	MeetTransition *MeetTransition `json:"meet_transition,omitempty"`
+	// Warnings - что пошло не так, как просили, но поиск это пережил
+	// (например, статьи нет в from_lang и язык определён заново)
+	Warnings []string `json:"warnings,omitempty"`
+	// Animation - журнал раскрытий и встречи (animate=true)
+	Animation *Animation `json:"animation,omitempty"`
+	// Explored - дерево поиска обоих фронтов (explored=true)
+	Explored *Explored `json:"explored,omitempty"`
+	// ForwardHops и BackwardHops - сколько переходов пути нашёл каждый
+	// фронт: от from до статьи встречи и от неё до to
+	ForwardHops  int `json:"forward_hops" example:"2"`
+	BackwardHops int `json:"backward_hops" example:"1"`
+}
+
+// PathGap - непройденный участок частичного пути
+type PathGap struct {
+	From     string `json:"from" example:"Млекопитающие"`
+	FromLang string `json:"from_lang" example:"ru"`
+	To       string `json:"to" example:"Физика"`
+	ToLang   string `json:"to_lang" example:"ru"`
+}
+
+// MeetTransition - ребро, на котором сошлись forward- и backward-данные.
+// Его чаще всего "не находят" при проходе пути вручную.
+type MeetTransition struct {
+	Index     int    `json:"index" example:"1"` // индекс в transitions
+	Type      string `json:"type" example:"link"`
+	Direction string `json:"direction" example:"backward"`
+	// Verified - что показала проверка (verify_meet=true): forward_link -
+	// ссылка есть в статье from, backlink - только в статье to на from,
+	// langlink - interwiki, category - шаг через категорию, unconfirmed -
+	// ничего не нашлось
+	Verified string `json:"verified,omitempty" example:"forward_link"`
+}
+
+// SearchStats - статистика поиска
+type SearchStats struct {
+	Duration             string  `json:"duration" example:"823.45ms"`
+	DurationMs           float64 `json:"duration_ms" example:"823.45"`
+	RequestCount         int64   `json:"request_count" example:"2"`
+	BlockedNodes         int64   `json:"blocked_nodes" example:"0"`
+	FailedFetches        int64   `json:"failed_fetches" example:"0"`
+	Degraded             bool    `json:"degraded" example:"false"`
+	Rounds               int     `json:"rounds" example:"1"`
+	PeakFrontier         int     `json:"peak_frontier" example:"480"`
+	LargestResponseBytes int64   `json:"largest_response_bytes" example:"48213"`
+	InterwikiRejected    int64   `json:"interwiki_rejected" example:"0"` // interwiki без обратной ссылки (WIKI_STRICT_INTERWIKI)
+	InterwikiDropped     int64   `json:"interwiki_dropped" example:"3"`  // interwiki в разделы не из WIKI_LANGS
+	InterwikiFallback    int64   `json:"interwiki_fallback" example:"0"` // кандидаты через версии в таких разделах (WIKI_INTERWIKI_FALLBACK)
+	CacheHits            int64   `json:"cache_hits" example:"12"`        // статьи, взятые из кеша ссылок
+	CacheMisses          int64   `json:"cache_misses" example:"140"`     // статьи, запрошенные у API
+	DepthPruned          int64   `json:"depth_pruned" example:"0"`       // узлы и встречи за пределом MaxDepth
+	Reprioritized        int64   `json:"reprioritized" example:"37"`     // узлы очереди, найденные снова с лучшим приоритетом
+	// BlockedBy - blocked_nodes по причинам: blocklist (WIKI_BLOCKLIST_FILE
+	// и exclude), forbidden, list (WIKI_SKIP_LISTS), disambig (WIKI_DISAMBIG_STRATEGY=skip)
+	BlockedBy map[string]int64 `json:"blocked_by,omitempty"`
+	// LimiterWaitMs - сколько запросы поиска в сумме ждали общего лимита
+	// WIKI_MAX_INFLIGHT/WIKI_MAX_RPS (параллельные запросы ждут одновременно,
+	// так что сумма может быть больше duration_ms)
+	LimiterWaitMs float64 `json:"limiter_wait_ms" example:"0"`
+	// NodesVisitedForward и NodesVisitedBackward - сколько статей посетил
+	// каждый фронт, вместе с from и to: размер исследованного графа
+	NodesVisitedForward  int `json:"nodes_visited_forward" example:"1840"`
+	NodesVisitedBackward int `json:"nodes_visited_backward" example:"920"`
+}
+
+// ErrorResponse - ответ с ошибкой
+type ErrorResponse struct {
+	Success bool   `json:"success" example:"false"`
+	Error   string `json:"error" example:"Путь не найден"`
+	Code    string `json:"code" example:"PATH_NOT_FOUND"`
+	// Debug - запросы к API неудачного поиска (debug=true)
+	Debug *DebugInfo `json:"debug,omitempty"`
+	// Cached - тот же поиск недавно не удался, ответ взят из кеша неудач
+	// (WIKI_NEGATIVE_CACHE_TTL_MS), запросов к Wikipedia не было
+	Cached bool `json:"cached,omitempty" example:"false"`
+}
+
+// DebugInfo - диагностика поиска (debug=true)
+type DebugInfo struct {
+	Requests []RequestTrace `json:"requests"`
+	// Dropped - сколько запросов не попало в requests из-за WIKI_DEBUG_REQUESTS
+	Dropped int `json:"dropped" example:"0"`
+}
+
+// RequestTrace - один запрос к MediaWiki API
+type RequestTrace struct {
+	URL       string  `json:"url" example:"https://ru.wikipedia.org/w/api.php?action=query&titles=Кошка"`
+	Status    int     `json:"status" example:"200"` // 0 - ответа нет (сеть, отмена)
+	Bytes     int64   `json:"bytes" example:"48213"`
+	LatencyMs float64 `json:"latency_ms" example:"182.4"` // до конца чтения тела
+	Error     string  `json:"error,omitempty"`
+}
@@ -0,0 +1,214 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"wikiracer/verify"
+)
+
+// postSearchTimeout - бюджет на дополнительные запросы после того, как путь найден.
+// Контекст поиска к этому моменту уже отменён встречей фронтов.
+const postSearchTimeout = 2 * time.Second
+
+// postSearchContext - контекст запросов после поиска: от родителя поиска,
+// а не от его ctx, так что уход клиента и остановка сервера прерывают и их
+func (s *APISearcher) postSearchContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(s.parent, postSearchTimeout)
+}
+
+// pageProps запрашивает pageprops статей пути. Ключ результата - Key()
+// узла; статьи без pageprops или с неудачным запросом в результат не попадают.
+func (s *APISearcher) pageProps(nodes []APIWikiNode, props string) map[string]map[string]string {
+	result := make(map[string]map[string]string)
+	s.queryPages(nodes, url.Values{"prop": {"pageprops"}, "ppprop": {props}}, func(key string, page APIWikiPage) {
+		if page.PageProps != nil {
+			result[key] = page.PageProps
+		}
+	})
+	return result
+}
+
+// pageLengths запрашивает размер статей в байтах (prop=info)
+func (s *APISearcher) pageLengths(nodes []APIWikiNode) map[string]int {
+	result := make(map[string]int)
+	s.queryPages(nodes, url.Values{"prop": {"info"}}, func(key string, page APIWikiPage) {
+		result[key] = page.Length
+	})
+	return result
+}
+
+// queryPages запрашивает страницы узлов с дополнительными params, группируя
+// их по языкам и батчами по 50 названий, и вызывает fn для каждой найденной
+// страницы с Key() узла. fn вызывается под общей блокировкой.
+func (s *APISearcher) queryPages(nodes []APIWikiNode, extra url.Values, fn func(key string, page APIWikiPage)) {
+	ctx, cancel := s.postSearchContext()
+	defer cancel()
+
+	byLang := make(map[string][]string)
+	for _, n := range nodes {
+		byLang[n.Lang] = append(byLang[n.Lang], n.Title)
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for lang, titles := range byLang {
+		for i := 0; i < len(titles); i += 50 {
+			end := i + 50
+			if end > len(titles) {
+				end = len(titles)
+			}
+			wg.Add(1)
+			go func(l string, batch []string) {
+				defer wg.Done()
+				params := url.Values{
+					"action":    {"query"},
+					"format":    {"json"},
+					"titles":    {strings.Join(batch, "|")},
+					"redirects": {"1"},
+				}
+				for k, v := range extra {
+					params[k] = v
+				}
+				data, err := s.query(ctx, apiWikis[l].APIURL, params)
+				if err != nil {
+					return
+				}
+				mu.Lock()
+				defer mu.Unlock()
+				for _, title := range batch {
+					if page, ok := data.pageByTitle(title); ok {
+						fn(APIWikiNode{Title: title, Lang: l}.Key(), page)
+					}
+				}
+			}(lang, titles[i:end])
+		}
+	}
+	wg.Wait()
+}
+
+// meetTransitionIndex - индекс перехода, на котором сошлись фронты: ребро,
+// найденное при встрече. Для forward-встречи это ребро в узел встречи,
+// для backward - из него. -1, если узла встречи в пути нет (частичный путь).
+func (s *APISearcher) meetTransitionIndex(path []APIWikiNode) int {
+	s.resultMu.Lock()
+	meets := append([]APIWikiNode(nil), s.meets...)
+	s.resultMu.Unlock()
+
+	for _, m := range meets {
+		key := m.Key()
+		for i, n := range path {
+			if n.Key() != key {
+				continue
+			}
+			if m.Via == "F" {
+				return i - 1
+			}
+			if i < len(path)-1 {
+				return i
+			}
+			return -1
+		}
+	}
+	return -1
+}
+
+// apiQuerier - запросы проверок пути (verify) через query поиска: с теми
+// же лимитами, повторами и счётчиками, что у самого поиска
+type apiQuerier struct{ s *APISearcher }
+
+func (q apiQuerier) Page(ctx context.Context, lang, title string, params url.Values) (verify.Page, error) {
+	query := url.Values{
+		"action":    {"query"},
+		"format":    {"json"},
+		"titles":    {title},
+		"redirects": {"1"},
+	}
+	for k, v := range params {
+		query[k] = v
+	}
+	data, err := q.s.query(ctx, wikiAPIURL(lang), query)
+	if err != nil {
+		return verify.Page{}, err
+	}
+	page, ok := data.pageByTitle(title)
+	if !ok {
+		return verify.Page{}, fmt.Errorf("статья %s:%s не найдена", lang, title)
+	}
+	p := verify.Page{Extract: page.Extract}
+	for _, l := range page.Links {
+		p.Links = append(p.Links, l.Title)
+	}
+	for _, ll := range page.LangLinks {
+		p.LangLinks = append(p.LangLinks, verify.Node{Title: ll.Title, Lang: ll.Lang})
+	}
+	for _, c := range page.Categories {
+		p.Categories = append(p.Categories, c.Title)
+	}
+	return p, nil
+}
+
+func (q apiQuerier) Wikitext(ctx context.Context, lang, title string) (string, error) {
+	params := url.Values{
+		"action":        {"parse"},
+		"format":        {"json"},
+		"formatversion": {"2"},
+		"prop":          {"wikitext"},
+		"page":          {title},
+		"redirects":     {"1"},
+	}
+	data, err := q.s.query(ctx, wikiAPIURL(lang), params)
+	if err != nil {
+		return "", err
+	}
+	return data.Parse.Wikitext, nil
+}
+
+func verifyNode(n APIWikiNode) verify.Node {
+	return verify.Node{Title: n.Title, Lang: n.Lang, Via: n.Via}
+}
+
+// verifyPath - путь для проверок verify
+func verifyPath(path []APIWikiNode) []verify.Node {
+	nodes := make([]verify.Node, len(path))
+	for i, n := range path {
+		nodes[i] = verifyNode(n)
+	}
+	return nodes
+}
+
+// verifyEdge проверяет переход from → to запросами к API (verify.Edge);
+// interwiki через версию в неподключённом разделе - по обоим шагам
+func (s *APISearcher) verifyEdge(from, to APIWikiNode) string {
+	ctx, cancel := s.postSearchContext()
+	defer cancel()
+	var through verify.Node
+	if via := s.fallbackThrough(from, to); via != "" {
+		lang, title, _ := strings.Cut(via, ":")
+		through = verify.Node{Title: title, Lang: lang}
+	}
+	return verify.Edge(ctx, apiQuerier{s}, verifyNode(from), verifyNode(to), through)
+}
+
+// canonicalize возвращает копию пути, где названия статей заменены на
+// канонические: цели редиректов после нормализации. Один батч на язык;
+// статьи, которые не удалось проверить, остаются как есть.
+func (s *APISearcher) canonicalize(path []APIWikiNode) []APIWikiNode {
+	canonical := make(map[string]string)
+	s.queryPages(path, url.Values{}, func(key string, page APIWikiPage) {
+		canonical[key] = page.Title
+	})
+
+	out := make([]APIWikiNode, len(path))
+	for i, n := range path {
+		out[i] = n
+		if title, ok := canonical[n.Key()]; ok && title != "" {
+			out[i].Title = title
+		}
+	}
+	return out
+}
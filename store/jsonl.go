@@ -0,0 +1,56 @@
+package store
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// JSONL дописывает поиски в файл, по одному JSON-объекту Record на строку.
+// Файл открывается на добавление, так что перезапуск не теряет историю;
+// такой журнал удобно разбирать jq или грузить в аналитику.
+type JSONL struct {
+	mu      sync.Mutex
+	f       *os.File
+	enc     *json.Encoder
+	dropped atomic.Int64
+}
+
+// OpenJSONL открывает (или создаёт) файл для дописывания
+func OpenJSONL(path string) (*JSONL, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &JSONL{f: f, enc: json.NewEncoder(f)}, nil
+}
+
+// Save пишет запись одной строкой; ошибки записи учитываются в Dropped
+func (j *JSONL) Save(r Record) {
+	if r.CreatedAt.IsZero() {
+		r.CreatedAt = time.Now().UTC()
+	}
+	if r.Stats == nil {
+		r.Stats = json.RawMessage("{}")
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	// Encoder пишет объект и перевод строки одним Write
+	if err := j.enc.Encode(r); err != nil {
+		j.dropped.Add(1)
+	}
+}
+
+// Dropped - сколько записей не удалось записать
+func (j *JSONL) Dropped() int64 {
+	return j.dropped.Load()
+}
+
+// Close закрывает файл
+func (j *JSONL) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.f.Close()
+}
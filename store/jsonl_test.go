@@ -0,0 +1,70 @@
+package store
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestJSONL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "searches.jsonl")
+	j, err := OpenJSONL(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	full := testRecord()
+	j.Save(full)
+	j.Save(Record{From: "A", To: "B", Outcome: OutcomeNotFound})
+	if err := j.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Повторное открытие дописывает, а не перезаписывает
+	j, err = OpenJSONL(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	j.Save(Record{From: "C", To: "D", Outcome: OutcomeTimeout})
+	if err := j.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if j.Dropped() != 0 {
+		t.Errorf("Dropped = %d, want 0", j.Dropped())
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var records []Record
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var r Record
+		if err := json.Unmarshal(sc.Bytes(), &r); err != nil {
+			t.Fatalf("строка %q: %v", sc.Text(), err)
+		}
+		records = append(records, r)
+	}
+	if len(records) != 3 {
+		t.Fatalf("записей %d, want 3", len(records))
+	}
+	if !reflect.DeepEqual(records[0], full) {
+		t.Errorf("запись = %+v, want %+v", records[0], full)
+	}
+	// Пустые время и stats заполняются при записи
+	r := records[1]
+	if r.CreatedAt.IsZero() || time.Since(r.CreatedAt) > time.Minute {
+		t.Errorf("CreatedAt = %v, want сейчас", r.CreatedAt)
+	}
+	if string(r.Stats) != "{}" {
+		t.Errorf("Stats = %s, want {}", r.Stats)
+	}
+	if records[2].From != "C" {
+		t.Errorf("третья запись From = %q, want C", records[2].From)
+	}
+}
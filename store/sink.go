@@ -0,0 +1,47 @@
+package store
+
+// Sink принимает завершённые поиски. Save не должен задерживать
+// ответ: реализации либо пишут асинхронно, либо пишут быстро.
+// *Store и *JSONL - реализации Sink.
+type Sink interface {
+	Save(r Record)
+	Close() error
+}
+
+// Nop - Sink, который ничего не делает; используется, когда
+// ни одно хранилище не настроено
+type Nop struct{}
+
+func (Nop) Save(Record)  {}
+func (Nop) Close() error { return nil }
+
+// multi раздаёт запись нескольким Sink
+type multi []Sink
+
+func (m multi) Save(r Record) {
+	for _, s := range m {
+		s.Save(r)
+	}
+}
+
+func (m multi) Close() error {
+	var first error
+	for _, s := range m {
+		if err := s.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// Multi объединяет несколько Sink в один: без аргументов - Nop,
+// с одним - он сам
+func Multi(sinks ...Sink) Sink {
+	switch len(sinks) {
+	case 0:
+		return Nop{}
+	case 1:
+		return sinks[0]
+	}
+	return multi(sinks)
+}
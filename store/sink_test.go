@@ -0,0 +1,72 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memSink - Sink в памяти: запоминает записи и ошибку Close
+type memSink struct {
+	mu       sync.Mutex
+	records  []Record
+	closed   bool
+	closeErr error
+}
+
+func (m *memSink) Save(r Record) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.records = append(m.records, r)
+}
+
+func (m *memSink) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.closed = true
+	return m.closeErr
+}
+
+// testRecord - запись со всеми заполненными полями
+func testRecord() Record {
+	return Record{
+		From:      "Кошка",
+		To:        "Философия",
+		Lang:      "ru",
+		FromLang:  "ru",
+		ToLang:    "en",
+		Path:      []string{"ru:Кошка", "ru:Млекопитающие", "en:Philosophy"},
+		Stats:     json.RawMessage(`{"requests":12}`),
+		Outcome:   OutcomeFound,
+		CreatedAt: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestMulti(t *testing.T) {
+	if _, ok := Multi().(Nop); !ok {
+		t.Errorf("Multi() = %T, want Nop", Multi())
+	}
+	one := &memSink{}
+	if got := Multi(one); got != Sink(one) {
+		t.Errorf("Multi(s) = %v, want s", got)
+	}
+
+	first, second := &memSink{closeErr: errors.New("первая")}, &memSink{closeErr: errors.New("вторая")}
+	sink := Multi(first, second)
+	r := testRecord()
+	sink.Save(r)
+	for i, m := range []*memSink{first, second} {
+		if len(m.records) != 1 || !reflect.DeepEqual(m.records[0], r) {
+			t.Errorf("sink %d: записи %+v, want [%+v]", i, m.records, r)
+		}
+	}
+	if err := sink.Close(); err == nil || err.Error() != "первая" {
+		t.Errorf("Close = %v, want первая ошибка", err)
+	}
+	if !first.closed || !second.closed {
+		t.Error("Close закрыл не все sink")
+	}
+}
@@ -0,0 +1,229 @@
+// Package store сохраняет завершённые поиски в SQLite для офлайн-анализа:
+// что ищут и как ведёт себя движок со временем.
+//
+// Запись асинхронная: Save кладёт запись в буфер и сразу возвращается,
+// фоновая горутина пишет буфер пачками в одной транзакции.
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Исходы поиска
+const (
+	OutcomeFound     = "found"
+	OutcomePartial   = "partial" // бюджет исчерпан, сохранена цепочка-догадка
+	OutcomeNotFound  = "not_found"
+	OutcomeTimeout   = "timeout"
+	OutcomeUpstream  = "upstream_error"
+	OutcomeCancelled = "cancelled"
+)
+
+const schema = `
+CREATE TABLE IF NOT EXISTS searches (
+	id         INTEGER PRIMARY KEY AUTOINCREMENT,
+	from_title TEXT NOT NULL,
+	to_title   TEXT NOT NULL,
+	lang       TEXT NOT NULL,
+	from_lang  TEXT NOT NULL,
+	to_lang    TEXT NOT NULL,
+	path       TEXT NOT NULL,
+	stats      TEXT NOT NULL,
+	outcome    TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS searches_from_to_lang ON searches (from_title, to_title, lang);
+`
+
+// Record - один завершённый поиск
+type Record struct {
+	ID        int64           `json:"id"`
+	From      string          `json:"from"`
+	To        string          `json:"to"`
+	Lang      string          `json:"lang"` // язык запроса
+	FromLang  string          `json:"from_lang"`
+	ToLang    string          `json:"to_lang"`
+	Path      []string        `json:"path"` // "lang:title" по шагам, пусто если путь не найден
+	Stats     json.RawMessage `json:"stats"`
+	Outcome   string          `json:"outcome"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// Store пишет поиски в SQLite. Нулевой *Store ничего не делает,
+// так что вызывающему коду не нужны проверки.
+type Store struct {
+	db      *sql.DB
+	queue   chan Record
+	done    chan struct{}
+	dropped atomic.Int64
+
+	// mu защищает closed и отправку в queue: поиск, доигравший после
+	// остановки сервера, не должен писать в закрытый канал
+	mu     sync.Mutex
+	closed bool
+}
+
+// maxBatch - сколько записей из буфера пишется одной транзакцией
+const maxBatch = 100
+
+// Open открывает базу по DSN, создаёт схему и запускает фоновую запись.
+// buffer - размер очереди; при переполнении новые записи отбрасываются.
+func Open(dsn string, buffer int) (*Store, error) {
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil, err
+	}
+	// Одно соединение: SQLite всё равно сериализует запись
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("схема: %w", err)
+	}
+
+	s := &Store{
+		db:    db,
+		queue: make(chan Record, buffer),
+		done:  make(chan struct{}),
+	}
+	go s.run()
+	return s, nil
+}
+
+// Save ставит запись в очередь, не дожидаясь записи на диск. После
+// Close запись отбрасывается и учитывается в Dropped
+func (s *Store) Save(r Record) {
+	if s == nil {
+		return
+	}
+	if r.CreatedAt.IsZero() {
+		r.CreatedAt = time.Now().UTC()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		s.dropped.Add(1)
+		return
+	}
+	select {
+	case s.queue <- r:
+	default:
+		s.dropped.Add(1)
+	}
+}
+
+// Dropped - сколько записей отброшено из-за переполненной очереди или
+// после Close
+func (s *Store) Dropped() int64 {
+	if s == nil {
+		return 0
+	}
+	return s.dropped.Load()
+}
+
+func (s *Store) run() {
+	defer close(s.done)
+	for r := range s.queue {
+		batch := []Record{r}
+	fill:
+		for len(batch) < maxBatch {
+			select {
+			case r, ok := <-s.queue:
+				if !ok {
+					break fill
+				}
+				batch = append(batch, r)
+			default:
+				break fill
+			}
+		}
+		if err := s.insert(batch); err != nil {
+			s.dropped.Add(int64(len(batch)))
+		}
+	}
+}
+
+func (s *Store) insert(batch []Record) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare(`INSERT INTO searches
+		(from_title, to_title, lang, from_lang, to_lang, path, stats, outcome, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+
+	for _, r := range batch {
+		path, err := json.Marshal(r.Path)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		stats := r.Stats
+		if stats == nil {
+			stats = json.RawMessage("{}")
+		}
+		if _, err := stmt.Exec(r.From, r.To, r.Lang, r.FromLang, r.ToLang,
+			string(path), string(stats), r.Outcome, r.CreatedAt); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Recent возвращает последние limit поисков, новые первыми
+func (s *Store) Recent(limit int) ([]Record, error) {
+	if s == nil {
+		return nil, nil
+	}
+	rows, err := s.db.Query(`SELECT id, from_title, to_title, lang, from_lang, to_lang,
+		path, stats, outcome, created_at FROM searches ORDER BY id DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	records := []Record{}
+	for rows.Next() {
+		var r Record
+		var path, stats string
+		if err := rows.Scan(&r.ID, &r.From, &r.To, &r.Lang, &r.FromLang, &r.ToLang,
+			&path, &stats, &r.Outcome, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(path), &r.Path); err != nil {
+			return nil, fmt.Errorf("запись %d: %w", r.ID, err)
+		}
+		r.Stats = json.RawMessage(stats)
+		records = append(records, r)
+	}
+	return records, rows.Err()
+}
+
+// Close дописывает очередь и закрывает базу; повторный вызов ничего не делает
+func (s *Store) Close() error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.queue)
+	s.mu.Unlock()
+	<-s.done
+	return s.db.Close()
+}
@@ -0,0 +1,107 @@
+package store
+
+import (
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// openTestStore открывает базу во временном каталоге
+func openTestStore(t *testing.T, buffer int) *Store {
+	t.Helper()
+	s, err := Open(filepath.Join(t.TempDir(), "searches.db"), buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// waitRecent ждёт, пока фоновая запись доведёт базу до n записей
+func waitRecent(t *testing.T, s *Store, n int) []Record {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		records, err := s.Recent(n + 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(records) >= n || time.Now().After(deadline) {
+			return records
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestStoreRecent(t *testing.T) {
+	s := openTestStore(t, 10)
+	defer s.Close()
+
+	first := testRecord()
+	second := Record{From: "A", To: "B", Lang: "en", FromLang: "en", ToLang: "en", Outcome: OutcomeNotFound}
+	s.Save(first)
+	s.Save(second)
+
+	records := waitRecent(t, s, 2)
+	if len(records) != 2 {
+		t.Fatalf("записей %d, want 2", len(records))
+	}
+	// Новые первыми; ID проставляет база
+	got := records[1]
+	if got.ID == 0 || records[0].ID <= got.ID {
+		t.Errorf("ID = %d, %d: want возрастающие", got.ID, records[0].ID)
+	}
+	got.ID = 0
+	got.CreatedAt = got.CreatedAt.UTC()
+	if !reflect.DeepEqual(got, first) {
+		t.Errorf("запись =\n%+v\nwant\n%+v", got, first)
+	}
+	if records[0].From != "A" || records[0].Path != nil || string(records[0].Stats) != "{}" {
+		t.Errorf("вторая запись = %+v", records[0])
+	}
+
+	if limited, err := s.Recent(1); err != nil || len(limited) != 1 {
+		t.Errorf("Recent(1) = %d записей, %v", len(limited), err)
+	}
+}
+
+func TestNilStore(t *testing.T) {
+	var s *Store
+	s.Save(testRecord())
+	if records, err := s.Recent(10); records != nil || err != nil {
+		t.Errorf("Recent = %v, %v", records, err)
+	}
+	if s.Dropped() != 0 || s.Close() != nil {
+		t.Error("нулевой Store должен ничего не делать")
+	}
+}
+
+func TestStoreSaveAfterClose(t *testing.T) {
+	s := openTestStore(t, 10)
+
+	// Поиски, доигрывающие во время остановки, пишут одновременно с Close
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				s.Save(testRecord())
+			}
+		}()
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+
+	dropped := s.Dropped()
+	s.Save(testRecord())
+	if got := s.Dropped(); got != dropped+1 {
+		t.Errorf("Dropped после Save в закрытый Store = %d, want %d", got, dropped+1)
+	}
+	if err := s.Close(); err != nil {
+		t.Errorf("повторный Close: %v", err)
+	}
+}
@@ -0,0 +1,41 @@
+// Package tokenize разбивает названия статей на слова для эвристики.
+//
+// Общий для CLI (main.go) и API: слова цели и кандидатов должны
+// выделяться одинаково, иначе совпадения теряются.
+package tokenize
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MinRunes - слова короче (в символах, а не байтах) не учитываются:
+// предлоги, союзы, артикли. В байтах кириллическое "на" - уже 4.
+const MinRunes = 3
+
+// Words возвращает слова названия в нижнем регистре. Слово - буквы и
+// цифры подряд (с диакритикой), всё остальное - разделители: скобки,
+// кавычки, дефисы и апострофы, так что "(специальная)" даёт
+// "специальная", а "états-unis" - "états" и "unis".
+func Words(title string) []string {
+	fields := strings.FieldsFunc(title, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.IsMark(r)
+	})
+	words := fields[:0]
+	for _, f := range fields {
+		if utf8.RuneCountInString(f) >= MinRunes {
+			words = append(words, strings.ToLower(f))
+		}
+	}
+	return words
+}
+
+// Set - слова названия множеством
+func Set(title string) map[string]bool {
+	set := make(map[string]bool)
+	for _, word := range Words(title) {
+		set[word] = true
+	}
+	return set
+}
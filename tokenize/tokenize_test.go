@@ -0,0 +1,36 @@
+package tokenize
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWords(t *testing.T) {
+	tests := []struct {
+		title string
+		want  []string
+	}{
+		// "на" и "в" - 4 и 2 байта, но 2 и 1 символ: отсекаются оба
+		{"Война на Украине", []string{"война", "украине"}},
+		{"Ёж в тумане", []string{"тумане"}},
+		// "Рим" - 6 байт и 3 символа: остаётся
+		{"Рим", []string{"рим"}},
+		{"Битва (специальная)", []string{"битва", "специальная"}},
+		{"États-Unis", []string{"états", "unis"}},
+		{"Of the", []string{"the"}},
+		{"", []string{}},
+	}
+	for _, tt := range tests {
+		if got := Words(tt.title); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Words(%q) = %q, want %q", tt.title, got, tt.want)
+		}
+	}
+}
+
+func TestSet(t *testing.T) {
+	got := Set("Париж и Париж на Сене")
+	want := map[string]bool{"париж": true, "сене": true}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Set = %v, want %v", got, want)
+	}
+}
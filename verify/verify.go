@@ -0,0 +1,259 @@
+// Package verify проверяет уже найденный путь по живой Wikipedia: есть ли
+// ссылки и interwiki его переходов, стоят ли ссылки в тексте статей, а не
+// в навбоксах, где именно они стоят и что связывает концы пути.
+//
+// Проверки идут своими запросами поверх готового пути и на сам поиск не
+// влияют: переход, который не удалось проверить, просто остаётся без
+// отметки. Запросы делает вызывающий (Querier) - со своими лимитами,
+// повторами и счётчиками.
+package verify
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// Node - статья пути
+type Node struct {
+	Title string
+	Lang  string
+	Via   string // откуда ребро из предыдущей статьи: "F" - links, "B" - linkshere, "C" - категория
+}
+
+func (n Node) String() string { return n.Lang + ":" + n.Title }
+
+// key - статья с названием, приведённым как в MediaWiki
+func (n Node) key() string { return n.Lang + ":" + linkKey(n.Title) }
+
+// Page - то, что проверкам нужно из ответа API о статье
+type Page struct {
+	Links      []string // prop=links
+	LangLinks  []Node   // prop=langlinks
+	Extract    string   // prop=extracts
+	Categories []string // prop=categories, с префиксом пространства имён
+}
+
+// Querier - запросы к API раздела
+type Querier interface {
+	// Page - статья title раздела lang (после редиректов) с дополнительными
+	// params, например prop; ошибка - запрос не удался или статьи нет
+	Page(ctx context.Context, lang, title string, params url.Values) (Page, error)
+	// Wikitext - вики-текст статьи (action=parse)
+	Wikitext(ctx context.Context, lang, title string) (string, error)
+}
+
+// LinkContext - место ссылки в вики-тексте статьи: видимый текст ссылки,
+// предложение с ней и раздел
+type LinkContext struct {
+	Anchor   string `json:"anchor" example:"кот Шрёдингера"`
+	Sentence string `json:"sentence" example:"Мысленный эксперимент известен как кот Шрёдингера."`
+	Section  string `json:"section,omitempty" example:"В культуре"` // пусто - вводная часть
+}
+
+// Connection - короткое объяснение, что связывает две статьи
+type Connection struct {
+	Via     string `json:"via,omitempty" example:"ru:Млекопитающие"`
+	Topic   string `json:"topic,omitempty" example:"Классы позвоночных"`
+	Extract string `json:"extract,omitempty" example:"Млекопитающие — класс позвоночных животных."`
+	Summary string `json:"summary" example:"Связаны через «Млекопитающие» (Классы позвоночных)"`
+}
+
+// Edge проверяет переход from → to: есть ли ссылка в from на to, иначе -
+// в to на from; для разных языков - interwiki в любую сторону. through -
+// версия в неподключённом разделе, через которую найден interwiki-переход
+// (нулевой Node - такой нет): тогда проверяются оба шага через неё.
+// Результат - forward_link, backlink, langlink, category или unconfirmed.
+func Edge(ctx context.Context, q Querier, from, to, through Node) string {
+	if to.Via == "C" {
+		return "category"
+	}
+	if from.Lang != to.Lang {
+		if hasLangLink(ctx, q, from, to) || hasLangLink(ctx, q, to, from) {
+			return "langlink"
+		}
+		if through.Title != "" && hasLangLink(ctx, q, from, through) && hasLangLink(ctx, q, through, to) {
+			return "langlink"
+		}
+		return "unconfirmed"
+	}
+	if ok, _ := linkExists(ctx, q, from, to); ok {
+		return "forward_link"
+	}
+	if ok, _ := linkExists(ctx, q, to, from); ok {
+		return "backlink"
+	}
+	return "unconfirmed"
+}
+
+// linkExists - есть ли в статье from ссылка на to (prop=links с pltitles);
+// ошибка отличает "ссылки нет" от неудачного запроса
+func linkExists(ctx context.Context, q Querier, from, to Node) (bool, error) {
+	page, err := q.Page(ctx, from.Lang, from.Title, url.Values{"prop": {"links"}, "pltitles": {to.Title}})
+	if err != nil {
+		return false, err
+	}
+	return len(page.Links) > 0, nil
+}
+
+// hasLangLink - ведёт ли interwiki статьи from на язык to.Lang к статье to
+func hasLangLink(ctx context.Context, q Querier, from, to Node) bool {
+	page, err := q.Page(ctx, from.Lang, from.Title, url.Values{"prop": {"langlinks"}, "lllang": {to.Lang}})
+	if err != nil {
+		return false
+	}
+	for _, ll := range page.LangLinks {
+		if strings.EqualFold(ll.Title, to.Title) {
+			return true
+		}
+	}
+	return false
+}
+
+// Links проверяет переходы пути по живому графу ссылок: для каждой пары
+// статей одного языка - один запрос prop=links, есть ли в статье from
+// ссылка на to. Ключ - индекс перехода; interwiki, шаги через категорию и
+// переходы, которые не удалось проверить, в результат не попадают.
+func Links(ctx context.Context, q Querier, path []Node) map[int]bool {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	result := make(map[int]bool)
+	for i := 0; i < len(path)-1; i++ {
+		from, to := path[i], path[i+1]
+		if from.Lang != to.Lang || to.Via == "C" {
+			continue
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ok, err := linkExists(ctx, q, from, to)
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			result[i] = ok
+			mu.Unlock()
+		}(i)
+	}
+	wg.Wait()
+	return result
+}
+
+// Prose проверяет ссылки пути по вики-тексту статей: ссылка считается
+// ссылкой из текста, если [[...]] на неё стоит в самой статье вне шаблонов.
+// Ссылки, которые приходят только из навбоксов и прочих {{...}}, в
+// вики-тексте статьи не видны. Ключ результата - индекс перехода; переходы
+// interwiki, через категорию и с неудачным запросом в результат не попадают.
+func Prose(ctx context.Context, q Querier, path []Node) map[int]bool {
+	hops, texts := hopWikitexts(ctx, q, path)
+	result := make(map[int]bool)
+	for i, h := range hops {
+		if text, ok := texts[h.host.key()]; ok {
+			result[i] = proseLinks(text)[linkKey(h.target.Title)]
+		}
+	}
+	return result
+}
+
+// Contexts находит, где в статьях стоят ссылки пути. Ключ результата -
+// индекс перехода; interwiki, шаги через категорию и ссылки, которых нет
+// в вики-тексте (из шаблонов-навбоксов или через редирект), в результат
+// не попадают.
+func Contexts(ctx context.Context, q Querier, path []Node) map[int]LinkContext {
+	hops, texts := hopWikitexts(ctx, q, path)
+	result := make(map[int]LinkContext)
+	for i, h := range hops {
+		if text, ok := texts[h.host.key()]; ok {
+			if lc, ok := findLinkContext(text, h.target.Title); ok {
+				result[i] = lc
+			}
+		}
+	}
+	return result
+}
+
+// linkHop - статья, в которой должна стоять ссылка перехода, и куда она ведёт
+type linkHop struct{ host, target Node }
+
+// hopWikitexts находит для переходов-ссылок пути статью, где стоит ссылка
+// (для обратной ссылки - следующая статья), и запрашивает её вики-текст.
+// Ключ hops - индекс перехода, texts - key() статьи; статей с неудачным
+// запросом в texts нет.
+func hopWikitexts(ctx context.Context, q Querier, path []Node) (map[int]linkHop, map[string]string) {
+	hops := make(map[int]linkHop)
+	hosts := make(map[string]Node)
+	for i := 0; i < len(path)-1; i++ {
+		from, to := path[i], path[i+1]
+		if to.Via == "C" || from.Lang != to.Lang {
+			continue
+		}
+		h := linkHop{host: from, target: to}
+		if to.Via == "B" {
+			h = linkHop{host: to, target: from}
+		}
+		hops[i] = h
+		hosts[h.host.key()] = h.host
+	}
+
+	texts := make(map[string]string)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for key, host := range hosts {
+		wg.Add(1)
+		go func(key string, host Node) {
+			defer wg.Done()
+			text, err := q.Wikitext(ctx, host.Lang, host.Title)
+			if err != nil || text == "" {
+				return
+			}
+			mu.Lock()
+			texts[key] = text
+			mu.Unlock()
+		}(key, host)
+	}
+	wg.Wait()
+	return hops, texts
+}
+
+// Summarize строит короткое объяснение связи концов пути через статью via
+// (обычно узел встречи фронтов; нулевой Node - середина пути): её вводное
+// предложение и первую видимую категорию. Не удалось запросить - только
+// название статьи.
+func Summarize(ctx context.Context, q Querier, path []Node, via Node) *Connection {
+	if len(path) <= 2 {
+		return &Connection{Summary: "Статьи связаны напрямую"}
+	}
+	if via.Title == "" {
+		via = path[len(path)/2]
+	}
+	c := &Connection{
+		Via:     via.String(),
+		Summary: fmt.Sprintf("Связаны через «%s»", via.Title),
+	}
+
+	page, err := q.Page(ctx, via.Lang, via.Title, url.Values{
+		"prop":        {"extracts|categories"},
+		"exintro":     {"1"},
+		"explaintext": {"1"},
+		"exsentences": {"1"},
+		"clshow":      {"!hidden"},
+		"cllimit":     {"1"},
+	})
+	if err != nil {
+		return c
+	}
+
+	c.Extract = page.Extract
+	if len(page.Categories) > 0 {
+		// "Категория:Млекопитающие" -> "Млекопитающие"
+		topic := page.Categories[0]
+		if _, name, ok := strings.Cut(topic, ":"); ok {
+			topic = name
+		}
+		c.Topic = topic
+		c.Summary = fmt.Sprintf("Связаны через «%s» (%s)", via.Title, topic)
+	}
+	return c
+}
@@ -0,0 +1,129 @@
+package verify
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// fakeWiki - статьи по Node.String(): ссылки, interwiki и вики-текст
+type fakeWiki struct {
+	links     map[string][]string
+	langlinks map[string][]Node
+	texts     map[string]string
+	pages     map[string]Page // ответы на запросы без pltitles/lllang
+}
+
+func (w fakeWiki) Page(ctx context.Context, lang, title string, params url.Values) (Page, error) {
+	key := lang + ":" + title
+	switch {
+	case params.Get("pltitles") != "":
+		var p Page
+		for _, l := range w.links[key] {
+			if l == params.Get("pltitles") {
+				p.Links = append(p.Links, l)
+			}
+		}
+		return p, nil
+	case params.Get("lllang") != "":
+		var p Page
+		for _, ll := range w.langlinks[key] {
+			if ll.Lang == params.Get("lllang") {
+				p.LangLinks = append(p.LangLinks, ll)
+			}
+		}
+		return p, nil
+	}
+	if p, ok := w.pages[key]; ok {
+		return p, nil
+	}
+	return Page{}, errors.New("нет статьи")
+}
+
+func (w fakeWiki) Wikitext(ctx context.Context, lang, title string) (string, error) {
+	if text, ok := w.texts[lang+":"+title]; ok {
+		return text, nil
+	}
+	return "", errors.New("нет статьи")
+}
+
+func TestEdge(t *testing.T) {
+	w := fakeWiki{
+		links: map[string][]string{"en:Cat": {"Mammal"}},
+		langlinks: map[string][]Node{
+			"en:Cat":   {{Title: "Katze", Lang: "de"}},
+			"en:Dog":   {{Title: "Perro", Lang: "es"}},
+			"es:Perro": {{Title: "Hund", Lang: "de"}},
+		},
+	}
+	en := func(title string) Node { return Node{Title: title, Lang: "en"} }
+
+	tests := []struct {
+		name          string
+		from, to, via Node
+		want          string
+	}{
+		{"ссылка", en("Cat"), en("Mammal"), Node{}, "forward_link"},
+		{"обратная ссылка", en("Mammal"), en("Cat"), Node{}, "backlink"},
+		{"ссылки нет", en("Mammal"), en("Dog"), Node{}, "unconfirmed"},
+		{"interwiki", en("Cat"), Node{Title: "katze", Lang: "de"}, Node{}, "langlink"},
+		{"interwiki обратно", Node{Title: "Katze", Lang: "de"}, en("Cat"), Node{}, "langlink"},
+		{"через неподключённый раздел", en("Dog"), Node{Title: "Hund", Lang: "de"}, Node{Title: "Perro", Lang: "es"}, "langlink"},
+		{"без промежуточной версии", en("Dog"), Node{Title: "Hund", Lang: "de"}, Node{}, "unconfirmed"},
+		{"категория", en("Cat"), Node{Title: "Dog", Lang: "en", Via: "C"}, Node{}, "category"},
+	}
+	for _, tt := range tests {
+		if got := Edge(context.Background(), w, tt.from, tt.to, tt.via); got != tt.want {
+			t.Errorf("%s: %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestProseAndContexts(t *testing.T) {
+	w := fakeWiki{texts: map[string]string{
+		"en:Cat":    "{{Infobox|[[Tiger]]}}\n== Biology ==\nThe cat is a small [[mammal|furry mammal]]. It hunts [[Mouse|mice]].",
+		"en:Mammal": "Mammals include the [[cat]].",
+	}}
+	// Cat → Tiger: ссылка есть только в шаблоне, в тексте её нет
+	path := []Node{
+		{Title: "Cat", Lang: "en"},
+		{Title: "Mammal", Lang: "en", Via: "F"},
+		{Title: "Cat", Lang: "en", Via: "F"},
+		{Title: "Tiger", Lang: "en", Via: "F"},
+	}
+
+	prose := Prose(context.Background(), w, path)
+	if !prose[0] || !prose[1] {
+		t.Errorf("ссылки из текста не найдены: %v", prose)
+	}
+	if ok, checked := prose[2]; !checked || ok {
+		t.Errorf("ссылка только из шаблона: %v, %v, want false", ok, checked)
+	}
+
+	contexts := Contexts(context.Background(), w, path)
+	want := LinkContext{Anchor: "furry mammal", Sentence: "The cat is a small furry mammal.", Section: "Biology"}
+	if contexts[0] != want {
+		t.Errorf("контекст Cat → Mammal %+v, want %+v", contexts[0], want)
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	w := fakeWiki{pages: map[string]Page{
+		"en:Mammal": {Extract: "Mammals are vertebrates.", Categories: []string{"Category:Vertebrate classes"}},
+	}}
+	path := []Node{{Title: "Cat", Lang: "en"}, {Title: "Mammal", Lang: "en"}, {Title: "Dog", Lang: "en"}}
+
+	c := Summarize(context.Background(), w, path, Node{})
+	if c.Via != "en:Mammal" || c.Topic != "Vertebrate classes" || c.Extract != "Mammals are vertebrates." ||
+		!strings.Contains(c.Summary, "«Mammal» (Vertebrate classes)") {
+		t.Errorf("связь %+v", c)
+	}
+	if c := Summarize(context.Background(), w, path, Node{Title: "Dog", Lang: "en"}); c.Topic != "" || c.Via != "en:Dog" {
+		t.Errorf("без ответа API: %+v", c)
+	}
+	if c := Summarize(context.Background(), w, path[:2], Node{}); c.Summary != "Статьи связаны напрямую" {
+		t.Errorf("прямая связь: %+v", c)
+	}
+}
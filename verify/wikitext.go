@@ -0,0 +1,138 @@
+package verify
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	fullLinkRe = regexp.MustCompile(`\[\[([^\[\]|#]+)(?:#[^\[\]|]*)?(?:\|([^\[\]]*))?\]\]`)
+	headingRe  = regexp.MustCompile(`(?m)^(=+)\s*(.*?)\s*=+\s*$`)
+	refRe      = regexp.MustCompile(`(?s)<ref[^>/]*/>|<ref[^>]*>.*?</ref>|<!--.*?-->`)
+	boldRe     = regexp.MustCompile(`'{2,}`)
+)
+
+// maxContextRunes - предложение длиннее обрезается
+const maxContextRunes = 300
+
+// findLinkContext ищет первую ссылку [[target]] или [[target|текст]]
+// в вики-тексте и возвращает её текст, предложение и раздел
+func findLinkContext(wikitext, target string) (LinkContext, bool) {
+	key := linkKey(target)
+	for _, m := range fullLinkRe.FindAllStringSubmatchIndex(wikitext, -1) {
+		if linkKey(wikitext[m[2]:m[3]]) != key {
+			continue
+		}
+		lc := LinkContext{Anchor: strings.TrimSpace(wikitext[m[2]:m[3]])}
+		if m[4] >= 0 && strings.TrimSpace(wikitext[m[4]:m[5]]) != "" {
+			lc.Anchor = plainWikitext(wikitext[m[4]:m[5]])
+		}
+		for _, h := range headingRe.FindAllStringSubmatchIndex(wikitext[:m[0]], -1) {
+			lc.Section = wikitext[h[4]:h[5]]
+		}
+		lc.Sentence = plainWikitext(sentenceAround(wikitext, m[0], m[1]))
+		if r := []rune(lc.Sentence); len(r) > maxContextRunes {
+			lc.Sentence = string(r[:maxContextRunes]) + "…"
+		}
+		return lc, true
+	}
+	return LinkContext{}, false
+}
+
+// sentenceAround вырезает из строки вики-текста предложение, в котором
+// стоит фрагмент [start, end): от конца предыдущего предложения до конца
+// следующего. Точки внутри [[...]], {{...}} и сносок концом не считаются.
+func sentenceAround(wikitext string, start, end int) string {
+	lineStart := strings.LastIndex(wikitext[:start], "\n") + 1
+	lineEnd := len(wikitext)
+	if i := strings.Index(wikitext[end:], "\n"); i >= 0 {
+		lineEnd = end + i
+	}
+	line := wikitext[lineStart:lineEnd]
+	start, end = start-lineStart, end-lineStart
+
+	from, depth := 0, 0
+	for i := 0; i < len(line); i++ {
+		switch {
+		case strings.HasPrefix(line[i:], "[[") || strings.HasPrefix(line[i:], "{{"):
+			depth++
+			i++
+		case strings.HasPrefix(line[i:], "]]") || strings.HasPrefix(line[i:], "}}"):
+			if depth > 0 {
+				depth--
+			}
+			i++
+		case line[i] == '<':
+			// Сноски и комментарии пропускаются целиком: точки в них - не конец
+			if m := refRe.FindStringIndex(line[i:]); m != nil && m[0] == 0 {
+				i += m[1] - 1
+			}
+		case depth == 0 && strings.ContainsRune(".!?", rune(line[i])) &&
+			(i+1 == len(line) || line[i+1] == ' '):
+			if i < start {
+				from = i + 1
+			} else if i >= end {
+				return line[from : i+1]
+			}
+		}
+	}
+	return line[from:]
+}
+
+// plainWikitext превращает фрагмент вики-текста в простой текст:
+// без сносок, шаблонов и разметки, ссылки - их видимым текстом
+func plainWikitext(s string) string {
+	s = refRe.ReplaceAllString(s, "")
+	for {
+		stripped := templateRe.ReplaceAllString(s, "")
+		if stripped == s {
+			break
+		}
+		s = stripped
+	}
+	s = fullLinkRe.ReplaceAllStringFunc(s, func(link string) string {
+		m := fullLinkRe.FindStringSubmatch(link)
+		if m[2] != "" {
+			return m[2]
+		}
+		return m[1]
+	})
+	s = boldRe.ReplaceAllString(s, "")
+	return strings.Join(strings.Fields(s), " ")
+}
+
+var (
+	templateRe = regexp.MustCompile(`\{\{[^{}]*\}\}`)
+	wikiLinkRe = regexp.MustCompile(`\[\[([^\[\]|#]+)`)
+)
+
+// proseLinks возвращает цели [[...]] вики-текста вне шаблонов.
+// Шаблоны вырезаются изнутри наружу, пока есть что вырезать.
+func proseLinks(wikitext string) map[string]bool {
+	for {
+		stripped := templateRe.ReplaceAllString(wikitext, "")
+		if stripped == wikitext {
+			break
+		}
+		wikitext = stripped
+	}
+	set := make(map[string]bool)
+	for _, m := range wikiLinkRe.FindAllStringSubmatch(wikitext, -1) {
+		set[linkKey(m[1])] = true
+	}
+	return set
+}
+
+// linkKey приводит цель ссылки к виду названия: пробелы вместо "_",
+// первая буква заглавная, как делает MediaWiki
+func linkKey(target string) string {
+	t := strings.TrimSpace(strings.ReplaceAll(target, "_", " "))
+	t = strings.TrimPrefix(t, ":")
+	r, size := utf8.DecodeRuneInString(t)
+	if r == utf8.RuneError {
+		return t
+	}
+	return string(unicode.ToUpper(r)) + t[size:]
+}
@@ -0,0 +1,78 @@
+// Package wikis - языковые разделы Wikipedia, по которым идёт поиск.
+//
+// Набор общий для CLI (main.go) и API: по умолчанию восемь разделов,
+// WIKI_LANGS или флаг CLI -langs заменяют его, например "en,ru,ja,zh".
+// Адрес API раздела строится из кода языка.
+package wikis
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Default - разделы по умолчанию
+var Default = []string{"en", "ru", "de", "fr", "es", "it", "pt", "uk"}
+
+// codeRe - код раздела: строчные латинские буквы, части через дефис
+// (ja, zh, simple, zh-yue, be-tarask)
+var codeRe = regexp.MustCompile(`^[a-z]{2,}(-[a-z]+)*$`)
+
+// APIURL - адрес action API раздела
+func APIURL(lang string) string {
+	return "https://" + lang + ".wikipedia.org/w/api.php"
+}
+
+// Parse разбирает список через запятую; пустая строка - Default.
+// Повторы убираются, порядок сохраняется.
+func Parse(spec string) ([]string, error) {
+	if strings.TrimSpace(spec) == "" {
+		return append([]string(nil), Default...), nil
+	}
+	var langs []string
+	seen := make(map[string]bool)
+	for _, lang := range strings.Split(spec, ",") {
+		lang = strings.ToLower(strings.TrimSpace(lang))
+		if !codeRe.MatchString(lang) {
+			return nil, fmt.Errorf("неверный код языка %q", lang)
+		}
+		if !seen[lang] {
+			seen[lang] = true
+			langs = append(langs, lang)
+		}
+	}
+	return langs, nil
+}
+
+// FromEnv - разделы из WIKI_LANGS, без неё - Default
+func FromEnv() ([]string, error) {
+	langs, err := Parse(os.Getenv("WIKI_LANGS"))
+	if err != nil {
+		return nil, fmt.Errorf("WIKI_LANGS: %w", err)
+	}
+	return langs, nil
+}
+
+// Sort возвращает langs в порядке priority; языки, которых нет
+// в priority, идут следом в исходном порядке
+func Sort(langs, priority []string) []string {
+	enabled := make(map[string]bool, len(langs))
+	for _, l := range langs {
+		enabled[l] = true
+	}
+	out := make([]string, 0, len(langs))
+	ranked := make(map[string]bool, len(priority))
+	for _, l := range priority {
+		if enabled[l] && !ranked[l] {
+			ranked[l] = true
+			out = append(out, l)
+		}
+	}
+	for _, l := range langs {
+		if !ranked[l] {
+			out = append(out, l)
+		}
+	}
+	return out
+}